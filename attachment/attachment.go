@@ -0,0 +1,100 @@
+// Package attachment encrypts files that are sent out of band.
+//
+// Instead of carrying files inline in dispatch.Payload, a client encrypts
+// each file with a fresh random key, uploads the ciphertext to srvhttps and
+// sends a dispatch.Attachment with the blob id, the key and the digest of
+// the ciphertext inside the encrypted Payload. srvhttps only ever stores
+// ciphertext, and the recipient checks the digest before decrypting, so a
+// blob swapped on the server is rejected.
+//
+// Files are padded with padding.Padme before encryption, so that the blob
+// size reveals little about the file size.
+package attachment
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"errors"
+
+	"github.com/Apurer/e2eechat/dispatch"
+	"github.com/Apurer/e2eechat/padding"
+)
+
+// KeySize is the size of the key of every attachment.
+const KeySize = 32
+
+var (
+	// ErrDigest is returned when a downloaded blob is not the one the
+	// sender uploaded.
+	ErrDigest = errors.New("attachment: digest mismatch")
+
+	// ErrDecrypt is returned when a blob cannot be decrypted with the key
+	// from the attachment.
+	ErrDecrypt = errors.New("attachment: cannot decrypt")
+)
+
+// Encrypt encrypts a file and returns the ciphertext to upload and the
+// attachment to send, whose Id the caller sets once the upload is done.
+func Encrypt(file []byte, contentType, fileName string) ([]byte, *dispatch.Attachment, error) {
+	key := make([]byte, KeySize)
+	if _, err := rand.Read(key); err != nil {
+		return nil, nil, err
+	}
+	aead, err := newCipher(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, nil, err
+	}
+
+	ciphertext := aead.Seal(nonce, nonce, padding.Pad(padding.Padme, file), nil)
+	digest := sha256.Sum256(ciphertext)
+	return ciphertext, &dispatch.Attachment{
+		Key:         key,
+		Digest:      digest[:],
+		ContentType: contentType,
+		Size:        uint64(len(file)),
+		FileName:    fileName,
+	}, nil
+}
+
+// Decrypt checks a downloaded blob against the attachment and decrypts it.
+func Decrypt(a *dispatch.Attachment, ciphertext []byte) ([]byte, error) {
+	digest := sha256.Sum256(ciphertext)
+	if subtle.ConstantTimeCompare(digest[:], a.GetDigest()) != 1 {
+		return nil, ErrDigest
+	}
+	aead, err := newCipher(a.GetKey())
+	if err != nil {
+		return nil, ErrDecrypt
+	}
+	if len(ciphertext) < aead.NonceSize() {
+		return nil, ErrDecrypt
+	}
+	nonce, ciphertext := ciphertext[:aead.NonceSize()], ciphertext[aead.NonceSize():]
+	b, err := aead.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return nil, ErrDecrypt
+	}
+	file, err := padding.Unpad(b)
+	if err != nil || uint64(len(file)) != a.GetSize() {
+		return nil, ErrDecrypt
+	}
+	return file, nil
+}
+
+func newCipher(key []byte) (cipher.AEAD, error) {
+	if len(key) != KeySize {
+		return nil, ErrDecrypt
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
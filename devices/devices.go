@@ -0,0 +1,361 @@
+// Package devices keeps track of the devices registered to each user and
+// their identity keys. srvhttps registers and revokes devices, srvtls reads
+// the same file to decide which devices a message is delivered to.
+package devices
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/Apurer/e2eechat/sealed"
+)
+
+// MaxDevices bounds the number of active devices of one user, since every
+// message is encrypted once per device.
+const MaxDevices = 16
+
+var (
+	// ErrNotFound is returned for unknown or foreign device ids.
+	ErrNotFound = errors.New("devices: device not found")
+
+	// ErrRevoked is returned when changing a device that was revoked.
+	ErrRevoked = errors.New("devices: device revoked")
+
+	// ErrTooMany is returned when a user already has MaxDevices active devices.
+	ErrTooMany = errors.New("devices: too many devices")
+
+	// ErrBadKey is returned for identity keys that are not ed25519 public keys
+	// and sealing keys that are not P-256 points.
+	ErrBadKey = errors.New("devices: invalid device key")
+
+	// ErrBadAccessKey is returned for access keys of the wrong size.
+	ErrBadAccessKey = errors.New("devices: invalid access key")
+)
+
+// Device is one client installation of a user. Revoked devices are kept so
+// their ids are never reused.
+type Device struct {
+	ID          uint64            `json:"id"`
+	UserID      uint64            `json:"user_id"`
+	Name        string            `json:"name"`
+	IdentityKey ed25519.PublicKey `json:"identity_key"`
+	SealingKey  []byte            `json:"sealing_key,omitempty"`
+	Created     time.Time         `json:"created"`
+	Revoked     *time.Time        `json:"revoked,omitempty"`
+}
+
+// Active reports whether the device may still connect and receive messages.
+func (d *Device) Active() bool {
+	return d.Revoked == nil
+}
+
+// Access controls who may send sealed sender messages to a user. Senders
+// must present the access key unless the user accepts sealed messages from
+// anyone.
+type Access struct {
+	KeyHash      []byte `json:"key_hash,omitempty"` // SHA-256 of the access key
+	Unrestricted bool   `json:"unrestricted,omitempty"`
+}
+
+type storeFile struct {
+	NextID  uint64             `json:"next_id"`
+	Devices []*Device          `json:"devices"`
+	Access  map[uint64]*Access `json:"access,omitempty"`
+}
+
+// Store is a device registry persisted as a JSON file.
+type Store struct {
+	path string
+
+	mu     sync.RWMutex
+	loaded os.FileInfo // the file as it was last read or written
+	nextID uint64
+	byUser map[uint64][]*Device
+	access map[uint64]*Access
+}
+
+// Open loads the registry at path, which is created on the first change if
+// it does not exist.
+func Open(path string) (*Store, error) {
+	s := &Store{
+		path:   path,
+		nextID: 1,
+		byUser: make(map[uint64][]*Device),
+		access: make(map[uint64]*Access),
+	}
+	if _, err := s.Reload(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Reload reads the file again if it changed since it was last read, and
+// reports whether it did. It lets a process that only reads the registry
+// pick up changes made by another one.
+func (s *Store) Reload() (bool, error) {
+	fi, err := os.Stat(s.path)
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.changed(fi) {
+		return false, nil
+	}
+	b, err := ioutil.ReadFile(s.path)
+	if err != nil {
+		return false, err
+	}
+	var f storeFile
+	if err := json.Unmarshal(b, &f); err != nil {
+		return false, err
+	}
+
+	s.byUser = make(map[uint64][]*Device)
+	for _, d := range f.Devices {
+		s.byUser[d.UserID] = append(s.byUser[d.UserID], d)
+	}
+	s.access = f.Access
+	if s.access == nil {
+		s.access = make(map[uint64]*Access)
+	}
+	s.nextID = f.NextID
+	if s.nextID == 0 {
+		s.nextID = 1
+	}
+	s.loaded = fi
+	return true, nil
+}
+
+// changed reports whether fi differs from the file last read or written.
+// The modification time alone can miss a write made within its resolution,
+// so the size is compared too, and since the file is always replaced by a
+// rename, so is the file itself.
+func (s *Store) changed(fi os.FileInfo) bool {
+	if s.loaded == nil {
+		return true
+	}
+	return !os.SameFile(fi, s.loaded) || fi.Size() != s.loaded.Size() || !fi.ModTime().Equal(s.loaded.ModTime())
+}
+
+// Register adds a device with the given identity key to userID. The sealing
+// key is optional, devices without one cannot receive sealed sender messages.
+func (s *Store) Register(userID uint64, name string, identityKey, sealingKey []byte) (*Device, error) {
+	if len(identityKey) != ed25519.PublicKeySize {
+		return nil, ErrBadKey
+	}
+	if sealingKey != nil && !sealed.ValidKey(sealingKey) {
+		return nil, ErrBadKey
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.active(userID)) >= MaxDevices {
+		return nil, ErrTooMany
+	}
+	d := &Device{
+		ID:          s.nextID,
+		UserID:      userID,
+		Name:        name,
+		IdentityKey: append(ed25519.PublicKey(nil), identityKey...),
+		SealingKey:  append([]byte(nil), sealingKey...),
+		Created:     time.Now().UTC(),
+	}
+	s.nextID++
+	s.byUser[userID] = append(s.byUser[userID], d)
+	if err := s.save(); err != nil {
+		s.byUser[userID] = s.byUser[userID][:len(s.byUser[userID])-1]
+		s.nextID--
+		return nil, err
+	}
+	return copyDevice(d), nil
+}
+
+// Revoke marks a device of userID as revoked.
+func (s *Store) Revoke(userID, deviceID uint64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	d := s.find(userID, deviceID)
+	if d == nil {
+		return ErrNotFound
+	}
+	if !d.Active() {
+		return ErrRevoked
+	}
+	now := time.Now().UTC()
+	d.Revoked = &now
+	if err := s.save(); err != nil {
+		d.Revoked = nil
+		return err
+	}
+	return nil
+}
+
+// Get returns a device of userID, revoked or not.
+func (s *Store) Get(userID, deviceID uint64) (*Device, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	d := s.find(userID, deviceID)
+	if d == nil {
+		return nil, ErrNotFound
+	}
+	return copyDevice(d), nil
+}
+
+// List returns every device of userID ordered by id, including revoked ones.
+func (s *Store) List(userID uint64) []*Device {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	list := make([]*Device, 0, len(s.byUser[userID]))
+	for _, d := range s.byUser[userID] {
+		list = append(list, copyDevice(d))
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].ID < list[j].ID })
+	return list
+}
+
+// Active returns the active devices of userID ordered by id.
+func (s *Store) Active(userID uint64) []*Device {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var list []*Device
+	for _, d := range s.active(userID) {
+		list = append(list, copyDevice(d))
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].ID < list[j].ID })
+	return list
+}
+
+// IsActive reports whether deviceID is an active device of userID.
+func (s *Store) IsActive(userID, deviceID uint64) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	d := s.find(userID, deviceID)
+	return d != nil && d.Active()
+}
+
+// SetAccess replaces the access key of userID. A nil key with unrestricted
+// false turns sealed sender delivery to the user off.
+func (s *Store) SetAccess(userID uint64, accessKey []byte, unrestricted bool) error {
+	if accessKey != nil && len(accessKey) != sealed.AccessKeySize {
+		return ErrBadAccessKey
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	old, ok := s.access[userID]
+	a := &Access{Unrestricted: unrestricted}
+	if accessKey != nil {
+		sum := sha256.Sum256(accessKey)
+		a.KeyHash = sum[:]
+	}
+	s.access[userID] = a
+	if err := s.save(); err != nil {
+		if ok {
+			s.access[userID] = old
+		} else {
+			delete(s.access, userID)
+		}
+		return err
+	}
+	return nil
+}
+
+// CheckAccess reports whether accessKey allows sending sealed sender
+// messages to userID.
+func (s *Store) CheckAccess(userID uint64, accessKey []byte) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	a, ok := s.access[userID]
+	if !ok {
+		return false
+	}
+	if a.Unrestricted {
+		return true
+	}
+	if len(a.KeyHash) == 0 || len(accessKey) != sealed.AccessKeySize {
+		return false
+	}
+	sum := sha256.Sum256(accessKey)
+	return subtle.ConstantTimeCompare(sum[:], a.KeyHash) == 1
+}
+
+func (s *Store) active(userID uint64) []*Device {
+	var list []*Device
+	for _, d := range s.byUser[userID] {
+		if d.Active() {
+			list = append(list, d)
+		}
+	}
+	return list
+}
+
+func (s *Store) find(userID, deviceID uint64) *Device {
+	for _, d := range s.byUser[userID] {
+		if d.ID == deviceID {
+			return d
+		}
+	}
+	return nil
+}
+
+// save writes the registry to a temporary file and renames it over the old
+// one, so that readers never see a partial file. The caller holds s.mu.
+func (s *Store) save() error {
+	f := storeFile{NextID: s.nextID, Access: s.access}
+	for _, list := range s.byUser {
+		f.Devices = append(f.Devices, list...)
+	}
+	sort.Slice(f.Devices, func(i, j int) bool { return f.Devices[i].ID < f.Devices[j].ID })
+
+	b, err := json.MarshalIndent(&f, "", "\t")
+	if err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(s.path), filepath.Base(s.path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(b); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), s.path); err != nil {
+		return err
+	}
+	if fi, err := os.Stat(s.path); err == nil {
+		s.loaded = fi
+	}
+	return nil
+}
+
+func copyDevice(d *Device) *Device {
+	c := *d
+	return &c
+}
@@ -0,0 +1,46 @@
+package devices
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "devices.json")
+	writer, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := writer.Register(1, "phone", bytes.Repeat([]byte{1}, 32), nil); err != nil {
+		t.Fatal(err)
+	}
+	reader, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if changed, err := reader.Reload(); changed || err != nil {
+		t.Fatalf("reload of an unchanged file: %v, %v", changed, err)
+	}
+
+	// a write within the resolution of the modification time is seen
+	fi, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d, err := writer.Register(1, "laptop", bytes.Repeat([]byte{2}, 32), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(path, time.Now(), fi.ModTime()); err != nil {
+		t.Fatal(err)
+	}
+	if changed, err := reader.Reload(); !changed || err != nil {
+		t.Fatalf("reload after a write: %v, %v", changed, err)
+	}
+	if _, err := reader.Get(1, d.ID); err != nil {
+		t.Fatalf("device added by the writer: %v", err)
+	}
+}
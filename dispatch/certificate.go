@@ -0,0 +1,51 @@
+package dispatch
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/binary"
+	"errors"
+	"time"
+)
+
+const senderCertificateContext = "e2eechat sender certificate v1"
+
+var (
+	// ErrBadCertificate is returned when a SenderCertificate was not signed
+	// by the server's sender certificate key.
+	ErrBadCertificate = errors.New("dispatch: bad sender certificate signature")
+
+	// ErrExpiredCertificate is returned for sender certificates past their
+	// expiry.
+	ErrExpiredCertificate = errors.New("dispatch: expired sender certificate")
+)
+
+// SigningBytes returns the canonical encoding of every field except the
+// signature, which is what srvhttps signs.
+func (x *SenderCertificate) SigningBytes() []byte {
+	var b bytes.Buffer
+	b.WriteString(senderCertificateContext)
+	binary.Write(&b, binary.BigEndian, x.GetUserId())
+	binary.Write(&b, binary.BigEndian, x.GetDeviceId())
+	binary.Write(&b, binary.BigEndian, uint32(len(x.GetIdentityKey())))
+	b.Write(x.GetIdentityKey())
+	binary.Write(&b, binary.BigEndian, x.GetExpires())
+	return b.Bytes()
+}
+
+// Sign signs the certificate with key.
+func (x *SenderCertificate) Sign(key ed25519.PrivateKey) {
+	x.Signature = ed25519.Sign(key, x.SigningBytes())
+}
+
+// Verify checks the signature against key and that the certificate has not
+// expired.
+func (x *SenderCertificate) Verify(key ed25519.PublicKey) error {
+	if !ed25519.Verify(key, x.SigningBytes(), x.GetSignature()) {
+		return ErrBadCertificate
+	}
+	if time.Now().After(time.Unix(x.GetExpires(), 0)) {
+		return ErrExpiredCertificate
+	}
+	return nil
+}
@@ -0,0 +1,21 @@
+package dispatch
+
+import "github.com/golang/protobuf/proto"
+
+// Action wraps the GoAway in a GOAWAY action.
+func (x *GoAway) Action() (*Action, error) {
+	b, err := proto.Marshal(x)
+	if err != nil {
+		return nil, err
+	}
+	return &Action{Type: Action_GOAWAY, Payload: b}, nil
+}
+
+// Action wraps the Throttle in a THROTTLE action.
+func (x *Throttle) Action() (*Action, error) {
+	b, err := proto.Marshal(x)
+	if err != nil {
+		return nil, err
+	}
+	return &Action{Type: Action_THROTTLE, Payload: b}, nil
+}
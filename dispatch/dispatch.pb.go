@@ -25,25 +25,118 @@ const (
 // of the legacy proto package is being used.
 const _ = proto.ProtoPackageIsVersion4
 
+// Capability is an optional part of the protocol. srvtls does not deliver
+// actions that need a capability to devices which did not negotiate it.
+type Capability int32
+
+const (
+	Capability_CAPABILITY_UNSPECIFIED Capability = 0
+	Capability_CHANNELS               Capability = 1 // CHANNEL actions
+	Capability_SENDER_KEYS            Capability = 2 // SENDER_KEY actions
+	Capability_MLS_GROUPS             Capability = 3 // MLS actions
+	Capability_SEALED_SENDER          Capability = 4 // SEALED actions
+	Capability_FLOW_CONTROL           Capability = 5 // GOAWAY and THROTTLE actions
+	Capability_ERROR_REPORTS          Capability = 6 // ERROR actions
+	Capability_PRESENCE               Capability = 7 // TYPING and PRESENCE actions
+)
+
+// Enum value maps for Capability.
+var (
+	Capability_name = map[int32]string{
+		0: "CAPABILITY_UNSPECIFIED",
+		1: "CHANNELS",
+		2: "SENDER_KEYS",
+		3: "MLS_GROUPS",
+		4: "SEALED_SENDER",
+		5: "FLOW_CONTROL",
+		6: "ERROR_REPORTS",
+		7: "PRESENCE",
+	}
+	Capability_value = map[string]int32{
+		"CAPABILITY_UNSPECIFIED": 0,
+		"CHANNELS":               1,
+		"SENDER_KEYS":            2,
+		"MLS_GROUPS":             3,
+		"SEALED_SENDER":          4,
+		"FLOW_CONTROL":           5,
+		"ERROR_REPORTS":          6,
+		"PRESENCE":               7,
+	}
+)
+
+func (x Capability) Enum() *Capability {
+	p := new(Capability)
+	*p = x
+	return p
+}
+
+func (x Capability) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (Capability) Descriptor() protoreflect.EnumDescriptor {
+	return file_dispatch_proto_enumTypes[0].Descriptor()
+}
+
+func (Capability) Type() protoreflect.EnumType {
+	return &file_dispatch_proto_enumTypes[0]
+}
+
+func (x Capability) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use Capability.Descriptor instead.
+func (Capability) EnumDescriptor() ([]byte, []int) {
+	return file_dispatch_proto_rawDescGZIP(), []int{0}
+}
+
 type Action_ActionType int32
 
 const (
 	Action_HANDSHAKE    Action_ActionType = 0
 	Action_TRANSMISSION Action_ActionType = 1
 	Action_CONFIRMATION Action_ActionType = 2
+	Action_GOAWAY       Action_ActionType = 3  // payload is a GoAway, sent by tls2tlsproxy
+	Action_THROTTLE     Action_ActionType = 4  // payload is a Throttle, sent by tls2tlsproxy
+	Action_CHANNEL      Action_ActionType = 5  // payload is a ChannelOp from clients, a ChannelEvent from srvtls
+	Action_SENDER_KEY   Action_ActionType = 6  // each copy is a SenderKeyDistribution encrypted for its device
+	Action_MLS          Action_ActionType = 7  // payload is an MlsMessage
+	Action_SEALED       Action_ActionType = 8  // each copy is a SealedEnvelope for its device, the sender stays hidden
+	Action_ERROR        Action_ActionType = 9  // payload is an Error, sent by srvtls and tls2tlsproxy
+	Action_TYPING       Action_ActionType = 10 // payload is a Typing, relayed only to connected devices
+	Action_PRESENCE     Action_ActionType = 11 // payload is a Presence, never relayed to other users as is
 )
 
 // Enum value maps for Action_ActionType.
 var (
 	Action_ActionType_name = map[int32]string{
-		0: "HANDSHAKE",
-		1: "TRANSMISSION",
-		2: "CONFIRMATION",
+		0:  "HANDSHAKE",
+		1:  "TRANSMISSION",
+		2:  "CONFIRMATION",
+		3:  "GOAWAY",
+		4:  "THROTTLE",
+		5:  "CHANNEL",
+		6:  "SENDER_KEY",
+		7:  "MLS",
+		8:  "SEALED",
+		9:  "ERROR",
+		10: "TYPING",
+		11: "PRESENCE",
 	}
 	Action_ActionType_value = map[string]int32{
 		"HANDSHAKE":    0,
 		"TRANSMISSION": 1,
 		"CONFIRMATION": 2,
+		"GOAWAY":       3,
+		"THROTTLE":     4,
+		"CHANNEL":      5,
+		"SENDER_KEY":   6,
+		"MLS":          7,
+		"SEALED":       8,
+		"ERROR":        9,
+		"TYPING":       10,
+		"PRESENCE":     11,
 	}
 )
 
@@ -58,11 +151,11 @@ func (x Action_ActionType) String() string {
 }
 
 func (Action_ActionType) Descriptor() protoreflect.EnumDescriptor {
-	return file_dispatch_proto_enumTypes[0].Descriptor()
+	return file_dispatch_proto_enumTypes[1].Descriptor()
 }
 
 func (Action_ActionType) Type() protoreflect.EnumType {
-	return &file_dispatch_proto_enumTypes[0]
+	return &file_dispatch_proto_enumTypes[1]
 }
 
 func (x Action_ActionType) Number() protoreflect.EnumNumber {
@@ -71,265 +164,639 @@ func (x Action_ActionType) Number() protoreflect.EnumNumber {
 
 // Deprecated: Use Action_ActionType.Descriptor instead.
 func (Action_ActionType) EnumDescriptor() ([]byte, []int) {
-	return file_dispatch_proto_rawDescGZIP(), []int{1, 0}
+	return file_dispatch_proto_rawDescGZIP(), []int{6, 0}
 }
 
-type Payload struct {
-	state         protoimpl.MessageState
-	sizeCache     protoimpl.SizeCache
-	unknownFields protoimpl.UnknownFields
+type ChannelOp_Op int32
 
-	SenderId  uint64 `protobuf:"varint,1,opt,name=sender_id,json=senderId,proto3" json:"sender_id,omitempty"`
-	Text      []byte `protobuf:"bytes,2,opt,name=text,proto3" json:"text,omitempty"`
-	Image     []byte `protobuf:"bytes,3,opt,name=image,proto3" json:"image,omitempty"`
-	Timestamp int64  `protobuf:"varint,4,opt,name=timestamp,proto3" json:"timestamp,omitempty"`
-}
+const (
+	ChannelOp_CREATE   ChannelOp_Op = 0
+	ChannelOp_INVITE   ChannelOp_Op = 1
+	ChannelOp_LEAVE    ChannelOp_Op = 2
+	ChannelOp_KICK     ChannelOp_Op = 3
+	ChannelOp_LIST     ChannelOp_Op = 4
+	ChannelOp_SET_ROLE ChannelOp_Op = 5
+)
 
-func (x *Payload) Reset() {
-	*x = Payload{}
-	if protoimpl.UnsafeEnabled {
-		mi := &file_dispatch_proto_msgTypes[0]
-		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
-		ms.StoreMessageInfo(mi)
+// Enum value maps for ChannelOp_Op.
+var (
+	ChannelOp_Op_name = map[int32]string{
+		0: "CREATE",
+		1: "INVITE",
+		2: "LEAVE",
+		3: "KICK",
+		4: "LIST",
+		5: "SET_ROLE",
 	}
-}
+	ChannelOp_Op_value = map[string]int32{
+		"CREATE":   0,
+		"INVITE":   1,
+		"LEAVE":    2,
+		"KICK":     3,
+		"LIST":     4,
+		"SET_ROLE": 5,
+	}
+)
 
-func (x *Payload) String() string {
-	return protoimpl.X.MessageStringOf(x)
+func (x ChannelOp_Op) Enum() *ChannelOp_Op {
+	p := new(ChannelOp_Op)
+	*p = x
+	return p
 }
 
-func (*Payload) ProtoMessage() {}
+func (x ChannelOp_Op) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
 
-func (x *Payload) ProtoReflect() protoreflect.Message {
-	mi := &file_dispatch_proto_msgTypes[0]
-	if protoimpl.UnsafeEnabled && x != nil {
-		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
-		if ms.LoadMessageInfo() == nil {
-			ms.StoreMessageInfo(mi)
-		}
-		return ms
-	}
-	return mi.MessageOf(x)
+func (ChannelOp_Op) Descriptor() protoreflect.EnumDescriptor {
+	return file_dispatch_proto_enumTypes[2].Descriptor()
 }
 
-// Deprecated: Use Payload.ProtoReflect.Descriptor instead.
-func (*Payload) Descriptor() ([]byte, []int) {
-	return file_dispatch_proto_rawDescGZIP(), []int{0}
+func (ChannelOp_Op) Type() protoreflect.EnumType {
+	return &file_dispatch_proto_enumTypes[2]
 }
 
-func (x *Payload) GetSenderId() uint64 {
-	if x != nil {
-		return x.SenderId
-	}
-	return 0
+func (x ChannelOp_Op) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
 }
 
-func (x *Payload) GetText() []byte {
-	if x != nil {
-		return x.Text
-	}
-	return nil
+// Deprecated: Use ChannelOp_Op.Descriptor instead.
+func (ChannelOp_Op) EnumDescriptor() ([]byte, []int) {
+	return file_dispatch_proto_rawDescGZIP(), []int{8, 0}
 }
 
-func (x *Payload) GetImage() []byte {
-	if x != nil {
-		return x.Image
+type ChannelMember_Role int32
+
+const (
+	ChannelMember_MEMBER ChannelMember_Role = 0
+	ChannelMember_ADMIN  ChannelMember_Role = 1 // may invite and kick members
+	ChannelMember_OWNER  ChannelMember_Role = 2 // may also kick admins and change roles
+)
+
+// Enum value maps for ChannelMember_Role.
+var (
+	ChannelMember_Role_name = map[int32]string{
+		0: "MEMBER",
+		1: "ADMIN",
+		2: "OWNER",
 	}
-	return nil
+	ChannelMember_Role_value = map[string]int32{
+		"MEMBER": 0,
+		"ADMIN":  1,
+		"OWNER":  2,
+	}
+)
+
+func (x ChannelMember_Role) Enum() *ChannelMember_Role {
+	p := new(ChannelMember_Role)
+	*p = x
+	return p
 }
 
-func (x *Payload) GetTimestamp() int64 {
-	if x != nil {
-		return x.Timestamp
-	}
-	return 0
+func (x ChannelMember_Role) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
 }
 
-type Action struct {
-	state         protoimpl.MessageState
-	sizeCache     protoimpl.SizeCache
-	unknownFields protoimpl.UnknownFields
+func (ChannelMember_Role) Descriptor() protoreflect.EnumDescriptor {
+	return file_dispatch_proto_enumTypes[3].Descriptor()
+}
 
-	Payload     []byte            `protobuf:"bytes,1,opt,name=payload,proto3" json:"payload,omitempty"`
-	RecipientId uint64            `protobuf:"varint,2,opt,name=recipient_id,json=recipientId,proto3" json:"recipient_id,omitempty"`
-	ChannelId   uint64            `protobuf:"varint,3,opt,name=channel_id,json=channelId,proto3" json:"channel_id,omitempty"`
-	Publickey   []byte            `protobuf:"bytes,4,opt,name=publickey,proto3" json:"publickey,omitempty"`
-	Hash        []byte            `protobuf:"bytes,5,opt,name=hash,proto3" json:"hash,omitempty"`
-	Type        Action_ActionType `protobuf:"varint,6,opt,name=type,proto3,enum=dispatch.Action_ActionType" json:"type,omitempty"`
+func (ChannelMember_Role) Type() protoreflect.EnumType {
+	return &file_dispatch_proto_enumTypes[3]
 }
 
-func (x *Action) Reset() {
-	*x = Action{}
-	if protoimpl.UnsafeEnabled {
-		mi := &file_dispatch_proto_msgTypes[1]
-		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
-		ms.StoreMessageInfo(mi)
-	}
+func (x ChannelMember_Role) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
 }
 
-func (x *Action) String() string {
-	return protoimpl.X.MessageStringOf(x)
+// Deprecated: Use ChannelMember_Role.Descriptor instead.
+func (ChannelMember_Role) EnumDescriptor() ([]byte, []int) {
+	return file_dispatch_proto_rawDescGZIP(), []int{9, 0}
 }
 
-func (*Action) ProtoMessage() {}
+type Typing_State int32
 
-func (x *Action) ProtoReflect() protoreflect.Message {
-	mi := &file_dispatch_proto_msgTypes[1]
-	if protoimpl.UnsafeEnabled && x != nil {
-		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
-		if ms.LoadMessageInfo() == nil {
-			ms.StoreMessageInfo(mi)
-		}
-		return ms
+const (
+	Typing_STOPPED Typing_State = 0
+	Typing_STARTED Typing_State = 1
+)
+
+// Enum value maps for Typing_State.
+var (
+	Typing_State_name = map[int32]string{
+		0: "STOPPED",
+		1: "STARTED",
 	}
-	return mi.MessageOf(x)
+	Typing_State_value = map[string]int32{
+		"STOPPED": 0,
+		"STARTED": 1,
+	}
+)
+
+func (x Typing_State) Enum() *Typing_State {
+	p := new(Typing_State)
+	*p = x
+	return p
 }
 
-// Deprecated: Use Action.ProtoReflect.Descriptor instead.
-func (*Action) Descriptor() ([]byte, []int) {
-	return file_dispatch_proto_rawDescGZIP(), []int{1}
+func (x Typing_State) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
 }
 
-func (x *Action) GetPayload() []byte {
-	if x != nil {
-		return x.Payload
-	}
-	return nil
+func (Typing_State) Descriptor() protoreflect.EnumDescriptor {
+	return file_dispatch_proto_enumTypes[4].Descriptor()
 }
 
-func (x *Action) GetRecipientId() uint64 {
-	if x != nil {
-		return x.RecipientId
-	}
-	return 0
+func (Typing_State) Type() protoreflect.EnumType {
+	return &file_dispatch_proto_enumTypes[4]
 }
 
-func (x *Action) GetChannelId() uint64 {
-	if x != nil {
-		return x.ChannelId
-	}
-	return 0
+func (x Typing_State) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
 }
 
-func (x *Action) GetPublickey() []byte {
-	if x != nil {
-		return x.Publickey
-	}
-	return nil
+// Deprecated: Use Typing_State.Descriptor instead.
+func (Typing_State) EnumDescriptor() ([]byte, []int) {
+	return file_dispatch_proto_rawDescGZIP(), []int{11, 0}
 }
 
-func (x *Action) GetHash() []byte {
-	if x != nil {
-		return x.Hash
+type Presence_Op int32
+
+const (
+	Presence_STATE       Presence_Op = 0
+	Presence_SUBSCRIBE   Presence_Op = 1
+	Presence_UNSUBSCRIBE Presence_Op = 2
+	Presence_SETTINGS    Presence_Op = 3
+)
+
+// Enum value maps for Presence_Op.
+var (
+	Presence_Op_name = map[int32]string{
+		0: "STATE",
+		1: "SUBSCRIBE",
+		2: "UNSUBSCRIBE",
+		3: "SETTINGS",
 	}
-	return nil
+	Presence_Op_value = map[string]int32{
+		"STATE":       0,
+		"SUBSCRIBE":   1,
+		"UNSUBSCRIBE": 2,
+		"SETTINGS":    3,
+	}
+)
+
+func (x Presence_Op) Enum() *Presence_Op {
+	p := new(Presence_Op)
+	*p = x
+	return p
 }
 
-func (x *Action) GetType() Action_ActionType {
-	if x != nil {
-		return x.Type
-	}
-	return Action_HANDSHAKE
+func (x Presence_Op) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
 }
 
-type Authentication struct {
-	state         protoimpl.MessageState
-	sizeCache     protoimpl.SizeCache
-	unknownFields protoimpl.UnknownFields
+func (Presence_Op) Descriptor() protoreflect.EnumDescriptor {
+	return file_dispatch_proto_enumTypes[5].Descriptor()
+}
 
-	UserId    uint64 `protobuf:"varint,1,opt,name=user_id,json=userId,proto3" json:"user_id,omitempty"`
-	Code      []byte `protobuf:"bytes,2,opt,name=code,proto3" json:"code,omitempty"`
-	Publickey []byte `protobuf:"bytes,3,opt,name=publickey,proto3" json:"publickey,omitempty"`
-	Hash      []byte `protobuf:"bytes,4,opt,name=hash,proto3" json:"hash,omitempty"`
+func (Presence_Op) Type() protoreflect.EnumType {
+	return &file_dispatch_proto_enumTypes[5]
 }
 
-func (x *Authentication) Reset() {
-	*x = Authentication{}
-	if protoimpl.UnsafeEnabled {
-		mi := &file_dispatch_proto_msgTypes[2]
-		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
-		ms.StoreMessageInfo(mi)
-	}
+func (x Presence_Op) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
 }
 
-func (x *Authentication) String() string {
-	return protoimpl.X.MessageStringOf(x)
+// Deprecated: Use Presence_Op.Descriptor instead.
+func (Presence_Op) EnumDescriptor() ([]byte, []int) {
+	return file_dispatch_proto_rawDescGZIP(), []int{12, 0}
 }
 
-func (*Authentication) ProtoMessage() {}
+type Presence_State int32
 
-func (x *Authentication) ProtoReflect() protoreflect.Message {
-	mi := &file_dispatch_proto_msgTypes[2]
-	if protoimpl.UnsafeEnabled && x != nil {
-		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
-		if ms.LoadMessageInfo() == nil {
-			ms.StoreMessageInfo(mi)
-		}
-		return ms
+const (
+	Presence_OFFLINE Presence_State = 0
+	Presence_ONLINE  Presence_State = 1
+	Presence_AWAY    Presence_State = 2 // connected but idle
+)
+
+// Enum value maps for Presence_State.
+var (
+	Presence_State_name = map[int32]string{
+		0: "OFFLINE",
+		1: "ONLINE",
+		2: "AWAY",
 	}
-	return mi.MessageOf(x)
+	Presence_State_value = map[string]int32{
+		"OFFLINE": 0,
+		"ONLINE":  1,
+		"AWAY":    2,
+	}
+)
+
+func (x Presence_State) Enum() *Presence_State {
+	p := new(Presence_State)
+	*p = x
+	return p
 }
 
-// Deprecated: Use Authentication.ProtoReflect.Descriptor instead.
-func (*Authentication) Descriptor() ([]byte, []int) {
-	return file_dispatch_proto_rawDescGZIP(), []int{2}
+func (x Presence_State) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
 }
 
-func (x *Authentication) GetUserId() uint64 {
-	if x != nil {
-		return x.UserId
-	}
-	return 0
+func (Presence_State) Descriptor() protoreflect.EnumDescriptor {
+	return file_dispatch_proto_enumTypes[6].Descriptor()
 }
 
-func (x *Authentication) GetCode() []byte {
-	if x != nil {
-		return x.Code
-	}
-	return nil
+func (Presence_State) Type() protoreflect.EnumType {
+	return &file_dispatch_proto_enumTypes[6]
 }
 
-func (x *Authentication) GetPublickey() []byte {
-	if x != nil {
-		return x.Publickey
-	}
-	return nil
+func (x Presence_State) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
 }
 
-func (x *Authentication) GetHash() []byte {
-	if x != nil {
-		return x.Hash
-	}
-	return nil
+// Deprecated: Use Presence_State.Descriptor instead.
+func (Presence_State) EnumDescriptor() ([]byte, []int) {
+	return file_dispatch_proto_rawDescGZIP(), []int{12, 1}
 }
 
-type Rule struct {
-	state         protoimpl.MessageState
-	sizeCache     protoimpl.SizeCache
-	unknownFields protoimpl.UnknownFields
+type PresenceSettings_Visibility int32
 
-	Ip     string `protobuf:"bytes,1,opt,name=ip,proto3" json:"ip,omitempty"`
-	Port   string `protobuf:"bytes,2,opt,name=port,proto3" json:"port,omitempty"`
-	Insert bool   `protobuf:"varint,3,opt,name=insert,proto3" json:"insert,omitempty"`
-}
+const (
+	PresenceSettings_CHANNEL_MEMBERS PresenceSettings_Visibility = 0 // users sharing a channel, and those in allowed
+	PresenceSettings_EVERYONE        PresenceSettings_Visibility = 1
+	PresenceSettings_ALLOWED         PresenceSettings_Visibility = 2 // only those in allowed
+	PresenceSettings_NOBODY          PresenceSettings_Visibility = 3
+)
 
-func (x *Rule) Reset() {
-	*x = Rule{}
+// Enum value maps for PresenceSettings_Visibility.
+var (
+	PresenceSettings_Visibility_name = map[int32]string{
+		0: "CHANNEL_MEMBERS",
+		1: "EVERYONE",
+		2: "ALLOWED",
+		3: "NOBODY",
+	}
+	PresenceSettings_Visibility_value = map[string]int32{
+		"CHANNEL_MEMBERS": 0,
+		"EVERYONE":        1,
+		"ALLOWED":         2,
+		"NOBODY":          3,
+	}
+)
+
+func (x PresenceSettings_Visibility) Enum() *PresenceSettings_Visibility {
+	p := new(PresenceSettings_Visibility)
+	*p = x
+	return p
+}
+
+func (x PresenceSettings_Visibility) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (PresenceSettings_Visibility) Descriptor() protoreflect.EnumDescriptor {
+	return file_dispatch_proto_enumTypes[7].Descriptor()
+}
+
+func (PresenceSettings_Visibility) Type() protoreflect.EnumType {
+	return &file_dispatch_proto_enumTypes[7]
+}
+
+func (x PresenceSettings_Visibility) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use PresenceSettings_Visibility.Descriptor instead.
+func (PresenceSettings_Visibility) EnumDescriptor() ([]byte, []int) {
+	return file_dispatch_proto_rawDescGZIP(), []int{13, 0}
+}
+
+type Error_Code int32
+
+const (
+	Error_UNKNOWN           Error_Code = 0
+	Error_INVALID_MESSAGE   Error_Code = 1 // the frame could not be decoded
+	Error_UNAUTHENTICATED   Error_Code = 2 // the device is unknown or revoked
+	Error_UNSUPPORTED       Error_Code = 3 // version, capability or action type not supported
+	Error_UNKNOWN_RECIPIENT Error_Code = 4 // the recipient has no active devices
+	Error_MISSING_COPIES    Error_Code = 5 // copies are missing for some devices, refresh the device list
+	Error_FORBIDDEN         Error_Code = 6 // not a member of the channel, wrong access key
+	Error_NOT_FOUND         Error_Code = 7 // the channel does not exist
+	Error_TOO_LARGE         Error_Code = 8 // the frame exceeds the size limit, or a limit on subscriptions
+	Error_UNAVAILABLE       Error_Code = 9 // a server is unreachable or shutting down
+	Error_INTERNAL          Error_Code = 10
+)
+
+// Enum value maps for Error_Code.
+var (
+	Error_Code_name = map[int32]string{
+		0:  "UNKNOWN",
+		1:  "INVALID_MESSAGE",
+		2:  "UNAUTHENTICATED",
+		3:  "UNSUPPORTED",
+		4:  "UNKNOWN_RECIPIENT",
+		5:  "MISSING_COPIES",
+		6:  "FORBIDDEN",
+		7:  "NOT_FOUND",
+		8:  "TOO_LARGE",
+		9:  "UNAVAILABLE",
+		10: "INTERNAL",
+	}
+	Error_Code_value = map[string]int32{
+		"UNKNOWN":           0,
+		"INVALID_MESSAGE":   1,
+		"UNAUTHENTICATED":   2,
+		"UNSUPPORTED":       3,
+		"UNKNOWN_RECIPIENT": 4,
+		"MISSING_COPIES":    5,
+		"FORBIDDEN":         6,
+		"NOT_FOUND":         7,
+		"TOO_LARGE":         8,
+		"UNAVAILABLE":       9,
+		"INTERNAL":          10,
+	}
+)
+
+func (x Error_Code) Enum() *Error_Code {
+	p := new(Error_Code)
+	*p = x
+	return p
+}
+
+func (x Error_Code) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (Error_Code) Descriptor() protoreflect.EnumDescriptor {
+	return file_dispatch_proto_enumTypes[8].Descriptor()
+}
+
+func (Error_Code) Type() protoreflect.EnumType {
+	return &file_dispatch_proto_enumTypes[8]
+}
+
+func (x Error_Code) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use Error_Code.Descriptor instead.
+func (Error_Code) EnumDescriptor() ([]byte, []int) {
+	return file_dispatch_proto_rawDescGZIP(), []int{15, 0}
+}
+
+type AuthResult_Status int32
+
+const (
+	AuthResult_OK                  AuthResult_Status = 0
+	AuthResult_INVALID             AuthResult_Status = 1 // the Authentication could not be decoded or is incomplete
+	AuthResult_REJECTED            AuthResult_Status = 2 // the credentials were not accepted
+	AuthResult_TIMEOUT             AuthResult_Status = 3
+	AuthResult_UNAVAILABLE         AuthResult_Status = 4 // the relay could not be reached
+	AuthResult_UNSUPPORTED_VERSION AuthResult_Status = 5 // the client's protocol version is too old
+)
+
+// Enum value maps for AuthResult_Status.
+var (
+	AuthResult_Status_name = map[int32]string{
+		0: "OK",
+		1: "INVALID",
+		2: "REJECTED",
+		3: "TIMEOUT",
+		4: "UNAVAILABLE",
+		5: "UNSUPPORTED_VERSION",
+	}
+	AuthResult_Status_value = map[string]int32{
+		"OK":                  0,
+		"INVALID":             1,
+		"REJECTED":            2,
+		"TIMEOUT":             3,
+		"UNAVAILABLE":         4,
+		"UNSUPPORTED_VERSION": 5,
+	}
+)
+
+func (x AuthResult_Status) Enum() *AuthResult_Status {
+	p := new(AuthResult_Status)
+	*p = x
+	return p
+}
+
+func (x AuthResult_Status) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (AuthResult_Status) Descriptor() protoreflect.EnumDescriptor {
+	return file_dispatch_proto_enumTypes[9].Descriptor()
+}
+
+func (AuthResult_Status) Type() protoreflect.EnumType {
+	return &file_dispatch_proto_enumTypes[9]
+}
+
+func (x AuthResult_Status) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use AuthResult_Status.Descriptor instead.
+func (AuthResult_Status) EnumDescriptor() ([]byte, []int) {
+	return file_dispatch_proto_rawDescGZIP(), []int{20, 0}
+}
+
+type Throttle_Scope int32
+
+const (
+	Throttle_USER Throttle_Scope = 0
+	Throttle_IP   Throttle_Scope = 1
+)
+
+// Enum value maps for Throttle_Scope.
+var (
+	Throttle_Scope_name = map[int32]string{
+		0: "USER",
+		1: "IP",
+	}
+	Throttle_Scope_value = map[string]int32{
+		"USER": 0,
+		"IP":   1,
+	}
+)
+
+func (x Throttle_Scope) Enum() *Throttle_Scope {
+	p := new(Throttle_Scope)
+	*p = x
+	return p
+}
+
+func (x Throttle_Scope) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (Throttle_Scope) Descriptor() protoreflect.EnumDescriptor {
+	return file_dispatch_proto_enumTypes[10].Descriptor()
+}
+
+func (Throttle_Scope) Type() protoreflect.EnumType {
+	return &file_dispatch_proto_enumTypes[10]
+}
+
+func (x Throttle_Scope) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use Throttle_Scope.Descriptor instead.
+func (Throttle_Scope) EnumDescriptor() ([]byte, []int) {
+	return file_dispatch_proto_rawDescGZIP(), []int{22, 0}
+}
+
+type MlsMessage_Kind int32
+
+const (
+	MlsMessage_APPLICATION MlsMessage_Kind = 0
+	MlsMessage_PROPOSAL    MlsMessage_Kind = 1
+	MlsMessage_COMMIT      MlsMessage_Kind = 2
+	MlsMessage_WELCOME     MlsMessage_Kind = 3
+)
+
+// Enum value maps for MlsMessage_Kind.
+var (
+	MlsMessage_Kind_name = map[int32]string{
+		0: "APPLICATION",
+		1: "PROPOSAL",
+		2: "COMMIT",
+		3: "WELCOME",
+	}
+	MlsMessage_Kind_value = map[string]int32{
+		"APPLICATION": 0,
+		"PROPOSAL":    1,
+		"COMMIT":      2,
+		"WELCOME":     3,
+	}
+)
+
+func (x MlsMessage_Kind) Enum() *MlsMessage_Kind {
+	p := new(MlsMessage_Kind)
+	*p = x
+	return p
+}
+
+func (x MlsMessage_Kind) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (MlsMessage_Kind) Descriptor() protoreflect.EnumDescriptor {
+	return file_dispatch_proto_enumTypes[11].Descriptor()
+}
+
+func (MlsMessage_Kind) Type() protoreflect.EnumType {
+	return &file_dispatch_proto_enumTypes[11]
+}
+
+func (x MlsMessage_Kind) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use MlsMessage_Kind.Descriptor instead.
+func (MlsMessage_Kind) EnumDescriptor() ([]byte, []int) {
+	return file_dispatch_proto_rawDescGZIP(), []int{26, 0}
+}
+
+type MlsMessage_Result int32
+
+const (
+	MlsMessage_NONE     MlsMessage_Result = 0
+	MlsMessage_ACCEPTED MlsMessage_Result = 1 // the commit was sequenced, current_epoch is the new epoch
+	MlsMessage_REJECTED MlsMessage_Result = 2 // the message was for another epoch than current_epoch
+)
+
+// Enum value maps for MlsMessage_Result.
+var (
+	MlsMessage_Result_name = map[int32]string{
+		0: "NONE",
+		1: "ACCEPTED",
+		2: "REJECTED",
+	}
+	MlsMessage_Result_value = map[string]int32{
+		"NONE":     0,
+		"ACCEPTED": 1,
+		"REJECTED": 2,
+	}
+)
+
+func (x MlsMessage_Result) Enum() *MlsMessage_Result {
+	p := new(MlsMessage_Result)
+	*p = x
+	return p
+}
+
+func (x MlsMessage_Result) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (MlsMessage_Result) Descriptor() protoreflect.EnumDescriptor {
+	return file_dispatch_proto_enumTypes[12].Descriptor()
+}
+
+func (MlsMessage_Result) Type() protoreflect.EnumType {
+	return &file_dispatch_proto_enumTypes[12]
+}
+
+func (x MlsMessage_Result) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use MlsMessage_Result.Descriptor instead.
+func (MlsMessage_Result) EnumDescriptor() ([]byte, []int) {
+	return file_dispatch_proto_rawDescGZIP(), []int{26, 1}
+}
+
+// Payload is what clients encrypt into Action.payload or its copies. The
+// encoded Payload is padded with package padding before encryption, so that
+// its length does not give away the length of the text or an attachment.
+// A Payload with an edit, delete or reaction changes an earlier message
+// instead of being one.
+type Payload struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	SenderId    uint64        `protobuf:"varint,1,opt,name=sender_id,json=senderId,proto3" json:"sender_id,omitempty"`
+	Text        []byte        `protobuf:"bytes,2,opt,name=text,proto3" json:"text,omitempty"`
+	Image       []byte        `protobuf:"bytes,3,opt,name=image,proto3" json:"image,omitempty"` // deprecated, images are sent as attachments
+	Timestamp   int64         `protobuf:"varint,4,opt,name=timestamp,proto3" json:"timestamp,omitempty"`
+	Attachments []*Attachment `protobuf:"bytes,5,rep,name=attachments,proto3" json:"attachments,omitempty"`
+	MessageId   uint64        `protobuf:"varint,6,opt,name=message_id,json=messageId,proto3" json:"message_id,omitempty"` // chosen by the sender, unique among its messages
+	// expire_timer is the disappearing messages timer of the conversation in
+	// seconds: clients delete the message that long after it was read, or
+	// keep it for 0. Every message carries the timer. A message whose timer
+	// differs from the conversation's, even one without text, changes it for
+	// the conversation. The Action carries the same expire_timer.
+	ExpireTimer uint32 `protobuf:"varint,10,opt,name=expire_timer,json=expireTimer,proto3" json:"expire_timer,omitempty"`
+	// Types that are assignable to Change:
+	//	*Payload_Edit
+	//	*Payload_Delete
+	//	*Payload_Reaction
+	Change isPayload_Change `protobuf_oneof:"change"`
+}
+
+func (x *Payload) Reset() {
+	*x = Payload{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_dispatch_proto_msgTypes[3]
+		mi := &file_dispatch_proto_msgTypes[0]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
 }
 
-func (x *Rule) String() string {
+func (x *Payload) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*Rule) ProtoMessage() {}
+func (*Payload) ProtoMessage() {}
 
-func (x *Rule) ProtoReflect() protoreflect.Message {
-	mi := &file_dispatch_proto_msgTypes[3]
+func (x *Payload) ProtoReflect() protoreflect.Message {
+	mi := &file_dispatch_proto_msgTypes[0]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -340,140 +807,4282 @@ func (x *Rule) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use Rule.ProtoReflect.Descriptor instead.
-func (*Rule) Descriptor() ([]byte, []int) {
-	return file_dispatch_proto_rawDescGZIP(), []int{3}
+// Deprecated: Use Payload.ProtoReflect.Descriptor instead.
+func (*Payload) Descriptor() ([]byte, []int) {
+	return file_dispatch_proto_rawDescGZIP(), []int{0}
 }
 
-func (x *Rule) GetIp() string {
+func (x *Payload) GetSenderId() uint64 {
 	if x != nil {
-		return x.Ip
+		return x.SenderId
 	}
-	return ""
+	return 0
 }
 
-func (x *Rule) GetPort() string {
+func (x *Payload) GetText() []byte {
 	if x != nil {
-		return x.Port
+		return x.Text
 	}
-	return ""
+	return nil
 }
 
-func (x *Rule) GetInsert() bool {
+func (x *Payload) GetImage() []byte {
 	if x != nil {
-		return x.Insert
+		return x.Image
 	}
-	return false
+	return nil
 }
 
-var File_dispatch_proto protoreflect.FileDescriptor
+func (x *Payload) GetTimestamp() int64 {
+	if x != nil {
+		return x.Timestamp
+	}
+	return 0
+}
 
-var file_dispatch_proto_rawDesc = []byte{
-	0x0a, 0x0e, 0x64, 0x69, 0x73, 0x70, 0x61, 0x74, 0x63, 0x68, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f,
-	0x12, 0x08, 0x64, 0x69, 0x73, 0x70, 0x61, 0x74, 0x63, 0x68, 0x22, 0x6e, 0x0a, 0x07, 0x50, 0x61,
-	0x79, 0x6c, 0x6f, 0x61, 0x64, 0x12, 0x1b, 0x0a, 0x09, 0x73, 0x65, 0x6e, 0x64, 0x65, 0x72, 0x5f,
-	0x69, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x04, 0x52, 0x08, 0x73, 0x65, 0x6e, 0x64, 0x65, 0x72,
-	0x49, 0x64, 0x12, 0x12, 0x0a, 0x04, 0x74, 0x65, 0x78, 0x74, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0c,
-	0x52, 0x04, 0x74, 0x65, 0x78, 0x74, 0x12, 0x14, 0x0a, 0x05, 0x69, 0x6d, 0x61, 0x67, 0x65, 0x18,
-	0x03, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x05, 0x69, 0x6d, 0x61, 0x67, 0x65, 0x12, 0x1c, 0x0a, 0x09,
-	0x74, 0x69, 0x6d, 0x65, 0x73, 0x74, 0x61, 0x6d, 0x70, 0x18, 0x04, 0x20, 0x01, 0x28, 0x03, 0x52,
-	0x09, 0x74, 0x69, 0x6d, 0x65, 0x73, 0x74, 0x61, 0x6d, 0x70, 0x22, 0x88, 0x02, 0x0a, 0x06, 0x41,
-	0x63, 0x74, 0x69, 0x6f, 0x6e, 0x12, 0x18, 0x0a, 0x07, 0x70, 0x61, 0x79, 0x6c, 0x6f, 0x61, 0x64,
-	0x18, 0x01, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x07, 0x70, 0x61, 0x79, 0x6c, 0x6f, 0x61, 0x64, 0x12,
-	0x21, 0x0a, 0x0c, 0x72, 0x65, 0x63, 0x69, 0x70, 0x69, 0x65, 0x6e, 0x74, 0x5f, 0x69, 0x64, 0x18,
-	0x02, 0x20, 0x01, 0x28, 0x04, 0x52, 0x0b, 0x72, 0x65, 0x63, 0x69, 0x70, 0x69, 0x65, 0x6e, 0x74,
-	0x49, 0x64, 0x12, 0x1d, 0x0a, 0x0a, 0x63, 0x68, 0x61, 0x6e, 0x6e, 0x65, 0x6c, 0x5f, 0x69, 0x64,
-	0x18, 0x03, 0x20, 0x01, 0x28, 0x04, 0x52, 0x09, 0x63, 0x68, 0x61, 0x6e, 0x6e, 0x65, 0x6c, 0x49,
-	0x64, 0x12, 0x1c, 0x0a, 0x09, 0x70, 0x75, 0x62, 0x6c, 0x69, 0x63, 0x6b, 0x65, 0x79, 0x18, 0x04,
-	0x20, 0x01, 0x28, 0x0c, 0x52, 0x09, 0x70, 0x75, 0x62, 0x6c, 0x69, 0x63, 0x6b, 0x65, 0x79, 0x12,
-	0x12, 0x0a, 0x04, 0x68, 0x61, 0x73, 0x68, 0x18, 0x05, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x04, 0x68,
-	0x61, 0x73, 0x68, 0x12, 0x2f, 0x0a, 0x04, 0x74, 0x79, 0x70, 0x65, 0x18, 0x06, 0x20, 0x01, 0x28,
-	0x0e, 0x32, 0x1b, 0x2e, 0x64, 0x69, 0x73, 0x70, 0x61, 0x74, 0x63, 0x68, 0x2e, 0x41, 0x63, 0x74,
-	0x69, 0x6f, 0x6e, 0x2e, 0x41, 0x63, 0x74, 0x69, 0x6f, 0x6e, 0x54, 0x79, 0x70, 0x65, 0x52, 0x04,
-	0x74, 0x79, 0x70, 0x65, 0x22, 0x3f, 0x0a, 0x0a, 0x41, 0x63, 0x74, 0x69, 0x6f, 0x6e, 0x54, 0x79,
-	0x70, 0x65, 0x12, 0x0d, 0x0a, 0x09, 0x48, 0x41, 0x4e, 0x44, 0x53, 0x48, 0x41, 0x4b, 0x45, 0x10,
-	0x00, 0x12, 0x10, 0x0a, 0x0c, 0x54, 0x52, 0x41, 0x4e, 0x53, 0x4d, 0x49, 0x53, 0x53, 0x49, 0x4f,
-	0x4e, 0x10, 0x01, 0x12, 0x10, 0x0a, 0x0c, 0x43, 0x4f, 0x4e, 0x46, 0x49, 0x52, 0x4d, 0x41, 0x54,
-	0x49, 0x4f, 0x4e, 0x10, 0x02, 0x22, 0x6f, 0x0a, 0x0e, 0x41, 0x75, 0x74, 0x68, 0x65, 0x6e, 0x74,
-	0x69, 0x63, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x12, 0x17, 0x0a, 0x07, 0x75, 0x73, 0x65, 0x72, 0x5f,
-	0x69, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x04, 0x52, 0x06, 0x75, 0x73, 0x65, 0x72, 0x49, 0x64,
-	0x12, 0x12, 0x0a, 0x04, 0x63, 0x6f, 0x64, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x04,
-	0x63, 0x6f, 0x64, 0x65, 0x12, 0x1c, 0x0a, 0x09, 0x70, 0x75, 0x62, 0x6c, 0x69, 0x63, 0x6b, 0x65,
-	0x79, 0x18, 0x03, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x09, 0x70, 0x75, 0x62, 0x6c, 0x69, 0x63, 0x6b,
-	0x65, 0x79, 0x12, 0x12, 0x0a, 0x04, 0x68, 0x61, 0x73, 0x68, 0x18, 0x04, 0x20, 0x01, 0x28, 0x0c,
-	0x52, 0x04, 0x68, 0x61, 0x73, 0x68, 0x22, 0x42, 0x0a, 0x04, 0x52, 0x75, 0x6c, 0x65, 0x12, 0x0e,
-	0x0a, 0x02, 0x69, 0x70, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x02, 0x69, 0x70, 0x12, 0x12,
-	0x0a, 0x04, 0x70, 0x6f, 0x72, 0x74, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x70, 0x6f,
-	0x72, 0x74, 0x12, 0x16, 0x0a, 0x06, 0x69, 0x6e, 0x73, 0x65, 0x72, 0x74, 0x18, 0x03, 0x20, 0x01,
-	0x28, 0x08, 0x52, 0x06, 0x69, 0x6e, 0x73, 0x65, 0x72, 0x74, 0x42, 0x25, 0x5a, 0x23, 0x67, 0x69,
-	0x74, 0x68, 0x75, 0x62, 0x2e, 0x63, 0x6f, 0x6d, 0x2f, 0x61, 0x70, 0x75, 0x72, 0x65, 0x72, 0x2f,
-	0x65, 0x32, 0x65, 0x65, 0x63, 0x68, 0x61, 0x74, 0x2f, 0x64, 0x69, 0x73, 0x70, 0x61, 0x74, 0x63,
-	0x68, 0x62, 0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x33,
+func (x *Payload) GetAttachments() []*Attachment {
+	if x != nil {
+		return x.Attachments
+	}
+	return nil
 }
 
-var (
-	file_dispatch_proto_rawDescOnce sync.Once
-	file_dispatch_proto_rawDescData = file_dispatch_proto_rawDesc
-)
+func (x *Payload) GetMessageId() uint64 {
+	if x != nil {
+		return x.MessageId
+	}
+	return 0
+}
 
-func file_dispatch_proto_rawDescGZIP() []byte {
-	file_dispatch_proto_rawDescOnce.Do(func() {
-		file_dispatch_proto_rawDescData = protoimpl.X.CompressGZIP(file_dispatch_proto_rawDescData)
-	})
-	return file_dispatch_proto_rawDescData
+func (x *Payload) GetExpireTimer() uint32 {
+	if x != nil {
+		return x.ExpireTimer
+	}
+	return 0
 }
 
-var file_dispatch_proto_enumTypes = make([]protoimpl.EnumInfo, 1)
-var file_dispatch_proto_msgTypes = make([]protoimpl.MessageInfo, 4)
-var file_dispatch_proto_goTypes = []interface{}{
-	(Action_ActionType)(0), // 0: dispatch.Action.ActionType
-	(*Payload)(nil),        // 1: dispatch.Payload
-	(*Action)(nil),         // 2: dispatch.Action
-	(*Authentication)(nil), // 3: dispatch.Authentication
-	(*Rule)(nil),           // 4: dispatch.Rule
+func (m *Payload) GetChange() isPayload_Change {
+	if m != nil {
+		return m.Change
+	}
+	return nil
 }
-var file_dispatch_proto_depIdxs = []int32{
-	0, // 0: dispatch.Action.type:type_name -> dispatch.Action.ActionType
-	1, // [1:1] is the sub-list for method output_type
-	1, // [1:1] is the sub-list for method input_type
-	1, // [1:1] is the sub-list for extension type_name
-	1, // [1:1] is the sub-list for extension extendee
-	0, // [0:1] is the sub-list for field type_name
+
+func (x *Payload) GetEdit() *Edit {
+	if x, ok := x.GetChange().(*Payload_Edit); ok {
+		return x.Edit
+	}
+	return nil
 }
 
-func init() { file_dispatch_proto_init() }
-func file_dispatch_proto_init() {
-	if File_dispatch_proto != nil {
-		return
+func (x *Payload) GetDelete() *Delete {
+	if x, ok := x.GetChange().(*Payload_Delete); ok {
+		return x.Delete
 	}
-	if !protoimpl.UnsafeEnabled {
-		file_dispatch_proto_msgTypes[0].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*Payload); i {
-			case 0:
-				return &v.state
-			case 1:
-				return &v.sizeCache
-			case 2:
-				return &v.unknownFields
-			default:
-				return nil
-			}
-		}
-		file_dispatch_proto_msgTypes[1].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*Action); i {
-			case 0:
-				return &v.state
-			case 1:
-				return &v.sizeCache
-			case 2:
-				return &v.unknownFields
-			default:
-				return nil
-			}
-		}
-		file_dispatch_proto_msgTypes[2].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*Authentication); i {
-			case 0:
+	return nil
+}
+
+func (x *Payload) GetReaction() *Reaction {
+	if x, ok := x.GetChange().(*Payload_Reaction); ok {
+		return x.Reaction
+	}
+	return nil
+}
+
+type isPayload_Change interface {
+	isPayload_Change()
+}
+
+type Payload_Edit struct {
+	Edit *Edit `protobuf:"bytes,7,opt,name=edit,proto3,oneof"`
+}
+
+type Payload_Delete struct {
+	Delete *Delete `protobuf:"bytes,8,opt,name=delete,proto3,oneof"`
+}
+
+type Payload_Reaction struct {
+	Reaction *Reaction `protobuf:"bytes,9,opt,name=reaction,proto3,oneof"`
+}
+
+func (*Payload_Edit) isPayload_Change() {}
+
+func (*Payload_Delete) isPayload_Change() {}
+
+func (*Payload_Reaction) isPayload_Change() {}
+
+// MessageRef names a message by its sender and the sender's message id.
+type MessageRef struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	SenderId  uint64 `protobuf:"varint,1,opt,name=sender_id,json=senderId,proto3" json:"sender_id,omitempty"`
+	MessageId uint64 `protobuf:"varint,2,opt,name=message_id,json=messageId,proto3" json:"message_id,omitempty"`
+}
+
+func (x *MessageRef) Reset() {
+	*x = MessageRef{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_dispatch_proto_msgTypes[1]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *MessageRef) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*MessageRef) ProtoMessage() {}
+
+func (x *MessageRef) ProtoReflect() protoreflect.Message {
+	mi := &file_dispatch_proto_msgTypes[1]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use MessageRef.ProtoReflect.Descriptor instead.
+func (*MessageRef) Descriptor() ([]byte, []int) {
+	return file_dispatch_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *MessageRef) GetSenderId() uint64 {
+	if x != nil {
+		return x.SenderId
+	}
+	return 0
+}
+
+func (x *MessageRef) GetMessageId() uint64 {
+	if x != nil {
+		return x.MessageId
+	}
+	return 0
+}
+
+// Edit replaces the text and attachments of an earlier message of the same
+// sender.
+type Edit struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Target      *MessageRef   `protobuf:"bytes,1,opt,name=target,proto3" json:"target,omitempty"`
+	Text        []byte        `protobuf:"bytes,2,opt,name=text,proto3" json:"text,omitempty"`
+	Attachments []*Attachment `protobuf:"bytes,3,rep,name=attachments,proto3" json:"attachments,omitempty"`
+}
+
+func (x *Edit) Reset() {
+	*x = Edit{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_dispatch_proto_msgTypes[2]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *Edit) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Edit) ProtoMessage() {}
+
+func (x *Edit) ProtoReflect() protoreflect.Message {
+	mi := &file_dispatch_proto_msgTypes[2]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Edit.ProtoReflect.Descriptor instead.
+func (*Edit) Descriptor() ([]byte, []int) {
+	return file_dispatch_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *Edit) GetTarget() *MessageRef {
+	if x != nil {
+		return x.Target
+	}
+	return nil
+}
+
+func (x *Edit) GetText() []byte {
+	if x != nil {
+		return x.Text
+	}
+	return nil
+}
+
+func (x *Edit) GetAttachments() []*Attachment {
+	if x != nil {
+		return x.Attachments
+	}
+	return nil
+}
+
+// Delete removes an earlier message of the same sender for everyone. The
+// Action carrying it sets deleted_message_id, so that srvtls drops copies of
+// the message it has not delivered yet.
+type Delete struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Target *MessageRef `protobuf:"bytes,1,opt,name=target,proto3" json:"target,omitempty"`
+}
+
+func (x *Delete) Reset() {
+	*x = Delete{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_dispatch_proto_msgTypes[3]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *Delete) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Delete) ProtoMessage() {}
+
+func (x *Delete) ProtoReflect() protoreflect.Message {
+	mi := &file_dispatch_proto_msgTypes[3]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Delete.ProtoReflect.Descriptor instead.
+func (*Delete) Descriptor() ([]byte, []int) {
+	return file_dispatch_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *Delete) GetTarget() *MessageRef {
+	if x != nil {
+		return x.Target
+	}
+	return nil
+}
+
+// Reaction adds an emoji to a message of anyone in the conversation, or
+// takes it back.
+type Reaction struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Target *MessageRef `protobuf:"bytes,1,opt,name=target,proto3" json:"target,omitempty"`
+	Emoji  string      `protobuf:"bytes,2,opt,name=emoji,proto3" json:"emoji,omitempty"`
+	Remove bool        `protobuf:"varint,3,opt,name=remove,proto3" json:"remove,omitempty"`
+}
+
+func (x *Reaction) Reset() {
+	*x = Reaction{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_dispatch_proto_msgTypes[4]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *Reaction) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Reaction) ProtoMessage() {}
+
+func (x *Reaction) ProtoReflect() protoreflect.Message {
+	mi := &file_dispatch_proto_msgTypes[4]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Reaction.ProtoReflect.Descriptor instead.
+func (*Reaction) Descriptor() ([]byte, []int) {
+	return file_dispatch_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *Reaction) GetTarget() *MessageRef {
+	if x != nil {
+		return x.Target
+	}
+	return nil
+}
+
+func (x *Reaction) GetEmoji() string {
+	if x != nil {
+		return x.Emoji
+	}
+	return ""
+}
+
+func (x *Reaction) GetRemove() bool {
+	if x != nil {
+		return x.Remove
+	}
+	return false
+}
+
+// Attachment points to a file encrypted with package attachment and
+// uploaded to srvhttps. Only the recipients, who get the key, can read it.
+type Attachment struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Id          string `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`                                      // blob id on srvhttps
+	Key         []byte `protobuf:"bytes,2,opt,name=key,proto3" json:"key,omitempty"`                                    // AES-256-GCM key of the file
+	Digest      []byte `protobuf:"bytes,3,opt,name=digest,proto3" json:"digest,omitempty"`                              // SHA-256 of the uploaded ciphertext
+	ContentType string `protobuf:"bytes,4,opt,name=content_type,json=contentType,proto3" json:"content_type,omitempty"` // MIME type
+	Size        uint64 `protobuf:"varint,5,opt,name=size,proto3" json:"size,omitempty"`                                 // size of the file before encryption
+	FileName    string `protobuf:"bytes,6,opt,name=file_name,json=fileName,proto3" json:"file_name,omitempty"`
+}
+
+func (x *Attachment) Reset() {
+	*x = Attachment{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_dispatch_proto_msgTypes[5]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *Attachment) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Attachment) ProtoMessage() {}
+
+func (x *Attachment) ProtoReflect() protoreflect.Message {
+	mi := &file_dispatch_proto_msgTypes[5]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Attachment.ProtoReflect.Descriptor instead.
+func (*Attachment) Descriptor() ([]byte, []int) {
+	return file_dispatch_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *Attachment) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *Attachment) GetKey() []byte {
+	if x != nil {
+		return x.Key
+	}
+	return nil
+}
+
+func (x *Attachment) GetDigest() []byte {
+	if x != nil {
+		return x.Digest
+	}
+	return nil
+}
+
+func (x *Attachment) GetContentType() string {
+	if x != nil {
+		return x.ContentType
+	}
+	return ""
+}
+
+func (x *Attachment) GetSize() uint64 {
+	if x != nil {
+		return x.Size
+	}
+	return 0
+}
+
+func (x *Attachment) GetFileName() string {
+	if x != nil {
+		return x.FileName
+	}
+	return ""
+}
+
+type Action struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Payload     []byte            `protobuf:"bytes,1,opt,name=payload,proto3" json:"payload,omitempty"`
+	RecipientId uint64            `protobuf:"varint,2,opt,name=recipient_id,json=recipientId,proto3" json:"recipient_id,omitempty"`
+	ChannelId   uint64            `protobuf:"varint,3,opt,name=channel_id,json=channelId,proto3" json:"channel_id,omitempty"`
+	Publickey   []byte            `protobuf:"bytes,4,opt,name=publickey,proto3" json:"publickey,omitempty"`
+	Hash        []byte            `protobuf:"bytes,5,opt,name=hash,proto3" json:"hash,omitempty"`
+	Type        Action_ActionType `protobuf:"varint,6,opt,name=type,proto3,enum=dispatch.Action_ActionType" json:"type,omitempty"`
+	// copies holds the payload encrypted separately for every device of the
+	// recipient. srvtls delivers each copy to its device only, with payload
+	// replaced by the copy and copies cleared.
+	Copies            []*DeviceCopy `protobuf:"bytes,7,rep,name=copies,proto3" json:"copies,omitempty"`
+	RecipientDeviceId uint64        `protobuf:"varint,8,opt,name=recipient_device_id,json=recipientDeviceId,proto3" json:"recipient_device_id,omitempty"` // set by srvtls on delivery
+	SenderId          uint64        `protobuf:"varint,9,opt,name=sender_id,json=senderId,proto3" json:"sender_id,omitempty"`                              // set by srvtls from the verified session
+	SenderDeviceId    uint64        `protobuf:"varint,10,opt,name=sender_device_id,json=senderDeviceId,proto3" json:"sender_device_id,omitempty"`         // set by srvtls from the verified session
+	AccessKey         []byte        `protobuf:"bytes,11,opt,name=access_key,json=accessKey,proto3" json:"access_key,omitempty"`                           // for SEALED actions, the recipient's access key
+	MessageId         uint64        `protobuf:"varint,12,opt,name=message_id,json=messageId,proto3" json:"message_id,omitempty"`                          // chosen by the client, echoed in the Error about this action and kept on delivery
+	DeletedMessageId  uint64        `protobuf:"varint,13,opt,name=deleted_message_id,json=deletedMessageId,proto3" json:"deleted_message_id,omitempty"`   // message_id of an earlier action of the sender whose queued copies srvtls drops
+	ExpireTimer       uint32        `protobuf:"varint,14,opt,name=expire_timer,json=expireTimer,proto3" json:"expire_timer,omitempty"`                    // seconds after which srvtls drops copies it could not deliver yet, 0 keeps them
+}
+
+func (x *Action) Reset() {
+	*x = Action{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_dispatch_proto_msgTypes[6]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *Action) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Action) ProtoMessage() {}
+
+func (x *Action) ProtoReflect() protoreflect.Message {
+	mi := &file_dispatch_proto_msgTypes[6]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Action.ProtoReflect.Descriptor instead.
+func (*Action) Descriptor() ([]byte, []int) {
+	return file_dispatch_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *Action) GetPayload() []byte {
+	if x != nil {
+		return x.Payload
+	}
+	return nil
+}
+
+func (x *Action) GetRecipientId() uint64 {
+	if x != nil {
+		return x.RecipientId
+	}
+	return 0
+}
+
+func (x *Action) GetChannelId() uint64 {
+	if x != nil {
+		return x.ChannelId
+	}
+	return 0
+}
+
+func (x *Action) GetPublickey() []byte {
+	if x != nil {
+		return x.Publickey
+	}
+	return nil
+}
+
+func (x *Action) GetHash() []byte {
+	if x != nil {
+		return x.Hash
+	}
+	return nil
+}
+
+func (x *Action) GetType() Action_ActionType {
+	if x != nil {
+		return x.Type
+	}
+	return Action_HANDSHAKE
+}
+
+func (x *Action) GetCopies() []*DeviceCopy {
+	if x != nil {
+		return x.Copies
+	}
+	return nil
+}
+
+func (x *Action) GetRecipientDeviceId() uint64 {
+	if x != nil {
+		return x.RecipientDeviceId
+	}
+	return 0
+}
+
+func (x *Action) GetSenderId() uint64 {
+	if x != nil {
+		return x.SenderId
+	}
+	return 0
+}
+
+func (x *Action) GetSenderDeviceId() uint64 {
+	if x != nil {
+		return x.SenderDeviceId
+	}
+	return 0
+}
+
+func (x *Action) GetAccessKey() []byte {
+	if x != nil {
+		return x.AccessKey
+	}
+	return nil
+}
+
+func (x *Action) GetMessageId() uint64 {
+	if x != nil {
+		return x.MessageId
+	}
+	return 0
+}
+
+func (x *Action) GetDeletedMessageId() uint64 {
+	if x != nil {
+		return x.DeletedMessageId
+	}
+	return 0
+}
+
+func (x *Action) GetExpireTimer() uint32 {
+	if x != nil {
+		return x.ExpireTimer
+	}
+	return 0
+}
+
+// DeviceCopy is the payload of an Action encrypted for one device.
+type DeviceCopy struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	DeviceId uint64 `protobuf:"varint,1,opt,name=device_id,json=deviceId,proto3" json:"device_id,omitempty"`
+	Payload  []byte `protobuf:"bytes,2,opt,name=payload,proto3" json:"payload,omitempty"`
+}
+
+func (x *DeviceCopy) Reset() {
+	*x = DeviceCopy{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_dispatch_proto_msgTypes[7]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *DeviceCopy) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DeviceCopy) ProtoMessage() {}
+
+func (x *DeviceCopy) ProtoReflect() protoreflect.Message {
+	mi := &file_dispatch_proto_msgTypes[7]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DeviceCopy.ProtoReflect.Descriptor instead.
+func (*DeviceCopy) Descriptor() ([]byte, []int) {
+	return file_dispatch_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *DeviceCopy) GetDeviceId() uint64 {
+	if x != nil {
+		return x.DeviceId
+	}
+	return 0
+}
+
+func (x *DeviceCopy) GetPayload() []byte {
+	if x != nil {
+		return x.Payload
+	}
+	return nil
+}
+
+// ChannelOp asks srvtls to change or describe a group channel. Actions with a
+// channel_id and any other type are delivered to every member of the channel.
+type ChannelOp struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Op        ChannelOp_Op       `protobuf:"varint,1,opt,name=op,proto3,enum=dispatch.ChannelOp_Op" json:"op,omitempty"`
+	ChannelId uint64             `protobuf:"varint,2,opt,name=channel_id,json=channelId,proto3" json:"channel_id,omitempty"`       // assigned by srvtls for CREATE
+	UserId    uint64             `protobuf:"varint,3,opt,name=user_id,json=userId,proto3" json:"user_id,omitempty"`                // target of INVITE, KICK and SET_ROLE
+	Role      ChannelMember_Role `protobuf:"varint,4,opt,name=role,proto3,enum=dispatch.ChannelMember_Role" json:"role,omitempty"` // for SET_ROLE, OWNER transfers ownership
+	Name      string             `protobuf:"bytes,5,opt,name=name,proto3" json:"name,omitempty"`                                   // for CREATE
+}
+
+func (x *ChannelOp) Reset() {
+	*x = ChannelOp{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_dispatch_proto_msgTypes[8]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ChannelOp) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ChannelOp) ProtoMessage() {}
+
+func (x *ChannelOp) ProtoReflect() protoreflect.Message {
+	mi := &file_dispatch_proto_msgTypes[8]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ChannelOp.ProtoReflect.Descriptor instead.
+func (*ChannelOp) Descriptor() ([]byte, []int) {
+	return file_dispatch_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *ChannelOp) GetOp() ChannelOp_Op {
+	if x != nil {
+		return x.Op
+	}
+	return ChannelOp_CREATE
+}
+
+func (x *ChannelOp) GetChannelId() uint64 {
+	if x != nil {
+		return x.ChannelId
+	}
+	return 0
+}
+
+func (x *ChannelOp) GetUserId() uint64 {
+	if x != nil {
+		return x.UserId
+	}
+	return 0
+}
+
+func (x *ChannelOp) GetRole() ChannelMember_Role {
+	if x != nil {
+		return x.Role
+	}
+	return ChannelMember_MEMBER
+}
+
+func (x *ChannelOp) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+type ChannelMember struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	UserId uint64             `protobuf:"varint,1,opt,name=user_id,json=userId,proto3" json:"user_id,omitempty"`
+	Role   ChannelMember_Role `protobuf:"varint,2,opt,name=role,proto3,enum=dispatch.ChannelMember_Role" json:"role,omitempty"`
+}
+
+func (x *ChannelMember) Reset() {
+	*x = ChannelMember{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_dispatch_proto_msgTypes[9]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ChannelMember) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ChannelMember) ProtoMessage() {}
+
+func (x *ChannelMember) ProtoReflect() protoreflect.Message {
+	mi := &file_dispatch_proto_msgTypes[9]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ChannelMember.ProtoReflect.Descriptor instead.
+func (*ChannelMember) Descriptor() ([]byte, []int) {
+	return file_dispatch_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *ChannelMember) GetUserId() uint64 {
+	if x != nil {
+		return x.UserId
+	}
+	return 0
+}
+
+func (x *ChannelMember) GetRole() ChannelMember_Role {
+	if x != nil {
+		return x.Role
+	}
+	return ChannelMember_MEMBER
+}
+
+// ChannelEvent reports a ChannelOp. Changes are sent to every member before
+// and after the change, refused ops and LIST only to the requesting device.
+type ChannelEvent struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Op      *ChannelOp       `protobuf:"bytes,1,opt,name=op,proto3" json:"op,omitempty"`
+	ActorId uint64           `protobuf:"varint,2,opt,name=actor_id,json=actorId,proto3" json:"actor_id,omitempty"` // user who sent the op
+	Name    string           `protobuf:"bytes,3,opt,name=name,proto3" json:"name,omitempty"`
+	Members []*ChannelMember `protobuf:"bytes,4,rep,name=members,proto3" json:"members,omitempty"` // after the op
+	Error   string           `protobuf:"bytes,5,opt,name=error,proto3" json:"error,omitempty"`     // why the op was refused
+}
+
+func (x *ChannelEvent) Reset() {
+	*x = ChannelEvent{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_dispatch_proto_msgTypes[10]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ChannelEvent) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ChannelEvent) ProtoMessage() {}
+
+func (x *ChannelEvent) ProtoReflect() protoreflect.Message {
+	mi := &file_dispatch_proto_msgTypes[10]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ChannelEvent.ProtoReflect.Descriptor instead.
+func (*ChannelEvent) Descriptor() ([]byte, []int) {
+	return file_dispatch_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *ChannelEvent) GetOp() *ChannelOp {
+	if x != nil {
+		return x.Op
+	}
+	return nil
+}
+
+func (x *ChannelEvent) GetActorId() uint64 {
+	if x != nil {
+		return x.ActorId
+	}
+	return 0
+}
+
+func (x *ChannelEvent) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *ChannelEvent) GetMembers() []*ChannelMember {
+	if x != nil {
+		return x.Members
+	}
+	return nil
+}
+
+func (x *ChannelEvent) GetError() string {
+	if x != nil {
+		return x.Error
+	}
+	return ""
+}
+
+// Typing tells the recipient, or every member of the channel, that the
+// sender started or stopped typing. It is not encrypted and srvtls only
+// passes it to devices connected at that moment, it is never queued.
+type Typing struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	State Typing_State `protobuf:"varint,1,opt,name=state,proto3,enum=dispatch.Typing_State" json:"state,omitempty"`
+}
+
+func (x *Typing) Reset() {
+	*x = Typing{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_dispatch_proto_msgTypes[11]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *Typing) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Typing) ProtoMessage() {}
+
+func (x *Typing) ProtoReflect() protoreflect.Message {
+	mi := &file_dispatch_proto_msgTypes[11]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Typing.ProtoReflect.Descriptor instead.
+func (*Typing) Descriptor() ([]byte, []int) {
+	return file_dispatch_proto_rawDescGZIP(), []int{11}
+}
+
+func (x *Typing) GetState() Typing_State {
+	if x != nil {
+		return x.State
+	}
+	return Typing_STOPPED
+}
+
+// Presence tells whether a user is connected. Clients send one to set their
+// own state or settings or to subscribe to the presence of user_id. srvtls
+// answers a SUBSCRIBE with the current state of user_id and sends a STATE
+// whenever it changes, as long as the subscribing connection is open and the
+// settings of user_id let the subscriber see it. Users who may not see the
+// presence of user_id always get OFFLINE without a last_seen.
+type Presence struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Op       Presence_Op       `protobuf:"varint,1,opt,name=op,proto3,enum=dispatch.Presence_Op" json:"op,omitempty"`
+	UserId   uint64            `protobuf:"varint,2,opt,name=user_id,json=userId,proto3" json:"user_id,omitempty"`              // target of SUBSCRIBE and UNSUBSCRIBE, subject of STATE from srvtls
+	State    Presence_State    `protobuf:"varint,3,opt,name=state,proto3,enum=dispatch.Presence_State" json:"state,omitempty"` // for STATE, clients may only set ONLINE and AWAY
+	LastSeen int64             `protobuf:"varint,4,opt,name=last_seen,json=lastSeen,proto3" json:"last_seen,omitempty"`        // unix time the user was last connected, 0 if not to be seen
+	Settings *PresenceSettings `protobuf:"bytes,5,opt,name=settings,proto3" json:"settings,omitempty"`                         // for SETTINGS, replaces the current ones
+}
+
+func (x *Presence) Reset() {
+	*x = Presence{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_dispatch_proto_msgTypes[12]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *Presence) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Presence) ProtoMessage() {}
+
+func (x *Presence) ProtoReflect() protoreflect.Message {
+	mi := &file_dispatch_proto_msgTypes[12]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Presence.ProtoReflect.Descriptor instead.
+func (*Presence) Descriptor() ([]byte, []int) {
+	return file_dispatch_proto_rawDescGZIP(), []int{12}
+}
+
+func (x *Presence) GetOp() Presence_Op {
+	if x != nil {
+		return x.Op
+	}
+	return Presence_STATE
+}
+
+func (x *Presence) GetUserId() uint64 {
+	if x != nil {
+		return x.UserId
+	}
+	return 0
+}
+
+func (x *Presence) GetState() Presence_State {
+	if x != nil {
+		return x.State
+	}
+	return Presence_OFFLINE
+}
+
+func (x *Presence) GetLastSeen() int64 {
+	if x != nil {
+		return x.LastSeen
+	}
+	return 0
+}
+
+func (x *Presence) GetSettings() *PresenceSettings {
+	if x != nil {
+		return x.Settings
+	}
+	return nil
+}
+
+// PresenceSettings decide who may see the presence and last-seen time of a
+// user, the user's own devices always can.
+type PresenceSettings struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Presence PresenceSettings_Visibility `protobuf:"varint,1,opt,name=presence,proto3,enum=dispatch.PresenceSettings_Visibility" json:"presence,omitempty"`
+	LastSeen PresenceSettings_Visibility `protobuf:"varint,2,opt,name=last_seen,json=lastSeen,proto3,enum=dispatch.PresenceSettings_Visibility" json:"last_seen,omitempty"`
+	Allowed  []uint64                    `protobuf:"varint,3,rep,packed,name=allowed,proto3" json:"allowed,omitempty"`
+}
+
+func (x *PresenceSettings) Reset() {
+	*x = PresenceSettings{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_dispatch_proto_msgTypes[13]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *PresenceSettings) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PresenceSettings) ProtoMessage() {}
+
+func (x *PresenceSettings) ProtoReflect() protoreflect.Message {
+	mi := &file_dispatch_proto_msgTypes[13]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PresenceSettings.ProtoReflect.Descriptor instead.
+func (*PresenceSettings) Descriptor() ([]byte, []int) {
+	return file_dispatch_proto_rawDescGZIP(), []int{13}
+}
+
+func (x *PresenceSettings) GetPresence() PresenceSettings_Visibility {
+	if x != nil {
+		return x.Presence
+	}
+	return PresenceSettings_CHANNEL_MEMBERS
+}
+
+func (x *PresenceSettings) GetLastSeen() PresenceSettings_Visibility {
+	if x != nil {
+		return x.LastSeen
+	}
+	return PresenceSettings_CHANNEL_MEMBERS
+}
+
+func (x *PresenceSettings) GetAllowed() []uint64 {
+	if x != nil {
+		return x.Allowed
+	}
+	return nil
+}
+
+// Envelope opens a connection from protocol version 2 on: the client sends
+// one with its highest version, the capabilities it supports and its
+// Authentication, and the proxy answers with one holding the negotiated
+// version, the capabilities both sides support and the AuthResult. Version 1
+// clients send a bare Authentication instead. Envelope fields start at 16 so
+// that such an Authentication decodes as an Envelope without a version. The
+// AuthChallenge and DeviceProof in between travel in Envelopes too, or bare
+// for version 1 clients.
+type Envelope struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Version        uint32          `protobuf:"varint,16,opt,name=version,proto3" json:"version,omitempty"`
+	Capabilities   []Capability    `protobuf:"varint,17,rep,packed,name=capabilities,proto3,enum=dispatch.Capability" json:"capabilities,omitempty"`
+	Authentication *Authentication `protobuf:"bytes,18,opt,name=authentication,proto3" json:"authentication,omitempty"`
+	AuthResult     *AuthResult     `protobuf:"bytes,19,opt,name=auth_result,json=authResult,proto3" json:"auth_result,omitempty"`
+	Challenge      *AuthChallenge  `protobuf:"bytes,20,opt,name=challenge,proto3" json:"challenge,omitempty"`
+	DeviceProof    *DeviceProof    `protobuf:"bytes,21,opt,name=device_proof,json=deviceProof,proto3" json:"device_proof,omitempty"`
+}
+
+func (x *Envelope) Reset() {
+	*x = Envelope{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_dispatch_proto_msgTypes[14]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *Envelope) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Envelope) ProtoMessage() {}
+
+func (x *Envelope) ProtoReflect() protoreflect.Message {
+	mi := &file_dispatch_proto_msgTypes[14]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Envelope.ProtoReflect.Descriptor instead.
+func (*Envelope) Descriptor() ([]byte, []int) {
+	return file_dispatch_proto_rawDescGZIP(), []int{14}
+}
+
+func (x *Envelope) GetVersion() uint32 {
+	if x != nil {
+		return x.Version
+	}
+	return 0
+}
+
+func (x *Envelope) GetCapabilities() []Capability {
+	if x != nil {
+		return x.Capabilities
+	}
+	return nil
+}
+
+func (x *Envelope) GetAuthentication() *Authentication {
+	if x != nil {
+		return x.Authentication
+	}
+	return nil
+}
+
+func (x *Envelope) GetAuthResult() *AuthResult {
+	if x != nil {
+		return x.AuthResult
+	}
+	return nil
+}
+
+func (x *Envelope) GetChallenge() *AuthChallenge {
+	if x != nil {
+		return x.Challenge
+	}
+	return nil
+}
+
+func (x *Envelope) GetDeviceProof() *DeviceProof {
+	if x != nil {
+		return x.DeviceProof
+	}
+	return nil
+}
+
+// Error reports why an action was not handled or why the connection is about
+// to be closed. Codes are stable, clients act on them, the detail is for
+// people.
+type Error struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Code          Error_Code `protobuf:"varint,1,opt,name=code,proto3,enum=dispatch.Error_Code" json:"code,omitempty"`
+	Detail        string     `protobuf:"bytes,2,opt,name=detail,proto3" json:"detail,omitempty"`
+	Retryable     bool       `protobuf:"varint,3,opt,name=retryable,proto3" json:"retryable,omitempty"`                  // the same action may succeed later unchanged
+	MessageId     uint64     `protobuf:"varint,4,opt,name=message_id,json=messageId,proto3" json:"message_id,omitempty"` // of the action this is about, 0 for the connection
+	CorrelationId string     `protobuf:"bytes,5,opt,name=correlation_id,json=correlationId,proto3" json:"correlation_id,omitempty"`
+}
+
+func (x *Error) Reset() {
+	*x = Error{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_dispatch_proto_msgTypes[15]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *Error) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Error) ProtoMessage() {}
+
+func (x *Error) ProtoReflect() protoreflect.Message {
+	mi := &file_dispatch_proto_msgTypes[15]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Error.ProtoReflect.Descriptor instead.
+func (*Error) Descriptor() ([]byte, []int) {
+	return file_dispatch_proto_rawDescGZIP(), []int{15}
+}
+
+func (x *Error) GetCode() Error_Code {
+	if x != nil {
+		return x.Code
+	}
+	return Error_UNKNOWN
+}
+
+func (x *Error) GetDetail() string {
+	if x != nil {
+		return x.Detail
+	}
+	return ""
+}
+
+func (x *Error) GetRetryable() bool {
+	if x != nil {
+		return x.Retryable
+	}
+	return false
+}
+
+func (x *Error) GetMessageId() uint64 {
+	if x != nil {
+		return x.MessageId
+	}
+	return 0
+}
+
+func (x *Error) GetCorrelationId() string {
+	if x != nil {
+		return x.CorrelationId
+	}
+	return ""
+}
+
+// Authentication is the first message of a client. The proxy checks that
+// login_token was issued for user_id before it vouches for the user towards
+// srvtls.
+type Authentication struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	UserId        uint64      `protobuf:"varint,1,opt,name=user_id,json=userId,proto3" json:"user_id,omitempty"`
+	Code          []byte      `protobuf:"bytes,2,opt,name=code,proto3" json:"code,omitempty"`                                        // unused, the login_token proves the account
+	Publickey     []byte      `protobuf:"bytes,3,opt,name=publickey,proto3" json:"publickey,omitempty"`                              // unused
+	Hash          []byte      `protobuf:"bytes,4,opt,name=hash,proto3" json:"hash,omitempty"`                                        // unused
+	CorrelationId string      `protobuf:"bytes,5,opt,name=correlation_id,json=correlationId,proto3" json:"correlation_id,omitempty"` // issued by srvhttps at login, ties the session logs together
+	LoginToken    *LoginToken `protobuf:"bytes,6,opt,name=login_token,json=loginToken,proto3" json:"login_token,omitempty"`          // issued when the user logged in, not needed for sealed_sender
+	DeviceId      uint64      `protobuf:"varint,7,opt,name=device_id,json=deviceId,proto3" json:"device_id,omitempty"`               // returned by srvhttps when the device was registered
+	SealedSender  bool        `protobuf:"varint,8,opt,name=sealed_sender,json=sealedSender,proto3" json:"sealed_sender,omitempty"`   // anonymous connection that only sends SEALED actions
+}
+
+func (x *Authentication) Reset() {
+	*x = Authentication{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_dispatch_proto_msgTypes[16]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *Authentication) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Authentication) ProtoMessage() {}
+
+func (x *Authentication) ProtoReflect() protoreflect.Message {
+	mi := &file_dispatch_proto_msgTypes[16]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Authentication.ProtoReflect.Descriptor instead.
+func (*Authentication) Descriptor() ([]byte, []int) {
+	return file_dispatch_proto_rawDescGZIP(), []int{16}
+}
+
+func (x *Authentication) GetUserId() uint64 {
+	if x != nil {
+		return x.UserId
+	}
+	return 0
+}
+
+func (x *Authentication) GetCode() []byte {
+	if x != nil {
+		return x.Code
+	}
+	return nil
+}
+
+func (x *Authentication) GetPublickey() []byte {
+	if x != nil {
+		return x.Publickey
+	}
+	return nil
+}
+
+func (x *Authentication) GetHash() []byte {
+	if x != nil {
+		return x.Hash
+	}
+	return nil
+}
+
+func (x *Authentication) GetCorrelationId() string {
+	if x != nil {
+		return x.CorrelationId
+	}
+	return ""
+}
+
+func (x *Authentication) GetLoginToken() *LoginToken {
+	if x != nil {
+		return x.LoginToken
+	}
+	return nil
+}
+
+func (x *Authentication) GetDeviceId() uint64 {
+	if x != nil {
+		return x.DeviceId
+	}
+	return 0
+}
+
+func (x *Authentication) GetSealedSender() bool {
+	if x != nil {
+		return x.SealedSender
+	}
+	return false
+}
+
+// LoginToken shows that a user logged in to the account. It is signed with
+// the login key, tls2tlsproxy and srvhttps only hold the public half.
+type LoginToken struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	UserId    uint64 `protobuf:"varint,1,opt,name=user_id,json=userId,proto3" json:"user_id,omitempty"`
+	Expires   int64  `protobuf:"varint,2,opt,name=expires,proto3" json:"expires,omitempty"` // unix time
+	Nonce     []byte `protobuf:"bytes,3,opt,name=nonce,proto3" json:"nonce,omitempty"`
+	Signature []byte `protobuf:"bytes,4,opt,name=signature,proto3" json:"signature,omitempty"` // ed25519 by the login key
+}
+
+func (x *LoginToken) Reset() {
+	*x = LoginToken{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_dispatch_proto_msgTypes[17]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *LoginToken) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*LoginToken) ProtoMessage() {}
+
+func (x *LoginToken) ProtoReflect() protoreflect.Message {
+	mi := &file_dispatch_proto_msgTypes[17]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use LoginToken.ProtoReflect.Descriptor instead.
+func (*LoginToken) Descriptor() ([]byte, []int) {
+	return file_dispatch_proto_rawDescGZIP(), []int{17}
+}
+
+func (x *LoginToken) GetUserId() uint64 {
+	if x != nil {
+		return x.UserId
+	}
+	return 0
+}
+
+func (x *LoginToken) GetExpires() int64 {
+	if x != nil {
+		return x.Expires
+	}
+	return 0
+}
+
+func (x *LoginToken) GetNonce() []byte {
+	if x != nil {
+		return x.Nonce
+	}
+	return nil
+}
+
+func (x *LoginToken) GetSignature() []byte {
+	if x != nil {
+		return x.Signature
+	}
+	return nil
+}
+
+// AuthChallenge is sent by the proxy once it accepted the login token of an
+// Authentication. The device answers with a DeviceProof.
+type AuthChallenge struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Nonce []byte `protobuf:"bytes,1,opt,name=nonce,proto3" json:"nonce,omitempty"`
+}
+
+func (x *AuthChallenge) Reset() {
+	*x = AuthChallenge{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_dispatch_proto_msgTypes[18]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *AuthChallenge) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AuthChallenge) ProtoMessage() {}
+
+func (x *AuthChallenge) ProtoReflect() protoreflect.Message {
+	mi := &file_dispatch_proto_msgTypes[18]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AuthChallenge.ProtoReflect.Descriptor instead.
+func (*AuthChallenge) Descriptor() ([]byte, []int) {
+	return file_dispatch_proto_rawDescGZIP(), []int{18}
+}
+
+func (x *AuthChallenge) GetNonce() []byte {
+	if x != nil {
+		return x.Nonce
+	}
+	return nil
+}
+
+// DeviceProof shows that the client holds the identity key of the device it
+// claims to be.
+type DeviceProof struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Signature []byte `protobuf:"bytes,1,opt,name=signature,proto3" json:"signature,omitempty"` // ed25519 over DeviceProofBytes of the challenge
+}
+
+func (x *DeviceProof) Reset() {
+	*x = DeviceProof{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_dispatch_proto_msgTypes[19]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *DeviceProof) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DeviceProof) ProtoMessage() {}
+
+func (x *DeviceProof) ProtoReflect() protoreflect.Message {
+	mi := &file_dispatch_proto_msgTypes[19]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DeviceProof.ProtoReflect.Descriptor instead.
+func (*DeviceProof) Descriptor() ([]byte, []int) {
+	return file_dispatch_proto_rawDescGZIP(), []int{19}
+}
+
+func (x *DeviceProof) GetSignature() []byte {
+	if x != nil {
+		return x.Signature
+	}
+	return nil
+}
+
+// AuthResult is the proxy's answer to an Authentication. Nothing but an
+// AuthChallenge is sent to the client before it, and the connection is
+// closed after any status other than OK.
+type AuthResult struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Status        AuthResult_Status `protobuf:"varint,1,opt,name=status,proto3,enum=dispatch.AuthResult_Status" json:"status,omitempty"`
+	Detail        string            `protobuf:"bytes,2,opt,name=detail,proto3" json:"detail,omitempty"`
+	CorrelationId string            `protobuf:"bytes,3,opt,name=correlation_id,json=correlationId,proto3" json:"correlation_id,omitempty"`
+}
+
+func (x *AuthResult) Reset() {
+	*x = AuthResult{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_dispatch_proto_msgTypes[20]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *AuthResult) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AuthResult) ProtoMessage() {}
+
+func (x *AuthResult) ProtoReflect() protoreflect.Message {
+	mi := &file_dispatch_proto_msgTypes[20]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AuthResult.ProtoReflect.Descriptor instead.
+func (*AuthResult) Descriptor() ([]byte, []int) {
+	return file_dispatch_proto_rawDescGZIP(), []int{20}
+}
+
+func (x *AuthResult) GetStatus() AuthResult_Status {
+	if x != nil {
+		return x.Status
+	}
+	return AuthResult_OK
+}
+
+func (x *AuthResult) GetDetail() string {
+	if x != nil {
+		return x.Detail
+	}
+	return ""
+}
+
+func (x *AuthResult) GetCorrelationId() string {
+	if x != nil {
+		return x.CorrelationId
+	}
+	return ""
+}
+
+// SessionHeader is sent by the proxy to srvtls ahead of the relayed client
+// traffic. It is signed with the proxy's session key so srvtls can trust the
+// identity it carries.
+type SessionHeader struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	UserId        uint64       `protobuf:"varint,1,opt,name=user_id,json=userId,proto3" json:"user_id,omitempty"`
+	DeviceId      uint64       `protobuf:"varint,2,opt,name=device_id,json=deviceId,proto3" json:"device_id,omitempty"`
+	CorrelationId string       `protobuf:"bytes,3,opt,name=correlation_id,json=correlationId,proto3" json:"correlation_id,omitempty"`
+	IssuedAt      int64        `protobuf:"varint,4,opt,name=issued_at,json=issuedAt,proto3" json:"issued_at,omitempty"` // unix time
+	Nonce         []byte       `protobuf:"bytes,5,opt,name=nonce,proto3" json:"nonce,omitempty"`
+	Signature     []byte       `protobuf:"bytes,6,opt,name=signature,proto3" json:"signature,omitempty"`                                        // ed25519 over SigningBytes
+	Version       uint32       `protobuf:"varint,7,opt,name=version,proto3" json:"version,omitempty"`                                           // negotiated protocol version, 0 from proxies predating it
+	Capabilities  []Capability `protobuf:"varint,8,rep,packed,name=capabilities,proto3,enum=dispatch.Capability" json:"capabilities,omitempty"` // negotiated capabilities
+}
+
+func (x *SessionHeader) Reset() {
+	*x = SessionHeader{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_dispatch_proto_msgTypes[21]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *SessionHeader) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SessionHeader) ProtoMessage() {}
+
+func (x *SessionHeader) ProtoReflect() protoreflect.Message {
+	mi := &file_dispatch_proto_msgTypes[21]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SessionHeader.ProtoReflect.Descriptor instead.
+func (*SessionHeader) Descriptor() ([]byte, []int) {
+	return file_dispatch_proto_rawDescGZIP(), []int{21}
+}
+
+func (x *SessionHeader) GetUserId() uint64 {
+	if x != nil {
+		return x.UserId
+	}
+	return 0
+}
+
+func (x *SessionHeader) GetDeviceId() uint64 {
+	if x != nil {
+		return x.DeviceId
+	}
+	return 0
+}
+
+func (x *SessionHeader) GetCorrelationId() string {
+	if x != nil {
+		return x.CorrelationId
+	}
+	return ""
+}
+
+func (x *SessionHeader) GetIssuedAt() int64 {
+	if x != nil {
+		return x.IssuedAt
+	}
+	return 0
+}
+
+func (x *SessionHeader) GetNonce() []byte {
+	if x != nil {
+		return x.Nonce
+	}
+	return nil
+}
+
+func (x *SessionHeader) GetSignature() []byte {
+	if x != nil {
+		return x.Signature
+	}
+	return nil
+}
+
+func (x *SessionHeader) GetVersion() uint32 {
+	if x != nil {
+		return x.Version
+	}
+	return 0
+}
+
+func (x *SessionHeader) GetCapabilities() []Capability {
+	if x != nil {
+		return x.Capabilities
+	}
+	return nil
+}
+
+// Throttle tells the client that the frame it just sent was not delivered
+// because it exceeded its quota, and when it may try again. It is sent as the
+// payload of a THROTTLE action.
+type Throttle struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Scope        Throttle_Scope `protobuf:"varint,1,opt,name=scope,proto3,enum=dispatch.Throttle_Scope" json:"scope,omitempty"`
+	RetryAfterMs int64          `protobuf:"varint,2,opt,name=retry_after_ms,json=retryAfterMs,proto3" json:"retry_after_ms,omitempty"`
+}
+
+func (x *Throttle) Reset() {
+	*x = Throttle{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_dispatch_proto_msgTypes[22]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *Throttle) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Throttle) ProtoMessage() {}
+
+func (x *Throttle) ProtoReflect() protoreflect.Message {
+	mi := &file_dispatch_proto_msgTypes[22]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Throttle.ProtoReflect.Descriptor instead.
+func (*Throttle) Descriptor() ([]byte, []int) {
+	return file_dispatch_proto_rawDescGZIP(), []int{22}
+}
+
+func (x *Throttle) GetScope() Throttle_Scope {
+	if x != nil {
+		return x.Scope
+	}
+	return Throttle_USER
+}
+
+func (x *Throttle) GetRetryAfterMs() int64 {
+	if x != nil {
+		return x.RetryAfterMs
+	}
+	return 0
+}
+
+// GoAway is sent by the server side when it is shutting down, as the payload
+// of a GOAWAY action. Clients should finish what they are doing and reconnect
+// before the deadline passes.
+type GoAway struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Reason   string `protobuf:"bytes,1,opt,name=reason,proto3" json:"reason,omitempty"`
+	Deadline int64  `protobuf:"varint,2,opt,name=deadline,proto3" json:"deadline,omitempty"` // unix time after which the connection is closed
+}
+
+func (x *GoAway) Reset() {
+	*x = GoAway{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_dispatch_proto_msgTypes[23]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *GoAway) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GoAway) ProtoMessage() {}
+
+func (x *GoAway) ProtoReflect() protoreflect.Message {
+	mi := &file_dispatch_proto_msgTypes[23]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GoAway.ProtoReflect.Descriptor instead.
+func (*GoAway) Descriptor() ([]byte, []int) {
+	return file_dispatch_proto_rawDescGZIP(), []int{23}
+}
+
+func (x *GoAway) GetReason() string {
+	if x != nil {
+		return x.Reason
+	}
+	return ""
+}
+
+func (x *GoAway) GetDeadline() int64 {
+	if x != nil {
+		return x.Deadline
+	}
+	return 0
+}
+
+// SenderKeyDistribution hands the sender key a device uses for a channel to
+// the other members. It must only travel encrypted over pairwise sessions.
+type SenderKeyDistribution struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	ChannelId  uint64 `protobuf:"varint,1,opt,name=channel_id,json=channelId,proto3" json:"channel_id,omitempty"`
+	KeyId      uint32 `protobuf:"varint,2,opt,name=key_id,json=keyId,proto3" json:"key_id,omitempty"`
+	Iteration  uint32 `protobuf:"varint,3,opt,name=iteration,proto3" json:"iteration,omitempty"` // of chain_key
+	ChainKey   []byte `protobuf:"bytes,4,opt,name=chain_key,json=chainKey,proto3" json:"chain_key,omitempty"`
+	SigningKey []byte `protobuf:"bytes,5,opt,name=signing_key,json=signingKey,proto3" json:"signing_key,omitempty"` // ed25519 public key
+}
+
+func (x *SenderKeyDistribution) Reset() {
+	*x = SenderKeyDistribution{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_dispatch_proto_msgTypes[24]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *SenderKeyDistribution) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SenderKeyDistribution) ProtoMessage() {}
+
+func (x *SenderKeyDistribution) ProtoReflect() protoreflect.Message {
+	mi := &file_dispatch_proto_msgTypes[24]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SenderKeyDistribution.ProtoReflect.Descriptor instead.
+func (*SenderKeyDistribution) Descriptor() ([]byte, []int) {
+	return file_dispatch_proto_rawDescGZIP(), []int{24}
+}
+
+func (x *SenderKeyDistribution) GetChannelId() uint64 {
+	if x != nil {
+		return x.ChannelId
+	}
+	return 0
+}
+
+func (x *SenderKeyDistribution) GetKeyId() uint32 {
+	if x != nil {
+		return x.KeyId
+	}
+	return 0
+}
+
+func (x *SenderKeyDistribution) GetIteration() uint32 {
+	if x != nil {
+		return x.Iteration
+	}
+	return 0
+}
+
+func (x *SenderKeyDistribution) GetChainKey() []byte {
+	if x != nil {
+		return x.ChainKey
+	}
+	return nil
+}
+
+func (x *SenderKeyDistribution) GetSigningKey() []byte {
+	if x != nil {
+		return x.SigningKey
+	}
+	return nil
+}
+
+// SenderKeyMessage is a channel post encrypted once with the sender's sender
+// key. It is the payload of TRANSMISSION actions with a channel_id and no
+// copies.
+type SenderKeyMessage struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	KeyId      uint32 `protobuf:"varint,1,opt,name=key_id,json=keyId,proto3" json:"key_id,omitempty"`
+	Iteration  uint32 `protobuf:"varint,2,opt,name=iteration,proto3" json:"iteration,omitempty"`
+	Ciphertext []byte `protobuf:"bytes,3,opt,name=ciphertext,proto3" json:"ciphertext,omitempty"`
+	Signature  []byte `protobuf:"bytes,4,opt,name=signature,proto3" json:"signature,omitempty"` // ed25519 over the other fields and the channel id
+}
+
+func (x *SenderKeyMessage) Reset() {
+	*x = SenderKeyMessage{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_dispatch_proto_msgTypes[25]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *SenderKeyMessage) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SenderKeyMessage) ProtoMessage() {}
+
+func (x *SenderKeyMessage) ProtoReflect() protoreflect.Message {
+	mi := &file_dispatch_proto_msgTypes[25]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SenderKeyMessage.ProtoReflect.Descriptor instead.
+func (*SenderKeyMessage) Descriptor() ([]byte, []int) {
+	return file_dispatch_proto_rawDescGZIP(), []int{25}
+}
+
+func (x *SenderKeyMessage) GetKeyId() uint32 {
+	if x != nil {
+		return x.KeyId
+	}
+	return 0
+}
+
+func (x *SenderKeyMessage) GetIteration() uint32 {
+	if x != nil {
+		return x.Iteration
+	}
+	return 0
+}
+
+func (x *SenderKeyMessage) GetCiphertext() []byte {
+	if x != nil {
+		return x.Ciphertext
+	}
+	return nil
+}
+
+func (x *SenderKeyMessage) GetSignature() []byte {
+	if x != nil {
+		return x.Signature
+	}
+	return nil
+}
+
+// MlsMessage carries a message of the MLS protocol (RFC 9420) for a channel.
+// srvtls is the delivery service: it never looks inside data, but it orders
+// the handshake messages of each channel so that exactly one commit is
+// accepted per epoch.
+type MlsMessage struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Kind         MlsMessage_Kind   `protobuf:"varint,1,opt,name=kind,proto3,enum=dispatch.MlsMessage_Kind" json:"kind,omitempty"`
+	Epoch        uint64            `protobuf:"varint,2,opt,name=epoch,proto3" json:"epoch,omitempty"`                                   // epoch the message was created in
+	Data         []byte            `protobuf:"bytes,3,opt,name=data,proto3" json:"data,omitempty"`                                      // an MlsPublicMessage, MlsPrivateMessage or MlsWelcome of package mls
+	Result       MlsMessage_Result `protobuf:"varint,4,opt,name=result,proto3,enum=dispatch.MlsMessage_Result" json:"result,omitempty"` // set on answers from srvtls to the sender
+	CurrentEpoch uint64            `protobuf:"varint,5,opt,name=current_epoch,json=currentEpoch,proto3" json:"current_epoch,omitempty"` // set on answers from srvtls to the sender
+}
+
+func (x *MlsMessage) Reset() {
+	*x = MlsMessage{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_dispatch_proto_msgTypes[26]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *MlsMessage) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*MlsMessage) ProtoMessage() {}
+
+func (x *MlsMessage) ProtoReflect() protoreflect.Message {
+	mi := &file_dispatch_proto_msgTypes[26]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use MlsMessage.ProtoReflect.Descriptor instead.
+func (*MlsMessage) Descriptor() ([]byte, []int) {
+	return file_dispatch_proto_rawDescGZIP(), []int{26}
+}
+
+func (x *MlsMessage) GetKind() MlsMessage_Kind {
+	if x != nil {
+		return x.Kind
+	}
+	return MlsMessage_APPLICATION
+}
+
+func (x *MlsMessage) GetEpoch() uint64 {
+	if x != nil {
+		return x.Epoch
+	}
+	return 0
+}
+
+func (x *MlsMessage) GetData() []byte {
+	if x != nil {
+		return x.Data
+	}
+	return nil
+}
+
+func (x *MlsMessage) GetResult() MlsMessage_Result {
+	if x != nil {
+		return x.Result
+	}
+	return MlsMessage_NONE
+}
+
+func (x *MlsMessage) GetCurrentEpoch() uint64 {
+	if x != nil {
+		return x.CurrentEpoch
+	}
+	return 0
+}
+
+// MlsLeafNode is a device in the ratchet tree of a group.
+type MlsLeafNode struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	UserId        uint64 `protobuf:"varint,1,opt,name=user_id,json=userId,proto3" json:"user_id,omitempty"`
+	DeviceId      uint64 `protobuf:"varint,2,opt,name=device_id,json=deviceId,proto3" json:"device_id,omitempty"`
+	EncryptionKey []byte `protobuf:"bytes,3,opt,name=encryption_key,json=encryptionKey,proto3" json:"encryption_key,omitempty"` // HPKE key of the leaf
+	SignatureKey  []byte `protobuf:"bytes,4,opt,name=signature_key,json=signatureKey,proto3" json:"signature_key,omitempty"`    // the identity key the device registered with srvhttps
+	Signature     []byte `protobuf:"bytes,5,opt,name=signature,proto3" json:"signature,omitempty"`                              // by signature_key over the other fields
+}
+
+func (x *MlsLeafNode) Reset() {
+	*x = MlsLeafNode{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_dispatch_proto_msgTypes[27]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *MlsLeafNode) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*MlsLeafNode) ProtoMessage() {}
+
+func (x *MlsLeafNode) ProtoReflect() protoreflect.Message {
+	mi := &file_dispatch_proto_msgTypes[27]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use MlsLeafNode.ProtoReflect.Descriptor instead.
+func (*MlsLeafNode) Descriptor() ([]byte, []int) {
+	return file_dispatch_proto_rawDescGZIP(), []int{27}
+}
+
+func (x *MlsLeafNode) GetUserId() uint64 {
+	if x != nil {
+		return x.UserId
+	}
+	return 0
+}
+
+func (x *MlsLeafNode) GetDeviceId() uint64 {
+	if x != nil {
+		return x.DeviceId
+	}
+	return 0
+}
+
+func (x *MlsLeafNode) GetEncryptionKey() []byte {
+	if x != nil {
+		return x.EncryptionKey
+	}
+	return nil
+}
+
+func (x *MlsLeafNode) GetSignatureKey() []byte {
+	if x != nil {
+		return x.SignatureKey
+	}
+	return nil
+}
+
+func (x *MlsLeafNode) GetSignature() []byte {
+	if x != nil {
+		return x.Signature
+	}
+	return nil
+}
+
+// MlsKeyPackage lets a device be added to a group while it is offline. Each
+// one is used once: init_key encrypts the Welcome to it.
+type MlsKeyPackage struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Leaf      *MlsLeafNode `protobuf:"bytes,1,opt,name=leaf,proto3" json:"leaf,omitempty"`
+	InitKey   []byte       `protobuf:"bytes,2,opt,name=init_key,json=initKey,proto3" json:"init_key,omitempty"`
+	Signature []byte       `protobuf:"bytes,3,opt,name=signature,proto3" json:"signature,omitempty"` // by the leaf's signature_key over leaf and init_key
+}
+
+func (x *MlsKeyPackage) Reset() {
+	*x = MlsKeyPackage{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_dispatch_proto_msgTypes[28]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *MlsKeyPackage) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*MlsKeyPackage) ProtoMessage() {}
+
+func (x *MlsKeyPackage) ProtoReflect() protoreflect.Message {
+	mi := &file_dispatch_proto_msgTypes[28]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use MlsKeyPackage.ProtoReflect.Descriptor instead.
+func (*MlsKeyPackage) Descriptor() ([]byte, []int) {
+	return file_dispatch_proto_rawDescGZIP(), []int{28}
+}
+
+func (x *MlsKeyPackage) GetLeaf() *MlsLeafNode {
+	if x != nil {
+		return x.Leaf
+	}
+	return nil
+}
+
+func (x *MlsKeyPackage) GetInitKey() []byte {
+	if x != nil {
+		return x.InitKey
+	}
+	return nil
+}
+
+func (x *MlsKeyPackage) GetSignature() []byte {
+	if x != nil {
+		return x.Signature
+	}
+	return nil
+}
+
+// MlsParentNode is an inner node of the ratchet tree.
+type MlsParentNode struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	EncryptionKey  []byte   `protobuf:"bytes,1,opt,name=encryption_key,json=encryptionKey,proto3" json:"encryption_key,omitempty"`
+	UnmergedLeaves []uint32 `protobuf:"varint,2,rep,packed,name=unmerged_leaves,json=unmergedLeaves,proto3" json:"unmerged_leaves,omitempty"` // leaves added since the key was set, which do not know it
+}
+
+func (x *MlsParentNode) Reset() {
+	*x = MlsParentNode{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_dispatch_proto_msgTypes[29]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *MlsParentNode) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*MlsParentNode) ProtoMessage() {}
+
+func (x *MlsParentNode) ProtoReflect() protoreflect.Message {
+	mi := &file_dispatch_proto_msgTypes[29]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use MlsParentNode.ProtoReflect.Descriptor instead.
+func (*MlsParentNode) Descriptor() ([]byte, []int) {
+	return file_dispatch_proto_rawDescGZIP(), []int{29}
+}
+
+func (x *MlsParentNode) GetEncryptionKey() []byte {
+	if x != nil {
+		return x.EncryptionKey
+	}
+	return nil
+}
+
+func (x *MlsParentNode) GetUnmergedLeaves() []uint32 {
+	if x != nil {
+		return x.UnmergedLeaves
+	}
+	return nil
+}
+
+// MlsNode is a node of the ratchet tree in array order, blank if neither is
+// set.
+type MlsNode struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Leaf   *MlsLeafNode   `protobuf:"bytes,1,opt,name=leaf,proto3" json:"leaf,omitempty"`
+	Parent *MlsParentNode `protobuf:"bytes,2,opt,name=parent,proto3" json:"parent,omitempty"`
+}
+
+func (x *MlsNode) Reset() {
+	*x = MlsNode{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_dispatch_proto_msgTypes[30]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *MlsNode) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*MlsNode) ProtoMessage() {}
+
+func (x *MlsNode) ProtoReflect() protoreflect.Message {
+	mi := &file_dispatch_proto_msgTypes[30]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use MlsNode.ProtoReflect.Descriptor instead.
+func (*MlsNode) Descriptor() ([]byte, []int) {
+	return file_dispatch_proto_rawDescGZIP(), []int{30}
+}
+
+func (x *MlsNode) GetLeaf() *MlsLeafNode {
+	if x != nil {
+		return x.Leaf
+	}
+	return nil
+}
+
+func (x *MlsNode) GetParent() *MlsParentNode {
+	if x != nil {
+		return x.Parent
+	}
+	return nil
+}
+
+// MlsHpkeCiphertext is a message encrypted with HPKE (RFC 9180) in base
+// mode with DHKEM(P-256, HKDF-SHA256), HKDF-SHA256 and AES-128-GCM.
+type MlsHpkeCiphertext struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	KemOutput  []byte `protobuf:"bytes,1,opt,name=kem_output,json=kemOutput,proto3" json:"kem_output,omitempty"`
+	Ciphertext []byte `protobuf:"bytes,2,opt,name=ciphertext,proto3" json:"ciphertext,omitempty"`
+}
+
+func (x *MlsHpkeCiphertext) Reset() {
+	*x = MlsHpkeCiphertext{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_dispatch_proto_msgTypes[31]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *MlsHpkeCiphertext) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*MlsHpkeCiphertext) ProtoMessage() {}
+
+func (x *MlsHpkeCiphertext) ProtoReflect() protoreflect.Message {
+	mi := &file_dispatch_proto_msgTypes[31]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use MlsHpkeCiphertext.ProtoReflect.Descriptor instead.
+func (*MlsHpkeCiphertext) Descriptor() ([]byte, []int) {
+	return file_dispatch_proto_rawDescGZIP(), []int{31}
+}
+
+func (x *MlsHpkeCiphertext) GetKemOutput() []byte {
+	if x != nil {
+		return x.KemOutput
+	}
+	return nil
+}
+
+func (x *MlsHpkeCiphertext) GetCiphertext() []byte {
+	if x != nil {
+		return x.Ciphertext
+	}
+	return nil
+}
+
+// MlsProposal changes the membership of a group. Proposals travel inside the
+// commit that applies them.
+type MlsProposal struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// Types that are assignable to Proposal:
+	//	*MlsProposal_Add
+	//	*MlsProposal_Remove
+	Proposal isMlsProposal_Proposal `protobuf_oneof:"proposal"`
+}
+
+func (x *MlsProposal) Reset() {
+	*x = MlsProposal{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_dispatch_proto_msgTypes[32]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *MlsProposal) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*MlsProposal) ProtoMessage() {}
+
+func (x *MlsProposal) ProtoReflect() protoreflect.Message {
+	mi := &file_dispatch_proto_msgTypes[32]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use MlsProposal.ProtoReflect.Descriptor instead.
+func (*MlsProposal) Descriptor() ([]byte, []int) {
+	return file_dispatch_proto_rawDescGZIP(), []int{32}
+}
+
+func (m *MlsProposal) GetProposal() isMlsProposal_Proposal {
+	if m != nil {
+		return m.Proposal
+	}
+	return nil
+}
+
+func (x *MlsProposal) GetAdd() *MlsKeyPackage {
+	if x, ok := x.GetProposal().(*MlsProposal_Add); ok {
+		return x.Add
+	}
+	return nil
+}
+
+func (x *MlsProposal) GetRemove() uint32 {
+	if x, ok := x.GetProposal().(*MlsProposal_Remove); ok {
+		return x.Remove
+	}
+	return 0
+}
+
+type isMlsProposal_Proposal interface {
+	isMlsProposal_Proposal()
+}
+
+type MlsProposal_Add struct {
+	Add *MlsKeyPackage `protobuf:"bytes,1,opt,name=add,proto3,oneof"`
+}
+
+type MlsProposal_Remove struct {
+	Remove uint32 `protobuf:"varint,2,opt,name=remove,proto3,oneof"` // leaf index
+}
+
+func (*MlsProposal_Add) isMlsProposal_Proposal() {}
+
+func (*MlsProposal_Remove) isMlsProposal_Proposal() {}
+
+// MlsUpdatePathNode is the new public key of a node on the committer's
+// filtered direct path, with its path secret encrypted to every node in the
+// resolution of the copath child.
+type MlsUpdatePathNode struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	EncryptionKey       []byte               `protobuf:"bytes,1,opt,name=encryption_key,json=encryptionKey,proto3" json:"encryption_key,omitempty"`
+	EncryptedPathSecret []*MlsHpkeCiphertext `protobuf:"bytes,2,rep,name=encrypted_path_secret,json=encryptedPathSecret,proto3" json:"encrypted_path_secret,omitempty"`
+}
+
+func (x *MlsUpdatePathNode) Reset() {
+	*x = MlsUpdatePathNode{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_dispatch_proto_msgTypes[33]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *MlsUpdatePathNode) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*MlsUpdatePathNode) ProtoMessage() {}
+
+func (x *MlsUpdatePathNode) ProtoReflect() protoreflect.Message {
+	mi := &file_dispatch_proto_msgTypes[33]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use MlsUpdatePathNode.ProtoReflect.Descriptor instead.
+func (*MlsUpdatePathNode) Descriptor() ([]byte, []int) {
+	return file_dispatch_proto_rawDescGZIP(), []int{33}
+}
+
+func (x *MlsUpdatePathNode) GetEncryptionKey() []byte {
+	if x != nil {
+		return x.EncryptionKey
+	}
+	return nil
+}
+
+func (x *MlsUpdatePathNode) GetEncryptedPathSecret() []*MlsHpkeCiphertext {
+	if x != nil {
+		return x.EncryptedPathSecret
+	}
+	return nil
+}
+
+type MlsUpdatePath struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Leaf  *MlsLeafNode         `protobuf:"bytes,1,opt,name=leaf,proto3" json:"leaf,omitempty"` // the committer's new leaf
+	Nodes []*MlsUpdatePathNode `protobuf:"bytes,2,rep,name=nodes,proto3" json:"nodes,omitempty"`
+}
+
+func (x *MlsUpdatePath) Reset() {
+	*x = MlsUpdatePath{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_dispatch_proto_msgTypes[34]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *MlsUpdatePath) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*MlsUpdatePath) ProtoMessage() {}
+
+func (x *MlsUpdatePath) ProtoReflect() protoreflect.Message {
+	mi := &file_dispatch_proto_msgTypes[34]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use MlsUpdatePath.ProtoReflect.Descriptor instead.
+func (*MlsUpdatePath) Descriptor() ([]byte, []int) {
+	return file_dispatch_proto_rawDescGZIP(), []int{34}
+}
+
+func (x *MlsUpdatePath) GetLeaf() *MlsLeafNode {
+	if x != nil {
+		return x.Leaf
+	}
+	return nil
+}
+
+func (x *MlsUpdatePath) GetNodes() []*MlsUpdatePathNode {
+	if x != nil {
+		return x.Nodes
+	}
+	return nil
+}
+
+// MlsCommit applies proposals and gives the committer a fresh path, which
+// starts the next epoch.
+type MlsCommit struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Proposals []*MlsProposal `protobuf:"bytes,1,rep,name=proposals,proto3" json:"proposals,omitempty"`
+	Path      *MlsUpdatePath `protobuf:"bytes,2,opt,name=path,proto3" json:"path,omitempty"`
+}
+
+func (x *MlsCommit) Reset() {
+	*x = MlsCommit{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_dispatch_proto_msgTypes[35]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *MlsCommit) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*MlsCommit) ProtoMessage() {}
+
+func (x *MlsCommit) ProtoReflect() protoreflect.Message {
+	mi := &file_dispatch_proto_msgTypes[35]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use MlsCommit.ProtoReflect.Descriptor instead.
+func (*MlsCommit) Descriptor() ([]byte, []int) {
+	return file_dispatch_proto_rawDescGZIP(), []int{35}
+}
+
+func (x *MlsCommit) GetProposals() []*MlsProposal {
+	if x != nil {
+		return x.Proposals
+	}
+	return nil
+}
+
+func (x *MlsCommit) GetPath() *MlsUpdatePath {
+	if x != nil {
+		return x.Path
+	}
+	return nil
+}
+
+// MlsPublicMessage carries a commit in the clear, so that srvtls could check
+// it, authenticated by the committer and the members of the epoch.
+type MlsPublicMessage struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	GroupId         []byte `protobuf:"bytes,1,opt,name=group_id,json=groupId,proto3" json:"group_id,omitempty"`
+	Epoch           uint64 `protobuf:"varint,2,opt,name=epoch,proto3" json:"epoch,omitempty"`
+	Sender          uint32 `protobuf:"varint,3,opt,name=sender,proto3" json:"sender,omitempty"` // leaf index
+	Commit          []byte `protobuf:"bytes,4,opt,name=commit,proto3" json:"commit,omitempty"`  // encoded MlsCommit, as signed
+	Signature       []byte `protobuf:"bytes,5,opt,name=signature,proto3" json:"signature,omitempty"`
+	ConfirmationTag []byte `protobuf:"bytes,6,opt,name=confirmation_tag,json=confirmationTag,proto3" json:"confirmation_tag,omitempty"` // proves the committer knows the new epoch
+	MembershipTag   []byte `protobuf:"bytes,7,opt,name=membership_tag,json=membershipTag,proto3" json:"membership_tag,omitempty"`       // proves the committer is a member of this epoch
+}
+
+func (x *MlsPublicMessage) Reset() {
+	*x = MlsPublicMessage{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_dispatch_proto_msgTypes[36]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *MlsPublicMessage) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*MlsPublicMessage) ProtoMessage() {}
+
+func (x *MlsPublicMessage) ProtoReflect() protoreflect.Message {
+	mi := &file_dispatch_proto_msgTypes[36]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use MlsPublicMessage.ProtoReflect.Descriptor instead.
+func (*MlsPublicMessage) Descriptor() ([]byte, []int) {
+	return file_dispatch_proto_rawDescGZIP(), []int{36}
+}
+
+func (x *MlsPublicMessage) GetGroupId() []byte {
+	if x != nil {
+		return x.GroupId
+	}
+	return nil
+}
+
+func (x *MlsPublicMessage) GetEpoch() uint64 {
+	if x != nil {
+		return x.Epoch
+	}
+	return 0
+}
+
+func (x *MlsPublicMessage) GetSender() uint32 {
+	if x != nil {
+		return x.Sender
+	}
+	return 0
+}
+
+func (x *MlsPublicMessage) GetCommit() []byte {
+	if x != nil {
+		return x.Commit
+	}
+	return nil
+}
+
+func (x *MlsPublicMessage) GetSignature() []byte {
+	if x != nil {
+		return x.Signature
+	}
+	return nil
+}
+
+func (x *MlsPublicMessage) GetConfirmationTag() []byte {
+	if x != nil {
+		return x.ConfirmationTag
+	}
+	return nil
+}
+
+func (x *MlsPublicMessage) GetMembershipTag() []byte {
+	if x != nil {
+		return x.MembershipTag
+	}
+	return nil
+}
+
+// MlsPrivateMessage is an application message, encrypted with a key of the
+// sender's ratchet in the secret tree of the epoch. The sender and the
+// generation of the key are encrypted separately under the sender data
+// secret.
+type MlsPrivateMessage struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	GroupId             []byte `protobuf:"bytes,1,opt,name=group_id,json=groupId,proto3" json:"group_id,omitempty"`
+	Epoch               uint64 `protobuf:"varint,2,opt,name=epoch,proto3" json:"epoch,omitempty"`
+	SenderDataNonce     []byte `protobuf:"bytes,3,opt,name=sender_data_nonce,json=senderDataNonce,proto3" json:"sender_data_nonce,omitempty"`
+	EncryptedSenderData []byte `protobuf:"bytes,4,opt,name=encrypted_sender_data,json=encryptedSenderData,proto3" json:"encrypted_sender_data,omitempty"`
+	Ciphertext          []byte `protobuf:"bytes,5,opt,name=ciphertext,proto3" json:"ciphertext,omitempty"` // an MlsApplicationContent
+}
+
+func (x *MlsPrivateMessage) Reset() {
+	*x = MlsPrivateMessage{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_dispatch_proto_msgTypes[37]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *MlsPrivateMessage) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*MlsPrivateMessage) ProtoMessage() {}
+
+func (x *MlsPrivateMessage) ProtoReflect() protoreflect.Message {
+	mi := &file_dispatch_proto_msgTypes[37]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use MlsPrivateMessage.ProtoReflect.Descriptor instead.
+func (*MlsPrivateMessage) Descriptor() ([]byte, []int) {
+	return file_dispatch_proto_rawDescGZIP(), []int{37}
+}
+
+func (x *MlsPrivateMessage) GetGroupId() []byte {
+	if x != nil {
+		return x.GroupId
+	}
+	return nil
+}
+
+func (x *MlsPrivateMessage) GetEpoch() uint64 {
+	if x != nil {
+		return x.Epoch
+	}
+	return 0
+}
+
+func (x *MlsPrivateMessage) GetSenderDataNonce() []byte {
+	if x != nil {
+		return x.SenderDataNonce
+	}
+	return nil
+}
+
+func (x *MlsPrivateMessage) GetEncryptedSenderData() []byte {
+	if x != nil {
+		return x.EncryptedSenderData
+	}
+	return nil
+}
+
+func (x *MlsPrivateMessage) GetCiphertext() []byte {
+	if x != nil {
+		return x.Ciphertext
+	}
+	return nil
+}
+
+type MlsApplicationContent struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Data      []byte `protobuf:"bytes,1,opt,name=data,proto3" json:"data,omitempty"`
+	Signature []byte `protobuf:"bytes,2,opt,name=signature,proto3" json:"signature,omitempty"` // by the sender's signature key
+}
+
+func (x *MlsApplicationContent) Reset() {
+	*x = MlsApplicationContent{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_dispatch_proto_msgTypes[38]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *MlsApplicationContent) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*MlsApplicationContent) ProtoMessage() {}
+
+func (x *MlsApplicationContent) ProtoReflect() protoreflect.Message {
+	mi := &file_dispatch_proto_msgTypes[38]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use MlsApplicationContent.ProtoReflect.Descriptor instead.
+func (*MlsApplicationContent) Descriptor() ([]byte, []int) {
+	return file_dispatch_proto_rawDescGZIP(), []int{38}
+}
+
+func (x *MlsApplicationContent) GetData() []byte {
+	if x != nil {
+		return x.Data
+	}
+	return nil
+}
+
+func (x *MlsApplicationContent) GetSignature() []byte {
+	if x != nil {
+		return x.Signature
+	}
+	return nil
+}
+
+// MlsGroupInfo is what a new member needs to know about the epoch it joins.
+type MlsGroupInfo struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	GroupId                 []byte     `protobuf:"bytes,1,opt,name=group_id,json=groupId,proto3" json:"group_id,omitempty"`
+	Epoch                   uint64     `protobuf:"varint,2,opt,name=epoch,proto3" json:"epoch,omitempty"`
+	Tree                    []*MlsNode `protobuf:"bytes,3,rep,name=tree,proto3" json:"tree,omitempty"`
+	ConfirmedTranscriptHash []byte     `protobuf:"bytes,4,opt,name=confirmed_transcript_hash,json=confirmedTranscriptHash,proto3" json:"confirmed_transcript_hash,omitempty"`
+	ConfirmationTag         []byte     `protobuf:"bytes,5,opt,name=confirmation_tag,json=confirmationTag,proto3" json:"confirmation_tag,omitempty"`
+	Signer                  uint32     `protobuf:"varint,6,opt,name=signer,proto3" json:"signer,omitempty"` // leaf index of the committer
+	Signature               []byte     `protobuf:"bytes,7,opt,name=signature,proto3" json:"signature,omitempty"`
+}
+
+func (x *MlsGroupInfo) Reset() {
+	*x = MlsGroupInfo{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_dispatch_proto_msgTypes[39]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *MlsGroupInfo) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*MlsGroupInfo) ProtoMessage() {}
+
+func (x *MlsGroupInfo) ProtoReflect() protoreflect.Message {
+	mi := &file_dispatch_proto_msgTypes[39]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use MlsGroupInfo.ProtoReflect.Descriptor instead.
+func (*MlsGroupInfo) Descriptor() ([]byte, []int) {
+	return file_dispatch_proto_rawDescGZIP(), []int{39}
+}
+
+func (x *MlsGroupInfo) GetGroupId() []byte {
+	if x != nil {
+		return x.GroupId
+	}
+	return nil
+}
+
+func (x *MlsGroupInfo) GetEpoch() uint64 {
+	if x != nil {
+		return x.Epoch
+	}
+	return 0
+}
+
+func (x *MlsGroupInfo) GetTree() []*MlsNode {
+	if x != nil {
+		return x.Tree
+	}
+	return nil
+}
+
+func (x *MlsGroupInfo) GetConfirmedTranscriptHash() []byte {
+	if x != nil {
+		return x.ConfirmedTranscriptHash
+	}
+	return nil
+}
+
+func (x *MlsGroupInfo) GetConfirmationTag() []byte {
+	if x != nil {
+		return x.ConfirmationTag
+	}
+	return nil
+}
+
+func (x *MlsGroupInfo) GetSigner() uint32 {
+	if x != nil {
+		return x.Signer
+	}
+	return 0
+}
+
+func (x *MlsGroupInfo) GetSignature() []byte {
+	if x != nil {
+		return x.Signature
+	}
+	return nil
+}
+
+// MlsGroupSecrets is encrypted to the init key of one new member.
+type MlsGroupSecrets struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	JoinerSecret []byte `protobuf:"bytes,1,opt,name=joiner_secret,json=joinerSecret,proto3" json:"joiner_secret,omitempty"`
+	PathSecret   []byte `protobuf:"bytes,2,opt,name=path_secret,json=pathSecret,proto3" json:"path_secret,omitempty"` // of the lowest node the member shares with the committer
+}
+
+func (x *MlsGroupSecrets) Reset() {
+	*x = MlsGroupSecrets{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_dispatch_proto_msgTypes[40]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *MlsGroupSecrets) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*MlsGroupSecrets) ProtoMessage() {}
+
+func (x *MlsGroupSecrets) ProtoReflect() protoreflect.Message {
+	mi := &file_dispatch_proto_msgTypes[40]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use MlsGroupSecrets.ProtoReflect.Descriptor instead.
+func (*MlsGroupSecrets) Descriptor() ([]byte, []int) {
+	return file_dispatch_proto_rawDescGZIP(), []int{40}
+}
+
+func (x *MlsGroupSecrets) GetJoinerSecret() []byte {
+	if x != nil {
+		return x.JoinerSecret
+	}
+	return nil
+}
+
+func (x *MlsGroupSecrets) GetPathSecret() []byte {
+	if x != nil {
+		return x.PathSecret
+	}
+	return nil
+}
+
+type MlsEncryptedGroupSecrets struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	KeyPackageRef         []byte             `protobuf:"bytes,1,opt,name=key_package_ref,json=keyPackageRef,proto3" json:"key_package_ref,omitempty"` // SHA-256 of the key package's init key
+	EncryptedGroupSecrets *MlsHpkeCiphertext `protobuf:"bytes,2,opt,name=encrypted_group_secrets,json=encryptedGroupSecrets,proto3" json:"encrypted_group_secrets,omitempty"`
+}
+
+func (x *MlsEncryptedGroupSecrets) Reset() {
+	*x = MlsEncryptedGroupSecrets{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_dispatch_proto_msgTypes[41]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *MlsEncryptedGroupSecrets) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*MlsEncryptedGroupSecrets) ProtoMessage() {}
+
+func (x *MlsEncryptedGroupSecrets) ProtoReflect() protoreflect.Message {
+	mi := &file_dispatch_proto_msgTypes[41]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use MlsEncryptedGroupSecrets.ProtoReflect.Descriptor instead.
+func (*MlsEncryptedGroupSecrets) Descriptor() ([]byte, []int) {
+	return file_dispatch_proto_rawDescGZIP(), []int{41}
+}
+
+func (x *MlsEncryptedGroupSecrets) GetKeyPackageRef() []byte {
+	if x != nil {
+		return x.KeyPackageRef
+	}
+	return nil
+}
+
+func (x *MlsEncryptedGroupSecrets) GetEncryptedGroupSecrets() *MlsHpkeCiphertext {
+	if x != nil {
+		return x.EncryptedGroupSecrets
+	}
+	return nil
+}
+
+// MlsWelcome adds new members to a group. The group info is encrypted with
+// the welcome secret, which every new member derives from its group secrets.
+type MlsWelcome struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Secrets            []*MlsEncryptedGroupSecrets `protobuf:"bytes,1,rep,name=secrets,proto3" json:"secrets,omitempty"`
+	EncryptedGroupInfo []byte                      `protobuf:"bytes,2,opt,name=encrypted_group_info,json=encryptedGroupInfo,proto3" json:"encrypted_group_info,omitempty"`
+}
+
+func (x *MlsWelcome) Reset() {
+	*x = MlsWelcome{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_dispatch_proto_msgTypes[42]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *MlsWelcome) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*MlsWelcome) ProtoMessage() {}
+
+func (x *MlsWelcome) ProtoReflect() protoreflect.Message {
+	mi := &file_dispatch_proto_msgTypes[42]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use MlsWelcome.ProtoReflect.Descriptor instead.
+func (*MlsWelcome) Descriptor() ([]byte, []int) {
+	return file_dispatch_proto_rawDescGZIP(), []int{42}
+}
+
+func (x *MlsWelcome) GetSecrets() []*MlsEncryptedGroupSecrets {
+	if x != nil {
+		return x.Secrets
+	}
+	return nil
+}
+
+func (x *MlsWelcome) GetEncryptedGroupInfo() []byte {
+	if x != nil {
+		return x.EncryptedGroupInfo
+	}
+	return nil
+}
+
+// SenderCertificate is issued by srvhttps to a device. It travels inside
+// sealed envelopes so that only the recipient learns who the sender is.
+type SenderCertificate struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	UserId      uint64 `protobuf:"varint,1,opt,name=user_id,json=userId,proto3" json:"user_id,omitempty"`
+	DeviceId    uint64 `protobuf:"varint,2,opt,name=device_id,json=deviceId,proto3" json:"device_id,omitempty"`
+	IdentityKey []byte `protobuf:"bytes,3,opt,name=identity_key,json=identityKey,proto3" json:"identity_key,omitempty"` // ed25519 public key of the device
+	Expires     int64  `protobuf:"varint,4,opt,name=expires,proto3" json:"expires,omitempty"`                           // unix time
+	Signature   []byte `protobuf:"bytes,5,opt,name=signature,proto3" json:"signature,omitempty"`                        // ed25519 by the server's sender certificate key
+}
+
+func (x *SenderCertificate) Reset() {
+	*x = SenderCertificate{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_dispatch_proto_msgTypes[43]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *SenderCertificate) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SenderCertificate) ProtoMessage() {}
+
+func (x *SenderCertificate) ProtoReflect() protoreflect.Message {
+	mi := &file_dispatch_proto_msgTypes[43]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SenderCertificate.ProtoReflect.Descriptor instead.
+func (*SenderCertificate) Descriptor() ([]byte, []int) {
+	return file_dispatch_proto_rawDescGZIP(), []int{43}
+}
+
+func (x *SenderCertificate) GetUserId() uint64 {
+	if x != nil {
+		return x.UserId
+	}
+	return 0
+}
+
+func (x *SenderCertificate) GetDeviceId() uint64 {
+	if x != nil {
+		return x.DeviceId
+	}
+	return 0
+}
+
+func (x *SenderCertificate) GetIdentityKey() []byte {
+	if x != nil {
+		return x.IdentityKey
+	}
+	return nil
+}
+
+func (x *SenderCertificate) GetExpires() int64 {
+	if x != nil {
+		return x.Expires
+	}
+	return 0
+}
+
+func (x *SenderCertificate) GetSignature() []byte {
+	if x != nil {
+		return x.Signature
+	}
+	return nil
+}
+
+// SealedEnvelope is encrypted to the sealing key of one device with an
+// ephemeral P-256 key.
+type SealedEnvelope struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	EphemeralKey []byte `protobuf:"bytes,1,opt,name=ephemeral_key,json=ephemeralKey,proto3" json:"ephemeral_key,omitempty"` // uncompressed P-256 point
+	Ciphertext   []byte `protobuf:"bytes,2,opt,name=ciphertext,proto3" json:"ciphertext,omitempty"`                         // AES-256-GCM of a SealedContent
+}
+
+func (x *SealedEnvelope) Reset() {
+	*x = SealedEnvelope{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_dispatch_proto_msgTypes[44]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *SealedEnvelope) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SealedEnvelope) ProtoMessage() {}
+
+func (x *SealedEnvelope) ProtoReflect() protoreflect.Message {
+	mi := &file_dispatch_proto_msgTypes[44]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SealedEnvelope.ProtoReflect.Descriptor instead.
+func (*SealedEnvelope) Descriptor() ([]byte, []int) {
+	return file_dispatch_proto_rawDescGZIP(), []int{44}
+}
+
+func (x *SealedEnvelope) GetEphemeralKey() []byte {
+	if x != nil {
+		return x.EphemeralKey
+	}
+	return nil
+}
+
+func (x *SealedEnvelope) GetCiphertext() []byte {
+	if x != nil {
+		return x.Ciphertext
+	}
+	return nil
+}
+
+// SealedContent is what the recipient finds in a SealedEnvelope.
+type SealedContent struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Certificate *SenderCertificate `protobuf:"bytes,1,opt,name=certificate,proto3" json:"certificate,omitempty"`
+	Payload     []byte             `protobuf:"bytes,2,opt,name=payload,proto3" json:"payload,omitempty"`
+	Signature   []byte             `protobuf:"bytes,3,opt,name=signature,proto3" json:"signature,omitempty"` // by the sender's identity key, binds payload to the envelope
+}
+
+func (x *SealedContent) Reset() {
+	*x = SealedContent{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_dispatch_proto_msgTypes[45]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *SealedContent) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SealedContent) ProtoMessage() {}
+
+func (x *SealedContent) ProtoReflect() protoreflect.Message {
+	mi := &file_dispatch_proto_msgTypes[45]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SealedContent.ProtoReflect.Descriptor instead.
+func (*SealedContent) Descriptor() ([]byte, []int) {
+	return file_dispatch_proto_rawDescGZIP(), []int{45}
+}
+
+func (x *SealedContent) GetCertificate() *SenderCertificate {
+	if x != nil {
+		return x.Certificate
+	}
+	return nil
+}
+
+func (x *SealedContent) GetPayload() []byte {
+	if x != nil {
+		return x.Payload
+	}
+	return nil
+}
+
+func (x *SealedContent) GetSignature() []byte {
+	if x != nil {
+		return x.Signature
+	}
+	return nil
+}
+
+type Rule struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Ip     string `protobuf:"bytes,1,opt,name=ip,proto3" json:"ip,omitempty"`
+	Port   string `protobuf:"bytes,2,opt,name=port,proto3" json:"port,omitempty"`
+	Insert bool   `protobuf:"varint,3,opt,name=insert,proto3" json:"insert,omitempty"`
+	Id     uint64 `protobuf:"varint,4,opt,name=id,proto3" json:"id,omitempty"` // echoed as message_id in the Error when the rule fails
+}
+
+func (x *Rule) Reset() {
+	*x = Rule{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_dispatch_proto_msgTypes[46]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *Rule) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Rule) ProtoMessage() {}
+
+func (x *Rule) ProtoReflect() protoreflect.Message {
+	mi := &file_dispatch_proto_msgTypes[46]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Rule.ProtoReflect.Descriptor instead.
+func (*Rule) Descriptor() ([]byte, []int) {
+	return file_dispatch_proto_rawDescGZIP(), []int{46}
+}
+
+func (x *Rule) GetIp() string {
+	if x != nil {
+		return x.Ip
+	}
+	return ""
+}
+
+func (x *Rule) GetPort() string {
+	if x != nil {
+		return x.Port
+	}
+	return ""
+}
+
+func (x *Rule) GetInsert() bool {
+	if x != nil {
+		return x.Insert
+	}
+	return false
+}
+
+func (x *Rule) GetId() uint64 {
+	if x != nil {
+		return x.Id
+	}
+	return 0
+}
+
+var File_dispatch_proto protoreflect.FileDescriptor
+
+var file_dispatch_proto_rawDesc = []byte{
+	0x0a, 0x0e, 0x64, 0x69, 0x73, 0x70, 0x61, 0x74, 0x63, 0x68, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f,
+	0x12, 0x08, 0x64, 0x69, 0x73, 0x70, 0x61, 0x74, 0x63, 0x68, 0x22, 0xf6, 0x02, 0x0a, 0x07, 0x50,
+	0x61, 0x79, 0x6c, 0x6f, 0x61, 0x64, 0x12, 0x1b, 0x0a, 0x09, 0x73, 0x65, 0x6e, 0x64, 0x65, 0x72,
+	0x5f, 0x69, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x04, 0x52, 0x08, 0x73, 0x65, 0x6e, 0x64, 0x65,
+	0x72, 0x49, 0x64, 0x12, 0x12, 0x0a, 0x04, 0x74, 0x65, 0x78, 0x74, 0x18, 0x02, 0x20, 0x01, 0x28,
+	0x0c, 0x52, 0x04, 0x74, 0x65, 0x78, 0x74, 0x12, 0x14, 0x0a, 0x05, 0x69, 0x6d, 0x61, 0x67, 0x65,
+	0x18, 0x03, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x05, 0x69, 0x6d, 0x61, 0x67, 0x65, 0x12, 0x1c, 0x0a,
+	0x09, 0x74, 0x69, 0x6d, 0x65, 0x73, 0x74, 0x61, 0x6d, 0x70, 0x18, 0x04, 0x20, 0x01, 0x28, 0x03,
+	0x52, 0x09, 0x74, 0x69, 0x6d, 0x65, 0x73, 0x74, 0x61, 0x6d, 0x70, 0x12, 0x36, 0x0a, 0x0b, 0x61,
+	0x74, 0x74, 0x61, 0x63, 0x68, 0x6d, 0x65, 0x6e, 0x74, 0x73, 0x18, 0x05, 0x20, 0x03, 0x28, 0x0b,
+	0x32, 0x14, 0x2e, 0x64, 0x69, 0x73, 0x70, 0x61, 0x74, 0x63, 0x68, 0x2e, 0x41, 0x74, 0x74, 0x61,
+	0x63, 0x68, 0x6d, 0x65, 0x6e, 0x74, 0x52, 0x0b, 0x61, 0x74, 0x74, 0x61, 0x63, 0x68, 0x6d, 0x65,
+	0x6e, 0x74, 0x73, 0x12, 0x1d, 0x0a, 0x0a, 0x6d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x5f, 0x69,
+	0x64, 0x18, 0x06, 0x20, 0x01, 0x28, 0x04, 0x52, 0x09, 0x6d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65,
+	0x49, 0x64, 0x12, 0x21, 0x0a, 0x0c, 0x65, 0x78, 0x70, 0x69, 0x72, 0x65, 0x5f, 0x74, 0x69, 0x6d,
+	0x65, 0x72, 0x18, 0x0a, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x0b, 0x65, 0x78, 0x70, 0x69, 0x72, 0x65,
+	0x54, 0x69, 0x6d, 0x65, 0x72, 0x12, 0x24, 0x0a, 0x04, 0x65, 0x64, 0x69, 0x74, 0x18, 0x07, 0x20,
+	0x01, 0x28, 0x0b, 0x32, 0x0e, 0x2e, 0x64, 0x69, 0x73, 0x70, 0x61, 0x74, 0x63, 0x68, 0x2e, 0x45,
+	0x64, 0x69, 0x74, 0x48, 0x00, 0x52, 0x04, 0x65, 0x64, 0x69, 0x74, 0x12, 0x2a, 0x0a, 0x06, 0x64,
+	0x65, 0x6c, 0x65, 0x74, 0x65, 0x18, 0x08, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x10, 0x2e, 0x64, 0x69,
+	0x73, 0x70, 0x61, 0x74, 0x63, 0x68, 0x2e, 0x44, 0x65, 0x6c, 0x65, 0x74, 0x65, 0x48, 0x00, 0x52,
+	0x06, 0x64, 0x65, 0x6c, 0x65, 0x74, 0x65, 0x12, 0x30, 0x0a, 0x08, 0x72, 0x65, 0x61, 0x63, 0x74,
+	0x69, 0x6f, 0x6e, 0x18, 0x09, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x12, 0x2e, 0x64, 0x69, 0x73, 0x70,
+	0x61, 0x74, 0x63, 0x68, 0x2e, 0x52, 0x65, 0x61, 0x63, 0x74, 0x69, 0x6f, 0x6e, 0x48, 0x00, 0x52,
+	0x08, 0x72, 0x65, 0x61, 0x63, 0x74, 0x69, 0x6f, 0x6e, 0x42, 0x08, 0x0a, 0x06, 0x63, 0x68, 0x61,
+	0x6e, 0x67, 0x65, 0x22, 0x48, 0x0a, 0x0a, 0x4d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x52, 0x65,
+	0x66, 0x12, 0x1b, 0x0a, 0x09, 0x73, 0x65, 0x6e, 0x64, 0x65, 0x72, 0x5f, 0x69, 0x64, 0x18, 0x01,
+	0x20, 0x01, 0x28, 0x04, 0x52, 0x08, 0x73, 0x65, 0x6e, 0x64, 0x65, 0x72, 0x49, 0x64, 0x12, 0x1d,
+	0x0a, 0x0a, 0x6d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x5f, 0x69, 0x64, 0x18, 0x02, 0x20, 0x01,
+	0x28, 0x04, 0x52, 0x09, 0x6d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x49, 0x64, 0x22, 0x80, 0x01,
+	0x0a, 0x04, 0x45, 0x64, 0x69, 0x74, 0x12, 0x2c, 0x0a, 0x06, 0x74, 0x61, 0x72, 0x67, 0x65, 0x74,
+	0x18, 0x01, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x14, 0x2e, 0x64, 0x69, 0x73, 0x70, 0x61, 0x74, 0x63,
+	0x68, 0x2e, 0x4d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x52, 0x65, 0x66, 0x52, 0x06, 0x74, 0x61,
+	0x72, 0x67, 0x65, 0x74, 0x12, 0x12, 0x0a, 0x04, 0x74, 0x65, 0x78, 0x74, 0x18, 0x02, 0x20, 0x01,
+	0x28, 0x0c, 0x52, 0x04, 0x74, 0x65, 0x78, 0x74, 0x12, 0x36, 0x0a, 0x0b, 0x61, 0x74, 0x74, 0x61,
+	0x63, 0x68, 0x6d, 0x65, 0x6e, 0x74, 0x73, 0x18, 0x03, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x14, 0x2e,
+	0x64, 0x69, 0x73, 0x70, 0x61, 0x74, 0x63, 0x68, 0x2e, 0x41, 0x74, 0x74, 0x61, 0x63, 0x68, 0x6d,
+	0x65, 0x6e, 0x74, 0x52, 0x0b, 0x61, 0x74, 0x74, 0x61, 0x63, 0x68, 0x6d, 0x65, 0x6e, 0x74, 0x73,
+	0x22, 0x36, 0x0a, 0x06, 0x44, 0x65, 0x6c, 0x65, 0x74, 0x65, 0x12, 0x2c, 0x0a, 0x06, 0x74, 0x61,
+	0x72, 0x67, 0x65, 0x74, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x14, 0x2e, 0x64, 0x69, 0x73,
+	0x70, 0x61, 0x74, 0x63, 0x68, 0x2e, 0x4d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x52, 0x65, 0x66,
+	0x52, 0x06, 0x74, 0x61, 0x72, 0x67, 0x65, 0x74, 0x22, 0x66, 0x0a, 0x08, 0x52, 0x65, 0x61, 0x63,
+	0x74, 0x69, 0x6f, 0x6e, 0x12, 0x2c, 0x0a, 0x06, 0x74, 0x61, 0x72, 0x67, 0x65, 0x74, 0x18, 0x01,
+	0x20, 0x01, 0x28, 0x0b, 0x32, 0x14, 0x2e, 0x64, 0x69, 0x73, 0x70, 0x61, 0x74, 0x63, 0x68, 0x2e,
+	0x4d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x52, 0x65, 0x66, 0x52, 0x06, 0x74, 0x61, 0x72, 0x67,
+	0x65, 0x74, 0x12, 0x14, 0x0a, 0x05, 0x65, 0x6d, 0x6f, 0x6a, 0x69, 0x18, 0x02, 0x20, 0x01, 0x28,
+	0x09, 0x52, 0x05, 0x65, 0x6d, 0x6f, 0x6a, 0x69, 0x12, 0x16, 0x0a, 0x06, 0x72, 0x65, 0x6d, 0x6f,
+	0x76, 0x65, 0x18, 0x03, 0x20, 0x01, 0x28, 0x08, 0x52, 0x06, 0x72, 0x65, 0x6d, 0x6f, 0x76, 0x65,
+	0x22, 0x9a, 0x01, 0x0a, 0x0a, 0x41, 0x74, 0x74, 0x61, 0x63, 0x68, 0x6d, 0x65, 0x6e, 0x74, 0x12,
+	0x0e, 0x0a, 0x02, 0x69, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x02, 0x69, 0x64, 0x12,
+	0x10, 0x0a, 0x03, 0x6b, 0x65, 0x79, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x03, 0x6b, 0x65,
+	0x79, 0x12, 0x16, 0x0a, 0x06, 0x64, 0x69, 0x67, 0x65, 0x73, 0x74, 0x18, 0x03, 0x20, 0x01, 0x28,
+	0x0c, 0x52, 0x06, 0x64, 0x69, 0x67, 0x65, 0x73, 0x74, 0x12, 0x21, 0x0a, 0x0c, 0x63, 0x6f, 0x6e,
+	0x74, 0x65, 0x6e, 0x74, 0x5f, 0x74, 0x79, 0x70, 0x65, 0x18, 0x04, 0x20, 0x01, 0x28, 0x09, 0x52,
+	0x0b, 0x63, 0x6f, 0x6e, 0x74, 0x65, 0x6e, 0x74, 0x54, 0x79, 0x70, 0x65, 0x12, 0x12, 0x0a, 0x04,
+	0x73, 0x69, 0x7a, 0x65, 0x18, 0x05, 0x20, 0x01, 0x28, 0x04, 0x52, 0x04, 0x73, 0x69, 0x7a, 0x65,
+	0x12, 0x1b, 0x0a, 0x09, 0x66, 0x69, 0x6c, 0x65, 0x5f, 0x6e, 0x61, 0x6d, 0x65, 0x18, 0x06, 0x20,
+	0x01, 0x28, 0x09, 0x52, 0x08, 0x66, 0x69, 0x6c, 0x65, 0x4e, 0x61, 0x6d, 0x65, 0x22, 0xae, 0x05,
+	0x0a, 0x06, 0x41, 0x63, 0x74, 0x69, 0x6f, 0x6e, 0x12, 0x18, 0x0a, 0x07, 0x70, 0x61, 0x79, 0x6c,
+	0x6f, 0x61, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x07, 0x70, 0x61, 0x79, 0x6c, 0x6f,
+	0x61, 0x64, 0x12, 0x21, 0x0a, 0x0c, 0x72, 0x65, 0x63, 0x69, 0x70, 0x69, 0x65, 0x6e, 0x74, 0x5f,
+	0x69, 0x64, 0x18, 0x02, 0x20, 0x01, 0x28, 0x04, 0x52, 0x0b, 0x72, 0x65, 0x63, 0x69, 0x70, 0x69,
+	0x65, 0x6e, 0x74, 0x49, 0x64, 0x12, 0x1d, 0x0a, 0x0a, 0x63, 0x68, 0x61, 0x6e, 0x6e, 0x65, 0x6c,
+	0x5f, 0x69, 0x64, 0x18, 0x03, 0x20, 0x01, 0x28, 0x04, 0x52, 0x09, 0x63, 0x68, 0x61, 0x6e, 0x6e,
+	0x65, 0x6c, 0x49, 0x64, 0x12, 0x1c, 0x0a, 0x09, 0x70, 0x75, 0x62, 0x6c, 0x69, 0x63, 0x6b, 0x65,
+	0x79, 0x18, 0x04, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x09, 0x70, 0x75, 0x62, 0x6c, 0x69, 0x63, 0x6b,
+	0x65, 0x79, 0x12, 0x12, 0x0a, 0x04, 0x68, 0x61, 0x73, 0x68, 0x18, 0x05, 0x20, 0x01, 0x28, 0x0c,
+	0x52, 0x04, 0x68, 0x61, 0x73, 0x68, 0x12, 0x2f, 0x0a, 0x04, 0x74, 0x79, 0x70, 0x65, 0x18, 0x06,
+	0x20, 0x01, 0x28, 0x0e, 0x32, 0x1b, 0x2e, 0x64, 0x69, 0x73, 0x70, 0x61, 0x74, 0x63, 0x68, 0x2e,
+	0x41, 0x63, 0x74, 0x69, 0x6f, 0x6e, 0x2e, 0x41, 0x63, 0x74, 0x69, 0x6f, 0x6e, 0x54, 0x79, 0x70,
+	0x65, 0x52, 0x04, 0x74, 0x79, 0x70, 0x65, 0x12, 0x2c, 0x0a, 0x06, 0x63, 0x6f, 0x70, 0x69, 0x65,
+	0x73, 0x18, 0x07, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x14, 0x2e, 0x64, 0x69, 0x73, 0x70, 0x61, 0x74,
+	0x63, 0x68, 0x2e, 0x44, 0x65, 0x76, 0x69, 0x63, 0x65, 0x43, 0x6f, 0x70, 0x79, 0x52, 0x06, 0x63,
+	0x6f, 0x70, 0x69, 0x65, 0x73, 0x12, 0x2e, 0x0a, 0x13, 0x72, 0x65, 0x63, 0x69, 0x70, 0x69, 0x65,
+	0x6e, 0x74, 0x5f, 0x64, 0x65, 0x76, 0x69, 0x63, 0x65, 0x5f, 0x69, 0x64, 0x18, 0x08, 0x20, 0x01,
+	0x28, 0x04, 0x52, 0x11, 0x72, 0x65, 0x63, 0x69, 0x70, 0x69, 0x65, 0x6e, 0x74, 0x44, 0x65, 0x76,
+	0x69, 0x63, 0x65, 0x49, 0x64, 0x12, 0x1b, 0x0a, 0x09, 0x73, 0x65, 0x6e, 0x64, 0x65, 0x72, 0x5f,
+	0x69, 0x64, 0x18, 0x09, 0x20, 0x01, 0x28, 0x04, 0x52, 0x08, 0x73, 0x65, 0x6e, 0x64, 0x65, 0x72,
+	0x49, 0x64, 0x12, 0x28, 0x0a, 0x10, 0x73, 0x65, 0x6e, 0x64, 0x65, 0x72, 0x5f, 0x64, 0x65, 0x76,
+	0x69, 0x63, 0x65, 0x5f, 0x69, 0x64, 0x18, 0x0a, 0x20, 0x01, 0x28, 0x04, 0x52, 0x0e, 0x73, 0x65,
+	0x6e, 0x64, 0x65, 0x72, 0x44, 0x65, 0x76, 0x69, 0x63, 0x65, 0x49, 0x64, 0x12, 0x1d, 0x0a, 0x0a,
+	0x61, 0x63, 0x63, 0x65, 0x73, 0x73, 0x5f, 0x6b, 0x65, 0x79, 0x18, 0x0b, 0x20, 0x01, 0x28, 0x0c,
+	0x52, 0x09, 0x61, 0x63, 0x63, 0x65, 0x73, 0x73, 0x4b, 0x65, 0x79, 0x12, 0x1d, 0x0a, 0x0a, 0x6d,
+	0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x5f, 0x69, 0x64, 0x18, 0x0c, 0x20, 0x01, 0x28, 0x04, 0x52,
+	0x09, 0x6d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x49, 0x64, 0x12, 0x2c, 0x0a, 0x12, 0x64, 0x65,
+	0x6c, 0x65, 0x74, 0x65, 0x64, 0x5f, 0x6d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x5f, 0x69, 0x64,
+	0x18, 0x0d, 0x20, 0x01, 0x28, 0x04, 0x52, 0x10, 0x64, 0x65, 0x6c, 0x65, 0x74, 0x65, 0x64, 0x4d,
+	0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x49, 0x64, 0x12, 0x21, 0x0a, 0x0c, 0x65, 0x78, 0x70, 0x69,
+	0x72, 0x65, 0x5f, 0x74, 0x69, 0x6d, 0x65, 0x72, 0x18, 0x0e, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x0b,
+	0x65, 0x78, 0x70, 0x69, 0x72, 0x65, 0x54, 0x69, 0x6d, 0x65, 0x72, 0x22, 0xb0, 0x01, 0x0a, 0x0a,
+	0x41, 0x63, 0x74, 0x69, 0x6f, 0x6e, 0x54, 0x79, 0x70, 0x65, 0x12, 0x0d, 0x0a, 0x09, 0x48, 0x41,
+	0x4e, 0x44, 0x53, 0x48, 0x41, 0x4b, 0x45, 0x10, 0x00, 0x12, 0x10, 0x0a, 0x0c, 0x54, 0x52, 0x41,
+	0x4e, 0x53, 0x4d, 0x49, 0x53, 0x53, 0x49, 0x4f, 0x4e, 0x10, 0x01, 0x12, 0x10, 0x0a, 0x0c, 0x43,
+	0x4f, 0x4e, 0x46, 0x49, 0x52, 0x4d, 0x41, 0x54, 0x49, 0x4f, 0x4e, 0x10, 0x02, 0x12, 0x0a, 0x0a,
+	0x06, 0x47, 0x4f, 0x41, 0x57, 0x41, 0x59, 0x10, 0x03, 0x12, 0x0c, 0x0a, 0x08, 0x54, 0x48, 0x52,
+	0x4f, 0x54, 0x54, 0x4c, 0x45, 0x10, 0x04, 0x12, 0x0b, 0x0a, 0x07, 0x43, 0x48, 0x41, 0x4e, 0x4e,
+	0x45, 0x4c, 0x10, 0x05, 0x12, 0x0e, 0x0a, 0x0a, 0x53, 0x45, 0x4e, 0x44, 0x45, 0x52, 0x5f, 0x4b,
+	0x45, 0x59, 0x10, 0x06, 0x12, 0x07, 0x0a, 0x03, 0x4d, 0x4c, 0x53, 0x10, 0x07, 0x12, 0x0a, 0x0a,
+	0x06, 0x53, 0x45, 0x41, 0x4c, 0x45, 0x44, 0x10, 0x08, 0x12, 0x09, 0x0a, 0x05, 0x45, 0x52, 0x52,
+	0x4f, 0x52, 0x10, 0x09, 0x12, 0x0a, 0x0a, 0x06, 0x54, 0x59, 0x50, 0x49, 0x4e, 0x47, 0x10, 0x0a,
+	0x12, 0x0c, 0x0a, 0x08, 0x50, 0x52, 0x45, 0x53, 0x45, 0x4e, 0x43, 0x45, 0x10, 0x0b, 0x22, 0x43,
+	0x0a, 0x0a, 0x44, 0x65, 0x76, 0x69, 0x63, 0x65, 0x43, 0x6f, 0x70, 0x79, 0x12, 0x1b, 0x0a, 0x09,
+	0x64, 0x65, 0x76, 0x69, 0x63, 0x65, 0x5f, 0x69, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x04, 0x52,
+	0x08, 0x64, 0x65, 0x76, 0x69, 0x63, 0x65, 0x49, 0x64, 0x12, 0x18, 0x0a, 0x07, 0x70, 0x61, 0x79,
+	0x6c, 0x6f, 0x61, 0x64, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x07, 0x70, 0x61, 0x79, 0x6c,
+	0x6f, 0x61, 0x64, 0x22, 0xfc, 0x01, 0x0a, 0x09, 0x43, 0x68, 0x61, 0x6e, 0x6e, 0x65, 0x6c, 0x4f,
+	0x70, 0x12, 0x26, 0x0a, 0x02, 0x6f, 0x70, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0e, 0x32, 0x16, 0x2e,
+	0x64, 0x69, 0x73, 0x70, 0x61, 0x74, 0x63, 0x68, 0x2e, 0x43, 0x68, 0x61, 0x6e, 0x6e, 0x65, 0x6c,
+	0x4f, 0x70, 0x2e, 0x4f, 0x70, 0x52, 0x02, 0x6f, 0x70, 0x12, 0x1d, 0x0a, 0x0a, 0x63, 0x68, 0x61,
+	0x6e, 0x6e, 0x65, 0x6c, 0x5f, 0x69, 0x64, 0x18, 0x02, 0x20, 0x01, 0x28, 0x04, 0x52, 0x09, 0x63,
+	0x68, 0x61, 0x6e, 0x6e, 0x65, 0x6c, 0x49, 0x64, 0x12, 0x17, 0x0a, 0x07, 0x75, 0x73, 0x65, 0x72,
+	0x5f, 0x69, 0x64, 0x18, 0x03, 0x20, 0x01, 0x28, 0x04, 0x52, 0x06, 0x75, 0x73, 0x65, 0x72, 0x49,
+	0x64, 0x12, 0x30, 0x0a, 0x04, 0x72, 0x6f, 0x6c, 0x65, 0x18, 0x04, 0x20, 0x01, 0x28, 0x0e, 0x32,
+	0x1c, 0x2e, 0x64, 0x69, 0x73, 0x70, 0x61, 0x74, 0x63, 0x68, 0x2e, 0x43, 0x68, 0x61, 0x6e, 0x6e,
+	0x65, 0x6c, 0x4d, 0x65, 0x6d, 0x62, 0x65, 0x72, 0x2e, 0x52, 0x6f, 0x6c, 0x65, 0x52, 0x04, 0x72,
+	0x6f, 0x6c, 0x65, 0x12, 0x12, 0x0a, 0x04, 0x6e, 0x61, 0x6d, 0x65, 0x18, 0x05, 0x20, 0x01, 0x28,
+	0x09, 0x52, 0x04, 0x6e, 0x61, 0x6d, 0x65, 0x22, 0x49, 0x0a, 0x02, 0x4f, 0x70, 0x12, 0x0a, 0x0a,
+	0x06, 0x43, 0x52, 0x45, 0x41, 0x54, 0x45, 0x10, 0x00, 0x12, 0x0a, 0x0a, 0x06, 0x49, 0x4e, 0x56,
+	0x49, 0x54, 0x45, 0x10, 0x01, 0x12, 0x09, 0x0a, 0x05, 0x4c, 0x45, 0x41, 0x56, 0x45, 0x10, 0x02,
+	0x12, 0x08, 0x0a, 0x04, 0x4b, 0x49, 0x43, 0x4b, 0x10, 0x03, 0x12, 0x08, 0x0a, 0x04, 0x4c, 0x49,
+	0x53, 0x54, 0x10, 0x04, 0x12, 0x0c, 0x0a, 0x08, 0x53, 0x45, 0x54, 0x5f, 0x52, 0x4f, 0x4c, 0x45,
+	0x10, 0x05, 0x22, 0x84, 0x01, 0x0a, 0x0d, 0x43, 0x68, 0x61, 0x6e, 0x6e, 0x65, 0x6c, 0x4d, 0x65,
+	0x6d, 0x62, 0x65, 0x72, 0x12, 0x17, 0x0a, 0x07, 0x75, 0x73, 0x65, 0x72, 0x5f, 0x69, 0x64, 0x18,
+	0x01, 0x20, 0x01, 0x28, 0x04, 0x52, 0x06, 0x75, 0x73, 0x65, 0x72, 0x49, 0x64, 0x12, 0x30, 0x0a,
+	0x04, 0x72, 0x6f, 0x6c, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0e, 0x32, 0x1c, 0x2e, 0x64, 0x69,
+	0x73, 0x70, 0x61, 0x74, 0x63, 0x68, 0x2e, 0x43, 0x68, 0x61, 0x6e, 0x6e, 0x65, 0x6c, 0x4d, 0x65,
+	0x6d, 0x62, 0x65, 0x72, 0x2e, 0x52, 0x6f, 0x6c, 0x65, 0x52, 0x04, 0x72, 0x6f, 0x6c, 0x65, 0x22,
+	0x28, 0x0a, 0x04, 0x52, 0x6f, 0x6c, 0x65, 0x12, 0x0a, 0x0a, 0x06, 0x4d, 0x45, 0x4d, 0x42, 0x45,
+	0x52, 0x10, 0x00, 0x12, 0x09, 0x0a, 0x05, 0x41, 0x44, 0x4d, 0x49, 0x4e, 0x10, 0x01, 0x12, 0x09,
+	0x0a, 0x05, 0x4f, 0x57, 0x4e, 0x45, 0x52, 0x10, 0x02, 0x22, 0xab, 0x01, 0x0a, 0x0c, 0x43, 0x68,
+	0x61, 0x6e, 0x6e, 0x65, 0x6c, 0x45, 0x76, 0x65, 0x6e, 0x74, 0x12, 0x23, 0x0a, 0x02, 0x6f, 0x70,
+	0x18, 0x01, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x13, 0x2e, 0x64, 0x69, 0x73, 0x70, 0x61, 0x74, 0x63,
+	0x68, 0x2e, 0x43, 0x68, 0x61, 0x6e, 0x6e, 0x65, 0x6c, 0x4f, 0x70, 0x52, 0x02, 0x6f, 0x70, 0x12,
+	0x19, 0x0a, 0x08, 0x61, 0x63, 0x74, 0x6f, 0x72, 0x5f, 0x69, 0x64, 0x18, 0x02, 0x20, 0x01, 0x28,
+	0x04, 0x52, 0x07, 0x61, 0x63, 0x74, 0x6f, 0x72, 0x49, 0x64, 0x12, 0x12, 0x0a, 0x04, 0x6e, 0x61,
+	0x6d, 0x65, 0x18, 0x03, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x6e, 0x61, 0x6d, 0x65, 0x12, 0x31,
+	0x0a, 0x07, 0x6d, 0x65, 0x6d, 0x62, 0x65, 0x72, 0x73, 0x18, 0x04, 0x20, 0x03, 0x28, 0x0b, 0x32,
+	0x17, 0x2e, 0x64, 0x69, 0x73, 0x70, 0x61, 0x74, 0x63, 0x68, 0x2e, 0x43, 0x68, 0x61, 0x6e, 0x6e,
+	0x65, 0x6c, 0x4d, 0x65, 0x6d, 0x62, 0x65, 0x72, 0x52, 0x07, 0x6d, 0x65, 0x6d, 0x62, 0x65, 0x72,
+	0x73, 0x12, 0x14, 0x0a, 0x05, 0x65, 0x72, 0x72, 0x6f, 0x72, 0x18, 0x05, 0x20, 0x01, 0x28, 0x09,
+	0x52, 0x05, 0x65, 0x72, 0x72, 0x6f, 0x72, 0x22, 0x59, 0x0a, 0x06, 0x54, 0x79, 0x70, 0x69, 0x6e,
+	0x67, 0x12, 0x2c, 0x0a, 0x05, 0x73, 0x74, 0x61, 0x74, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0e,
+	0x32, 0x16, 0x2e, 0x64, 0x69, 0x73, 0x70, 0x61, 0x74, 0x63, 0x68, 0x2e, 0x54, 0x79, 0x70, 0x69,
+	0x6e, 0x67, 0x2e, 0x53, 0x74, 0x61, 0x74, 0x65, 0x52, 0x05, 0x73, 0x74, 0x61, 0x74, 0x65, 0x22,
+	0x21, 0x0a, 0x05, 0x53, 0x74, 0x61, 0x74, 0x65, 0x12, 0x0b, 0x0a, 0x07, 0x53, 0x54, 0x4f, 0x50,
+	0x50, 0x45, 0x44, 0x10, 0x00, 0x12, 0x0b, 0x0a, 0x07, 0x53, 0x54, 0x41, 0x52, 0x54, 0x45, 0x44,
+	0x10, 0x01, 0x22, 0xba, 0x02, 0x0a, 0x08, 0x50, 0x72, 0x65, 0x73, 0x65, 0x6e, 0x63, 0x65, 0x12,
+	0x25, 0x0a, 0x02, 0x6f, 0x70, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0e, 0x32, 0x15, 0x2e, 0x64, 0x69,
+	0x73, 0x70, 0x61, 0x74, 0x63, 0x68, 0x2e, 0x50, 0x72, 0x65, 0x73, 0x65, 0x6e, 0x63, 0x65, 0x2e,
+	0x4f, 0x70, 0x52, 0x02, 0x6f, 0x70, 0x12, 0x17, 0x0a, 0x07, 0x75, 0x73, 0x65, 0x72, 0x5f, 0x69,
+	0x64, 0x18, 0x02, 0x20, 0x01, 0x28, 0x04, 0x52, 0x06, 0x75, 0x73, 0x65, 0x72, 0x49, 0x64, 0x12,
+	0x2e, 0x0a, 0x05, 0x73, 0x74, 0x61, 0x74, 0x65, 0x18, 0x03, 0x20, 0x01, 0x28, 0x0e, 0x32, 0x18,
+	0x2e, 0x64, 0x69, 0x73, 0x70, 0x61, 0x74, 0x63, 0x68, 0x2e, 0x50, 0x72, 0x65, 0x73, 0x65, 0x6e,
+	0x63, 0x65, 0x2e, 0x53, 0x74, 0x61, 0x74, 0x65, 0x52, 0x05, 0x73, 0x74, 0x61, 0x74, 0x65, 0x12,
+	0x1b, 0x0a, 0x09, 0x6c, 0x61, 0x73, 0x74, 0x5f, 0x73, 0x65, 0x65, 0x6e, 0x18, 0x04, 0x20, 0x01,
+	0x28, 0x03, 0x52, 0x08, 0x6c, 0x61, 0x73, 0x74, 0x53, 0x65, 0x65, 0x6e, 0x12, 0x36, 0x0a, 0x08,
+	0x73, 0x65, 0x74, 0x74, 0x69, 0x6e, 0x67, 0x73, 0x18, 0x05, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x1a,
+	0x2e, 0x64, 0x69, 0x73, 0x70, 0x61, 0x74, 0x63, 0x68, 0x2e, 0x50, 0x72, 0x65, 0x73, 0x65, 0x6e,
+	0x63, 0x65, 0x53, 0x65, 0x74, 0x74, 0x69, 0x6e, 0x67, 0x73, 0x52, 0x08, 0x73, 0x65, 0x74, 0x74,
+	0x69, 0x6e, 0x67, 0x73, 0x22, 0x3d, 0x0a, 0x02, 0x4f, 0x70, 0x12, 0x09, 0x0a, 0x05, 0x53, 0x54,
+	0x41, 0x54, 0x45, 0x10, 0x00, 0x12, 0x0d, 0x0a, 0x09, 0x53, 0x55, 0x42, 0x53, 0x43, 0x52, 0x49,
+	0x42, 0x45, 0x10, 0x01, 0x12, 0x0f, 0x0a, 0x0b, 0x55, 0x4e, 0x53, 0x55, 0x42, 0x53, 0x43, 0x52,
+	0x49, 0x42, 0x45, 0x10, 0x02, 0x12, 0x0c, 0x0a, 0x08, 0x53, 0x45, 0x54, 0x54, 0x49, 0x4e, 0x47,
+	0x53, 0x10, 0x03, 0x22, 0x2a, 0x0a, 0x05, 0x53, 0x74, 0x61, 0x74, 0x65, 0x12, 0x0b, 0x0a, 0x07,
+	0x4f, 0x46, 0x46, 0x4c, 0x49, 0x4e, 0x45, 0x10, 0x00, 0x12, 0x0a, 0x0a, 0x06, 0x4f, 0x4e, 0x4c,
+	0x49, 0x4e, 0x45, 0x10, 0x01, 0x12, 0x08, 0x0a, 0x04, 0x41, 0x57, 0x41, 0x59, 0x10, 0x02, 0x22,
+	0xfd, 0x01, 0x0a, 0x10, 0x50, 0x72, 0x65, 0x73, 0x65, 0x6e, 0x63, 0x65, 0x53, 0x65, 0x74, 0x74,
+	0x69, 0x6e, 0x67, 0x73, 0x12, 0x41, 0x0a, 0x08, 0x70, 0x72, 0x65, 0x73, 0x65, 0x6e, 0x63, 0x65,
+	0x18, 0x01, 0x20, 0x01, 0x28, 0x0e, 0x32, 0x25, 0x2e, 0x64, 0x69, 0x73, 0x70, 0x61, 0x74, 0x63,
+	0x68, 0x2e, 0x50, 0x72, 0x65, 0x73, 0x65, 0x6e, 0x63, 0x65, 0x53, 0x65, 0x74, 0x74, 0x69, 0x6e,
+	0x67, 0x73, 0x2e, 0x56, 0x69, 0x73, 0x69, 0x62, 0x69, 0x6c, 0x69, 0x74, 0x79, 0x52, 0x08, 0x70,
+	0x72, 0x65, 0x73, 0x65, 0x6e, 0x63, 0x65, 0x12, 0x42, 0x0a, 0x09, 0x6c, 0x61, 0x73, 0x74, 0x5f,
+	0x73, 0x65, 0x65, 0x6e, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0e, 0x32, 0x25, 0x2e, 0x64, 0x69, 0x73,
+	0x70, 0x61, 0x74, 0x63, 0x68, 0x2e, 0x50, 0x72, 0x65, 0x73, 0x65, 0x6e, 0x63, 0x65, 0x53, 0x65,
+	0x74, 0x74, 0x69, 0x6e, 0x67, 0x73, 0x2e, 0x56, 0x69, 0x73, 0x69, 0x62, 0x69, 0x6c, 0x69, 0x74,
+	0x79, 0x52, 0x08, 0x6c, 0x61, 0x73, 0x74, 0x53, 0x65, 0x65, 0x6e, 0x12, 0x18, 0x0a, 0x07, 0x61,
+	0x6c, 0x6c, 0x6f, 0x77, 0x65, 0x64, 0x18, 0x03, 0x20, 0x03, 0x28, 0x04, 0x52, 0x07, 0x61, 0x6c,
+	0x6c, 0x6f, 0x77, 0x65, 0x64, 0x22, 0x48, 0x0a, 0x0a, 0x56, 0x69, 0x73, 0x69, 0x62, 0x69, 0x6c,
+	0x69, 0x74, 0x79, 0x12, 0x13, 0x0a, 0x0f, 0x43, 0x48, 0x41, 0x4e, 0x4e, 0x45, 0x4c, 0x5f, 0x4d,
+	0x45, 0x4d, 0x42, 0x45, 0x52, 0x53, 0x10, 0x00, 0x12, 0x0c, 0x0a, 0x08, 0x45, 0x56, 0x45, 0x52,
+	0x59, 0x4f, 0x4e, 0x45, 0x10, 0x01, 0x12, 0x0b, 0x0a, 0x07, 0x41, 0x4c, 0x4c, 0x4f, 0x57, 0x45,
+	0x44, 0x10, 0x02, 0x12, 0x0a, 0x0a, 0x06, 0x4e, 0x4f, 0x42, 0x4f, 0x44, 0x59, 0x10, 0x03, 0x22,
+	0xce, 0x02, 0x0a, 0x08, 0x45, 0x6e, 0x76, 0x65, 0x6c, 0x6f, 0x70, 0x65, 0x12, 0x18, 0x0a, 0x07,
+	0x76, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e, 0x18, 0x10, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x07, 0x76,
+	0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e, 0x12, 0x38, 0x0a, 0x0c, 0x63, 0x61, 0x70, 0x61, 0x62, 0x69,
+	0x6c, 0x69, 0x74, 0x69, 0x65, 0x73, 0x18, 0x11, 0x20, 0x03, 0x28, 0x0e, 0x32, 0x14, 0x2e, 0x64,
+	0x69, 0x73, 0x70, 0x61, 0x74, 0x63, 0x68, 0x2e, 0x43, 0x61, 0x70, 0x61, 0x62, 0x69, 0x6c, 0x69,
+	0x74, 0x79, 0x52, 0x0c, 0x63, 0x61, 0x70, 0x61, 0x62, 0x69, 0x6c, 0x69, 0x74, 0x69, 0x65, 0x73,
+	0x12, 0x40, 0x0a, 0x0e, 0x61, 0x75, 0x74, 0x68, 0x65, 0x6e, 0x74, 0x69, 0x63, 0x61, 0x74, 0x69,
+	0x6f, 0x6e, 0x18, 0x12, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x18, 0x2e, 0x64, 0x69, 0x73, 0x70, 0x61,
+	0x74, 0x63, 0x68, 0x2e, 0x41, 0x75, 0x74, 0x68, 0x65, 0x6e, 0x74, 0x69, 0x63, 0x61, 0x74, 0x69,
+	0x6f, 0x6e, 0x52, 0x0e, 0x61, 0x75, 0x74, 0x68, 0x65, 0x6e, 0x74, 0x69, 0x63, 0x61, 0x74, 0x69,
+	0x6f, 0x6e, 0x12, 0x35, 0x0a, 0x0b, 0x61, 0x75, 0x74, 0x68, 0x5f, 0x72, 0x65, 0x73, 0x75, 0x6c,
+	0x74, 0x18, 0x13, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x14, 0x2e, 0x64, 0x69, 0x73, 0x70, 0x61, 0x74,
+	0x63, 0x68, 0x2e, 0x41, 0x75, 0x74, 0x68, 0x52, 0x65, 0x73, 0x75, 0x6c, 0x74, 0x52, 0x0a, 0x61,
+	0x75, 0x74, 0x68, 0x52, 0x65, 0x73, 0x75, 0x6c, 0x74, 0x12, 0x35, 0x0a, 0x09, 0x63, 0x68, 0x61,
+	0x6c, 0x6c, 0x65, 0x6e, 0x67, 0x65, 0x18, 0x14, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x17, 0x2e, 0x64,
+	0x69, 0x73, 0x70, 0x61, 0x74, 0x63, 0x68, 0x2e, 0x41, 0x75, 0x74, 0x68, 0x43, 0x68, 0x61, 0x6c,
+	0x6c, 0x65, 0x6e, 0x67, 0x65, 0x52, 0x09, 0x63, 0x68, 0x61, 0x6c, 0x6c, 0x65, 0x6e, 0x67, 0x65,
+	0x12, 0x38, 0x0a, 0x0c, 0x64, 0x65, 0x76, 0x69, 0x63, 0x65, 0x5f, 0x70, 0x72, 0x6f, 0x6f, 0x66,
+	0x18, 0x15, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x15, 0x2e, 0x64, 0x69, 0x73, 0x70, 0x61, 0x74, 0x63,
+	0x68, 0x2e, 0x44, 0x65, 0x76, 0x69, 0x63, 0x65, 0x50, 0x72, 0x6f, 0x6f, 0x66, 0x52, 0x0b, 0x64,
+	0x65, 0x76, 0x69, 0x63, 0x65, 0x50, 0x72, 0x6f, 0x6f, 0x66, 0x4a, 0x04, 0x08, 0x01, 0x10, 0x10,
+	0x22, 0xf5, 0x02, 0x0a, 0x05, 0x45, 0x72, 0x72, 0x6f, 0x72, 0x12, 0x28, 0x0a, 0x04, 0x63, 0x6f,
+	0x64, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0e, 0x32, 0x14, 0x2e, 0x64, 0x69, 0x73, 0x70, 0x61,
+	0x74, 0x63, 0x68, 0x2e, 0x45, 0x72, 0x72, 0x6f, 0x72, 0x2e, 0x43, 0x6f, 0x64, 0x65, 0x52, 0x04,
+	0x63, 0x6f, 0x64, 0x65, 0x12, 0x16, 0x0a, 0x06, 0x64, 0x65, 0x74, 0x61, 0x69, 0x6c, 0x18, 0x02,
+	0x20, 0x01, 0x28, 0x09, 0x52, 0x06, 0x64, 0x65, 0x74, 0x61, 0x69, 0x6c, 0x12, 0x1c, 0x0a, 0x09,
+	0x72, 0x65, 0x74, 0x72, 0x79, 0x61, 0x62, 0x6c, 0x65, 0x18, 0x03, 0x20, 0x01, 0x28, 0x08, 0x52,
+	0x09, 0x72, 0x65, 0x74, 0x72, 0x79, 0x61, 0x62, 0x6c, 0x65, 0x12, 0x1d, 0x0a, 0x0a, 0x6d, 0x65,
+	0x73, 0x73, 0x61, 0x67, 0x65, 0x5f, 0x69, 0x64, 0x18, 0x04, 0x20, 0x01, 0x28, 0x04, 0x52, 0x09,
+	0x6d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x49, 0x64, 0x12, 0x25, 0x0a, 0x0e, 0x63, 0x6f, 0x72,
+	0x72, 0x65, 0x6c, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x5f, 0x69, 0x64, 0x18, 0x05, 0x20, 0x01, 0x28,
+	0x09, 0x52, 0x0d, 0x63, 0x6f, 0x72, 0x72, 0x65, 0x6c, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x49, 0x64,
+	0x22, 0xc5, 0x01, 0x0a, 0x04, 0x43, 0x6f, 0x64, 0x65, 0x12, 0x0b, 0x0a, 0x07, 0x55, 0x4e, 0x4b,
+	0x4e, 0x4f, 0x57, 0x4e, 0x10, 0x00, 0x12, 0x13, 0x0a, 0x0f, 0x49, 0x4e, 0x56, 0x41, 0x4c, 0x49,
+	0x44, 0x5f, 0x4d, 0x45, 0x53, 0x53, 0x41, 0x47, 0x45, 0x10, 0x01, 0x12, 0x13, 0x0a, 0x0f, 0x55,
+	0x4e, 0x41, 0x55, 0x54, 0x48, 0x45, 0x4e, 0x54, 0x49, 0x43, 0x41, 0x54, 0x45, 0x44, 0x10, 0x02,
+	0x12, 0x0f, 0x0a, 0x0b, 0x55, 0x4e, 0x53, 0x55, 0x50, 0x50, 0x4f, 0x52, 0x54, 0x45, 0x44, 0x10,
+	0x03, 0x12, 0x15, 0x0a, 0x11, 0x55, 0x4e, 0x4b, 0x4e, 0x4f, 0x57, 0x4e, 0x5f, 0x52, 0x45, 0x43,
+	0x49, 0x50, 0x49, 0x45, 0x4e, 0x54, 0x10, 0x04, 0x12, 0x12, 0x0a, 0x0e, 0x4d, 0x49, 0x53, 0x53,
+	0x49, 0x4e, 0x47, 0x5f, 0x43, 0x4f, 0x50, 0x49, 0x45, 0x53, 0x10, 0x05, 0x12, 0x0d, 0x0a, 0x09,
+	0x46, 0x4f, 0x52, 0x42, 0x49, 0x44, 0x44, 0x45, 0x4e, 0x10, 0x06, 0x12, 0x0d, 0x0a, 0x09, 0x4e,
+	0x4f, 0x54, 0x5f, 0x46, 0x4f, 0x55, 0x4e, 0x44, 0x10, 0x07, 0x12, 0x0d, 0x0a, 0x09, 0x54, 0x4f,
+	0x4f, 0x5f, 0x4c, 0x41, 0x52, 0x47, 0x45, 0x10, 0x08, 0x12, 0x0f, 0x0a, 0x0b, 0x55, 0x4e, 0x41,
+	0x56, 0x41, 0x49, 0x4c, 0x41, 0x42, 0x4c, 0x45, 0x10, 0x09, 0x12, 0x0c, 0x0a, 0x08, 0x49, 0x4e,
+	0x54, 0x45, 0x52, 0x4e, 0x41, 0x4c, 0x10, 0x0a, 0x22, 0x8f, 0x02, 0x0a, 0x0e, 0x41, 0x75, 0x74,
+	0x68, 0x65, 0x6e, 0x74, 0x69, 0x63, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x12, 0x17, 0x0a, 0x07, 0x75,
+	0x73, 0x65, 0x72, 0x5f, 0x69, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x04, 0x52, 0x06, 0x75, 0x73,
+	0x65, 0x72, 0x49, 0x64, 0x12, 0x12, 0x0a, 0x04, 0x63, 0x6f, 0x64, 0x65, 0x18, 0x02, 0x20, 0x01,
+	0x28, 0x0c, 0x52, 0x04, 0x63, 0x6f, 0x64, 0x65, 0x12, 0x1c, 0x0a, 0x09, 0x70, 0x75, 0x62, 0x6c,
+	0x69, 0x63, 0x6b, 0x65, 0x79, 0x18, 0x03, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x09, 0x70, 0x75, 0x62,
+	0x6c, 0x69, 0x63, 0x6b, 0x65, 0x79, 0x12, 0x12, 0x0a, 0x04, 0x68, 0x61, 0x73, 0x68, 0x18, 0x04,
+	0x20, 0x01, 0x28, 0x0c, 0x52, 0x04, 0x68, 0x61, 0x73, 0x68, 0x12, 0x25, 0x0a, 0x0e, 0x63, 0x6f,
+	0x72, 0x72, 0x65, 0x6c, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x5f, 0x69, 0x64, 0x18, 0x05, 0x20, 0x01,
+	0x28, 0x09, 0x52, 0x0d, 0x63, 0x6f, 0x72, 0x72, 0x65, 0x6c, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x49,
+	0x64, 0x12, 0x35, 0x0a, 0x0b, 0x6c, 0x6f, 0x67, 0x69, 0x6e, 0x5f, 0x74, 0x6f, 0x6b, 0x65, 0x6e,
+	0x18, 0x06, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x14, 0x2e, 0x64, 0x69, 0x73, 0x70, 0x61, 0x74, 0x63,
+	0x68, 0x2e, 0x4c, 0x6f, 0x67, 0x69, 0x6e, 0x54, 0x6f, 0x6b, 0x65, 0x6e, 0x52, 0x0a, 0x6c, 0x6f,
+	0x67, 0x69, 0x6e, 0x54, 0x6f, 0x6b, 0x65, 0x6e, 0x12, 0x1b, 0x0a, 0x09, 0x64, 0x65, 0x76, 0x69,
+	0x63, 0x65, 0x5f, 0x69, 0x64, 0x18, 0x07, 0x20, 0x01, 0x28, 0x04, 0x52, 0x08, 0x64, 0x65, 0x76,
+	0x69, 0x63, 0x65, 0x49, 0x64, 0x12, 0x23, 0x0a, 0x0d, 0x73, 0x65, 0x61, 0x6c, 0x65, 0x64, 0x5f,
+	0x73, 0x65, 0x6e, 0x64, 0x65, 0x72, 0x18, 0x08, 0x20, 0x01, 0x28, 0x08, 0x52, 0x0c, 0x73, 0x65,
+	0x61, 0x6c, 0x65, 0x64, 0x53, 0x65, 0x6e, 0x64, 0x65, 0x72, 0x22, 0x73, 0x0a, 0x0a, 0x4c, 0x6f,
+	0x67, 0x69, 0x6e, 0x54, 0x6f, 0x6b, 0x65, 0x6e, 0x12, 0x17, 0x0a, 0x07, 0x75, 0x73, 0x65, 0x72,
+	0x5f, 0x69, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x04, 0x52, 0x06, 0x75, 0x73, 0x65, 0x72, 0x49,
+	0x64, 0x12, 0x18, 0x0a, 0x07, 0x65, 0x78, 0x70, 0x69, 0x72, 0x65, 0x73, 0x18, 0x02, 0x20, 0x01,
+	0x28, 0x03, 0x52, 0x07, 0x65, 0x78, 0x70, 0x69, 0x72, 0x65, 0x73, 0x12, 0x14, 0x0a, 0x05, 0x6e,
+	0x6f, 0x6e, 0x63, 0x65, 0x18, 0x03, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x05, 0x6e, 0x6f, 0x6e, 0x63,
+	0x65, 0x12, 0x1c, 0x0a, 0x09, 0x73, 0x69, 0x67, 0x6e, 0x61, 0x74, 0x75, 0x72, 0x65, 0x18, 0x04,
+	0x20, 0x01, 0x28, 0x0c, 0x52, 0x09, 0x73, 0x69, 0x67, 0x6e, 0x61, 0x74, 0x75, 0x72, 0x65, 0x22,
+	0x25, 0x0a, 0x0d, 0x41, 0x75, 0x74, 0x68, 0x43, 0x68, 0x61, 0x6c, 0x6c, 0x65, 0x6e, 0x67, 0x65,
+	0x12, 0x14, 0x0a, 0x05, 0x6e, 0x6f, 0x6e, 0x63, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0c, 0x52,
+	0x05, 0x6e, 0x6f, 0x6e, 0x63, 0x65, 0x22, 0x2b, 0x0a, 0x0b, 0x44, 0x65, 0x76, 0x69, 0x63, 0x65,
+	0x50, 0x72, 0x6f, 0x6f, 0x66, 0x12, 0x1c, 0x0a, 0x09, 0x73, 0x69, 0x67, 0x6e, 0x61, 0x74, 0x75,
+	0x72, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x09, 0x73, 0x69, 0x67, 0x6e, 0x61, 0x74,
+	0x75, 0x72, 0x65, 0x22, 0xe4, 0x01, 0x0a, 0x0a, 0x41, 0x75, 0x74, 0x68, 0x52, 0x65, 0x73, 0x75,
+	0x6c, 0x74, 0x12, 0x33, 0x0a, 0x06, 0x73, 0x74, 0x61, 0x74, 0x75, 0x73, 0x18, 0x01, 0x20, 0x01,
+	0x28, 0x0e, 0x32, 0x1b, 0x2e, 0x64, 0x69, 0x73, 0x70, 0x61, 0x74, 0x63, 0x68, 0x2e, 0x41, 0x75,
+	0x74, 0x68, 0x52, 0x65, 0x73, 0x75, 0x6c, 0x74, 0x2e, 0x53, 0x74, 0x61, 0x74, 0x75, 0x73, 0x52,
+	0x06, 0x73, 0x74, 0x61, 0x74, 0x75, 0x73, 0x12, 0x16, 0x0a, 0x06, 0x64, 0x65, 0x74, 0x61, 0x69,
+	0x6c, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x06, 0x64, 0x65, 0x74, 0x61, 0x69, 0x6c, 0x12,
+	0x25, 0x0a, 0x0e, 0x63, 0x6f, 0x72, 0x72, 0x65, 0x6c, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x5f, 0x69,
+	0x64, 0x18, 0x03, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0d, 0x63, 0x6f, 0x72, 0x72, 0x65, 0x6c, 0x61,
+	0x74, 0x69, 0x6f, 0x6e, 0x49, 0x64, 0x22, 0x62, 0x0a, 0x06, 0x53, 0x74, 0x61, 0x74, 0x75, 0x73,
+	0x12, 0x06, 0x0a, 0x02, 0x4f, 0x4b, 0x10, 0x00, 0x12, 0x0b, 0x0a, 0x07, 0x49, 0x4e, 0x56, 0x41,
+	0x4c, 0x49, 0x44, 0x10, 0x01, 0x12, 0x0c, 0x0a, 0x08, 0x52, 0x45, 0x4a, 0x45, 0x43, 0x54, 0x45,
+	0x44, 0x10, 0x02, 0x12, 0x0b, 0x0a, 0x07, 0x54, 0x49, 0x4d, 0x45, 0x4f, 0x55, 0x54, 0x10, 0x03,
+	0x12, 0x0f, 0x0a, 0x0b, 0x55, 0x4e, 0x41, 0x56, 0x41, 0x49, 0x4c, 0x41, 0x42, 0x4c, 0x45, 0x10,
+	0x04, 0x12, 0x17, 0x0a, 0x13, 0x55, 0x4e, 0x53, 0x55, 0x50, 0x50, 0x4f, 0x52, 0x54, 0x45, 0x44,
+	0x5f, 0x56, 0x45, 0x52, 0x53, 0x49, 0x4f, 0x4e, 0x10, 0x05, 0x22, 0x91, 0x02, 0x0a, 0x0d, 0x53,
+	0x65, 0x73, 0x73, 0x69, 0x6f, 0x6e, 0x48, 0x65, 0x61, 0x64, 0x65, 0x72, 0x12, 0x17, 0x0a, 0x07,
+	0x75, 0x73, 0x65, 0x72, 0x5f, 0x69, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x04, 0x52, 0x06, 0x75,
+	0x73, 0x65, 0x72, 0x49, 0x64, 0x12, 0x1b, 0x0a, 0x09, 0x64, 0x65, 0x76, 0x69, 0x63, 0x65, 0x5f,
+	0x69, 0x64, 0x18, 0x02, 0x20, 0x01, 0x28, 0x04, 0x52, 0x08, 0x64, 0x65, 0x76, 0x69, 0x63, 0x65,
+	0x49, 0x64, 0x12, 0x25, 0x0a, 0x0e, 0x63, 0x6f, 0x72, 0x72, 0x65, 0x6c, 0x61, 0x74, 0x69, 0x6f,
+	0x6e, 0x5f, 0x69, 0x64, 0x18, 0x03, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0d, 0x63, 0x6f, 0x72, 0x72,
+	0x65, 0x6c, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x49, 0x64, 0x12, 0x1b, 0x0a, 0x09, 0x69, 0x73, 0x73,
+	0x75, 0x65, 0x64, 0x5f, 0x61, 0x74, 0x18, 0x04, 0x20, 0x01, 0x28, 0x03, 0x52, 0x08, 0x69, 0x73,
+	0x73, 0x75, 0x65, 0x64, 0x41, 0x74, 0x12, 0x14, 0x0a, 0x05, 0x6e, 0x6f, 0x6e, 0x63, 0x65, 0x18,
+	0x05, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x05, 0x6e, 0x6f, 0x6e, 0x63, 0x65, 0x12, 0x1c, 0x0a, 0x09,
+	0x73, 0x69, 0x67, 0x6e, 0x61, 0x74, 0x75, 0x72, 0x65, 0x18, 0x06, 0x20, 0x01, 0x28, 0x0c, 0x52,
+	0x09, 0x73, 0x69, 0x67, 0x6e, 0x61, 0x74, 0x75, 0x72, 0x65, 0x12, 0x18, 0x0a, 0x07, 0x76, 0x65,
+	0x72, 0x73, 0x69, 0x6f, 0x6e, 0x18, 0x07, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x07, 0x76, 0x65, 0x72,
+	0x73, 0x69, 0x6f, 0x6e, 0x12, 0x38, 0x0a, 0x0c, 0x63, 0x61, 0x70, 0x61, 0x62, 0x69, 0x6c, 0x69,
+	0x74, 0x69, 0x65, 0x73, 0x18, 0x08, 0x20, 0x03, 0x28, 0x0e, 0x32, 0x14, 0x2e, 0x64, 0x69, 0x73,
+	0x70, 0x61, 0x74, 0x63, 0x68, 0x2e, 0x43, 0x61, 0x70, 0x61, 0x62, 0x69, 0x6c, 0x69, 0x74, 0x79,
+	0x52, 0x0c, 0x63, 0x61, 0x70, 0x61, 0x62, 0x69, 0x6c, 0x69, 0x74, 0x69, 0x65, 0x73, 0x22, 0x7b,
+	0x0a, 0x08, 0x54, 0x68, 0x72, 0x6f, 0x74, 0x74, 0x6c, 0x65, 0x12, 0x2e, 0x0a, 0x05, 0x73, 0x63,
+	0x6f, 0x70, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0e, 0x32, 0x18, 0x2e, 0x64, 0x69, 0x73, 0x70,
+	0x61, 0x74, 0x63, 0x68, 0x2e, 0x54, 0x68, 0x72, 0x6f, 0x74, 0x74, 0x6c, 0x65, 0x2e, 0x53, 0x63,
+	0x6f, 0x70, 0x65, 0x52, 0x05, 0x73, 0x63, 0x6f, 0x70, 0x65, 0x12, 0x24, 0x0a, 0x0e, 0x72, 0x65,
+	0x74, 0x72, 0x79, 0x5f, 0x61, 0x66, 0x74, 0x65, 0x72, 0x5f, 0x6d, 0x73, 0x18, 0x02, 0x20, 0x01,
+	0x28, 0x03, 0x52, 0x0c, 0x72, 0x65, 0x74, 0x72, 0x79, 0x41, 0x66, 0x74, 0x65, 0x72, 0x4d, 0x73,
+	0x22, 0x19, 0x0a, 0x05, 0x53, 0x63, 0x6f, 0x70, 0x65, 0x12, 0x08, 0x0a, 0x04, 0x55, 0x53, 0x45,
+	0x52, 0x10, 0x00, 0x12, 0x06, 0x0a, 0x02, 0x49, 0x50, 0x10, 0x01, 0x22, 0x3c, 0x0a, 0x06, 0x47,
+	0x6f, 0x41, 0x77, 0x61, 0x79, 0x12, 0x16, 0x0a, 0x06, 0x72, 0x65, 0x61, 0x73, 0x6f, 0x6e, 0x18,
+	0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x06, 0x72, 0x65, 0x61, 0x73, 0x6f, 0x6e, 0x12, 0x1a, 0x0a,
+	0x08, 0x64, 0x65, 0x61, 0x64, 0x6c, 0x69, 0x6e, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x03, 0x52,
+	0x08, 0x64, 0x65, 0x61, 0x64, 0x6c, 0x69, 0x6e, 0x65, 0x22, 0xa9, 0x01, 0x0a, 0x15, 0x53, 0x65,
+	0x6e, 0x64, 0x65, 0x72, 0x4b, 0x65, 0x79, 0x44, 0x69, 0x73, 0x74, 0x72, 0x69, 0x62, 0x75, 0x74,
+	0x69, 0x6f, 0x6e, 0x12, 0x1d, 0x0a, 0x0a, 0x63, 0x68, 0x61, 0x6e, 0x6e, 0x65, 0x6c, 0x5f, 0x69,
+	0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x04, 0x52, 0x09, 0x63, 0x68, 0x61, 0x6e, 0x6e, 0x65, 0x6c,
+	0x49, 0x64, 0x12, 0x15, 0x0a, 0x06, 0x6b, 0x65, 0x79, 0x5f, 0x69, 0x64, 0x18, 0x02, 0x20, 0x01,
+	0x28, 0x0d, 0x52, 0x05, 0x6b, 0x65, 0x79, 0x49, 0x64, 0x12, 0x1c, 0x0a, 0x09, 0x69, 0x74, 0x65,
+	0x72, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x18, 0x03, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x09, 0x69, 0x74,
+	0x65, 0x72, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x12, 0x1b, 0x0a, 0x09, 0x63, 0x68, 0x61, 0x69, 0x6e,
+	0x5f, 0x6b, 0x65, 0x79, 0x18, 0x04, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x08, 0x63, 0x68, 0x61, 0x69,
+	0x6e, 0x4b, 0x65, 0x79, 0x12, 0x1f, 0x0a, 0x0b, 0x73, 0x69, 0x67, 0x6e, 0x69, 0x6e, 0x67, 0x5f,
+	0x6b, 0x65, 0x79, 0x18, 0x05, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x0a, 0x73, 0x69, 0x67, 0x6e, 0x69,
+	0x6e, 0x67, 0x4b, 0x65, 0x79, 0x22, 0x85, 0x01, 0x0a, 0x10, 0x53, 0x65, 0x6e, 0x64, 0x65, 0x72,
+	0x4b, 0x65, 0x79, 0x4d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x12, 0x15, 0x0a, 0x06, 0x6b, 0x65,
+	0x79, 0x5f, 0x69, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x05, 0x6b, 0x65, 0x79, 0x49,
+	0x64, 0x12, 0x1c, 0x0a, 0x09, 0x69, 0x74, 0x65, 0x72, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x18, 0x02,
+	0x20, 0x01, 0x28, 0x0d, 0x52, 0x09, 0x69, 0x74, 0x65, 0x72, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x12,
+	0x1e, 0x0a, 0x0a, 0x63, 0x69, 0x70, 0x68, 0x65, 0x72, 0x74, 0x65, 0x78, 0x74, 0x18, 0x03, 0x20,
+	0x01, 0x28, 0x0c, 0x52, 0x0a, 0x63, 0x69, 0x70, 0x68, 0x65, 0x72, 0x74, 0x65, 0x78, 0x74, 0x12,
+	0x1c, 0x0a, 0x09, 0x73, 0x69, 0x67, 0x6e, 0x61, 0x74, 0x75, 0x72, 0x65, 0x18, 0x04, 0x20, 0x01,
+	0x28, 0x0c, 0x52, 0x09, 0x73, 0x69, 0x67, 0x6e, 0x61, 0x74, 0x75, 0x72, 0x65, 0x22, 0xaf, 0x02,
+	0x0a, 0x0a, 0x4d, 0x6c, 0x73, 0x4d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x12, 0x2d, 0x0a, 0x04,
+	0x6b, 0x69, 0x6e, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0e, 0x32, 0x19, 0x2e, 0x64, 0x69, 0x73,
+	0x70, 0x61, 0x74, 0x63, 0x68, 0x2e, 0x4d, 0x6c, 0x73, 0x4d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65,
+	0x2e, 0x4b, 0x69, 0x6e, 0x64, 0x52, 0x04, 0x6b, 0x69, 0x6e, 0x64, 0x12, 0x14, 0x0a, 0x05, 0x65,
+	0x70, 0x6f, 0x63, 0x68, 0x18, 0x02, 0x20, 0x01, 0x28, 0x04, 0x52, 0x05, 0x65, 0x70, 0x6f, 0x63,
+	0x68, 0x12, 0x12, 0x0a, 0x04, 0x64, 0x61, 0x74, 0x61, 0x18, 0x03, 0x20, 0x01, 0x28, 0x0c, 0x52,
+	0x04, 0x64, 0x61, 0x74, 0x61, 0x12, 0x33, 0x0a, 0x06, 0x72, 0x65, 0x73, 0x75, 0x6c, 0x74, 0x18,
+	0x04, 0x20, 0x01, 0x28, 0x0e, 0x32, 0x1b, 0x2e, 0x64, 0x69, 0x73, 0x70, 0x61, 0x74, 0x63, 0x68,
+	0x2e, 0x4d, 0x6c, 0x73, 0x4d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x2e, 0x52, 0x65, 0x73, 0x75,
+	0x6c, 0x74, 0x52, 0x06, 0x72, 0x65, 0x73, 0x75, 0x6c, 0x74, 0x12, 0x23, 0x0a, 0x0d, 0x63, 0x75,
+	0x72, 0x72, 0x65, 0x6e, 0x74, 0x5f, 0x65, 0x70, 0x6f, 0x63, 0x68, 0x18, 0x05, 0x20, 0x01, 0x28,
+	0x04, 0x52, 0x0c, 0x63, 0x75, 0x72, 0x72, 0x65, 0x6e, 0x74, 0x45, 0x70, 0x6f, 0x63, 0x68, 0x22,
+	0x3e, 0x0a, 0x04, 0x4b, 0x69, 0x6e, 0x64, 0x12, 0x0f, 0x0a, 0x0b, 0x41, 0x50, 0x50, 0x4c, 0x49,
+	0x43, 0x41, 0x54, 0x49, 0x4f, 0x4e, 0x10, 0x00, 0x12, 0x0c, 0x0a, 0x08, 0x50, 0x52, 0x4f, 0x50,
+	0x4f, 0x53, 0x41, 0x4c, 0x10, 0x01, 0x12, 0x0a, 0x0a, 0x06, 0x43, 0x4f, 0x4d, 0x4d, 0x49, 0x54,
+	0x10, 0x02, 0x12, 0x0b, 0x0a, 0x07, 0x57, 0x45, 0x4c, 0x43, 0x4f, 0x4d, 0x45, 0x10, 0x03, 0x22,
+	0x2e, 0x0a, 0x06, 0x52, 0x65, 0x73, 0x75, 0x6c, 0x74, 0x12, 0x08, 0x0a, 0x04, 0x4e, 0x4f, 0x4e,
+	0x45, 0x10, 0x00, 0x12, 0x0c, 0x0a, 0x08, 0x41, 0x43, 0x43, 0x45, 0x50, 0x54, 0x45, 0x44, 0x10,
+	0x01, 0x12, 0x0c, 0x0a, 0x08, 0x52, 0x45, 0x4a, 0x45, 0x43, 0x54, 0x45, 0x44, 0x10, 0x02, 0x22,
+	0xad, 0x01, 0x0a, 0x0b, 0x4d, 0x6c, 0x73, 0x4c, 0x65, 0x61, 0x66, 0x4e, 0x6f, 0x64, 0x65, 0x12,
+	0x17, 0x0a, 0x07, 0x75, 0x73, 0x65, 0x72, 0x5f, 0x69, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x04,
+	0x52, 0x06, 0x75, 0x73, 0x65, 0x72, 0x49, 0x64, 0x12, 0x1b, 0x0a, 0x09, 0x64, 0x65, 0x76, 0x69,
+	0x63, 0x65, 0x5f, 0x69, 0x64, 0x18, 0x02, 0x20, 0x01, 0x28, 0x04, 0x52, 0x08, 0x64, 0x65, 0x76,
+	0x69, 0x63, 0x65, 0x49, 0x64, 0x12, 0x25, 0x0a, 0x0e, 0x65, 0x6e, 0x63, 0x72, 0x79, 0x70, 0x74,
+	0x69, 0x6f, 0x6e, 0x5f, 0x6b, 0x65, 0x79, 0x18, 0x03, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x0d, 0x65,
+	0x6e, 0x63, 0x72, 0x79, 0x70, 0x74, 0x69, 0x6f, 0x6e, 0x4b, 0x65, 0x79, 0x12, 0x23, 0x0a, 0x0d,
+	0x73, 0x69, 0x67, 0x6e, 0x61, 0x74, 0x75, 0x72, 0x65, 0x5f, 0x6b, 0x65, 0x79, 0x18, 0x04, 0x20,
+	0x01, 0x28, 0x0c, 0x52, 0x0c, 0x73, 0x69, 0x67, 0x6e, 0x61, 0x74, 0x75, 0x72, 0x65, 0x4b, 0x65,
+	0x79, 0x12, 0x1c, 0x0a, 0x09, 0x73, 0x69, 0x67, 0x6e, 0x61, 0x74, 0x75, 0x72, 0x65, 0x18, 0x05,
+	0x20, 0x01, 0x28, 0x0c, 0x52, 0x09, 0x73, 0x69, 0x67, 0x6e, 0x61, 0x74, 0x75, 0x72, 0x65, 0x22,
+	0x73, 0x0a, 0x0d, 0x4d, 0x6c, 0x73, 0x4b, 0x65, 0x79, 0x50, 0x61, 0x63, 0x6b, 0x61, 0x67, 0x65,
+	0x12, 0x29, 0x0a, 0x04, 0x6c, 0x65, 0x61, 0x66, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x15,
+	0x2e, 0x64, 0x69, 0x73, 0x70, 0x61, 0x74, 0x63, 0x68, 0x2e, 0x4d, 0x6c, 0x73, 0x4c, 0x65, 0x61,
+	0x66, 0x4e, 0x6f, 0x64, 0x65, 0x52, 0x04, 0x6c, 0x65, 0x61, 0x66, 0x12, 0x19, 0x0a, 0x08, 0x69,
+	0x6e, 0x69, 0x74, 0x5f, 0x6b, 0x65, 0x79, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x07, 0x69,
+	0x6e, 0x69, 0x74, 0x4b, 0x65, 0x79, 0x12, 0x1c, 0x0a, 0x09, 0x73, 0x69, 0x67, 0x6e, 0x61, 0x74,
+	0x75, 0x72, 0x65, 0x18, 0x03, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x09, 0x73, 0x69, 0x67, 0x6e, 0x61,
+	0x74, 0x75, 0x72, 0x65, 0x22, 0x5f, 0x0a, 0x0d, 0x4d, 0x6c, 0x73, 0x50, 0x61, 0x72, 0x65, 0x6e,
+	0x74, 0x4e, 0x6f, 0x64, 0x65, 0x12, 0x25, 0x0a, 0x0e, 0x65, 0x6e, 0x63, 0x72, 0x79, 0x70, 0x74,
+	0x69, 0x6f, 0x6e, 0x5f, 0x6b, 0x65, 0x79, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x0d, 0x65,
+	0x6e, 0x63, 0x72, 0x79, 0x70, 0x74, 0x69, 0x6f, 0x6e, 0x4b, 0x65, 0x79, 0x12, 0x27, 0x0a, 0x0f,
+	0x75, 0x6e, 0x6d, 0x65, 0x72, 0x67, 0x65, 0x64, 0x5f, 0x6c, 0x65, 0x61, 0x76, 0x65, 0x73, 0x18,
+	0x02, 0x20, 0x03, 0x28, 0x0d, 0x52, 0x0e, 0x75, 0x6e, 0x6d, 0x65, 0x72, 0x67, 0x65, 0x64, 0x4c,
+	0x65, 0x61, 0x76, 0x65, 0x73, 0x22, 0x65, 0x0a, 0x07, 0x4d, 0x6c, 0x73, 0x4e, 0x6f, 0x64, 0x65,
+	0x12, 0x29, 0x0a, 0x04, 0x6c, 0x65, 0x61, 0x66, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x15,
+	0x2e, 0x64, 0x69, 0x73, 0x70, 0x61, 0x74, 0x63, 0x68, 0x2e, 0x4d, 0x6c, 0x73, 0x4c, 0x65, 0x61,
+	0x66, 0x4e, 0x6f, 0x64, 0x65, 0x52, 0x04, 0x6c, 0x65, 0x61, 0x66, 0x12, 0x2f, 0x0a, 0x06, 0x70,
+	0x61, 0x72, 0x65, 0x6e, 0x74, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x17, 0x2e, 0x64, 0x69,
+	0x73, 0x70, 0x61, 0x74, 0x63, 0x68, 0x2e, 0x4d, 0x6c, 0x73, 0x50, 0x61, 0x72, 0x65, 0x6e, 0x74,
+	0x4e, 0x6f, 0x64, 0x65, 0x52, 0x06, 0x70, 0x61, 0x72, 0x65, 0x6e, 0x74, 0x22, 0x52, 0x0a, 0x11,
+	0x4d, 0x6c, 0x73, 0x48, 0x70, 0x6b, 0x65, 0x43, 0x69, 0x70, 0x68, 0x65, 0x72, 0x74, 0x65, 0x78,
+	0x74, 0x12, 0x1d, 0x0a, 0x0a, 0x6b, 0x65, 0x6d, 0x5f, 0x6f, 0x75, 0x74, 0x70, 0x75, 0x74, 0x18,
+	0x01, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x09, 0x6b, 0x65, 0x6d, 0x4f, 0x75, 0x74, 0x70, 0x75, 0x74,
+	0x12, 0x1e, 0x0a, 0x0a, 0x63, 0x69, 0x70, 0x68, 0x65, 0x72, 0x74, 0x65, 0x78, 0x74, 0x18, 0x02,
+	0x20, 0x01, 0x28, 0x0c, 0x52, 0x0a, 0x63, 0x69, 0x70, 0x68, 0x65, 0x72, 0x74, 0x65, 0x78, 0x74,
+	0x22, 0x60, 0x0a, 0x0b, 0x4d, 0x6c, 0x73, 0x50, 0x72, 0x6f, 0x70, 0x6f, 0x73, 0x61, 0x6c, 0x12,
+	0x2b, 0x0a, 0x03, 0x61, 0x64, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x17, 0x2e, 0x64,
+	0x69, 0x73, 0x70, 0x61, 0x74, 0x63, 0x68, 0x2e, 0x4d, 0x6c, 0x73, 0x4b, 0x65, 0x79, 0x50, 0x61,
+	0x63, 0x6b, 0x61, 0x67, 0x65, 0x48, 0x00, 0x52, 0x03, 0x61, 0x64, 0x64, 0x12, 0x18, 0x0a, 0x06,
+	0x72, 0x65, 0x6d, 0x6f, 0x76, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0d, 0x48, 0x00, 0x52, 0x06,
+	0x72, 0x65, 0x6d, 0x6f, 0x76, 0x65, 0x42, 0x0a, 0x0a, 0x08, 0x70, 0x72, 0x6f, 0x70, 0x6f, 0x73,
+	0x61, 0x6c, 0x22, 0x8b, 0x01, 0x0a, 0x11, 0x4d, 0x6c, 0x73, 0x55, 0x70, 0x64, 0x61, 0x74, 0x65,
+	0x50, 0x61, 0x74, 0x68, 0x4e, 0x6f, 0x64, 0x65, 0x12, 0x25, 0x0a, 0x0e, 0x65, 0x6e, 0x63, 0x72,
+	0x79, 0x70, 0x74, 0x69, 0x6f, 0x6e, 0x5f, 0x6b, 0x65, 0x79, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0c,
+	0x52, 0x0d, 0x65, 0x6e, 0x63, 0x72, 0x79, 0x70, 0x74, 0x69, 0x6f, 0x6e, 0x4b, 0x65, 0x79, 0x12,
+	0x4f, 0x0a, 0x15, 0x65, 0x6e, 0x63, 0x72, 0x79, 0x70, 0x74, 0x65, 0x64, 0x5f, 0x70, 0x61, 0x74,
+	0x68, 0x5f, 0x73, 0x65, 0x63, 0x72, 0x65, 0x74, 0x18, 0x02, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x1b,
+	0x2e, 0x64, 0x69, 0x73, 0x70, 0x61, 0x74, 0x63, 0x68, 0x2e, 0x4d, 0x6c, 0x73, 0x48, 0x70, 0x6b,
+	0x65, 0x43, 0x69, 0x70, 0x68, 0x65, 0x72, 0x74, 0x65, 0x78, 0x74, 0x52, 0x13, 0x65, 0x6e, 0x63,
+	0x72, 0x79, 0x70, 0x74, 0x65, 0x64, 0x50, 0x61, 0x74, 0x68, 0x53, 0x65, 0x63, 0x72, 0x65, 0x74,
+	0x22, 0x6d, 0x0a, 0x0d, 0x4d, 0x6c, 0x73, 0x55, 0x70, 0x64, 0x61, 0x74, 0x65, 0x50, 0x61, 0x74,
+	0x68, 0x12, 0x29, 0x0a, 0x04, 0x6c, 0x65, 0x61, 0x66, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0b, 0x32,
+	0x15, 0x2e, 0x64, 0x69, 0x73, 0x70, 0x61, 0x74, 0x63, 0x68, 0x2e, 0x4d, 0x6c, 0x73, 0x4c, 0x65,
+	0x61, 0x66, 0x4e, 0x6f, 0x64, 0x65, 0x52, 0x04, 0x6c, 0x65, 0x61, 0x66, 0x12, 0x31, 0x0a, 0x05,
+	0x6e, 0x6f, 0x64, 0x65, 0x73, 0x18, 0x02, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x1b, 0x2e, 0x64, 0x69,
+	0x73, 0x70, 0x61, 0x74, 0x63, 0x68, 0x2e, 0x4d, 0x6c, 0x73, 0x55, 0x70, 0x64, 0x61, 0x74, 0x65,
+	0x50, 0x61, 0x74, 0x68, 0x4e, 0x6f, 0x64, 0x65, 0x52, 0x05, 0x6e, 0x6f, 0x64, 0x65, 0x73, 0x22,
+	0x6d, 0x0a, 0x09, 0x4d, 0x6c, 0x73, 0x43, 0x6f, 0x6d, 0x6d, 0x69, 0x74, 0x12, 0x33, 0x0a, 0x09,
+	0x70, 0x72, 0x6f, 0x70, 0x6f, 0x73, 0x61, 0x6c, 0x73, 0x18, 0x01, 0x20, 0x03, 0x28, 0x0b, 0x32,
+	0x15, 0x2e, 0x64, 0x69, 0x73, 0x70, 0x61, 0x74, 0x63, 0x68, 0x2e, 0x4d, 0x6c, 0x73, 0x50, 0x72,
+	0x6f, 0x70, 0x6f, 0x73, 0x61, 0x6c, 0x52, 0x09, 0x70, 0x72, 0x6f, 0x70, 0x6f, 0x73, 0x61, 0x6c,
+	0x73, 0x12, 0x2b, 0x0a, 0x04, 0x70, 0x61, 0x74, 0x68, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0b, 0x32,
+	0x17, 0x2e, 0x64, 0x69, 0x73, 0x70, 0x61, 0x74, 0x63, 0x68, 0x2e, 0x4d, 0x6c, 0x73, 0x55, 0x70,
+	0x64, 0x61, 0x74, 0x65, 0x50, 0x61, 0x74, 0x68, 0x52, 0x04, 0x70, 0x61, 0x74, 0x68, 0x22, 0xe3,
+	0x01, 0x0a, 0x10, 0x4d, 0x6c, 0x73, 0x50, 0x75, 0x62, 0x6c, 0x69, 0x63, 0x4d, 0x65, 0x73, 0x73,
+	0x61, 0x67, 0x65, 0x12, 0x19, 0x0a, 0x08, 0x67, 0x72, 0x6f, 0x75, 0x70, 0x5f, 0x69, 0x64, 0x18,
+	0x01, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x07, 0x67, 0x72, 0x6f, 0x75, 0x70, 0x49, 0x64, 0x12, 0x14,
+	0x0a, 0x05, 0x65, 0x70, 0x6f, 0x63, 0x68, 0x18, 0x02, 0x20, 0x01, 0x28, 0x04, 0x52, 0x05, 0x65,
+	0x70, 0x6f, 0x63, 0x68, 0x12, 0x16, 0x0a, 0x06, 0x73, 0x65, 0x6e, 0x64, 0x65, 0x72, 0x18, 0x03,
+	0x20, 0x01, 0x28, 0x0d, 0x52, 0x06, 0x73, 0x65, 0x6e, 0x64, 0x65, 0x72, 0x12, 0x16, 0x0a, 0x06,
+	0x63, 0x6f, 0x6d, 0x6d, 0x69, 0x74, 0x18, 0x04, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x06, 0x63, 0x6f,
+	0x6d, 0x6d, 0x69, 0x74, 0x12, 0x1c, 0x0a, 0x09, 0x73, 0x69, 0x67, 0x6e, 0x61, 0x74, 0x75, 0x72,
+	0x65, 0x18, 0x05, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x09, 0x73, 0x69, 0x67, 0x6e, 0x61, 0x74, 0x75,
+	0x72, 0x65, 0x12, 0x29, 0x0a, 0x10, 0x63, 0x6f, 0x6e, 0x66, 0x69, 0x72, 0x6d, 0x61, 0x74, 0x69,
+	0x6f, 0x6e, 0x5f, 0x74, 0x61, 0x67, 0x18, 0x06, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x0f, 0x63, 0x6f,
+	0x6e, 0x66, 0x69, 0x72, 0x6d, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x54, 0x61, 0x67, 0x12, 0x25, 0x0a,
+	0x0e, 0x6d, 0x65, 0x6d, 0x62, 0x65, 0x72, 0x73, 0x68, 0x69, 0x70, 0x5f, 0x74, 0x61, 0x67, 0x18,
+	0x07, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x0d, 0x6d, 0x65, 0x6d, 0x62, 0x65, 0x72, 0x73, 0x68, 0x69,
+	0x70, 0x54, 0x61, 0x67, 0x22, 0xc4, 0x01, 0x0a, 0x11, 0x4d, 0x6c, 0x73, 0x50, 0x72, 0x69, 0x76,
+	0x61, 0x74, 0x65, 0x4d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x12, 0x19, 0x0a, 0x08, 0x67, 0x72,
+	0x6f, 0x75, 0x70, 0x5f, 0x69, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x07, 0x67, 0x72,
+	0x6f, 0x75, 0x70, 0x49, 0x64, 0x12, 0x14, 0x0a, 0x05, 0x65, 0x70, 0x6f, 0x63, 0x68, 0x18, 0x02,
+	0x20, 0x01, 0x28, 0x04, 0x52, 0x05, 0x65, 0x70, 0x6f, 0x63, 0x68, 0x12, 0x2a, 0x0a, 0x11, 0x73,
+	0x65, 0x6e, 0x64, 0x65, 0x72, 0x5f, 0x64, 0x61, 0x74, 0x61, 0x5f, 0x6e, 0x6f, 0x6e, 0x63, 0x65,
+	0x18, 0x03, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x0f, 0x73, 0x65, 0x6e, 0x64, 0x65, 0x72, 0x44, 0x61,
+	0x74, 0x61, 0x4e, 0x6f, 0x6e, 0x63, 0x65, 0x12, 0x32, 0x0a, 0x15, 0x65, 0x6e, 0x63, 0x72, 0x79,
+	0x70, 0x74, 0x65, 0x64, 0x5f, 0x73, 0x65, 0x6e, 0x64, 0x65, 0x72, 0x5f, 0x64, 0x61, 0x74, 0x61,
+	0x18, 0x04, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x13, 0x65, 0x6e, 0x63, 0x72, 0x79, 0x70, 0x74, 0x65,
+	0x64, 0x53, 0x65, 0x6e, 0x64, 0x65, 0x72, 0x44, 0x61, 0x74, 0x61, 0x12, 0x1e, 0x0a, 0x0a, 0x63,
+	0x69, 0x70, 0x68, 0x65, 0x72, 0x74, 0x65, 0x78, 0x74, 0x18, 0x05, 0x20, 0x01, 0x28, 0x0c, 0x52,
+	0x0a, 0x63, 0x69, 0x70, 0x68, 0x65, 0x72, 0x74, 0x65, 0x78, 0x74, 0x22, 0x49, 0x0a, 0x15, 0x4d,
+	0x6c, 0x73, 0x41, 0x70, 0x70, 0x6c, 0x69, 0x63, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x43, 0x6f, 0x6e,
+	0x74, 0x65, 0x6e, 0x74, 0x12, 0x12, 0x0a, 0x04, 0x64, 0x61, 0x74, 0x61, 0x18, 0x01, 0x20, 0x01,
+	0x28, 0x0c, 0x52, 0x04, 0x64, 0x61, 0x74, 0x61, 0x12, 0x1c, 0x0a, 0x09, 0x73, 0x69, 0x67, 0x6e,
+	0x61, 0x74, 0x75, 0x72, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x09, 0x73, 0x69, 0x67,
+	0x6e, 0x61, 0x74, 0x75, 0x72, 0x65, 0x22, 0x83, 0x02, 0x0a, 0x0c, 0x4d, 0x6c, 0x73, 0x47, 0x72,
+	0x6f, 0x75, 0x70, 0x49, 0x6e, 0x66, 0x6f, 0x12, 0x19, 0x0a, 0x08, 0x67, 0x72, 0x6f, 0x75, 0x70,
+	0x5f, 0x69, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x07, 0x67, 0x72, 0x6f, 0x75, 0x70,
+	0x49, 0x64, 0x12, 0x14, 0x0a, 0x05, 0x65, 0x70, 0x6f, 0x63, 0x68, 0x18, 0x02, 0x20, 0x01, 0x28,
+	0x04, 0x52, 0x05, 0x65, 0x70, 0x6f, 0x63, 0x68, 0x12, 0x25, 0x0a, 0x04, 0x74, 0x72, 0x65, 0x65,
+	0x18, 0x03, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x11, 0x2e, 0x64, 0x69, 0x73, 0x70, 0x61, 0x74, 0x63,
+	0x68, 0x2e, 0x4d, 0x6c, 0x73, 0x4e, 0x6f, 0x64, 0x65, 0x52, 0x04, 0x74, 0x72, 0x65, 0x65, 0x12,
+	0x3a, 0x0a, 0x19, 0x63, 0x6f, 0x6e, 0x66, 0x69, 0x72, 0x6d, 0x65, 0x64, 0x5f, 0x74, 0x72, 0x61,
+	0x6e, 0x73, 0x63, 0x72, 0x69, 0x70, 0x74, 0x5f, 0x68, 0x61, 0x73, 0x68, 0x18, 0x04, 0x20, 0x01,
+	0x28, 0x0c, 0x52, 0x17, 0x63, 0x6f, 0x6e, 0x66, 0x69, 0x72, 0x6d, 0x65, 0x64, 0x54, 0x72, 0x61,
+	0x6e, 0x73, 0x63, 0x72, 0x69, 0x70, 0x74, 0x48, 0x61, 0x73, 0x68, 0x12, 0x29, 0x0a, 0x10, 0x63,
+	0x6f, 0x6e, 0x66, 0x69, 0x72, 0x6d, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x5f, 0x74, 0x61, 0x67, 0x18,
+	0x05, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x0f, 0x63, 0x6f, 0x6e, 0x66, 0x69, 0x72, 0x6d, 0x61, 0x74,
+	0x69, 0x6f, 0x6e, 0x54, 0x61, 0x67, 0x12, 0x16, 0x0a, 0x06, 0x73, 0x69, 0x67, 0x6e, 0x65, 0x72,
+	0x18, 0x06, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x06, 0x73, 0x69, 0x67, 0x6e, 0x65, 0x72, 0x12, 0x1c,
+	0x0a, 0x09, 0x73, 0x69, 0x67, 0x6e, 0x61, 0x74, 0x75, 0x72, 0x65, 0x18, 0x07, 0x20, 0x01, 0x28,
+	0x0c, 0x52, 0x09, 0x73, 0x69, 0x67, 0x6e, 0x61, 0x74, 0x75, 0x72, 0x65, 0x22, 0x57, 0x0a, 0x0f,
+	0x4d, 0x6c, 0x73, 0x47, 0x72, 0x6f, 0x75, 0x70, 0x53, 0x65, 0x63, 0x72, 0x65, 0x74, 0x73, 0x12,
+	0x23, 0x0a, 0x0d, 0x6a, 0x6f, 0x69, 0x6e, 0x65, 0x72, 0x5f, 0x73, 0x65, 0x63, 0x72, 0x65, 0x74,
+	0x18, 0x01, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x0c, 0x6a, 0x6f, 0x69, 0x6e, 0x65, 0x72, 0x53, 0x65,
+	0x63, 0x72, 0x65, 0x74, 0x12, 0x1f, 0x0a, 0x0b, 0x70, 0x61, 0x74, 0x68, 0x5f, 0x73, 0x65, 0x63,
+	0x72, 0x65, 0x74, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x0a, 0x70, 0x61, 0x74, 0x68, 0x53,
+	0x65, 0x63, 0x72, 0x65, 0x74, 0x22, 0x97, 0x01, 0x0a, 0x18, 0x4d, 0x6c, 0x73, 0x45, 0x6e, 0x63,
+	0x72, 0x79, 0x70, 0x74, 0x65, 0x64, 0x47, 0x72, 0x6f, 0x75, 0x70, 0x53, 0x65, 0x63, 0x72, 0x65,
+	0x74, 0x73, 0x12, 0x26, 0x0a, 0x0f, 0x6b, 0x65, 0x79, 0x5f, 0x70, 0x61, 0x63, 0x6b, 0x61, 0x67,
+	0x65, 0x5f, 0x72, 0x65, 0x66, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x0d, 0x6b, 0x65, 0x79,
+	0x50, 0x61, 0x63, 0x6b, 0x61, 0x67, 0x65, 0x52, 0x65, 0x66, 0x12, 0x53, 0x0a, 0x17, 0x65, 0x6e,
+	0x63, 0x72, 0x79, 0x70, 0x74, 0x65, 0x64, 0x5f, 0x67, 0x72, 0x6f, 0x75, 0x70, 0x5f, 0x73, 0x65,
+	0x63, 0x72, 0x65, 0x74, 0x73, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x1b, 0x2e, 0x64, 0x69,
+	0x73, 0x70, 0x61, 0x74, 0x63, 0x68, 0x2e, 0x4d, 0x6c, 0x73, 0x48, 0x70, 0x6b, 0x65, 0x43, 0x69,
+	0x70, 0x68, 0x65, 0x72, 0x74, 0x65, 0x78, 0x74, 0x52, 0x15, 0x65, 0x6e, 0x63, 0x72, 0x79, 0x70,
+	0x74, 0x65, 0x64, 0x47, 0x72, 0x6f, 0x75, 0x70, 0x53, 0x65, 0x63, 0x72, 0x65, 0x74, 0x73, 0x22,
+	0x7c, 0x0a, 0x0a, 0x4d, 0x6c, 0x73, 0x57, 0x65, 0x6c, 0x63, 0x6f, 0x6d, 0x65, 0x12, 0x3c, 0x0a,
+	0x07, 0x73, 0x65, 0x63, 0x72, 0x65, 0x74, 0x73, 0x18, 0x01, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x22,
+	0x2e, 0x64, 0x69, 0x73, 0x70, 0x61, 0x74, 0x63, 0x68, 0x2e, 0x4d, 0x6c, 0x73, 0x45, 0x6e, 0x63,
+	0x72, 0x79, 0x70, 0x74, 0x65, 0x64, 0x47, 0x72, 0x6f, 0x75, 0x70, 0x53, 0x65, 0x63, 0x72, 0x65,
+	0x74, 0x73, 0x52, 0x07, 0x73, 0x65, 0x63, 0x72, 0x65, 0x74, 0x73, 0x12, 0x30, 0x0a, 0x14, 0x65,
+	0x6e, 0x63, 0x72, 0x79, 0x70, 0x74, 0x65, 0x64, 0x5f, 0x67, 0x72, 0x6f, 0x75, 0x70, 0x5f, 0x69,
+	0x6e, 0x66, 0x6f, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x12, 0x65, 0x6e, 0x63, 0x72, 0x79,
+	0x70, 0x74, 0x65, 0x64, 0x47, 0x72, 0x6f, 0x75, 0x70, 0x49, 0x6e, 0x66, 0x6f, 0x22, 0xa4, 0x01,
+	0x0a, 0x11, 0x53, 0x65, 0x6e, 0x64, 0x65, 0x72, 0x43, 0x65, 0x72, 0x74, 0x69, 0x66, 0x69, 0x63,
+	0x61, 0x74, 0x65, 0x12, 0x17, 0x0a, 0x07, 0x75, 0x73, 0x65, 0x72, 0x5f, 0x69, 0x64, 0x18, 0x01,
+	0x20, 0x01, 0x28, 0x04, 0x52, 0x06, 0x75, 0x73, 0x65, 0x72, 0x49, 0x64, 0x12, 0x1b, 0x0a, 0x09,
+	0x64, 0x65, 0x76, 0x69, 0x63, 0x65, 0x5f, 0x69, 0x64, 0x18, 0x02, 0x20, 0x01, 0x28, 0x04, 0x52,
+	0x08, 0x64, 0x65, 0x76, 0x69, 0x63, 0x65, 0x49, 0x64, 0x12, 0x21, 0x0a, 0x0c, 0x69, 0x64, 0x65,
+	0x6e, 0x74, 0x69, 0x74, 0x79, 0x5f, 0x6b, 0x65, 0x79, 0x18, 0x03, 0x20, 0x01, 0x28, 0x0c, 0x52,
+	0x0b, 0x69, 0x64, 0x65, 0x6e, 0x74, 0x69, 0x74, 0x79, 0x4b, 0x65, 0x79, 0x12, 0x18, 0x0a, 0x07,
+	0x65, 0x78, 0x70, 0x69, 0x72, 0x65, 0x73, 0x18, 0x04, 0x20, 0x01, 0x28, 0x03, 0x52, 0x07, 0x65,
+	0x78, 0x70, 0x69, 0x72, 0x65, 0x73, 0x12, 0x1c, 0x0a, 0x09, 0x73, 0x69, 0x67, 0x6e, 0x61, 0x74,
+	0x75, 0x72, 0x65, 0x18, 0x05, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x09, 0x73, 0x69, 0x67, 0x6e, 0x61,
+	0x74, 0x75, 0x72, 0x65, 0x22, 0x55, 0x0a, 0x0e, 0x53, 0x65, 0x61, 0x6c, 0x65, 0x64, 0x45, 0x6e,
+	0x76, 0x65, 0x6c, 0x6f, 0x70, 0x65, 0x12, 0x23, 0x0a, 0x0d, 0x65, 0x70, 0x68, 0x65, 0x6d, 0x65,
+	0x72, 0x61, 0x6c, 0x5f, 0x6b, 0x65, 0x79, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x0c, 0x65,
+	0x70, 0x68, 0x65, 0x6d, 0x65, 0x72, 0x61, 0x6c, 0x4b, 0x65, 0x79, 0x12, 0x1e, 0x0a, 0x0a, 0x63,
+	0x69, 0x70, 0x68, 0x65, 0x72, 0x74, 0x65, 0x78, 0x74, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0c, 0x52,
+	0x0a, 0x63, 0x69, 0x70, 0x68, 0x65, 0x72, 0x74, 0x65, 0x78, 0x74, 0x22, 0x86, 0x01, 0x0a, 0x0d,
+	0x53, 0x65, 0x61, 0x6c, 0x65, 0x64, 0x43, 0x6f, 0x6e, 0x74, 0x65, 0x6e, 0x74, 0x12, 0x3d, 0x0a,
+	0x0b, 0x63, 0x65, 0x72, 0x74, 0x69, 0x66, 0x69, 0x63, 0x61, 0x74, 0x65, 0x18, 0x01, 0x20, 0x01,
+	0x28, 0x0b, 0x32, 0x1b, 0x2e, 0x64, 0x69, 0x73, 0x70, 0x61, 0x74, 0x63, 0x68, 0x2e, 0x53, 0x65,
+	0x6e, 0x64, 0x65, 0x72, 0x43, 0x65, 0x72, 0x74, 0x69, 0x66, 0x69, 0x63, 0x61, 0x74, 0x65, 0x52,
+	0x0b, 0x63, 0x65, 0x72, 0x74, 0x69, 0x66, 0x69, 0x63, 0x61, 0x74, 0x65, 0x12, 0x18, 0x0a, 0x07,
+	0x70, 0x61, 0x79, 0x6c, 0x6f, 0x61, 0x64, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x07, 0x70,
+	0x61, 0x79, 0x6c, 0x6f, 0x61, 0x64, 0x12, 0x1c, 0x0a, 0x09, 0x73, 0x69, 0x67, 0x6e, 0x61, 0x74,
+	0x75, 0x72, 0x65, 0x18, 0x03, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x09, 0x73, 0x69, 0x67, 0x6e, 0x61,
+	0x74, 0x75, 0x72, 0x65, 0x22, 0x52, 0x0a, 0x04, 0x52, 0x75, 0x6c, 0x65, 0x12, 0x0e, 0x0a, 0x02,
+	0x69, 0x70, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x02, 0x69, 0x70, 0x12, 0x12, 0x0a, 0x04,
+	0x70, 0x6f, 0x72, 0x74, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x70, 0x6f, 0x72, 0x74,
+	0x12, 0x16, 0x0a, 0x06, 0x69, 0x6e, 0x73, 0x65, 0x72, 0x74, 0x18, 0x03, 0x20, 0x01, 0x28, 0x08,
+	0x52, 0x06, 0x69, 0x6e, 0x73, 0x65, 0x72, 0x74, 0x12, 0x0e, 0x0a, 0x02, 0x69, 0x64, 0x18, 0x04,
+	0x20, 0x01, 0x28, 0x04, 0x52, 0x02, 0x69, 0x64, 0x2a, 0x9d, 0x01, 0x0a, 0x0a, 0x43, 0x61, 0x70,
+	0x61, 0x62, 0x69, 0x6c, 0x69, 0x74, 0x79, 0x12, 0x1a, 0x0a, 0x16, 0x43, 0x41, 0x50, 0x41, 0x42,
+	0x49, 0x4c, 0x49, 0x54, 0x59, 0x5f, 0x55, 0x4e, 0x53, 0x50, 0x45, 0x43, 0x49, 0x46, 0x49, 0x45,
+	0x44, 0x10, 0x00, 0x12, 0x0c, 0x0a, 0x08, 0x43, 0x48, 0x41, 0x4e, 0x4e, 0x45, 0x4c, 0x53, 0x10,
+	0x01, 0x12, 0x0f, 0x0a, 0x0b, 0x53, 0x45, 0x4e, 0x44, 0x45, 0x52, 0x5f, 0x4b, 0x45, 0x59, 0x53,
+	0x10, 0x02, 0x12, 0x0e, 0x0a, 0x0a, 0x4d, 0x4c, 0x53, 0x5f, 0x47, 0x52, 0x4f, 0x55, 0x50, 0x53,
+	0x10, 0x03, 0x12, 0x11, 0x0a, 0x0d, 0x53, 0x45, 0x41, 0x4c, 0x45, 0x44, 0x5f, 0x53, 0x45, 0x4e,
+	0x44, 0x45, 0x52, 0x10, 0x04, 0x12, 0x10, 0x0a, 0x0c, 0x46, 0x4c, 0x4f, 0x57, 0x5f, 0x43, 0x4f,
+	0x4e, 0x54, 0x52, 0x4f, 0x4c, 0x10, 0x05, 0x12, 0x11, 0x0a, 0x0d, 0x45, 0x52, 0x52, 0x4f, 0x52,
+	0x5f, 0x52, 0x45, 0x50, 0x4f, 0x52, 0x54, 0x53, 0x10, 0x06, 0x12, 0x0c, 0x0a, 0x08, 0x50, 0x52,
+	0x45, 0x53, 0x45, 0x4e, 0x43, 0x45, 0x10, 0x07, 0x42, 0x25, 0x5a, 0x23, 0x67, 0x69, 0x74, 0x68,
+	0x75, 0x62, 0x2e, 0x63, 0x6f, 0x6d, 0x2f, 0x41, 0x70, 0x75, 0x72, 0x65, 0x72, 0x2f, 0x65, 0x32,
+	0x65, 0x65, 0x63, 0x68, 0x61, 0x74, 0x2f, 0x64, 0x69, 0x73, 0x70, 0x61, 0x74, 0x63, 0x68, 0x62,
+	0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x33,
+}
+
+var (
+	file_dispatch_proto_rawDescOnce sync.Once
+	file_dispatch_proto_rawDescData = file_dispatch_proto_rawDesc
+)
+
+func file_dispatch_proto_rawDescGZIP() []byte {
+	file_dispatch_proto_rawDescOnce.Do(func() {
+		file_dispatch_proto_rawDescData = protoimpl.X.CompressGZIP(file_dispatch_proto_rawDescData)
+	})
+	return file_dispatch_proto_rawDescData
+}
+
+var file_dispatch_proto_enumTypes = make([]protoimpl.EnumInfo, 13)
+var file_dispatch_proto_msgTypes = make([]protoimpl.MessageInfo, 47)
+var file_dispatch_proto_goTypes = []interface{}{
+	(Capability)(0),                  // 0: dispatch.Capability
+	(Action_ActionType)(0),           // 1: dispatch.Action.ActionType
+	(ChannelOp_Op)(0),                // 2: dispatch.ChannelOp.Op
+	(ChannelMember_Role)(0),          // 3: dispatch.ChannelMember.Role
+	(Typing_State)(0),                // 4: dispatch.Typing.State
+	(Presence_Op)(0),                 // 5: dispatch.Presence.Op
+	(Presence_State)(0),              // 6: dispatch.Presence.State
+	(PresenceSettings_Visibility)(0), // 7: dispatch.PresenceSettings.Visibility
+	(Error_Code)(0),                  // 8: dispatch.Error.Code
+	(AuthResult_Status)(0),           // 9: dispatch.AuthResult.Status
+	(Throttle_Scope)(0),              // 10: dispatch.Throttle.Scope
+	(MlsMessage_Kind)(0),             // 11: dispatch.MlsMessage.Kind
+	(MlsMessage_Result)(0),           // 12: dispatch.MlsMessage.Result
+	(*Payload)(nil),                  // 13: dispatch.Payload
+	(*MessageRef)(nil),               // 14: dispatch.MessageRef
+	(*Edit)(nil),                     // 15: dispatch.Edit
+	(*Delete)(nil),                   // 16: dispatch.Delete
+	(*Reaction)(nil),                 // 17: dispatch.Reaction
+	(*Attachment)(nil),               // 18: dispatch.Attachment
+	(*Action)(nil),                   // 19: dispatch.Action
+	(*DeviceCopy)(nil),               // 20: dispatch.DeviceCopy
+	(*ChannelOp)(nil),                // 21: dispatch.ChannelOp
+	(*ChannelMember)(nil),            // 22: dispatch.ChannelMember
+	(*ChannelEvent)(nil),             // 23: dispatch.ChannelEvent
+	(*Typing)(nil),                   // 24: dispatch.Typing
+	(*Presence)(nil),                 // 25: dispatch.Presence
+	(*PresenceSettings)(nil),         // 26: dispatch.PresenceSettings
+	(*Envelope)(nil),                 // 27: dispatch.Envelope
+	(*Error)(nil),                    // 28: dispatch.Error
+	(*Authentication)(nil),           // 29: dispatch.Authentication
+	(*LoginToken)(nil),               // 30: dispatch.LoginToken
+	(*AuthChallenge)(nil),            // 31: dispatch.AuthChallenge
+	(*DeviceProof)(nil),              // 32: dispatch.DeviceProof
+	(*AuthResult)(nil),               // 33: dispatch.AuthResult
+	(*SessionHeader)(nil),            // 34: dispatch.SessionHeader
+	(*Throttle)(nil),                 // 35: dispatch.Throttle
+	(*GoAway)(nil),                   // 36: dispatch.GoAway
+	(*SenderKeyDistribution)(nil),    // 37: dispatch.SenderKeyDistribution
+	(*SenderKeyMessage)(nil),         // 38: dispatch.SenderKeyMessage
+	(*MlsMessage)(nil),               // 39: dispatch.MlsMessage
+	(*MlsLeafNode)(nil),              // 40: dispatch.MlsLeafNode
+	(*MlsKeyPackage)(nil),            // 41: dispatch.MlsKeyPackage
+	(*MlsParentNode)(nil),            // 42: dispatch.MlsParentNode
+	(*MlsNode)(nil),                  // 43: dispatch.MlsNode
+	(*MlsHpkeCiphertext)(nil),        // 44: dispatch.MlsHpkeCiphertext
+	(*MlsProposal)(nil),              // 45: dispatch.MlsProposal
+	(*MlsUpdatePathNode)(nil),        // 46: dispatch.MlsUpdatePathNode
+	(*MlsUpdatePath)(nil),            // 47: dispatch.MlsUpdatePath
+	(*MlsCommit)(nil),                // 48: dispatch.MlsCommit
+	(*MlsPublicMessage)(nil),         // 49: dispatch.MlsPublicMessage
+	(*MlsPrivateMessage)(nil),        // 50: dispatch.MlsPrivateMessage
+	(*MlsApplicationContent)(nil),    // 51: dispatch.MlsApplicationContent
+	(*MlsGroupInfo)(nil),             // 52: dispatch.MlsGroupInfo
+	(*MlsGroupSecrets)(nil),          // 53: dispatch.MlsGroupSecrets
+	(*MlsEncryptedGroupSecrets)(nil), // 54: dispatch.MlsEncryptedGroupSecrets
+	(*MlsWelcome)(nil),               // 55: dispatch.MlsWelcome
+	(*SenderCertificate)(nil),        // 56: dispatch.SenderCertificate
+	(*SealedEnvelope)(nil),           // 57: dispatch.SealedEnvelope
+	(*SealedContent)(nil),            // 58: dispatch.SealedContent
+	(*Rule)(nil),                     // 59: dispatch.Rule
+}
+var file_dispatch_proto_depIdxs = []int32{
+	18, // 0: dispatch.Payload.attachments:type_name -> dispatch.Attachment
+	15, // 1: dispatch.Payload.edit:type_name -> dispatch.Edit
+	16, // 2: dispatch.Payload.delete:type_name -> dispatch.Delete
+	17, // 3: dispatch.Payload.reaction:type_name -> dispatch.Reaction
+	14, // 4: dispatch.Edit.target:type_name -> dispatch.MessageRef
+	18, // 5: dispatch.Edit.attachments:type_name -> dispatch.Attachment
+	14, // 6: dispatch.Delete.target:type_name -> dispatch.MessageRef
+	14, // 7: dispatch.Reaction.target:type_name -> dispatch.MessageRef
+	1,  // 8: dispatch.Action.type:type_name -> dispatch.Action.ActionType
+	20, // 9: dispatch.Action.copies:type_name -> dispatch.DeviceCopy
+	2,  // 10: dispatch.ChannelOp.op:type_name -> dispatch.ChannelOp.Op
+	3,  // 11: dispatch.ChannelOp.role:type_name -> dispatch.ChannelMember.Role
+	3,  // 12: dispatch.ChannelMember.role:type_name -> dispatch.ChannelMember.Role
+	21, // 13: dispatch.ChannelEvent.op:type_name -> dispatch.ChannelOp
+	22, // 14: dispatch.ChannelEvent.members:type_name -> dispatch.ChannelMember
+	4,  // 15: dispatch.Typing.state:type_name -> dispatch.Typing.State
+	5,  // 16: dispatch.Presence.op:type_name -> dispatch.Presence.Op
+	6,  // 17: dispatch.Presence.state:type_name -> dispatch.Presence.State
+	26, // 18: dispatch.Presence.settings:type_name -> dispatch.PresenceSettings
+	7,  // 19: dispatch.PresenceSettings.presence:type_name -> dispatch.PresenceSettings.Visibility
+	7,  // 20: dispatch.PresenceSettings.last_seen:type_name -> dispatch.PresenceSettings.Visibility
+	0,  // 21: dispatch.Envelope.capabilities:type_name -> dispatch.Capability
+	29, // 22: dispatch.Envelope.authentication:type_name -> dispatch.Authentication
+	33, // 23: dispatch.Envelope.auth_result:type_name -> dispatch.AuthResult
+	31, // 24: dispatch.Envelope.challenge:type_name -> dispatch.AuthChallenge
+	32, // 25: dispatch.Envelope.device_proof:type_name -> dispatch.DeviceProof
+	8,  // 26: dispatch.Error.code:type_name -> dispatch.Error.Code
+	30, // 27: dispatch.Authentication.login_token:type_name -> dispatch.LoginToken
+	9,  // 28: dispatch.AuthResult.status:type_name -> dispatch.AuthResult.Status
+	0,  // 29: dispatch.SessionHeader.capabilities:type_name -> dispatch.Capability
+	10, // 30: dispatch.Throttle.scope:type_name -> dispatch.Throttle.Scope
+	11, // 31: dispatch.MlsMessage.kind:type_name -> dispatch.MlsMessage.Kind
+	12, // 32: dispatch.MlsMessage.result:type_name -> dispatch.MlsMessage.Result
+	40, // 33: dispatch.MlsKeyPackage.leaf:type_name -> dispatch.MlsLeafNode
+	40, // 34: dispatch.MlsNode.leaf:type_name -> dispatch.MlsLeafNode
+	42, // 35: dispatch.MlsNode.parent:type_name -> dispatch.MlsParentNode
+	41, // 36: dispatch.MlsProposal.add:type_name -> dispatch.MlsKeyPackage
+	44, // 37: dispatch.MlsUpdatePathNode.encrypted_path_secret:type_name -> dispatch.MlsHpkeCiphertext
+	40, // 38: dispatch.MlsUpdatePath.leaf:type_name -> dispatch.MlsLeafNode
+	46, // 39: dispatch.MlsUpdatePath.nodes:type_name -> dispatch.MlsUpdatePathNode
+	45, // 40: dispatch.MlsCommit.proposals:type_name -> dispatch.MlsProposal
+	47, // 41: dispatch.MlsCommit.path:type_name -> dispatch.MlsUpdatePath
+	43, // 42: dispatch.MlsGroupInfo.tree:type_name -> dispatch.MlsNode
+	44, // 43: dispatch.MlsEncryptedGroupSecrets.encrypted_group_secrets:type_name -> dispatch.MlsHpkeCiphertext
+	54, // 44: dispatch.MlsWelcome.secrets:type_name -> dispatch.MlsEncryptedGroupSecrets
+	56, // 45: dispatch.SealedContent.certificate:type_name -> dispatch.SenderCertificate
+	46, // [46:46] is the sub-list for method output_type
+	46, // [46:46] is the sub-list for method input_type
+	46, // [46:46] is the sub-list for extension type_name
+	46, // [46:46] is the sub-list for extension extendee
+	0,  // [0:46] is the sub-list for field type_name
+}
+
+func init() { file_dispatch_proto_init() }
+func file_dispatch_proto_init() {
+	if File_dispatch_proto != nil {
+		return
+	}
+	if !protoimpl.UnsafeEnabled {
+		file_dispatch_proto_msgTypes[0].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*Payload); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_dispatch_proto_msgTypes[1].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*MessageRef); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_dispatch_proto_msgTypes[2].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*Edit); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_dispatch_proto_msgTypes[3].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*Delete); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_dispatch_proto_msgTypes[4].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*Reaction); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_dispatch_proto_msgTypes[5].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*Attachment); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_dispatch_proto_msgTypes[6].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*Action); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_dispatch_proto_msgTypes[7].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*DeviceCopy); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_dispatch_proto_msgTypes[8].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*ChannelOp); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_dispatch_proto_msgTypes[9].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*ChannelMember); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_dispatch_proto_msgTypes[10].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*ChannelEvent); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_dispatch_proto_msgTypes[11].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*Typing); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_dispatch_proto_msgTypes[12].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*Presence); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_dispatch_proto_msgTypes[13].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*PresenceSettings); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_dispatch_proto_msgTypes[14].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*Envelope); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_dispatch_proto_msgTypes[15].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*Error); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_dispatch_proto_msgTypes[16].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*Authentication); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_dispatch_proto_msgTypes[17].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*LoginToken); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_dispatch_proto_msgTypes[18].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*AuthChallenge); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_dispatch_proto_msgTypes[19].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*DeviceProof); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_dispatch_proto_msgTypes[20].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*AuthResult); i {
+			case 0:
 				return &v.state
 			case 1:
 				return &v.sizeCache
@@ -483,7 +5092,307 @@ func file_dispatch_proto_init() {
 				return nil
 			}
 		}
-		file_dispatch_proto_msgTypes[3].Exporter = func(v interface{}, i int) interface{} {
+		file_dispatch_proto_msgTypes[21].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*SessionHeader); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_dispatch_proto_msgTypes[22].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*Throttle); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_dispatch_proto_msgTypes[23].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*GoAway); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_dispatch_proto_msgTypes[24].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*SenderKeyDistribution); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_dispatch_proto_msgTypes[25].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*SenderKeyMessage); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_dispatch_proto_msgTypes[26].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*MlsMessage); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_dispatch_proto_msgTypes[27].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*MlsLeafNode); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_dispatch_proto_msgTypes[28].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*MlsKeyPackage); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_dispatch_proto_msgTypes[29].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*MlsParentNode); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_dispatch_proto_msgTypes[30].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*MlsNode); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_dispatch_proto_msgTypes[31].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*MlsHpkeCiphertext); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_dispatch_proto_msgTypes[32].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*MlsProposal); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_dispatch_proto_msgTypes[33].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*MlsUpdatePathNode); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_dispatch_proto_msgTypes[34].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*MlsUpdatePath); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_dispatch_proto_msgTypes[35].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*MlsCommit); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_dispatch_proto_msgTypes[36].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*MlsPublicMessage); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_dispatch_proto_msgTypes[37].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*MlsPrivateMessage); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_dispatch_proto_msgTypes[38].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*MlsApplicationContent); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_dispatch_proto_msgTypes[39].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*MlsGroupInfo); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_dispatch_proto_msgTypes[40].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*MlsGroupSecrets); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_dispatch_proto_msgTypes[41].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*MlsEncryptedGroupSecrets); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_dispatch_proto_msgTypes[42].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*MlsWelcome); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_dispatch_proto_msgTypes[43].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*SenderCertificate); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_dispatch_proto_msgTypes[44].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*SealedEnvelope); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_dispatch_proto_msgTypes[45].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*SealedContent); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_dispatch_proto_msgTypes[46].Exporter = func(v interface{}, i int) interface{} {
 			switch v := v.(*Rule); i {
 			case 0:
 				return &v.state
@@ -496,13 +5405,22 @@ func file_dispatch_proto_init() {
 			}
 		}
 	}
+	file_dispatch_proto_msgTypes[0].OneofWrappers = []interface{}{
+		(*Payload_Edit)(nil),
+		(*Payload_Delete)(nil),
+		(*Payload_Reaction)(nil),
+	}
+	file_dispatch_proto_msgTypes[32].OneofWrappers = []interface{}{
+		(*MlsProposal_Add)(nil),
+		(*MlsProposal_Remove)(nil),
+	}
 	type x struct{}
 	out := protoimpl.TypeBuilder{
 		File: protoimpl.DescBuilder{
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: file_dispatch_proto_rawDesc,
-			NumEnums:      1,
-			NumMessages:   4,
+			NumEnums:      13,
+			NumMessages:   47,
 			NumExtensions: 0,
 			NumServices:   0,
 		},
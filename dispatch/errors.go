@@ -0,0 +1,37 @@
+package dispatch
+
+import "github.com/golang/protobuf/proto"
+
+// retryable lists the codes for which the same action may succeed later.
+var retryable = map[Error_Code]bool{
+	Error_UNAVAILABLE: true,
+	Error_INTERNAL:    true,
+}
+
+// NewError returns an Error about the action with the given message id, or
+// about the connection if it is 0.
+func NewError(code Error_Code, detail string, messageID uint64) *Error {
+	return &Error{
+		Code:      code,
+		Detail:    detail,
+		Retryable: retryable[code],
+		MessageId: messageID,
+	}
+}
+
+// Error makes *Error an error, so that it can be returned like one.
+func (x *Error) Error() string {
+	if x.GetDetail() == "" {
+		return "dispatch: " + x.GetCode().String()
+	}
+	return "dispatch: " + x.GetCode().String() + ": " + x.GetDetail()
+}
+
+// Action wraps the error in an ERROR action for the device it is sent to.
+func (x *Error) Action() (*Action, error) {
+	b, err := proto.Marshal(x)
+	if err != nil {
+		return nil, err
+	}
+	return &Action{Type: Action_ERROR, Payload: b}, nil
+}
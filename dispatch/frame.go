@@ -0,0 +1,82 @@
+package dispatch
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+
+	"github.com/golang/protobuf/proto"
+)
+
+// Messages on a chat connection are framed with a 4 byte big-endian length
+// prefix, so that a reader always consumes exactly one message at a time no
+// matter how the stream was split into reads.
+
+// FrameHeaderSize is the length of the prefix preceding every message.
+const FrameHeaderSize = 4
+
+// ErrFrameTooLarge is returned when a frame does not fit the buffer it is
+// read into.
+var ErrFrameTooLarge = errors.New("dispatch: frame too large")
+
+// ReadRawFrame reads one complete frame, header included, into buf and
+// returns its total length.
+func ReadRawFrame(r io.Reader, buf []byte) (int, error) {
+	if len(buf) < FrameHeaderSize {
+		return 0, ErrFrameTooLarge
+	}
+	if _, err := io.ReadFull(r, buf[:FrameHeaderSize]); err != nil {
+		return 0, err
+	}
+	n := int(binary.BigEndian.Uint32(buf))
+	if n > len(buf)-FrameHeaderSize {
+		return 0, ErrFrameTooLarge
+	}
+	if _, err := io.ReadFull(r, buf[FrameHeaderSize:FrameHeaderSize+n]); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return 0, err
+	}
+	return FrameHeaderSize + n, nil
+}
+
+// ReadFrame reads one frame into buf and returns the message it carries.
+func ReadFrame(r io.Reader, buf []byte) ([]byte, error) {
+	n, err := ReadRawFrame(r, buf)
+	if err != nil {
+		return nil, err
+	}
+	return buf[FrameHeaderSize:n], nil
+}
+
+// AppendFrame appends msg with its length prefix to dst.
+func AppendFrame(dst, msg []byte) []byte {
+	var hdr [FrameHeaderSize]byte
+	binary.BigEndian.PutUint32(hdr[:], uint32(len(msg)))
+	return append(append(dst, hdr[:]...), msg...)
+}
+
+// WriteFrame writes msg as a single frame with one call to w.Write.
+func WriteFrame(w io.Writer, msg []byte) error {
+	_, err := w.Write(AppendFrame(make([]byte, 0, FrameHeaderSize+len(msg)), msg))
+	return err
+}
+
+// ReadMessage reads one frame into buf and decodes it into m.
+func ReadMessage(r io.Reader, buf []byte, m proto.Message) error {
+	b, err := ReadFrame(r, buf)
+	if err != nil {
+		return err
+	}
+	return proto.Unmarshal(b, m)
+}
+
+// WriteMessage encodes m and writes it as a single frame.
+func WriteMessage(w io.Writer, m proto.Message) error {
+	b, err := proto.Marshal(m)
+	if err != nil {
+		return err
+	}
+	return WriteFrame(w, b)
+}
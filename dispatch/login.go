@@ -0,0 +1,69 @@
+package dispatch
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"time"
+)
+
+const loginTokenContext = "e2eechat login token v1"
+
+var (
+	// ErrBadLoginToken is returned when a LoginToken is missing or was not
+	// signed by the login key.
+	ErrBadLoginToken = errors.New("dispatch: bad login token")
+
+	// ErrExpiredLoginToken is returned for login tokens past their expiry.
+	ErrExpiredLoginToken = errors.New("dispatch: expired login token")
+)
+
+// SigningBytes returns the canonical encoding of every field except the
+// signature, which is what the login key signs.
+func (x *LoginToken) SigningBytes() []byte {
+	var b bytes.Buffer
+	b.WriteString(loginTokenContext)
+	binary.Write(&b, binary.BigEndian, x.GetUserId())
+	binary.Write(&b, binary.BigEndian, x.GetExpires())
+	binary.Write(&b, binary.BigEndian, uint32(len(x.GetNonce())))
+	b.Write(x.GetNonce())
+	return b.Bytes()
+}
+
+// Sign gives the token a fresh nonce and signs it with key.
+func (x *LoginToken) Sign(key ed25519.PrivateKey) error {
+	x.Nonce = make([]byte, 16)
+	if _, err := rand.Read(x.Nonce); err != nil {
+		return err
+	}
+	x.Signature = ed25519.Sign(key, x.SigningBytes())
+	return nil
+}
+
+// Verify checks the signature against key and that the token has not
+// expired. A nil token fails with ErrBadLoginToken.
+func (x *LoginToken) Verify(key ed25519.PublicKey) error {
+	if x == nil || !ed25519.Verify(key, x.SigningBytes(), x.GetSignature()) {
+		return ErrBadLoginToken
+	}
+	if time.Now().After(time.Unix(x.GetExpires(), 0)) {
+		return ErrExpiredLoginToken
+	}
+	return nil
+}
+
+const deviceProofContext = "e2eechat device proof v1"
+
+// DeviceProofBytes returns what a device signs with its identity key to
+// answer the AuthChallenge with nonce.
+func DeviceProofBytes(nonce []byte, userID, deviceID uint64) []byte {
+	var b bytes.Buffer
+	b.WriteString(deviceProofContext)
+	binary.Write(&b, binary.BigEndian, userID)
+	binary.Write(&b, binary.BigEndian, deviceID)
+	binary.Write(&b, binary.BigEndian, uint32(len(nonce)))
+	b.Write(nonce)
+	return b.Bytes()
+}
@@ -0,0 +1,64 @@
+package dispatch
+
+import (
+	"time"
+	"unicode/utf8"
+)
+
+// MaxReactionSize bounds the emoji of a Reaction, enough for the longest
+// emoji sequences.
+const MaxReactionSize = 64
+
+// Ref returns the reference other payloads use to point at this one.
+func (x *Payload) Ref() *MessageRef {
+	return &MessageRef{SenderId: x.GetSenderId(), MessageId: x.GetMessageId()}
+}
+
+// Target returns the message an edit, delete or reaction refers to, or nil
+// for a new message.
+func (x *Payload) Target() *MessageRef {
+	switch c := x.GetChange().(type) {
+	case *Payload_Edit:
+		return c.Edit.GetTarget()
+	case *Payload_Delete:
+		return c.Delete.GetTarget()
+	case *Payload_Reaction:
+		return c.Reaction.GetTarget()
+	}
+	return nil
+}
+
+// Allowed reports whether a payload received from sender may be applied.
+// Users only edit and delete their own messages, and a reaction needs an
+// emoji. Receiving clients ignore payloads for which it is false. sender is
+// the one srvtls or the sender certificate vouches for, not the sender_id of
+// the payload.
+func (x *Payload) Allowed(sender uint64) bool {
+	if x.GetSenderId() != sender {
+		return false
+	}
+	switch c := x.GetChange().(type) {
+	case *Payload_Edit:
+		return c.Edit.GetTarget().GetSenderId() == sender
+	case *Payload_Delete:
+		return c.Delete.GetTarget().GetSenderId() == sender
+	case *Payload_Reaction:
+		e := c.Reaction.GetEmoji()
+		return c.Reaction.GetTarget() != nil && e != "" && len(e) <= MaxReactionSize && utf8.ValidString(e)
+	}
+	return true
+}
+
+// Matches reports whether r refers to p.
+func (r *MessageRef) Matches(p *Payload) bool {
+	return r.GetMessageId() != 0 && r.GetSenderId() == p.GetSenderId() && r.GetMessageId() == p.GetMessageId()
+}
+
+// ExpiresAt returns when a message read at read is to be deleted, or the
+// zero time if it is kept.
+func (x *Payload) ExpiresAt(read time.Time) time.Time {
+	if x.GetExpireTimer() == 0 {
+		return time.Time{}
+	}
+	return read.Add(time.Duration(x.GetExpireTimer()) * time.Second)
+}
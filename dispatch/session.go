@@ -0,0 +1,67 @@
+package dispatch
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"time"
+)
+
+const sessionHeaderContext = "e2eechat session header v1"
+
+var (
+	// ErrBadSignature is returned when a SessionHeader was not signed by the
+	// expected key.
+	ErrBadSignature = errors.New("dispatch: bad session header signature")
+
+	// ErrStaleSession is returned when a SessionHeader was issued too far
+	// from the current time.
+	ErrStaleSession = errors.New("dispatch: stale session header")
+)
+
+// SigningBytes returns the canonical encoding of every field except the
+// signature, which is what the proxy signs.
+func (x *SessionHeader) SigningBytes() []byte {
+	var b bytes.Buffer
+	b.WriteString(sessionHeaderContext)
+	binary.Write(&b, binary.BigEndian, x.GetUserId())
+	binary.Write(&b, binary.BigEndian, x.GetDeviceId())
+	binary.Write(&b, binary.BigEndian, uint32(len(x.GetCorrelationId())))
+	b.WriteString(x.GetCorrelationId())
+	binary.Write(&b, binary.BigEndian, x.GetIssuedAt())
+	binary.Write(&b, binary.BigEndian, uint32(len(x.GetNonce())))
+	b.Write(x.GetNonce())
+	binary.Write(&b, binary.BigEndian, x.GetVersion())
+	binary.Write(&b, binary.BigEndian, uint32(len(x.GetCapabilities())))
+	for _, c := range x.GetCapabilities() {
+		binary.Write(&b, binary.BigEndian, int32(c))
+	}
+	return b.Bytes()
+}
+
+// Sign stamps the header with the current time and a fresh nonce and signs
+// it with key.
+func (x *SessionHeader) Sign(key ed25519.PrivateKey) error {
+	x.IssuedAt = time.Now().Unix()
+	x.Nonce = make([]byte, 16)
+	if _, err := rand.Read(x.Nonce); err != nil {
+		return err
+	}
+	x.Signature = ed25519.Sign(key, x.SigningBytes())
+	return nil
+}
+
+// Verify checks the signature against key and that the header was issued
+// within maxSkew of now.
+func (x *SessionHeader) Verify(key ed25519.PublicKey, maxSkew time.Duration) error {
+	if !ed25519.Verify(key, x.SigningBytes(), x.GetSignature()) {
+		return ErrBadSignature
+	}
+	skew := time.Since(time.Unix(x.GetIssuedAt(), 0))
+	if skew > maxSkew || skew < -maxSkew {
+		return ErrStaleSession
+	}
+	return nil
+}
@@ -0,0 +1,95 @@
+package dispatch
+
+import "errors"
+
+const (
+	// Version1 is the protocol of clients that open with a bare
+	// Authentication and have no capabilities.
+	Version1 uint32 = 1
+
+	// Version2 adds Envelope and capability negotiation.
+	Version2 uint32 = 2
+
+	// CurrentVersion is the highest version this package speaks.
+	CurrentVersion = Version2
+)
+
+// EnvelopeALPN is the ALPN protocol a client offers to announce that it
+// opens with an Envelope when it authenticates with a client certificate,
+// where there is no Authentication to tell the versions apart.
+const EnvelopeALPN = "e2eechat/2"
+
+// ErrUnsupportedVersion is returned for versions below the accepted minimum.
+var ErrUnsupportedVersion = errors.New("dispatch: unsupported protocol version")
+
+// SupportedCapabilities lists every capability this package knows.
+var SupportedCapabilities = []Capability{
+	Capability_CHANNELS,
+	Capability_SENDER_KEYS,
+	Capability_MLS_GROUPS,
+	Capability_SEALED_SENDER,
+	Capability_FLOW_CONTROL,
+	Capability_ERROR_REPORTS,
+	Capability_PRESENCE,
+}
+
+var requiredCapability = map[Action_ActionType]Capability{
+	Action_GOAWAY:     Capability_FLOW_CONTROL,
+	Action_THROTTLE:   Capability_FLOW_CONTROL,
+	Action_CHANNEL:    Capability_CHANNELS,
+	Action_SENDER_KEY: Capability_SENDER_KEYS,
+	Action_MLS:        Capability_MLS_GROUPS,
+	Action_SEALED:     Capability_SEALED_SENDER,
+	Action_ERROR:      Capability_ERROR_REPORTS,
+	Action_TYPING:     Capability_PRESENCE,
+	Action_PRESENCE:   Capability_PRESENCE,
+}
+
+// NegotiateVersion returns the version to speak with a peer whose highest
+// version is offered, given the range accepted locally. Newer peers are
+// downgraded to max, older ones than min are refused. An offered version of
+// 0 is a peer predating versions, which speaks Version1.
+func NegotiateVersion(offered, min, max uint32) (uint32, error) {
+	if offered == 0 {
+		offered = Version1
+	}
+	if offered < min {
+		return 0, ErrUnsupportedVersion
+	}
+	if offered > max {
+		return max, nil
+	}
+	return offered, nil
+}
+
+// NegotiateCapabilities returns the capabilities in both offered and
+// supported, in the order of supported. Version1 has none.
+func NegotiateCapabilities(version uint32, offered, supported []Capability) []Capability {
+	if version < Version2 {
+		return nil
+	}
+	var caps []Capability
+	for _, c := range supported {
+		if HasCapability(offered, c) {
+			caps = append(caps, c)
+		}
+	}
+	return caps
+}
+
+// HasCapability reports whether caps contains c.
+func HasCapability(caps []Capability, c Capability) bool {
+	for _, have := range caps {
+		if have == c {
+			return true
+		}
+	}
+	return false
+}
+
+// Accepts reports whether a device that negotiated caps can handle actions
+// of type t.
+func Accepts(caps []Capability, t Action_ActionType) bool {
+	c, ok := requiredCapability[t]
+	return !ok || HasCapability(caps, c)
+}
@@ -2,7 +2,6 @@ package main
 
 import (
 	"fmt"
-	"log"
 	"net"
 	"os"
 
@@ -10,6 +9,7 @@ import (
 	"sync"
 
 	"github.com/Apurer/e2eechat/dispatch"
+	"github.com/Apurer/e2eechat/logging"
 	"github.com/Apurer/eev"
 	"github.com/Apurer/eev/privatekey"
 	"github.com/Apurer/ipexc"
@@ -24,6 +24,8 @@ type remoteAddr struct {
 var remAddrSrvTLS remoteAddr
 var remAddrSrvHTTPS remoteAddr
 
+var logger = logging.FromEnv("ipmgr")
+
 const (
 	bufferSize = 1024
 )
@@ -40,34 +42,37 @@ var (
 			return new(dispatch.Rule)
 		},
 	}
-)
 
-func init() {
+	// insertRule and deleteRule change the firewall, tests replace them.
+	insertRule = ipexc.Insert
+	deleteRule = ipexc.Delete
+)
 
+// loadConfig reads the addresses encrypted with the key at KEY_PATH. The
+// key path and its passphrase are removed from the environment once read.
+func loadConfig() error {
 	keypath := os.Getenv("KEY_PATH")
-	err := os.Unsetenv("KEY_PATH")
-	if err != nil {
-		panic(err)
+	if err := os.Unsetenv("KEY_PATH"); err != nil {
+		return err
 	}
 	passphrase := os.Getenv("PASSPHRASE")
-	err = os.Unsetenv("PASSPHRASE")
-	if err != nil {
-		panic(err)
+	if err := os.Unsetenv("PASSPHRASE"); err != nil {
+		return err
 	}
 
 	privkey, err := privatekey.Read(keypath, passphrase)
 	if err != nil {
-		panic(err)
+		return fmt.Errorf("%s: %v", keypath, err)
 	}
 
 	port, err := eev.Get("TLS_SERVER_PORT", privkey)
 	if err != nil {
-		panic(err)
+		return err
 	}
 
 	domain, err := eev.Get("TLS_SERVER_DOMAIN", privkey)
 	if err != nil {
-		panic(err)
+		return err
 	}
 
 	remAddrSrvTLS.port = port
@@ -75,29 +80,30 @@ func init() {
 
 	port, err = eev.Get("HTTPS_SERVER_PORT", privkey)
 	if err != nil {
-		panic(err)
+		return err
 	}
 
 	domain, err = eev.Get("HTTPS_SERVER_DOMAIN", privkey)
 	if err != nil {
-		panic(err)
+		return err
 	}
 
 	remAddrSrvHTTPS.port = port
 	remAddrSrvHTTPS.domain = domain
+	return nil
 }
 
 func (remAddr *remoteAddr) resolveTCPAddrAndConnect(conf *tls.Config) (*tls.Conn, error) {
 
 	remTCPAddr, err := net.ResolveTCPAddr("tcp", fmt.Sprintf("%s:%s", remAddr.domain, remAddr.port))
 	if err != nil {
-		log.Print(err)
+		logger.Error("resolving address", "domain", remAddr.domain, "port", remAddr.port, "error", err)
 		return nil, err
 	}
 
 	remConn, err := tls.Dial("tcp", remTCPAddr.String(), conf)
 	if err != nil {
-		log.Print(err)
+		logger.Error("dial", "addr", remTCPAddr, "error", err)
 		return nil, err
 	}
 
@@ -105,6 +111,10 @@ func (remAddr *remoteAddr) resolveTCPAddrAndConnect(conf *tls.Config) (*tls.Conn
 }
 
 func main() {
+	if err := loadConfig(); err != nil {
+		logger.Error("reading configuration", "error", err)
+		return
+	}
 
 	conf := &tls.Config{
 		InsecureSkipVerify: true,
@@ -112,17 +122,17 @@ func main() {
 
 	remConnSrvHTTPS, err := remAddrSrvHTTPS.resolveTCPAddrAndConnect(conf)
 	if err != nil {
-		log.Print(err)
 		return
 	}
 
 	// connects via tls and receives incoming requests to modify iptables rules
 	remConnSrvTLS, err := remAddrSrvTLS.resolveTCPAddrAndConnect(conf)
 	if err != nil {
-		log.Print(err)
 		return
 	}
 
+	logger.Info("connected", "srvtls", remConnSrvTLS.RemoteAddr(), "srvhttps", remConnSrvHTTPS.RemoteAddr())
+
 	defer remConnSrvTLS.Close()
 	defer remConnSrvHTTPS.Close()
 
@@ -137,30 +147,53 @@ func manage(remConnSrvTLS net.Conn, remConnSrvHTTPS net.Conn) {
 		select {
 		case b1 := <-insertRuleChan:
 			if b1 == nil {
+				logger.Warn("connection closed", "peer", "srvtls")
 				return
 			}
-			insertRule := rulePool.Get().(*dispatch.Rule)
-			defer rulePool.Put(insertRule)
-			err := proto.Unmarshal(b1, insertRule)
-			if err != nil {
-				return
+			rule := rulePool.Get().(*dispatch.Rule)
+			if err := proto.Unmarshal(b1, rule); err != nil {
+				logger.Error("decoding insert rule", "peer", "srvtls", "error", err)
+				reportError(remConnSrvTLS, dispatch.Error_INVALID_MESSAGE, err, 0)
+			} else if err := insertRule(rule.Port, rule.Ip); err != nil {
+				logger.Error("inserting rule", "ip", rule.Ip, "port", rule.Port, "error", err)
+				reportError(remConnSrvTLS, dispatch.Error_INTERNAL, err, rule.Id)
+			} else {
+				logger.Debug("rule inserted", "ip", rule.Ip, "port", rule.Port)
 			}
-			ipexc.Insert(insertRule.Port, insertRule.Ip)
+			rulePool.Put(rule)
 		case b2 := <-deleteRuleChan:
 			if b2 == nil {
+				logger.Warn("connection closed", "peer", "srvhttps")
 				return
 			}
-			deleteRule := rulePool.Get().(*dispatch.Rule)
-			defer rulePool.Put(deleteRule)
-			err := proto.Unmarshal(b2, deleteRule)
-			if err != nil {
-				return
+			rule := rulePool.Get().(*dispatch.Rule)
+			if err := proto.Unmarshal(b2, rule); err != nil {
+				logger.Error("decoding delete rule", "peer", "srvhttps", "error", err)
+				reportError(remConnSrvHTTPS, dispatch.Error_INVALID_MESSAGE, err, 0)
+			} else if err := deleteRule(rule.Port, rule.Ip); err != nil {
+				logger.Error("deleting rule", "ip", rule.Ip, "port", rule.Port, "error", err)
+				reportError(remConnSrvHTTPS, dispatch.Error_INTERNAL, err, rule.Id)
+			} else {
+				logger.Debug("rule deleted", "ip", rule.Ip, "port", rule.Port)
 			}
-			ipexc.Delete(deleteRule.Port, deleteRule.Ip)
+			rulePool.Put(rule)
 		}
 	}
 }
 
+// reportError tells the peer that sent a rule why it was not applied. Rules
+// are sent as bare protobuf messages, so is the answer.
+func reportError(conn net.Conn, code dispatch.Error_Code, cause error, ruleID uint64) {
+	b, err := proto.Marshal(dispatch.NewError(code, cause.Error(), ruleID))
+	if err != nil {
+		logger.Error("encoding error", "error", err)
+		return
+	}
+	if _, err := conn.Write(b); err != nil {
+		logger.Warn("reporting error", "peer", conn.RemoteAddr(), "error", err)
+	}
+}
+
 func chanFromConn(conn net.Conn) chan []byte {
 	c := make(chan []byte)
 
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/Apurer/e2eechat/dispatch"
+	"google.golang.org/protobuf/proto"
+)
+
+// tcpPair returns both ends of a loopback TCP connection.
+func tcpPair(t *testing.T) (net.Conn, net.Conn) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	accepted := make(chan net.Conn, 1)
+	go func() {
+		c, err := ln.Accept()
+		if err != nil {
+			t.Error(err)
+		}
+		accepted <- c
+	}()
+	c, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c, <-accepted
+}
+
+func TestManageReportsErrors(t *testing.T) {
+	errFirewall := errors.New("iptables failed")
+	applied := make(chan string, 1)
+	apply := func(port, ip string) error {
+		if ip == "192.0.2.66" {
+			return errFirewall
+		}
+		applied <- ip + ":" + port
+		return nil
+	}
+	defer func(ins, del func(port, ip string) error) {
+		insertRule, deleteRule = ins, del
+	}(insertRule, deleteRule)
+	insertRule, deleteRule = apply, apply
+
+	srvTLS, insertSide := tcpPair(t)
+	srvHTTPS, deleteSide := tcpPair(t)
+	done := make(chan struct{})
+	go func() {
+		manage(insertSide, deleteSide)
+		close(done)
+	}()
+
+	rule := func(ip string, id uint64) []byte {
+		b, err := proto.Marshal(&dispatch.Rule{Ip: ip, Port: "443", Id: id})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return b
+	}
+	tests := []struct {
+		name    string
+		peer    net.Conn
+		sent    []byte
+		applied string
+		want    *dispatch.Error // nil when the rule applies
+	}{
+		{"insert", srvTLS, rule("192.0.2.1", 1), "192.0.2.1:443", nil},
+		{"delete", srvHTTPS, rule("192.0.2.2", 2), "192.0.2.2:443", nil},
+		{"undecodable insert", srvTLS, []byte{0xff}, "", &dispatch.Error{Code: dispatch.Error_INVALID_MESSAGE}},
+		{"failing insert", srvTLS, rule("192.0.2.66", 3), "", &dispatch.Error{Code: dispatch.Error_INTERNAL, MessageId: 3}},
+		{"failing delete", srvHTTPS, rule("192.0.2.66", 4), "", &dispatch.Error{Code: dispatch.Error_INTERNAL, MessageId: 4}},
+		// a failed rule leaves nothing behind for the next one
+		{"insert after a failure", srvTLS, rule("192.0.2.5", 0), "192.0.2.5:443", nil},
+	}
+	buf := make([]byte, bufferSize)
+	for _, tt := range tests {
+		if _, err := tt.peer.Write(tt.sent); err != nil {
+			t.Fatal(err)
+		}
+		if tt.want == nil {
+			select {
+			case got := <-applied:
+				if got != tt.applied {
+					t.Errorf("%s: applied %s, want %s", tt.name, got, tt.applied)
+				}
+			case <-time.After(time.Second):
+				t.Errorf("%s: rule not applied", tt.name)
+			}
+			continue
+		}
+		tt.peer.SetReadDeadline(time.Now().Add(time.Second))
+		n, err := tt.peer.Read(buf)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		var got dispatch.Error
+		if err := proto.Unmarshal(buf[:n], &got); err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if got.Code != tt.want.Code || got.MessageId != tt.want.MessageId || got.Detail == "" {
+			t.Errorf("%s: got %v %d %q, want %v %d", tt.name, got.Code, got.MessageId, got.Detail, tt.want.Code, tt.want.MessageId)
+		}
+	}
+
+	srvTLS.Close()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("manage did not return after srvtls closed the connection")
+	}
+	srvHTTPS.Close()
+}
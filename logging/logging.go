@@ -0,0 +1,243 @@
+// Package logging provides the leveled JSON logger shared by the e2eechat
+// binaries. Every line is a single JSON object, fields named after secrets
+// are redacted and byte slices are never written out verbatim, so tokens,
+// keys and message payloads do not end up in log files.
+package logging
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Level is the severity of a log line.
+type Level int
+
+// Levels in increasing order of severity.
+const (
+	Debug Level = iota
+	Info
+	Warn
+	Error
+)
+
+func (l Level) String() string {
+	switch l {
+	case Debug:
+		return "debug"
+	case Info:
+		return "info"
+	case Warn:
+		return "warn"
+	case Error:
+		return "error"
+	}
+	return fmt.Sprintf("level(%d)", int(l))
+}
+
+// ParseLevel parses the textual form of a level, as used in LOG_LEVEL.
+func ParseLevel(s string) (Level, error) {
+	switch strings.ToLower(s) {
+	case "debug":
+		return Debug, nil
+	case "", "info":
+		return Info, nil
+	case "warn", "warning":
+		return Warn, nil
+	case "error":
+		return Error, nil
+	}
+	return Info, fmt.Errorf("logging: unknown level %q", s)
+}
+
+// CorrelationKey is the field name under which the correlation id of a
+// session is logged.
+const CorrelationKey = "correlation_id"
+
+// Redacted replaces the value of sensitive fields.
+const Redacted = "[REDACTED]"
+
+// sensitive lists the field names whose values are never logged.
+var sensitive = map[string]bool{
+	"token": true, "key": true, "code": true, "hash": true, "secret": true,
+	"password": true, "passphrase": true, "payload": true, "text": true,
+	"image": true, "signature": true, "nonce": true, "cookie": true,
+	"authorization": true,
+}
+
+// sensitiveSuffixes redacts compound names such as identity_key or
+// login_token, while status_code or keys are still logged.
+var sensitiveSuffixes = []string{
+	"_token", "_key", "_hash", "_secret", "_password", "_passphrase",
+	"_payload", "_signature", "_nonce",
+}
+
+// Logger writes leveled JSON lines. Loggers derived with With share the
+// underlying writer and level.
+type Logger struct {
+	out    *output
+	fields []field
+}
+
+type output struct {
+	mu    sync.Mutex
+	w     io.Writer
+	level Level
+}
+
+type field struct {
+	key   string
+	value interface{}
+}
+
+// New returns a logger writing to w that drops lines below level. Every line
+// carries the name of the service that produced it.
+func New(w io.Writer, service string, level Level) *Logger {
+	return &Logger{
+		out:    &output{w: w, level: level},
+		fields: []field{{"service", service}},
+	}
+}
+
+// FromEnv returns a logger writing to stderr at the level named by the
+// LOG_LEVEL environment variable.
+func FromEnv(service string) *Logger {
+	level, err := ParseLevel(os.Getenv("LOG_LEVEL"))
+	l := New(os.Stderr, service, level)
+	if err != nil {
+		l.Warn("invalid LOG_LEVEL, using info", "error", err)
+	}
+	return l
+}
+
+// With returns a logger that adds the given key/value pairs to every line.
+func (l *Logger) With(kv ...interface{}) *Logger {
+	fields := make([]field, len(l.fields), len(l.fields)+len(kv)/2)
+	copy(fields, l.fields)
+	return &Logger{
+		out:    l.out,
+		fields: appendFields(fields, kv),
+	}
+}
+
+// Enabled reports whether lines at level are written.
+func (l *Logger) Enabled(level Level) bool {
+	return level >= l.out.level
+}
+
+// Debug logs at Debug level.
+func (l *Logger) Debug(msg string, kv ...interface{}) { l.log(Debug, msg, kv) }
+
+// Info logs at Info level.
+func (l *Logger) Info(msg string, kv ...interface{}) { l.log(Info, msg, kv) }
+
+// Warn logs at Warn level.
+func (l *Logger) Warn(msg string, kv ...interface{}) { l.log(Warn, msg, kv) }
+
+// Error logs at Error level.
+func (l *Logger) Error(msg string, kv ...interface{}) { l.log(Error, msg, kv) }
+
+func (l *Logger) log(level Level, msg string, kv []interface{}) {
+	if !l.Enabled(level) {
+		return
+	}
+
+	var b bytes.Buffer
+	b.WriteString(`{"time":`)
+	writeValue(&b, time.Now().UTC().Format(time.RFC3339Nano))
+	b.WriteString(`,"level":`)
+	writeValue(&b, level.String())
+	b.WriteString(`,"msg":`)
+	writeValue(&b, msg)
+	for _, f := range appendFields(l.fields, kv) {
+		b.WriteByte(',')
+		writeValue(&b, f.key)
+		b.WriteByte(':')
+		writeValue(&b, redact(f.key, f.value))
+	}
+	b.WriteString("}\n")
+
+	l.out.mu.Lock()
+	defer l.out.mu.Unlock()
+	l.out.w.Write(b.Bytes())
+}
+
+func appendFields(fields []field, kv []interface{}) []field {
+	for i := 0; i < len(kv); i += 2 {
+		key, ok := kv[i].(string)
+		if !ok {
+			key = fmt.Sprint(kv[i])
+		}
+		if i+1 == len(kv) {
+			fields = append(fields, field{"!BADKEY", key})
+			break
+		}
+		fields = append(fields, field{key, kv[i+1]})
+	}
+	return fields
+}
+
+func redact(key string, value interface{}) interface{} {
+	if isSensitive(key) {
+		return Redacted
+	}
+
+	switch v := value.(type) {
+	case []byte:
+		// raw bytes are usually ciphertext or key material
+		return fmt.Sprintf("[%d bytes]", len(v))
+	case error:
+		return v.Error()
+	case fmt.Stringer:
+		return v.String()
+	}
+	return value
+}
+
+func isSensitive(key string) bool {
+	k := strings.ToLower(key)
+	if sensitive[k] {
+		return true
+	}
+	for _, s := range sensitiveSuffixes {
+		if strings.HasSuffix(k, s) {
+			return true
+		}
+	}
+	return false
+}
+
+func writeValue(b *bytes.Buffer, v interface{}) {
+	enc, err := json.Marshal(v)
+	if err != nil {
+		enc, _ = json.Marshal(fmt.Sprint(v))
+	}
+	b.Write(enc)
+}
+
+// NewCorrelationID returns a random id that ties together the log lines of
+// one session across services.
+func NewCorrelationID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "0000000000000000"
+	}
+	return hex.EncodeToString(b)
+}
+
+// ValidCorrelationID reports whether id looks like one produced by
+// NewCorrelationID, so ids supplied by clients cannot inject arbitrary text.
+func ValidCorrelationID(id string) bool {
+	if len(id) != 16 {
+		return false
+	}
+	_, err := hex.DecodeString(id)
+	return err == nil
+}
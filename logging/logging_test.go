@@ -0,0 +1,133 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+// lines decodes the JSON lines written to b.
+func lines(t *testing.T, b *bytes.Buffer) []map[string]interface{} {
+	var out []map[string]interface{}
+	for _, l := range strings.Split(strings.TrimSpace(b.String()), "\n") {
+		if l == "" {
+			continue
+		}
+		m := make(map[string]interface{})
+		if err := json.Unmarshal([]byte(l), &m); err != nil {
+			t.Fatalf("line %q: %v", l, err)
+		}
+		out = append(out, m)
+	}
+	return out
+}
+
+func TestRedaction(t *testing.T) {
+	tests := []struct {
+		key   string
+		value interface{}
+		want  interface{}
+	}{
+		{"token", "abc", Redacted},
+		{"login_token", "abc", Redacted},
+		{"key", "abc", Redacted},
+		{"identity_key", "abc", Redacted},
+		{"Session_Key", "abc", Redacted},
+		{"code", "1234", Redacted},
+		{"passphrase", "hunter2", Redacted},
+		{"payload", []byte("hello"), Redacted},
+		{"signature", "abc", Redacted},
+		{"nonce", "abc", Redacted},
+		{"password_hash", "abc", Redacted},
+		// names that only contain a sensitive word are logged
+		{"status_code", float64(404), float64(404)},
+		{"keys", float64(3), float64(3)},
+		{"keyring", "channel 7", "channel 7"},
+		{"context", "upload", "upload"},
+		{"user_id", float64(7), float64(7)},
+		// values that are logged are rendered readably
+		{"blob", []byte("hello"), "[5 bytes]"},
+		{"empty", []byte{}, "[0 bytes]"},
+		{"error", errors.New("boom"), "boom"},
+		{"timeout", 3 * time.Second, "3s"},
+		{"ok", true, true},
+	}
+	for _, tt := range tests {
+		var b bytes.Buffer
+		New(&b, "test", Debug).Info("msg", tt.key, tt.value)
+		got := lines(t, &b)[0][tt.key]
+		if got != tt.want {
+			t.Errorf("%s=%v logged as %v, want %v", tt.key, tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestLogger(t *testing.T) {
+	var b bytes.Buffer
+	l := New(&b, "test", Info).With("remote", "192.0.2.1", CorrelationKey, "0123456789abcdef")
+	l.Debug("dropped")
+	l.Info("kept", "count", 2)
+	l.With("user_id", 7).Warn("derived")
+	l.Error("odd", "dangling")
+
+	got := lines(t, &b)
+	if len(got) != 3 {
+		t.Fatalf("%d lines written, want 3", len(got))
+	}
+	tests := []struct {
+		field string
+		want  []interface{}
+	}{
+		{"msg", []interface{}{"kept", "derived", "odd"}},
+		{"level", []interface{}{"info", "warn", "error"}},
+		{"service", []interface{}{"test", "test", "test"}},
+		{CorrelationKey, []interface{}{"0123456789abcdef", "0123456789abcdef", "0123456789abcdef"}},
+		{"user_id", []interface{}{nil, float64(7), nil}},
+		{"!BADKEY", []interface{}{nil, nil, "dangling"}},
+	}
+	for _, tt := range tests {
+		for i, want := range tt.want {
+			if got[i][tt.field] != want {
+				t.Errorf("line %d: %s = %v, want %v", i, tt.field, got[i][tt.field], want)
+			}
+		}
+	}
+}
+
+func TestParseLevel(t *testing.T) {
+	tests := []struct {
+		s       string
+		want    Level
+		wantErr bool
+	}{
+		{"", Info, false},
+		{"debug", Debug, false},
+		{"WARNING", Warn, false},
+		{"error", Error, false},
+		{"verbose", Info, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseLevel(tt.s)
+		if got != tt.want || (err != nil) != tt.wantErr {
+			t.Errorf("ParseLevel(%q) = %v, %v", tt.s, got, err)
+		}
+	}
+}
+
+func TestCorrelationID(t *testing.T) {
+	id := NewCorrelationID()
+	if !ValidCorrelationID(id) {
+		t.Fatalf("NewCorrelationID() = %q is not valid", id)
+	}
+	if id == NewCorrelationID() {
+		t.Error("two sessions got the same correlation id")
+	}
+	for _, id := range []string{"", "0123", "0123456789abcdeg", `01234567","x":"`} {
+		if ValidCorrelationID(id) {
+			t.Errorf("ValidCorrelationID(%q) = true", id)
+		}
+	}
+}
@@ -0,0 +1,226 @@
+package mls
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"math/big"
+
+	"github.com/Apurer/e2eechat/dispatch"
+)
+
+// The cipher suite is that of MLS_128_DHKEMP256_AES128GCM_SHA256_P256, with
+// ed25519 signatures instead of ECDSA so that devices sign with the identity
+// key they registered with srvhttps.
+const (
+	hashSize  = sha256.Size
+	keySize   = 16 // AES-128-GCM
+	nonceSize = 12
+
+	// PublicKeySize is the size of an uncompressed P-256 HPKE key.
+	PublicKeySize = 65
+
+	mlsLabel  = "MLS 1.0 "
+	hpkeLabel = "HPKE-v1"
+)
+
+var (
+	kemSuiteID  = []byte{'K', 'E', 'M', 0x00, 0x10}
+	hpkeSuiteID = []byte{'H', 'P', 'K', 'E', 0x00, 0x10, 0x00, 0x01, 0x00, 0x01}
+)
+
+var (
+	// ErrBadKey is returned for HPKE keys that are not P-256 points.
+	ErrBadKey = errors.New("mls: invalid public key")
+
+	// ErrDecrypt is returned when a ciphertext was not meant for the
+	// receiver or was modified.
+	ErrDecrypt = errors.New("mls: cannot decrypt")
+)
+
+// keyPair is an HPKE key pair of a leaf, a parent node or a key package.
+type keyPair struct {
+	pub  []byte
+	priv []byte
+}
+
+func generateKeyPair() (*keyPair, error) {
+	priv, x, y, err := elliptic.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	return &keyPair{pub: elliptic.Marshal(elliptic.P256(), x, y), priv: priv}, nil
+}
+
+// deriveKeyPair is DeriveKeyPair of DHKEM(P-256) (RFC 9180, 7.1.3), how
+// TreeKEM turns a node secret into the key pair of the node.
+func deriveKeyPair(secret []byte) (*keyPair, error) {
+	curve := elliptic.P256()
+	prk := labeledExtract(kemSuiteID, nil, "dkp_prk", secret)
+	for counter := 0; counter < 256; counter++ {
+		priv := labeledExpand(kemSuiteID, prk, "candidate", []byte{byte(counter)}, 32)
+		k := new(big.Int).SetBytes(priv)
+		if k.Sign() == 0 || k.Cmp(curve.Params().N) >= 0 {
+			continue
+		}
+		x, y := curve.ScalarBaseMult(priv)
+		return &keyPair{pub: elliptic.Marshal(curve, x, y), priv: priv}, nil
+	}
+	return nil, errors.New("mls: cannot derive key pair")
+}
+
+// sealHPKE encrypts plaintext to pub in HPKE base mode with the info of
+// EncryptWithLabel.
+func sealHPKE(pub []byte, label string, context, plaintext []byte) (*dispatch.MlsHpkeCiphertext, error) {
+	curve := elliptic.P256()
+	rx, ry := elliptic.Unmarshal(curve, pub)
+	if rx == nil {
+		return nil, ErrBadKey
+	}
+	ephemeral, err := generateKeyPair()
+	if err != nil {
+		return nil, err
+	}
+	dh, _ := curve.ScalarMult(rx, ry, ephemeral.priv)
+	aead, nonce, err := hpkeContext(dh, ephemeral.pub, pub, encryptContext(label, context))
+	if err != nil {
+		return nil, err
+	}
+	return &dispatch.MlsHpkeCiphertext{
+		KemOutput:  ephemeral.pub,
+		Ciphertext: aead.Seal(nil, nonce, plaintext, nil),
+	}, nil
+}
+
+// openHPKE decrypts what sealHPKE encrypted to kp.
+func openHPKE(kp *keyPair, label string, context []byte, ct *dispatch.MlsHpkeCiphertext) ([]byte, error) {
+	curve := elliptic.P256()
+	ex, ey := elliptic.Unmarshal(curve, ct.GetKemOutput())
+	if ex == nil {
+		return nil, ErrBadKey
+	}
+	dh, _ := curve.ScalarMult(ex, ey, kp.priv)
+	aead, nonce, err := hpkeContext(dh, ct.GetKemOutput(), kp.pub, encryptContext(label, context))
+	if err != nil {
+		return nil, err
+	}
+	b, err := aead.Open(nil, nonce, ct.GetCiphertext(), nil)
+	if err != nil {
+		return nil, ErrDecrypt
+	}
+	return b, nil
+}
+
+// hpkeContext runs the DHKEM key derivation and the base mode key schedule,
+// returning the AEAD and the nonce of the single message sent with it.
+func hpkeContext(dh *big.Int, enc, pub, info []byte) (cipher.AEAD, []byte, error) {
+	dhBytes := make([]byte, 32)
+	dh.FillBytes(dhBytes)
+	kemContext := append(append([]byte(nil), enc...), pub...)
+	eaePRK := labeledExtract(kemSuiteID, nil, "eae_prk", dhBytes)
+	shared := labeledExpand(kemSuiteID, eaePRK, "shared_secret", kemContext, hashSize)
+
+	pskIDHash := labeledExtract(hpkeSuiteID, nil, "psk_id_hash", nil)
+	infoHash := labeledExtract(hpkeSuiteID, nil, "info_hash", info)
+	ksContext := append(append([]byte{0}, pskIDHash...), infoHash...)
+	secret := labeledExtract(hpkeSuiteID, shared, "secret", nil)
+	key := labeledExpand(hpkeSuiteID, secret, "key", ksContext, keySize)
+	nonce := labeledExpand(hpkeSuiteID, secret, "base_nonce", ksContext, nonceSize)
+	aead, err := newAEAD(key)
+	return aead, nonce, err
+}
+
+func labeledExtract(suiteID, salt []byte, label string, ikm []byte) []byte {
+	var b bytes.Buffer
+	b.WriteString(hpkeLabel)
+	b.Write(suiteID)
+	b.WriteString(label)
+	b.Write(ikm)
+	return extract(salt, b.Bytes())
+}
+
+func labeledExpand(suiteID, prk []byte, label string, info []byte, length int) []byte {
+	var b bytes.Buffer
+	binary.Write(&b, binary.BigEndian, uint16(length))
+	b.WriteString(hpkeLabel)
+	b.Write(suiteID)
+	b.WriteString(label)
+	b.Write(info)
+	return expand(prk, b.Bytes(), length)
+}
+
+// encryptContext is the EncryptContext of EncryptWithLabel.
+func encryptContext(label string, context []byte) []byte {
+	var b bytes.Buffer
+	writeVector(&b, []byte(mlsLabel+label))
+	writeVector(&b, context)
+	return b.Bytes()
+}
+
+// expandWithLabel is ExpandWithLabel of RFC 9420, 8.
+func expandWithLabel(secret []byte, label string, context []byte, length int) []byte {
+	var b bytes.Buffer
+	binary.Write(&b, binary.BigEndian, uint16(length))
+	writeVector(&b, []byte(mlsLabel+label))
+	writeVector(&b, context)
+	return expand(secret, b.Bytes(), length)
+}
+
+// deriveSecret is DeriveSecret of RFC 9420, 8.
+func deriveSecret(secret []byte, label string) []byte {
+	return expandWithLabel(secret, label, nil, hashSize)
+}
+
+// extract is HKDF-Extract with SHA-256 (RFC 5869).
+func extract(salt, ikm []byte) []byte {
+	if len(salt) == 0 {
+		salt = make([]byte, hashSize)
+	}
+	return hmacSHA256(salt, ikm)
+}
+
+// expand is HKDF-Expand with SHA-256 (RFC 5869).
+func expand(prk, info []byte, length int) []byte {
+	var out, t []byte
+	for i := byte(1); len(out) < length; i++ {
+		h := hmac.New(sha256.New, prk)
+		h.Write(t)
+		h.Write(info)
+		h.Write([]byte{i})
+		t = h.Sum(nil)
+		out = append(out, t...)
+	}
+	return out[:length]
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func hmacSHA256(key, data []byte) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write(data)
+	return h.Sum(nil)
+}
+
+// writeVector writes b with a length prefix, for the byte strings that are
+// hashed, signed or used as KDF context.
+func writeVector(w *bytes.Buffer, b []byte) {
+	binary.Write(w, binary.BigEndian, uint32(len(b)))
+	w.Write(b)
+}
+
+func validKey(pub []byte) bool {
+	x, _ := elliptic.Unmarshal(elliptic.P256(), pub)
+	return x != nil
+}
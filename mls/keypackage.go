@@ -0,0 +1,131 @@
+package mls
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+
+	"github.com/Apurer/e2eechat/dispatch"
+)
+
+const (
+	leafContext       = "e2eechat mls leaf node v1"
+	keyPackageContext = "e2eechat mls key package v1"
+)
+
+var (
+	// ErrBadLeaf is returned for leaf nodes with missing keys or a bad
+	// signature.
+	ErrBadLeaf = errors.New("mls: invalid leaf node")
+
+	// ErrBadKeyPackage is returned for key packages that are malformed or
+	// not signed by their leaf.
+	ErrBadKeyPackage = errors.New("mls: invalid key package")
+)
+
+// KeyPackage is a key package of the local device together with its private
+// keys. The public part is published on srvhttps, the rest stays on the
+// device until a Welcome for it arrives.
+type KeyPackage struct {
+	Public *dispatch.MlsKeyPackage
+
+	initKey       *keyPair
+	encryptionKey *keyPair
+	signingKey    ed25519.PrivateKey
+}
+
+// NewKeyPackage creates a key package for a device, signed with its identity
+// key.
+func NewKeyPackage(userID, deviceID uint64, identityKey ed25519.PrivateKey) (*KeyPackage, error) {
+	initKey, err := generateKeyPair()
+	if err != nil {
+		return nil, err
+	}
+	leaf, encryptionKey, err := newLeaf(userID, deviceID, identityKey)
+	if err != nil {
+		return nil, err
+	}
+	kp := &dispatch.MlsKeyPackage{Leaf: leaf, InitKey: initKey.pub}
+	kp.Signature = ed25519.Sign(identityKey, keyPackageBytes(kp))
+	return &KeyPackage{
+		Public:        kp,
+		initKey:       initKey,
+		encryptionKey: encryptionKey,
+		signingKey:    identityKey,
+	}, nil
+}
+
+// VerifyKeyPackage checks that kp is well formed and signed by the signature
+// key of its leaf. Whether that key belongs to the device named in the leaf
+// is up to the caller, against the device registry.
+func VerifyKeyPackage(kp *dispatch.MlsKeyPackage) error {
+	if err := verifyLeaf(kp.GetLeaf()); err != nil {
+		return err
+	}
+	if !validKey(kp.GetInitKey()) || bytes.Equal(kp.GetInitKey(), kp.GetLeaf().GetEncryptionKey()) {
+		return ErrBadKeyPackage
+	}
+	if !ed25519.Verify(kp.GetLeaf().GetSignatureKey(), keyPackageBytes(kp), kp.GetSignature()) {
+		return ErrBadKeyPackage
+	}
+	return nil
+}
+
+// KeyPackageRef identifies a key package in a Welcome.
+func KeyPackageRef(kp *dispatch.MlsKeyPackage) []byte {
+	h := sha256.Sum256(kp.GetInitKey())
+	return h[:]
+}
+
+// newLeaf returns a signed leaf node with a fresh encryption key.
+func newLeaf(userID, deviceID uint64, identityKey ed25519.PrivateKey) (*dispatch.MlsLeafNode, *keyPair, error) {
+	kp, err := generateKeyPair()
+	if err != nil {
+		return nil, nil, err
+	}
+	return signLeaf(userID, deviceID, kp, identityKey), kp, nil
+}
+
+func signLeaf(userID, deviceID uint64, kp *keyPair, identityKey ed25519.PrivateKey) *dispatch.MlsLeafNode {
+	leaf := &dispatch.MlsLeafNode{
+		UserId:        userID,
+		DeviceId:      deviceID,
+		EncryptionKey: kp.pub,
+		SignatureKey:  identityKey.Public().(ed25519.PublicKey),
+	}
+	leaf.Signature = ed25519.Sign(identityKey, leafBytes(leaf))
+	return leaf
+}
+
+func verifyLeaf(leaf *dispatch.MlsLeafNode) error {
+	if leaf == nil || leaf.UserId == 0 || !validKey(leaf.EncryptionKey) || len(leaf.SignatureKey) != ed25519.PublicKeySize {
+		return ErrBadLeaf
+	}
+	if !ed25519.Verify(leaf.SignatureKey, leafBytes(leaf), leaf.Signature) {
+		return ErrBadLeaf
+	}
+	return nil
+}
+
+// leafBytes is what the signature of a leaf covers, and what the tree hash
+// covers of it.
+func leafBytes(leaf *dispatch.MlsLeafNode) []byte {
+	var b bytes.Buffer
+	b.WriteString(leafContext)
+	binary.Write(&b, binary.BigEndian, leaf.GetUserId())
+	binary.Write(&b, binary.BigEndian, leaf.GetDeviceId())
+	writeVector(&b, leaf.GetEncryptionKey())
+	writeVector(&b, leaf.GetSignatureKey())
+	return b.Bytes()
+}
+
+func keyPackageBytes(kp *dispatch.MlsKeyPackage) []byte {
+	var b bytes.Buffer
+	b.WriteString(keyPackageContext)
+	writeVector(&b, leafBytes(kp.GetLeaf()))
+	writeVector(&b, kp.GetLeaf().GetSignature())
+	writeVector(&b, kp.GetInitKey())
+	return b.Bytes()
+}
@@ -0,0 +1,154 @@
+package mls
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+)
+
+// MaxSkip bounds how far ahead of the last received message of a sender a
+// message may be, and how many skipped keys are kept for late messages.
+const MaxSkip = 2000
+
+var (
+	// ErrOldMessage is returned for a message whose key was already used or
+	// discarded.
+	ErrOldMessage = errors.New("mls: message key already used")
+
+	// ErrTooFarAhead is returned when more than MaxSkip keys of a sender
+	// would have to be skipped.
+	ErrTooFarAhead = errors.New("mls: message too far ahead")
+)
+
+// epochSecrets are the secrets of one epoch derived from its epoch secret
+// (RFC 9420, 8).
+type epochSecrets struct {
+	senderData    []byte
+	encryption    []byte
+	exporter      []byte
+	authenticator []byte
+	confirmation  []byte
+	membership    []byte
+	init          []byte
+}
+
+// keySchedule derives the joiner secret and the secrets of the epoch with
+// group context ctx from the init secret of the previous epoch and the
+// commit secret.
+func keySchedule(initSecret, commitSecret, ctx []byte) (joiner []byte, s *epochSecrets) {
+	joiner = expandWithLabel(extract(initSecret, commitSecret), "joiner", ctx, hashSize)
+	return joiner, epochFromJoiner(joiner, ctx)
+}
+
+// epochFromJoiner is the part of the key schedule new members run, who get
+// the joiner secret in their Welcome. There are no pre-shared keys, so the
+// PSK secret is all zeros.
+func epochFromJoiner(joiner, ctx []byte) *epochSecrets {
+	return newEpochSecrets(expandWithLabel(extract(joiner, make([]byte, hashSize)), "epoch", ctx, hashSize))
+}
+
+// newEpochSecrets derives the secrets of an epoch from its epoch secret.
+func newEpochSecrets(epoch []byte) *epochSecrets {
+	return &epochSecrets{
+		senderData:    deriveSecret(epoch, "sender data"),
+		encryption:    deriveSecret(epoch, "encryption"),
+		exporter:      deriveSecret(epoch, "exporter"),
+		authenticator: deriveSecret(epoch, "authentication"),
+		confirmation:  deriveSecret(epoch, "confirm"),
+		membership:    deriveSecret(epoch, "membership"),
+		init:          deriveSecret(epoch, "init"),
+	}
+}
+
+// welcomeKey returns the key and nonce the group info of a Welcome is
+// encrypted with.
+func welcomeKey(joiner []byte) (key, nonce []byte) {
+	welcome := deriveSecret(extract(joiner, make([]byte, hashSize)), "welcome")
+	return expandWithLabel(welcome, "key", nil, keySize), expandWithLabel(welcome, "nonce", nil, nonceSize)
+}
+
+// groupContext is what binds the secrets of an epoch to the group, its
+// epoch, its tree and its history.
+func groupContext(groupID []byte, epoch uint64, treeHash, confirmedTranscriptHash []byte) []byte {
+	var b bytes.Buffer
+	writeVector(&b, groupID)
+	binary.Write(&b, binary.BigEndian, epoch)
+	writeVector(&b, treeHash)
+	writeVector(&b, confirmedTranscriptHash)
+	return b.Bytes()
+}
+
+// leafSecret derives the secret of leaf i of the secret tree, whose root is
+// the encryption secret of the epoch, in a tree of the given number of
+// leaves.
+func leafSecret(encryption []byte, leaves, i uint32) []byte {
+	s := encryption
+	for x, target := leaves-1, 2*i; x != target; {
+		if target < x {
+			s = expandWithLabel(s, "tree", []byte("left"), hashSize)
+			x = left(x)
+		} else {
+			s = expandWithLabel(s, "tree", []byte("right"), hashSize)
+			x = right(x)
+		}
+	}
+	return s
+}
+
+// ratchet is the application ratchet of one sender in an epoch.
+type ratchet struct {
+	secret     []byte
+	generation uint32
+	skipped    map[uint32][2][]byte
+}
+
+func newRatchet(leafSecret []byte) *ratchet {
+	return &ratchet{
+		secret:  expandWithLabel(leafSecret, "application", nil, hashSize),
+		skipped: make(map[uint32][2][]byte),
+	}
+}
+
+// next returns the key and nonce of the current generation and advances the
+// ratchet, forgetting the secret they came from.
+func (r *ratchet) next() (key, nonce []byte, generation uint32) {
+	var g [4]byte
+	binary.BigEndian.PutUint32(g[:], r.generation)
+	key = expandWithLabel(r.secret, "key", g[:], keySize)
+	nonce = expandWithLabel(r.secret, "nonce", g[:], nonceSize)
+	r.secret = expandWithLabel(r.secret, "secret", g[:], hashSize)
+	generation = r.generation
+	r.generation++
+	return key, nonce, generation
+}
+
+// get returns the key and nonce of generation g for a receiver, keeping
+// those of skipped generations for messages that arrive late.
+func (r *ratchet) get(g uint32) (key, nonce []byte, err error) {
+	if g < r.generation {
+		kn, ok := r.skipped[g]
+		if !ok {
+			return nil, nil, ErrOldMessage
+		}
+		delete(r.skipped, g)
+		return kn[0], kn[1], nil
+	}
+	if g-r.generation > MaxSkip {
+		return nil, nil, ErrTooFarAhead
+	}
+	for r.generation < g {
+		k, n, skipped := r.next()
+		r.skipped[skipped] = [2][]byte{k, n}
+	}
+	for len(r.skipped) > MaxSkip {
+		oldest := g
+		for s := range r.skipped {
+			if s < oldest {
+				oldest = s
+			}
+		}
+		delete(r.skipped, oldest)
+	}
+	key, nonce, _ = r.next()
+	return key, nonce, nil
+}
@@ -0,0 +1,906 @@
+// Package mls implements group key agreement for channels after the
+// Messaging Layer Security protocol (RFC 9420).
+//
+// Every device of a channel is a leaf of a ratchet tree. A member changes
+// the group with a commit, which adds devices from their key packages,
+// removes devices and gives the committer fresh keys along its path to the
+// root (TreeKEM), so rekeying costs a logarithmic number of encryptions in
+// the size of the group. Each commit starts a new epoch whose secrets are
+// derived from the previous ones and the new path, and new members receive
+// them in a Welcome. Application messages are encrypted with per-sender
+// ratchets derived from the epoch's encryption secret.
+//
+// srvtls is the delivery service. It orders the commits of each channel and
+// accepts one per epoch, so a member sends the Commit of a PendingCommit,
+// merges it once srvtls accepts it, and only then sends the Welcome. When
+// srvtls rejects it, another member's commit won and arrives next.
+//
+// The structures follow RFC 9420 but travel as protobuf messages of package
+// dispatch, and leaves sign with the ed25519 identity key of their device,
+// so this is not wire compatible with other implementations. Parent hashes,
+// pre-shared keys, external commits and proposals sent on their own are
+// not implemented.
+package mls
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"sync"
+
+	"github.com/Apurer/e2eechat/dispatch"
+	"github.com/Apurer/e2eechat/padding"
+	"github.com/golang/protobuf/proto"
+)
+
+const (
+	commitContext      = "e2eechat mls commit v1"
+	groupInfoContext   = "e2eechat mls group info v1"
+	applicationContext = "e2eechat mls application v1"
+
+	// keepEpochs is how many earlier epochs application messages are still
+	// decrypted for, since members may send them before they see a commit.
+	keepEpochs = 2
+
+	senderDataSize = 12
+)
+
+var (
+	// ErrWrongGroup is returned for messages of another group.
+	ErrWrongGroup = errors.New("mls: message for another group")
+
+	// ErrWrongEpoch is returned for commits that are not for the current
+	// epoch and for application messages of epochs no longer kept.
+	ErrWrongEpoch = errors.New("mls: message for another epoch")
+
+	// ErrBadCommit is returned for commits that cannot be applied.
+	ErrBadCommit = errors.New("mls: invalid commit")
+
+	// ErrBadProposal is returned by Commit for additions and removals that
+	// are not possible.
+	ErrBadProposal = errors.New("mls: invalid proposal")
+
+	// ErrBadSignature is returned when a message was not signed by the leaf
+	// it claims to come from.
+	ErrBadSignature = errors.New("mls: bad signature")
+
+	// ErrBadTag is returned when a commit was not made by a member of the
+	// epoch or leads to another epoch than the committer's.
+	ErrBadTag = errors.New("mls: bad confirmation or membership tag")
+
+	// ErrBadMessage is returned for messages that cannot be decoded.
+	ErrBadMessage = errors.New("mls: malformed message")
+
+	// ErrRemoved is returned once the local device was removed from the
+	// group.
+	ErrRemoved = errors.New("mls: removed from the group")
+
+	// ErrNotWelcomed is returned by Join for a Welcome that does not
+	// include the key package.
+	ErrNotWelcomed = errors.New("mls: welcome is not for this key package")
+
+	// ErrBadWelcome is returned for a Welcome that cannot be joined with.
+	ErrBadWelcome = errors.New("mls: invalid welcome")
+)
+
+// Member is a device in the group.
+type Member struct {
+	Leaf     uint32
+	UserID   uint64
+	DeviceID uint64
+
+	// SignatureKey is the identity key the device signs with. Clients
+	// check it against the identity key the device registered.
+	SignatureKey ed25519.PublicKey
+}
+
+// state is what a member knows in one epoch.
+type state struct {
+	epoch     uint64
+	tree      *tree
+	context   []byte
+	secrets   *epochSecrets
+	confirmed []byte
+	interim   []byte
+
+	// keys are the private keys of the nodes the member knows, its leaf
+	// and nodes of its direct path
+	keys map[uint32]*keyPair
+
+	ratchets map[uint32]*ratchet
+}
+
+func (s *state) ratchet(leaf uint32) *ratchet {
+	r := s.ratchets[leaf]
+	if r == nil {
+		r = newRatchet(leafSecret(s.secrets.encryption, s.tree.leaves(), leaf))
+		s.ratchets[leaf] = r
+	}
+	return r
+}
+
+// Group is the MLS state of the local device for one channel.
+type Group struct {
+	// Padding pads application data before it is encrypted. It is
+	// padding.Default unless changed before first use.
+	Padding padding.Scheme
+
+	mu         sync.Mutex
+	groupID    []byte
+	own        uint32
+	userID     uint64
+	deviceID   uint64
+	signingKey ed25519.PrivateKey
+	current    *state
+	past       []*state
+	removed    bool
+}
+
+// Create starts the group of a channel with the local device as its only
+// member, in epoch 0.
+func Create(channelID, userID, deviceID uint64, identityKey ed25519.PrivateKey) (*Group, error) {
+	leaf, kp, err := newLeaf(userID, deviceID, identityKey)
+	if err != nil {
+		return nil, err
+	}
+	t, _ := newTree([]*dispatch.MlsNode{{Leaf: leaf}})
+	g := &Group{
+		Padding:    padding.Default,
+		groupID:    groupID(channelID),
+		userID:     userID,
+		deviceID:   deviceID,
+		signingKey: identityKey,
+	}
+
+	epochSecret := make([]byte, hashSize)
+	if _, err := rand.Read(epochSecret); err != nil {
+		return nil, err
+	}
+	s := &state{
+		tree:     t,
+		keys:     map[uint32]*keyPair{0: kp},
+		ratchets: make(map[uint32]*ratchet),
+	}
+	s.context = groupContext(g.groupID, 0, t.hash(t.root()), nil)
+	s.secrets = newEpochSecrets(epochSecret)
+	s.interim = hashOf(nil, hmacSHA256(s.secrets.confirmation, nil))
+	g.current = s
+	return g, nil
+}
+
+// ChannelID returns the channel of the group.
+func (g *Group) ChannelID() uint64 {
+	return binary.BigEndian.Uint64(g.groupID)
+}
+
+// Epoch returns the current epoch.
+func (g *Group) Epoch() uint64 {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	return g.current.epoch
+}
+
+// Members returns the devices in the group.
+func (g *Group) Members() []Member {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	var members []Member
+	t := g.current.tree
+	for i := uint32(0); i < t.leaves(); i++ {
+		if leaf := t.leaf(i); leaf != nil {
+			members = append(members, Member{
+				Leaf:         i,
+				UserID:       leaf.UserId,
+				DeviceID:     leaf.DeviceId,
+				SignatureKey: leaf.SignatureKey,
+			})
+		}
+	}
+	return members
+}
+
+// EpochAuthenticator returns a secret of the current epoch that members can
+// compare out of band to make sure they are in the same group.
+func (g *Group) EpochAuthenticator() []byte {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	return append([]byte(nil), g.current.secrets.authenticator...)
+}
+
+// PendingCommit is a commit the local device made that srvtls has not
+// accepted yet.
+type PendingCommit struct {
+	// Commit is to be sent to the channel.
+	Commit *dispatch.MlsMessage
+
+	// Welcome is to be sent to the channel once srvtls accepted the
+	// commit, nil if no devices were added.
+	Welcome *dispatch.MlsMessage
+
+	from uint64
+	next *state
+}
+
+// Commit adds the devices of the key packages adds and removes the leaves
+// removes, and gives the local device fresh keys. The group is unchanged
+// until the commit is merged. The key packages must have been checked
+// against the device registry, and their users invited to the channel so
+// that they receive the Welcome.
+func (g *Group) Commit(adds []*dispatch.MlsKeyPackage, removes []uint32) (*PendingCommit, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.removed {
+		return nil, ErrRemoved
+	}
+	cur := g.current
+	next := cur.tree.clone()
+	commit := new(dispatch.MlsCommit)
+
+	for _, i := range removes {
+		if i == g.own || next.leaf(i) == nil {
+			return nil, ErrBadProposal
+		}
+		next.remove(i)
+		commit.Proposals = append(commit.Proposals, &dispatch.MlsProposal{Proposal: &dispatch.MlsProposal_Remove{Remove: i}})
+	}
+	added := make(map[uint32]*dispatch.MlsKeyPackage)
+	for _, kp := range adds {
+		if err := VerifyKeyPackage(kp); err != nil {
+			return nil, err
+		}
+		added[next.add(kp.Leaf)] = kp
+		commit.Proposals = append(commit.Proposals, &dispatch.MlsProposal{Proposal: &dispatch.MlsProposal_Add{Add: kp}})
+	}
+	newLeaves := make(map[uint32]bool, len(added))
+	for i := range added {
+		newLeaves[i] = true
+	}
+
+	// TreeKEM: a chain of path secrets up the filtered direct path, each
+	// giving the key pair of its node
+	leaf, leafKey, err := newLeaf(g.userID, g.deviceID, g.signingKey)
+	if err != nil {
+		return nil, err
+	}
+	path, copath := next.filteredDirectPath(g.own)
+	pathSecrets := make([][]byte, len(path))
+	pathKeys := make([]*keyPair, len(path))
+	pubs := make([][]byte, len(path))
+	commitSecret := make([]byte, hashSize)
+	if len(path) > 0 {
+		ps := make([]byte, hashSize)
+		if _, err := rand.Read(ps); err != nil {
+			return nil, err
+		}
+		for k := range path {
+			pathSecrets[k] = ps
+			if pathKeys[k], err = deriveKeyPair(deriveSecret(ps, "node")); err != nil {
+				return nil, err
+			}
+			pubs[k] = pathKeys[k].pub
+			ps = deriveSecret(ps, "path")
+		}
+		commitSecret = ps
+	}
+	next.setPath(g.own, leaf, path, pubs)
+
+	// the path secrets are encrypted under the provisional group context,
+	// which new members are left out of
+	provisional := groupContext(g.groupID, cur.epoch+1, next.hash(next.root()), cur.confirmed)
+	update := &dispatch.MlsUpdatePath{Leaf: leaf}
+	for k, c := range copath {
+		node := &dispatch.MlsUpdatePathNode{EncryptionKey: pubs[k]}
+		for _, x := range next.resolution(c, newLeaves) {
+			ct, err := sealHPKE(next.encryptionKey(x), "UpdatePathNode", provisional, pathSecrets[k])
+			if err != nil {
+				return nil, err
+			}
+			node.EncryptedPathSecret = append(node.EncryptedPathSecret, ct)
+		}
+		update.Nodes = append(update.Nodes, node)
+	}
+	commit.Path = update
+
+	commitBytes, err := proto.Marshal(commit)
+	if err != nil {
+		return nil, err
+	}
+	framed := framedCommit(cur.context, g.own, commitBytes)
+	signature := ed25519.Sign(g.signingKey, framed)
+
+	s := &state{
+		epoch:     cur.epoch + 1,
+		tree:      next,
+		confirmed: hashOf(cur.interim, framed, signature),
+		keys:      keepKeys(cur.keys, next),
+		ratchets:  make(map[uint32]*ratchet),
+	}
+	s.keys[2*g.own] = leafKey
+	for k, p := range path {
+		s.keys[p] = pathKeys[k]
+	}
+	s.context = groupContext(g.groupID, s.epoch, next.hash(next.root()), s.confirmed)
+	joiner, secrets := keySchedule(cur.secrets.init, commitSecret, s.context)
+	s.secrets = secrets
+	confirmationTag := hmacSHA256(secrets.confirmation, s.confirmed)
+	s.interim = hashOf(s.confirmed, confirmationTag)
+
+	msg := &dispatch.MlsPublicMessage{
+		GroupId:         g.groupID,
+		Epoch:           cur.epoch,
+		Sender:          g.own,
+		Commit:          commitBytes,
+		Signature:       signature,
+		ConfirmationTag: confirmationTag,
+		MembershipTag:   hmacSHA256(cur.secrets.membership, membershipInput(framed, signature, confirmationTag)),
+	}
+	data, err := proto.Marshal(msg)
+	if err != nil {
+		return nil, err
+	}
+	p := &PendingCommit{
+		Commit: &dispatch.MlsMessage{Kind: dispatch.MlsMessage_COMMIT, Epoch: cur.epoch, Data: data},
+		from:   cur.epoch,
+		next:   s,
+	}
+
+	if len(added) > 0 {
+		welcome, err := g.welcome(s, joiner, confirmationTag, added, path, copath, pathSecrets)
+		if err != nil {
+			return nil, err
+		}
+		data, err := proto.Marshal(welcome)
+		if err != nil {
+			return nil, err
+		}
+		p.Welcome = &dispatch.MlsMessage{Kind: dispatch.MlsMessage_WELCOME, Epoch: s.epoch, Data: data}
+	}
+	return p, nil
+}
+
+// welcome builds the Welcome of the devices added in the epoch s starts.
+func (g *Group) welcome(s *state, joiner, confirmationTag []byte, added map[uint32]*dispatch.MlsKeyPackage, path, copath []uint32, pathSecrets [][]byte) (*dispatch.MlsWelcome, error) {
+	info := &dispatch.MlsGroupInfo{
+		GroupId:                 g.groupID,
+		Epoch:                   s.epoch,
+		Tree:                    s.tree.nodes,
+		ConfirmedTranscriptHash: s.confirmed,
+		ConfirmationTag:         confirmationTag,
+		Signer:                  g.own,
+	}
+	info.Signature = ed25519.Sign(g.signingKey, groupInfoBytes(s.context, confirmationTag, g.own))
+	b, err := proto.Marshal(info)
+	if err != nil {
+		return nil, err
+	}
+	key, nonce := welcomeKey(joiner)
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	w := &dispatch.MlsWelcome{EncryptedGroupInfo: aead.Seal(nil, nonce, b, nil)}
+
+	for i, kp := range added {
+		secrets := &dispatch.MlsGroupSecrets{JoinerSecret: joiner}
+		for k, c := range copath {
+			if inSubtree(c, 2*i) {
+				secrets.PathSecret = pathSecrets[k]
+				break
+			}
+		}
+		b, err := proto.Marshal(secrets)
+		if err != nil {
+			return nil, err
+		}
+		ct, err := sealHPKE(kp.InitKey, "Welcome", w.EncryptedGroupInfo, b)
+		if err != nil {
+			return nil, err
+		}
+		w.Secrets = append(w.Secrets, &dispatch.MlsEncryptedGroupSecrets{
+			KeyPackageRef:         KeyPackageRef(kp),
+			EncryptedGroupSecrets: ct,
+		})
+	}
+	return w, nil
+}
+
+// Merge applies a commit of the local device after srvtls accepted it.
+func (g *Group) Merge(p *PendingCommit) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.removed {
+		return ErrRemoved
+	}
+	if p.from != g.current.epoch {
+		return ErrWrongEpoch
+	}
+	g.advance(p.next)
+	return nil
+}
+
+// ProcessCommit applies a commit of another member. Commits arrive in the
+// order srvtls accepted them. ErrRemoved is returned for the commit that
+// removes the local device, after which the group cannot be used anymore.
+func (g *Group) ProcessCommit(m *dispatch.MlsMessage) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.removed {
+		return ErrRemoved
+	}
+	msg := new(dispatch.MlsPublicMessage)
+	if m.GetKind() != dispatch.MlsMessage_COMMIT || proto.Unmarshal(m.GetData(), msg) != nil {
+		return ErrBadMessage
+	}
+	if !bytes.Equal(msg.GroupId, g.groupID) {
+		return ErrWrongGroup
+	}
+	cur := g.current
+	if msg.Epoch != cur.epoch {
+		return ErrWrongEpoch
+	}
+	sender := cur.tree.leaf(msg.Sender)
+	if sender == nil || msg.Sender == g.own {
+		return ErrBadCommit
+	}
+	framed := framedCommit(cur.context, msg.Sender, msg.Commit)
+	tag := hmacSHA256(cur.secrets.membership, membershipInput(framed, msg.Signature, msg.ConfirmationTag))
+	if !hmac.Equal(tag, msg.MembershipTag) {
+		return ErrBadTag
+	}
+	if !ed25519.Verify(sender.SignatureKey, framed, msg.Signature) {
+		return ErrBadSignature
+	}
+	commit := new(dispatch.MlsCommit)
+	if err := proto.Unmarshal(msg.Commit, commit); err != nil {
+		return ErrBadMessage
+	}
+
+	next := cur.tree.clone()
+	newLeaves := make(map[uint32]bool)
+	removed := false
+	for _, p := range commit.Proposals {
+		switch p := p.Proposal.(type) {
+		case *dispatch.MlsProposal_Remove:
+			if p.Remove == msg.Sender || next.leaf(p.Remove) == nil {
+				return ErrBadCommit
+			}
+			removed = removed || p.Remove == g.own
+			next.remove(p.Remove)
+		case *dispatch.MlsProposal_Add:
+			if err := VerifyKeyPackage(p.Add); err != nil {
+				return err
+			}
+			newLeaves[next.add(p.Add.Leaf)] = true
+		default:
+			return ErrBadCommit
+		}
+	}
+	if removed {
+		g.removed = true
+		g.current, g.past = nil, nil
+		return ErrRemoved
+	}
+
+	update := commit.Path
+	if err := verifyLeaf(update.GetLeaf()); err != nil {
+		return err
+	}
+	if update.Leaf.UserId != sender.UserId || update.Leaf.DeviceId != sender.DeviceId || !bytes.Equal(update.Leaf.SignatureKey, sender.SignatureKey) {
+		return ErrBadCommit
+	}
+	path, copath := next.filteredDirectPath(msg.Sender)
+	if len(update.Nodes) != len(path) {
+		return ErrBadCommit
+	}
+	pubs := make([][]byte, len(path))
+	for k, n := range update.Nodes {
+		if !validKey(n.EncryptionKey) {
+			return ErrBadCommit
+		}
+		pubs[k] = n.EncryptionKey
+	}
+	next.setPath(msg.Sender, update.Leaf, path, pubs)
+	provisional := groupContext(g.groupID, cur.epoch+1, next.hash(next.root()), cur.confirmed)
+
+	// the lowest node of the path above the local device brings the path
+	// secret, the ones above follow from it
+	keys := keepKeys(cur.keys, next)
+	var ps []byte
+	for k, c := range copath {
+		if !inSubtree(c, 2*g.own) {
+			continue
+		}
+		res := next.resolution(c, newLeaves)
+		if len(update.Nodes[k].EncryptedPathSecret) != len(res) {
+			return ErrBadCommit
+		}
+		for j, x := range res {
+			if kp := cur.keys[x]; kp != nil {
+				var err error
+				if ps, err = openHPKE(kp, "UpdatePathNode", provisional, update.Nodes[k].EncryptedPathSecret[j]); err != nil {
+					return err
+				}
+				break
+			}
+		}
+		if ps == nil {
+			return ErrBadCommit
+		}
+		for ; k < len(path); k++ {
+			kp, err := deriveKeyPair(deriveSecret(ps, "node"))
+			if err != nil {
+				return err
+			}
+			if !bytes.Equal(kp.pub, pubs[k]) {
+				return ErrBadCommit
+			}
+			keys[path[k]] = kp
+			ps = deriveSecret(ps, "path")
+		}
+		break
+	}
+	if ps == nil {
+		return ErrBadCommit
+	}
+
+	s := &state{
+		epoch:     cur.epoch + 1,
+		tree:      next,
+		confirmed: hashOf(cur.interim, framed, msg.Signature),
+		keys:      keys,
+		ratchets:  make(map[uint32]*ratchet),
+	}
+	s.context = groupContext(g.groupID, s.epoch, next.hash(next.root()), s.confirmed)
+	_, s.secrets = keySchedule(cur.secrets.init, ps, s.context)
+	if !hmac.Equal(hmacSHA256(s.secrets.confirmation, s.confirmed), msg.ConfirmationTag) {
+		return ErrBadTag
+	}
+	s.interim = hashOf(s.confirmed, msg.ConfirmationTag)
+	g.advance(s)
+	return nil
+}
+
+// Join creates the group a Welcome adds the device of kp to.
+func Join(kp *KeyPackage, m *dispatch.MlsMessage) (*Group, error) {
+	w := new(dispatch.MlsWelcome)
+	if m.GetKind() != dispatch.MlsMessage_WELCOME || proto.Unmarshal(m.GetData(), w) != nil {
+		return nil, ErrBadMessage
+	}
+	ref := KeyPackageRef(kp.Public)
+	var secrets *dispatch.MlsGroupSecrets
+	for _, s := range w.Secrets {
+		if !bytes.Equal(s.KeyPackageRef, ref) {
+			continue
+		}
+		b, err := openHPKE(kp.initKey, "Welcome", w.EncryptedGroupInfo, s.EncryptedGroupSecrets)
+		if err != nil {
+			return nil, err
+		}
+		secrets = new(dispatch.MlsGroupSecrets)
+		if err := proto.Unmarshal(b, secrets); err != nil || len(secrets.JoinerSecret) != hashSize {
+			return nil, ErrBadWelcome
+		}
+		break
+	}
+	if secrets == nil {
+		return nil, ErrNotWelcomed
+	}
+
+	key, nonce := welcomeKey(secrets.JoinerSecret)
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	b, err := aead.Open(nil, nonce, w.EncryptedGroupInfo, nil)
+	if err != nil {
+		return nil, ErrDecrypt
+	}
+	info := new(dispatch.MlsGroupInfo)
+	if err := proto.Unmarshal(b, info); err != nil || len(info.GroupId) != 8 {
+		return nil, ErrBadWelcome
+	}
+
+	t, ok := newTree(info.Tree)
+	if !ok {
+		return nil, ErrBadWelcome
+	}
+	for i := uint32(0); i < t.leaves(); i++ {
+		if !t.blank(2 * i) {
+			if err := verifyLeaf(t.leaf(i)); err != nil {
+				return nil, err
+			}
+		}
+	}
+	own, ok := t.find(kp.encryptionKey.pub)
+	signer := t.leaf(info.Signer)
+	if !ok || signer == nil || own == info.Signer {
+		return nil, ErrBadWelcome
+	}
+
+	s := &state{
+		epoch:     info.Epoch,
+		tree:      t,
+		confirmed: info.ConfirmedTranscriptHash,
+		keys:      map[uint32]*keyPair{2 * own: kp.encryptionKey},
+		ratchets:  make(map[uint32]*ratchet),
+	}
+	s.context = groupContext(info.GroupId, s.epoch, t.hash(t.root()), s.confirmed)
+	if !ed25519.Verify(signer.SignatureKey, groupInfoBytes(s.context, info.ConfirmationTag, info.Signer), info.Signature) {
+		return nil, ErrBadSignature
+	}
+	s.secrets = epochFromJoiner(secrets.JoinerSecret, s.context)
+	if !hmac.Equal(hmacSHA256(s.secrets.confirmation, s.confirmed), info.ConfirmationTag) {
+		return nil, ErrBadTag
+	}
+	s.interim = hashOf(s.confirmed, info.ConfirmationTag)
+
+	// the path secret of the lowest node shared with the committer gives
+	// the keys of that node and the ones above it
+	path, copath := t.filteredDirectPath(info.Signer)
+	ps := secrets.PathSecret
+	for k, c := range copath {
+		if !inSubtree(c, 2*own) {
+			continue
+		}
+		for ; k < len(path); k++ {
+			if ps == nil {
+				return nil, ErrBadWelcome
+			}
+			kp, err := deriveKeyPair(deriveSecret(ps, "node"))
+			if err != nil {
+				return nil, err
+			}
+			if !bytes.Equal(kp.pub, t.encryptionKey(path[k])) {
+				return nil, ErrBadWelcome
+			}
+			s.keys[path[k]] = kp
+			ps = deriveSecret(ps, "path")
+		}
+		break
+	}
+
+	leaf := t.leaf(own)
+	return &Group{
+		Padding:    padding.Default,
+		groupID:    info.GroupId,
+		own:        own,
+		userID:     leaf.UserId,
+		deviceID:   leaf.DeviceId,
+		signingKey: kp.signingKey,
+		current:    s,
+	}, nil
+}
+
+// Encrypt pads data and encrypts it for the members of the current epoch.
+func (g *Group) Encrypt(data []byte) (*dispatch.MlsMessage, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.removed {
+		return nil, ErrRemoved
+	}
+	s := g.current
+	data = padding.Pad(g.Padding, data)
+	content, err := proto.Marshal(&dispatch.MlsApplicationContent{
+		Data:      data,
+		Signature: ed25519.Sign(g.signingKey, applicationBytes(s.context, g.own, data)),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	key, nonce, generation := s.ratchet(g.own).next()
+	var senderData [senderDataSize]byte
+	binary.BigEndian.PutUint32(senderData[0:], g.own)
+	binary.BigEndian.PutUint32(senderData[4:], generation)
+	// the reuse guard keeps a nonce fresh should the ratchet state ever
+	// be restored from a copy
+	if _, err := rand.Read(senderData[8:]); err != nil {
+		return nil, err
+	}
+	for i := 0; i < 4; i++ {
+		nonce[i] ^= senderData[8+i]
+	}
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	aad := privateAAD(g.groupID, s.epoch)
+	msg := &dispatch.MlsPrivateMessage{
+		GroupId:         g.groupID,
+		Epoch:           s.epoch,
+		SenderDataNonce: make([]byte, nonceSize),
+		Ciphertext:      aead.Seal(nil, nonce, content, aad),
+	}
+	if _, err := rand.Read(msg.SenderDataNonce); err != nil {
+		return nil, err
+	}
+	sdAEAD, err := newAEAD(expandWithLabel(s.secrets.senderData, "key", nil, keySize))
+	if err != nil {
+		return nil, err
+	}
+	msg.EncryptedSenderData = sdAEAD.Seal(nil, msg.SenderDataNonce, senderData[:], aad)
+
+	b, err := proto.Marshal(msg)
+	if err != nil {
+		return nil, err
+	}
+	return &dispatch.MlsMessage{Kind: dispatch.MlsMessage_APPLICATION, Epoch: s.epoch, Data: b}, nil
+}
+
+// Decrypt returns the application data of m and the member that sent it.
+func (g *Group) Decrypt(m *dispatch.MlsMessage) (Member, []byte, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.removed {
+		return Member{}, nil, ErrRemoved
+	}
+	msg := new(dispatch.MlsPrivateMessage)
+	if m.GetKind() != dispatch.MlsMessage_APPLICATION || proto.Unmarshal(m.GetData(), msg) != nil {
+		return Member{}, nil, ErrBadMessage
+	}
+	if !bytes.Equal(msg.GroupId, g.groupID) {
+		return Member{}, nil, ErrWrongGroup
+	}
+	s := g.epochState(msg.Epoch)
+	if s == nil {
+		return Member{}, nil, ErrWrongEpoch
+	}
+
+	aad := privateAAD(g.groupID, s.epoch)
+	sdAEAD, err := newAEAD(expandWithLabel(s.secrets.senderData, "key", nil, keySize))
+	if err != nil {
+		return Member{}, nil, err
+	}
+	if len(msg.SenderDataNonce) != nonceSize {
+		return Member{}, nil, ErrBadMessage
+	}
+	senderData, err := sdAEAD.Open(nil, msg.SenderDataNonce, msg.EncryptedSenderData, aad)
+	if err != nil || len(senderData) != senderDataSize {
+		return Member{}, nil, ErrDecrypt
+	}
+	leafIndex := binary.BigEndian.Uint32(senderData[0:])
+	generation := binary.BigEndian.Uint32(senderData[4:])
+	leaf := s.tree.leaf(leafIndex)
+	if leaf == nil || leafIndex == g.own {
+		return Member{}, nil, ErrBadMessage
+	}
+
+	key, nonce, err := s.ratchet(leafIndex).get(generation)
+	if err != nil {
+		return Member{}, nil, err
+	}
+	for i := 0; i < 4; i++ {
+		nonce[i] ^= senderData[8+i]
+	}
+	aead, err := newAEAD(key)
+	if err != nil {
+		return Member{}, nil, err
+	}
+	b, err := aead.Open(nil, nonce, msg.Ciphertext, aad)
+	if err != nil {
+		return Member{}, nil, ErrDecrypt
+	}
+	content := new(dispatch.MlsApplicationContent)
+	if err := proto.Unmarshal(b, content); err != nil {
+		return Member{}, nil, ErrBadMessage
+	}
+	if !ed25519.Verify(leaf.SignatureKey, applicationBytes(s.context, leafIndex, content.Data), content.Signature) {
+		return Member{}, nil, ErrBadSignature
+	}
+	data, err := padding.Unpad(content.Data)
+	if err != nil {
+		return Member{}, nil, err
+	}
+	sender := Member{Leaf: leafIndex, UserID: leaf.UserId, DeviceID: leaf.DeviceId, SignatureKey: leaf.SignatureKey}
+	return sender, data, nil
+}
+
+// epochState returns the state of a current or kept epoch.
+func (g *Group) epochState(epoch uint64) *state {
+	if epoch == g.current.epoch {
+		return g.current
+	}
+	for _, s := range g.past {
+		if s.epoch == epoch {
+			return s
+		}
+	}
+	return nil
+}
+
+// advance moves to the epoch of s. Of the old epoch only what decrypts late
+// application messages is kept.
+func (g *Group) advance(s *state) {
+	old := g.current
+	old.keys = nil
+	old.secrets = &epochSecrets{senderData: old.secrets.senderData, encryption: old.secrets.encryption}
+	g.past = append(g.past, old)
+	if len(g.past) > keepEpochs {
+		g.past = g.past[len(g.past)-keepEpochs:]
+	}
+	g.current = s
+}
+
+// keepKeys returns the private keys in keys whose nodes still hold the same
+// public key in t.
+func keepKeys(keys map[uint32]*keyPair, t *tree) map[uint32]*keyPair {
+	kept := make(map[uint32]*keyPair, len(keys))
+	for x, kp := range keys {
+		if x < uint32(len(t.nodes)) && !t.blank(x) && bytes.Equal(t.encryptionKey(x), kp.pub) {
+			kept[x] = kp
+		}
+	}
+	return kept
+}
+
+func groupID(channelID uint64) []byte {
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint64(b, channelID)
+	return b
+}
+
+// framedCommit is what the committer signs: the commit in the context of the
+// epoch it was made in.
+func framedCommit(ctx []byte, sender uint32, commit []byte) []byte {
+	var b bytes.Buffer
+	b.WriteString(commitContext)
+	writeVector(&b, ctx)
+	binary.Write(&b, binary.BigEndian, sender)
+	writeVector(&b, commit)
+	return b.Bytes()
+}
+
+func membershipInput(framed, signature, confirmationTag []byte) []byte {
+	var b bytes.Buffer
+	writeVector(&b, framed)
+	writeVector(&b, signature)
+	writeVector(&b, confirmationTag)
+	return b.Bytes()
+}
+
+func groupInfoBytes(ctx, confirmationTag []byte, signer uint32) []byte {
+	var b bytes.Buffer
+	b.WriteString(groupInfoContext)
+	writeVector(&b, ctx)
+	writeVector(&b, confirmationTag)
+	binary.Write(&b, binary.BigEndian, signer)
+	return b.Bytes()
+}
+
+func applicationBytes(ctx []byte, sender uint32, data []byte) []byte {
+	var b bytes.Buffer
+	b.WriteString(applicationContext)
+	writeVector(&b, ctx)
+	binary.Write(&b, binary.BigEndian, sender)
+	writeVector(&b, data)
+	return b.Bytes()
+}
+
+func privateAAD(groupID []byte, epoch uint64) []byte {
+	var b bytes.Buffer
+	writeVector(&b, groupID)
+	binary.Write(&b, binary.BigEndian, epoch)
+	return b.Bytes()
+}
+
+// hashOf hashes its arguments, each with a length prefix.
+func hashOf(parts ...[]byte) []byte {
+	var b bytes.Buffer
+	for _, p := range parts {
+		writeVector(&b, p)
+	}
+	h := sha256.Sum256(b.Bytes())
+	return h[:]
+}
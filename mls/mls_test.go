@@ -0,0 +1,334 @@
+package mls
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"fmt"
+	"testing"
+
+	"github.com/Apurer/e2eechat/dispatch"
+	"github.com/golang/protobuf/proto"
+)
+
+const testChannel = 7
+
+func TestTreeMath(t *testing.T) {
+	// a tree of 8 leaves, RFC 9420 appendix C
+	tests := []struct {
+		x, level, parent, sibling uint32
+	}{
+		{0, 0, 1, 2},
+		{2, 0, 1, 0},
+		{1, 1, 3, 5},
+		{5, 1, 3, 1},
+		{3, 2, 7, 11},
+		{9, 1, 11, 13},
+		{11, 2, 7, 3},
+		{14, 0, 13, 12},
+	}
+	for _, tt := range tests {
+		if got := level(tt.x); got != tt.level {
+			t.Errorf("level(%d) = %d, want %d", tt.x, got, tt.level)
+		}
+		if got := parent(tt.x); got != tt.parent {
+			t.Errorf("parent(%d) = %d, want %d", tt.x, got, tt.parent)
+		}
+		if got := sibling(tt.x); got != tt.sibling {
+			t.Errorf("sibling(%d) = %d, want %d", tt.x, got, tt.sibling)
+		}
+	}
+
+	tr := &tree{nodes: make([]*dispatch.MlsNode, 15)}
+	if got, want := fmt.Sprint(tr.directPath(4)), "[5 3 7]"; got != want {
+		t.Errorf("directPath(4) = %s, want %s", got, want)
+	}
+	if !inSubtree(3, 6) || inSubtree(3, 8) || !inSubtree(7, 14) || !inSubtree(4, 4) {
+		t.Error("inSubtree is wrong")
+	}
+}
+
+type device struct {
+	userID, deviceID uint64
+	key              ed25519.PrivateKey
+}
+
+func newDevice(t *testing.T, userID uint64) *device {
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &device{userID: userID, deviceID: 1, key: key}
+}
+
+func (d *device) keyPackage(t *testing.T) *KeyPackage {
+	kp, err := NewKeyPackage(d.userID, d.deviceID, d.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return kp
+}
+
+// add has committer add devices and every other member in groups process the
+// commit. It returns the groups of the new devices.
+func add(t *testing.T, committer *Group, groups []*Group, devices ...*device) []*Group {
+	var kps []*KeyPackage
+	var pubs []*dispatch.MlsKeyPackage
+	for _, d := range devices {
+		kp := d.keyPackage(t)
+		kps = append(kps, kp)
+		pubs = append(pubs, kp.Public)
+	}
+	p, err := committer.Commit(pubs, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	commit(t, committer, p, groups)
+
+	var joined []*Group
+	for _, kp := range kps {
+		g, err := Join(kp, p.Welcome)
+		if err != nil {
+			t.Fatalf("Join: %v", err)
+		}
+		joined = append(joined, g)
+	}
+	return joined
+}
+
+// commit merges p and has every other group process it.
+func commit(t *testing.T, committer *Group, p *PendingCommit, groups []*Group) {
+	if err := committer.Merge(p); err != nil {
+		t.Fatal(err)
+	}
+	for _, g := range groups {
+		if g == committer {
+			continue
+		}
+		if err := g.ProcessCommit(p.Commit); err != nil {
+			t.Fatalf("ProcessCommit of leaf %d: %v", g.own, err)
+		}
+	}
+}
+
+// checkGroups checks that all groups are in the same epoch and that every
+// member can read the others.
+func checkGroups(t *testing.T, groups []*Group) {
+	t.Helper()
+
+	for _, g := range groups[1:] {
+		if g.Epoch() != groups[0].Epoch() || !bytes.Equal(g.EpochAuthenticator(), groups[0].EpochAuthenticator()) {
+			t.Fatalf("leaf %d is in another epoch than leaf %d", g.own, groups[0].own)
+		}
+	}
+	for _, from := range groups {
+		text := []byte(fmt.Sprintf("hello from %d", from.userID))
+		m, err := from.Encrypt(text)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, to := range groups {
+			if to == from {
+				continue
+			}
+			sender, got, err := to.Decrypt(m)
+			if err != nil {
+				t.Fatalf("leaf %d decrypting from leaf %d: %v", to.own, from.own, err)
+			}
+			if !bytes.Equal(got, text) || sender.UserID != from.userID || sender.Leaf != from.own {
+				t.Fatalf("leaf %d got %q from user %d, want %q from user %d", to.own, got, sender.UserID, text, from.userID)
+			}
+		}
+	}
+}
+
+func TestGroup(t *testing.T) {
+	alice, bob, carol, dave, erin := newDevice(t, 1), newDevice(t, 2), newDevice(t, 3), newDevice(t, 4), newDevice(t, 5)
+
+	a, err := Create(testChannel, alice.userID, alice.deviceID, alice.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	groups := append([]*Group{a}, add(t, a, []*Group{a}, bob, carol)...)
+	checkGroups(t, groups)
+	if got := len(a.Members()); got != 3 {
+		t.Fatalf("%d members, want 3", got)
+	}
+
+	// bob removes carol, who learns that she is out
+	b, c := groups[1], groups[2]
+	p, err := b.Commit(nil, []uint32{c.own})
+	if err != nil {
+		t.Fatal(err)
+	}
+	commit(t, b, p, []*Group{a})
+	if err := c.ProcessCommit(p.Commit); err != ErrRemoved {
+		t.Fatalf("removed member: got %v, want %v", err, ErrRemoved)
+	}
+	groups = []*Group{a, b}
+	checkGroups(t, groups)
+
+	// dave and erin join through carol's old leaf and a grown tree, and
+	// stay unmerged in the parents until someone commits over them
+	groups = append(groups, add(t, a, groups, dave, erin)...)
+	checkGroups(t, groups)
+
+	d := groups[2]
+	p, err = d.Commit(nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	commit(t, d, p, groups)
+	checkGroups(t, groups)
+	if got := a.Epoch(); got != 4 {
+		t.Fatalf("epoch %d, want 4", got)
+	}
+}
+
+func TestConcurrentCommits(t *testing.T) {
+	alice, bob := newDevice(t, 1), newDevice(t, 2)
+	a, err := Create(testChannel, alice.userID, alice.deviceID, alice.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := add(t, a, []*Group{a}, bob)[0]
+
+	// both commit in the same epoch, srvtls accepts alice's
+	pa, err := a.Commit(nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pb, err := b.Commit(nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	commit(t, a, pa, []*Group{b})
+	if err := b.Merge(pb); err != ErrWrongEpoch {
+		t.Fatalf("merging a stale commit: got %v, want %v", err, ErrWrongEpoch)
+	}
+	if err := a.ProcessCommit(pb.Commit); err != ErrWrongEpoch {
+		t.Fatalf("processing a stale commit: got %v, want %v", err, ErrWrongEpoch)
+	}
+	checkGroups(t, []*Group{a, b})
+}
+
+func TestLateAndReplayedMessages(t *testing.T) {
+	alice, bob := newDevice(t, 1), newDevice(t, 2)
+	a, err := Create(testChannel, alice.userID, alice.deviceID, alice.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := add(t, a, []*Group{a}, bob)[0]
+
+	first, err := a.Encrypt([]byte("first"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := a.Encrypt([]byte("second"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// bob commits before he reads alice's messages of the epoch
+	p, err := b.Commit(nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	commit(t, b, p, []*Group{a})
+
+	for _, m := range []*dispatch.MlsMessage{second, first} {
+		if _, _, err := b.Decrypt(m); err != nil {
+			t.Fatalf("late message: %v", err)
+		}
+	}
+	if _, _, err := b.Decrypt(first); err != ErrOldMessage {
+		t.Fatalf("replayed message: got %v, want %v", err, ErrOldMessage)
+	}
+}
+
+func TestTampering(t *testing.T) {
+	alice, bob, carol := newDevice(t, 1), newDevice(t, 2), newDevice(t, 3)
+	a, err := Create(testChannel, alice.userID, alice.deviceID, alice.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	joined := add(t, a, []*Group{a}, bob, carol)
+	b, c := joined[0], joined[1]
+
+	p, err := a.Commit(nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name   string
+		change func(*dispatch.MlsPublicMessage)
+		want   error
+	}{
+		{"membership tag", func(m *dispatch.MlsPublicMessage) { m.MembershipTag[0] ^= 1 }, ErrBadTag},
+		{"confirmation tag", func(m *dispatch.MlsPublicMessage) { m.ConfirmationTag[0] ^= 1 }, ErrBadTag},
+		{"other sender", func(m *dispatch.MlsPublicMessage) { m.Sender = c.own }, ErrBadTag},
+		{"other group", func(m *dispatch.MlsPublicMessage) { m.GroupId = groupID(testChannel + 1) }, ErrWrongGroup},
+		{"other epoch", func(m *dispatch.MlsPublicMessage) { m.Epoch++ }, ErrWrongEpoch},
+	}
+	for _, tt := range tests {
+		msg := new(dispatch.MlsPublicMessage)
+		if err := proto.Unmarshal(p.Commit.Data, msg); err != nil {
+			t.Fatal(err)
+		}
+		tt.change(msg)
+		data, err := proto.Marshal(msg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		m := &dispatch.MlsMessage{Kind: dispatch.MlsMessage_COMMIT, Epoch: msg.Epoch, Data: data}
+		if err := b.ProcessCommit(m); err != tt.want {
+			t.Errorf("%s: got %v, want %v", tt.name, err, tt.want)
+		}
+	}
+
+	// an outsider with a key package of its own cannot join with someone
+	// else's Welcome
+	kp := newDevice(t, 9).keyPackage(t)
+	p, err = a.Commit([]*dispatch.MlsKeyPackage{newDevice(t, 4).keyPackage(t).Public}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Join(kp, p.Welcome); err != ErrNotWelcomed {
+		t.Errorf("foreign welcome: got %v, want %v", err, ErrNotWelcomed)
+	}
+
+	m, err := a.Encrypt([]byte("hi"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg := new(dispatch.MlsPrivateMessage)
+	if err := proto.Unmarshal(m.Data, msg); err != nil {
+		t.Fatal(err)
+	}
+	msg.Ciphertext[0] ^= 1
+	m.Data, _ = proto.Marshal(msg)
+	if _, _, err := b.Decrypt(m); err != ErrDecrypt {
+		t.Errorf("modified ciphertext: got %v, want %v", err, ErrDecrypt)
+	}
+}
+
+func TestVerifyKeyPackage(t *testing.T) {
+	d := newDevice(t, 1)
+	tests := []struct {
+		name   string
+		change func(*dispatch.MlsKeyPackage)
+		want   error
+	}{
+		{"valid", func(*dispatch.MlsKeyPackage) {}, nil},
+		{"other user", func(kp *dispatch.MlsKeyPackage) { kp.Leaf.UserId++ }, ErrBadLeaf},
+		{"other init key", func(kp *dispatch.MlsKeyPackage) { kp.InitKey = d.keyPackage(t).Public.InitKey }, ErrBadKeyPackage},
+		{"init key reused as leaf key", func(kp *dispatch.MlsKeyPackage) { kp.InitKey = kp.Leaf.EncryptionKey }, ErrBadKeyPackage},
+		{"no leaf", func(kp *dispatch.MlsKeyPackage) { kp.Leaf = nil }, ErrBadLeaf},
+	}
+	for _, tt := range tests {
+		kp := d.keyPackage(t).Public
+		tt.change(kp)
+		if err := VerifyKeyPackage(kp); err != tt.want {
+			t.Errorf("%s: got %v, want %v", tt.name, err, tt.want)
+		}
+	}
+}
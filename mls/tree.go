@@ -0,0 +1,244 @@
+package mls
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+
+	"github.com/Apurer/e2eechat/dispatch"
+	"github.com/golang/protobuf/proto"
+)
+
+// The ratchet tree is kept in the array representation of RFC 9420,
+// appendix C: leaf i is node 2i, the parents sit between their children and
+// the number of leaves is a power of two. Blank nodes are MlsNodes with
+// neither a leaf nor a parent.
+
+// level returns the height of node x above the leaves.
+func level(x uint32) uint32 {
+	var k uint32
+	for (x>>k)&1 == 1 {
+		k++
+	}
+	return k
+}
+
+func left(x uint32) uint32 {
+	return x ^ (1 << (level(x) - 1))
+}
+
+func right(x uint32) uint32 {
+	return x ^ (3 << (level(x) - 1))
+}
+
+func parent(x uint32) uint32 {
+	k := level(x)
+	b := (x >> (k + 1)) & 1
+	return (x | 1<<k) ^ (b << (k + 1))
+}
+
+func sibling(x uint32) uint32 {
+	p := parent(x)
+	if x < p {
+		return right(p)
+	}
+	return left(p)
+}
+
+// inSubtree reports whether node y is x or below it.
+func inSubtree(x, y uint32) bool {
+	k := level(x)
+	return y+(1<<k) > x && y < x+(1<<k)
+}
+
+type tree struct {
+	nodes []*dispatch.MlsNode
+}
+
+func newTree(nodes []*dispatch.MlsNode) (*tree, bool) {
+	n := len(nodes) + 1
+	if n < 2 || n&(n-1) != 0 {
+		return nil, false
+	}
+	t := &tree{nodes: make([]*dispatch.MlsNode, len(nodes))}
+	for i, node := range nodes {
+		if node == nil {
+			node = new(dispatch.MlsNode)
+		}
+		if node.Leaf != nil && node.Parent != nil || i%2 == 0 && node.Parent != nil || i%2 == 1 && node.Leaf != nil {
+			return nil, false
+		}
+		t.nodes[i] = node
+	}
+	return t, true
+}
+
+func (t *tree) clone() *tree {
+	c := &tree{nodes: make([]*dispatch.MlsNode, len(t.nodes))}
+	for i, n := range t.nodes {
+		c.nodes[i] = proto.Clone(n).(*dispatch.MlsNode)
+	}
+	return c
+}
+
+func (t *tree) leaves() uint32 {
+	return uint32(len(t.nodes)+1) / 2
+}
+
+func (t *tree) root() uint32 {
+	return t.leaves() - 1
+}
+
+func (t *tree) leaf(i uint32) *dispatch.MlsLeafNode {
+	if i >= t.leaves() {
+		return nil
+	}
+	return t.nodes[2*i].GetLeaf()
+}
+
+func (t *tree) blank(x uint32) bool {
+	return t.nodes[x].GetLeaf() == nil && t.nodes[x].GetParent() == nil
+}
+
+func (t *tree) encryptionKey(x uint32) []byte {
+	if x%2 == 0 {
+		return t.nodes[x].GetLeaf().GetEncryptionKey()
+	}
+	return t.nodes[x].GetParent().GetEncryptionKey()
+}
+
+// directPath returns the ancestors of x up to the root.
+func (t *tree) directPath(x uint32) []uint32 {
+	var path []uint32
+	for r := t.root(); x != r; {
+		x = parent(x)
+		path = append(path, x)
+	}
+	return path
+}
+
+// filteredDirectPath returns the ancestors of leaf i whose child off the
+// path has a non-empty resolution, and those children. Only these get a
+// new key when the leaf commits.
+func (t *tree) filteredDirectPath(i uint32) (path, copath []uint32) {
+	child := 2 * i
+	for _, p := range t.directPath(child) {
+		c := sibling(child)
+		if len(t.resolution(c, nil)) > 0 {
+			path = append(path, p)
+			copath = append(copath, c)
+		}
+		child = p
+	}
+	return path, copath
+}
+
+// resolution returns the nodes that together cover the members below x,
+// leaving out the leaves in exclude.
+func (t *tree) resolution(x uint32, exclude map[uint32]bool) []uint32 {
+	if t.blank(x) {
+		if level(x) == 0 {
+			return nil
+		}
+		return append(t.resolution(left(x), exclude), t.resolution(right(x), exclude)...)
+	}
+	if level(x) == 0 {
+		if exclude[x/2] {
+			return nil
+		}
+		return []uint32{x}
+	}
+	res := []uint32{x}
+	for _, l := range t.nodes[x].GetParent().GetUnmergedLeaves() {
+		if !exclude[l] {
+			res = append(res, 2*l)
+		}
+	}
+	return res
+}
+
+// add puts leaf in the leftmost blank leaf, growing the tree if there is
+// none, and returns its index.
+func (t *tree) add(leaf *dispatch.MlsLeafNode) uint32 {
+	i := uint32(0)
+	for ; i < t.leaves() && !t.blank(2*i); i++ {
+	}
+	if i == t.leaves() {
+		for n := len(t.nodes) + 1; n > 0; n-- {
+			t.nodes = append(t.nodes, new(dispatch.MlsNode))
+		}
+	}
+	t.nodes[2*i] = &dispatch.MlsNode{Leaf: leaf}
+	for _, p := range t.directPath(2 * i) {
+		if n := t.nodes[p].GetParent(); n != nil {
+			n.UnmergedLeaves = append(n.UnmergedLeaves, i)
+		}
+	}
+	return i
+}
+
+// remove blanks leaf i and its direct path, and shrinks the tree while its
+// right half is blank.
+func (t *tree) remove(i uint32) {
+	t.nodes[2*i] = new(dispatch.MlsNode)
+	for _, p := range t.directPath(2 * i) {
+		t.nodes[p] = new(dispatch.MlsNode)
+	}
+	for t.leaves() > 1 {
+		r := t.root()
+		for x := r + 1; x < uint32(len(t.nodes)); x++ {
+			if !t.blank(x) {
+				return
+			}
+		}
+		t.nodes = t.nodes[:r]
+	}
+}
+
+// setPath gives the filtered direct path of leaf i the keys in keys, blanks
+// the rest of its direct path and sets the new leaf.
+func (t *tree) setPath(i uint32, leaf *dispatch.MlsLeafNode, path []uint32, keys [][]byte) {
+	t.nodes[2*i] = &dispatch.MlsNode{Leaf: leaf}
+	for _, p := range t.directPath(2 * i) {
+		t.nodes[p] = new(dispatch.MlsNode)
+	}
+	for k, p := range path {
+		t.nodes[p] = &dispatch.MlsNode{Parent: &dispatch.MlsParentNode{EncryptionKey: keys[k]}}
+	}
+}
+
+// hash is the tree hash of the subtree under x, which the group context
+// carries so that all members agree on the tree.
+func (t *tree) hash(x uint32) []byte {
+	var b bytes.Buffer
+	if level(x) == 0 {
+		b.WriteByte(1)
+		binary.Write(&b, binary.BigEndian, x/2)
+		if leaf := t.nodes[x].GetLeaf(); leaf != nil {
+			writeVector(&b, leafBytes(leaf))
+			writeVector(&b, leaf.Signature)
+		}
+	} else {
+		b.WriteByte(2)
+		if p := t.nodes[x].GetParent(); p != nil {
+			writeVector(&b, p.EncryptionKey)
+			for _, l := range p.UnmergedLeaves {
+				binary.Write(&b, binary.BigEndian, l)
+			}
+		}
+		b.Write(t.hash(left(x)))
+		b.Write(t.hash(right(x)))
+	}
+	h := sha256.Sum256(b.Bytes())
+	return h[:]
+}
+
+// find returns the leaf holding the encryption key pub.
+func (t *tree) find(pub []byte) (uint32, bool) {
+	for i := uint32(0); i < t.leaves(); i++ {
+		if leaf := t.leaf(i); leaf != nil && bytes.Equal(leaf.EncryptionKey, pub) {
+			return i, true
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,156 @@
+// Package padding hides the length of plaintexts before they are encrypted.
+//
+// Ciphertexts are exactly as long as their plaintexts plus a constant, so
+// without padding the relay learns the length of every text and whether an
+// image was attached. Pad rounds a plaintext up to a size chosen by a
+// Scheme, marking where the plaintext ends ISO/IEC 7816-4 style with a 0x80
+// byte followed by zeros, and Unpad strips it again after decryption.
+// Unpad does not need to know the scheme, so senders can change it without
+// breaking receivers. The PADDING environment variable sets Default: "padme",
+// or a comma separated list of bucket sizes.
+//
+// With DefaultBuckets every text up to 255 bytes is sent as 256 bytes, and
+// one of 300 bytes is as long as one of 1000:
+//
+//	plaintext           1    100    255    256   1000   1023   5000  70000
+//	DefaultBuckets    256    256    256   1024   1024   1024  16384  81920
+//	Padme               2    104    256    272   1024   1024   5120  71680
+package padding
+
+import (
+	"errors"
+	"fmt"
+	"math/bits"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ErrInvalidPadding is returned by Unpad for data without a padding marker.
+var ErrInvalidPadding = errors.New("padding: invalid padding")
+
+// Scheme decides the padded size of a message.
+type Scheme interface {
+	// Size returns the padded size of n bytes, at least n.
+	Size(n int) int
+}
+
+// DefaultBuckets suit chat messages: short texts share the smallest bucket,
+// attachment pointers and longer texts the next ones.
+var DefaultBuckets = Buckets{256, 1024, 4096, 16384}
+
+// Default is the scheme used where none is configured, the one PADDING
+// names or DefaultBuckets. Programs that want to report an invalid PADDING
+// call FromEnv themselves.
+var Default = defaultScheme()
+
+func defaultScheme() Scheme {
+	s, err := FromEnv()
+	if err != nil {
+		return DefaultBuckets
+	}
+	return s
+}
+
+// FromEnv returns the scheme named by the PADDING environment variable, or
+// DefaultBuckets if it is not set.
+func FromEnv() (Scheme, error) {
+	v := os.Getenv("PADDING")
+	if v == "" {
+		return DefaultBuckets, nil
+	}
+	return Parse(v)
+}
+
+// Parse parses a scheme as given in configuration: "padme" or bucket sizes
+// for ParseBuckets.
+func Parse(s string) (Scheme, error) {
+	if strings.TrimSpace(s) == "padme" {
+		return Padme, nil
+	}
+	return ParseBuckets(s)
+}
+
+// Pad returns b followed by the marker byte and as many zeros as needed to
+// reach s.Size(len(b)+1).
+func Pad(s Scheme, b []byte) []byte {
+	n := len(b) + 1
+	size := s.Size(n)
+	if size < n {
+		size = n
+	}
+	p := make([]byte, size)
+	copy(p, b)
+	p[len(b)] = 0x80
+	return p
+}
+
+// Unpad strips the padding added by Pad.
+func Unpad(p []byte) ([]byte, error) {
+	i := len(p) - 1
+	for i >= 0 && p[i] == 0 {
+		i--
+	}
+	if i < 0 || p[i] != 0x80 {
+		return nil, ErrInvalidPadding
+	}
+	return p[:i], nil
+}
+
+// Buckets pads to the smallest bucket that fits, and beyond the largest one
+// to a multiple of it. Buckets are sorted in ascending order.
+type Buckets []int
+
+// Size implements Scheme.
+func (b Buckets) Size(n int) int {
+	if len(b) == 0 {
+		return n
+	}
+	for _, size := range b {
+		if n <= size {
+			return size
+		}
+	}
+	last := b[len(b)-1]
+	return (n + last - 1) / last * last
+}
+
+// ParseBuckets parses a comma separated list of sizes in bytes, as given in
+// configuration.
+func ParseBuckets(s string) (Buckets, error) {
+	var b Buckets
+	for _, f := range strings.Split(s, ",") {
+		f = strings.TrimSpace(f)
+		if f == "" {
+			continue
+		}
+		size, err := strconv.Atoi(f)
+		if err != nil || size <= 0 {
+			return nil, fmt.Errorf("padding: invalid bucket size %q", f)
+		}
+		b = append(b, size)
+	}
+	if len(b) == 0 {
+		return nil, errors.New("padding: no buckets")
+	}
+	sort.Ints(b)
+	return b, nil
+}
+
+// Padme is the Padmé scheme of the PURBs paper: sizes are rounded so that
+// only O(log log n) bits of the length are revealed, with at most 12%
+// overhead.
+var Padme Scheme = padme{}
+
+type padme struct{}
+
+func (padme) Size(n int) int {
+	if n < 2 {
+		return n
+	}
+	e := bits.Len(uint(n)) - 1 // floor(log2 n)
+	s := bits.Len(uint(e))     // floor(log2 e) + 1
+	mask := 1<<uint(e-s) - 1
+	return (n + mask) &^ mask
+}
@@ -0,0 +1,116 @@
+package padding
+
+import (
+	"bytes"
+	"os"
+	"testing"
+)
+
+func TestBucketsHideLength(t *testing.T) {
+	// plaintexts in the same row must be padded to the same size
+	tests := []struct {
+		scheme Scheme
+		sizes  []int
+		want   int
+	}{
+		{DefaultBuckets, []int{0, 1, 100, 255}, 256},
+		{DefaultBuckets, []int{256, 300, 1000, 1023}, 1024},
+		{DefaultBuckets, []int{1024, 4095}, 4096},
+		{DefaultBuckets, []int{4096, 5000, 16383}, 16384},
+		{DefaultBuckets, []int{65536, 70000, 81919}, 81920},
+		{Padme, []int{97, 100, 103}, 104},
+		{Padme, []int{1000, 1023}, 1024},
+		{Padme, []int{4865, 5000, 5119}, 5120},
+		{Padme, []int{70000, 71679}, 71680},
+	}
+	for _, tt := range tests {
+		for _, n := range tt.sizes {
+			if got := len(Pad(tt.scheme, make([]byte, n))); got != tt.want {
+				t.Errorf("%T: %d bytes padded to %d, want %d", tt.scheme, n, got, tt.want)
+			}
+		}
+	}
+}
+
+func TestPadUnpad(t *testing.T) {
+	for _, s := range []Scheme{DefaultBuckets, Padme, Buckets(nil)} {
+		for _, n := range []int{0, 1, 2, 255, 256, 1000, 5000} {
+			// trailing zeros and marker bytes in the plaintext survive
+			b := bytes.Repeat([]byte{0x80, 0}, n)[:n]
+			p := Pad(s, b)
+			if len(p) != s.Size(n+1) {
+				t.Errorf("%T: %d bytes padded to %d", s, n, len(p))
+			}
+			got, err := Unpad(p)
+			if err != nil || !bytes.Equal(got, b) {
+				t.Errorf("%T: Unpad(Pad(%d bytes)) = %d bytes, %v", s, n, len(got), err)
+			}
+		}
+	}
+	for _, p := range [][]byte{nil, {0}, {0x01, 0}, {0x7f}} {
+		if _, err := Unpad(p); err != ErrInvalidPadding {
+			t.Errorf("Unpad(%x): got %v, want %v", p, err, ErrInvalidPadding)
+		}
+	}
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    Scheme
+		wantErr bool
+	}{
+		{"padme", Padme, false},
+		{"1024, 256,4096", Buckets{256, 1024, 4096}, false},
+		{"512", Buckets{512}, false},
+		{"", nil, true},
+		{"256,abc", nil, true},
+		{"0", nil, true},
+		{"-1", nil, true},
+	}
+	for _, tt := range tests {
+		got, err := Parse(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("Parse(%q): error %v, want error %v", tt.in, err, tt.wantErr)
+			continue
+		}
+		if err == nil && !sameScheme(got, tt.want) {
+			t.Errorf("Parse(%q) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestFromEnv(t *testing.T) {
+	defer os.Setenv("PADDING", os.Getenv("PADDING"))
+
+	tests := []struct {
+		env     string
+		want    Scheme
+		wantErr bool
+	}{
+		{"", DefaultBuckets, false},
+		{"padme", Padme, false},
+		{"128,512", Buckets{128, 512}, false},
+		{"lots", nil, true},
+	}
+	for _, tt := range tests {
+		os.Setenv("PADDING", tt.env)
+		got, err := FromEnv()
+		if (err != nil) != tt.wantErr {
+			t.Errorf("PADDING=%q: error %v, want error %v", tt.env, err, tt.wantErr)
+			continue
+		}
+		if err == nil && !sameScheme(got, tt.want) {
+			t.Errorf("PADDING=%q: got %v, want %v", tt.env, got, tt.want)
+		}
+	}
+}
+
+func sameScheme(a, b Scheme) bool {
+	for _, n := range []int{1, 100, 256, 1000, 5000, 70000} {
+		if a.Size(n) != b.Size(n) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,346 @@
+// Package proxyproto implements the binary PROXY protocol version 2 header
+// used by tls2tlsproxy to tell srvtls who the client really is.
+package proxyproto
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/tls"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+)
+
+// TLV types from the PROXY protocol specification, plus the custom range
+// (0xE0-0xEF) used for e2eechat specific values.
+const (
+	TypeALPN      byte = 0x01
+	TypeAuthority byte = 0x02
+	TypeSSL       byte = 0x20
+
+	SubtypeSSLVersion byte = 0x21
+	SubtypeSSLCN      byte = 0x22
+	SubtypeSSLCipher  byte = 0x23
+
+	TypeUserID        byte = 0xE0
+	TypeCorrelationID byte = 0xE1
+	TypeDeviceID      byte = 0xE2
+)
+
+// SSL client flags carried in the TypeSSL TLV.
+const (
+	ClientSSL      byte = 0x01
+	ClientCertConn byte = 0x02
+	ClientCertSess byte = 0x04
+)
+
+const (
+	versionCommandLocal byte = 0x20
+	versionCommandProxy byte = 0x21
+
+	familyUnspec   byte = 0x00
+	familyTCPv4    byte = 0x11
+	familyTCPv6    byte = 0x21
+	addrLenTCPv4        = 12
+	addrLenTCPv6        = 36
+	maxHeaderValue      = 0xFFFF
+)
+
+var signature = []byte{0x0D, 0x0A, 0x0D, 0x0A, 0x00, 0x0D, 0x0A, 0x51, 0x55, 0x49, 0x54, 0x0A}
+
+var (
+	// ErrNoSignature is returned by Read when the stream does not start with a
+	// PROXY protocol v2 header.
+	ErrNoSignature = errors.New("proxyproto: missing v2 signature")
+
+	errTruncated = errors.New("proxyproto: truncated header")
+)
+
+// TLV is a single type-length-value entry of the header.
+type TLV struct {
+	Type  byte
+	Value []byte
+}
+
+// Header is a PROXY protocol v2 header. A header with nil addresses is sent
+// with the LOCAL command, meaning the connection was not proxied.
+type Header struct {
+	Source      *net.TCPAddr
+	Destination *net.TCPAddr
+	TLVs        []TLV
+}
+
+// Lookup returns the value of the first TLV of the given type.
+func (h *Header) Lookup(typ byte) ([]byte, bool) {
+	for _, tlv := range h.TLVs {
+		if tlv.Type == typ {
+			return tlv.Value, true
+		}
+	}
+	return nil, false
+}
+
+// UserID returns the verified user id carried in the TypeUserID TLV.
+func (h *Header) UserID() (uint64, bool) {
+	return h.uint64(TypeUserID)
+}
+
+// DeviceID returns the verified device id carried in the TypeDeviceID TLV.
+func (h *Header) DeviceID() (uint64, bool) {
+	return h.uint64(TypeDeviceID)
+}
+
+func (h *Header) uint64(typ byte) (uint64, bool) {
+	v, ok := h.Lookup(typ)
+	if !ok || len(v) != 8 {
+		return 0, false
+	}
+	return binary.BigEndian.Uint64(v), true
+}
+
+// CorrelationID returns the logging correlation id of the session.
+func (h *Header) CorrelationID() string {
+	v, _ := h.Lookup(TypeCorrelationID)
+	return string(v)
+}
+
+// Marshal encodes the header in the binary v2 format.
+func (h *Header) Marshal() ([]byte, error) {
+	var addrs bytes.Buffer
+	command, family := versionCommandLocal, familyUnspec
+
+	if h.Source != nil && h.Destination != nil {
+		command = versionCommandProxy
+		src4, dst4 := h.Source.IP.To4(), h.Destination.IP.To4()
+		if src4 != nil && dst4 != nil {
+			family = familyTCPv4
+			addrs.Write(src4)
+			addrs.Write(dst4)
+		} else {
+			family = familyTCPv6
+			addrs.Write(h.Source.IP.To16())
+			addrs.Write(h.Destination.IP.To16())
+		}
+		binary.Write(&addrs, binary.BigEndian, uint16(h.Source.Port))
+		binary.Write(&addrs, binary.BigEndian, uint16(h.Destination.Port))
+	}
+
+	for _, tlv := range h.TLVs {
+		if len(tlv.Value) > maxHeaderValue {
+			return nil, fmt.Errorf("proxyproto: tlv 0x%02x too long", tlv.Type)
+		}
+		addrs.WriteByte(tlv.Type)
+		binary.Write(&addrs, binary.BigEndian, uint16(len(tlv.Value)))
+		addrs.Write(tlv.Value)
+	}
+	if addrs.Len() > maxHeaderValue {
+		return nil, errors.New("proxyproto: header too long")
+	}
+
+	out := make([]byte, 0, len(signature)+4+addrs.Len())
+	out = append(out, signature...)
+	out = append(out, command, family)
+	out = append(out, byte(addrs.Len()>>8), byte(addrs.Len()))
+	return append(out, addrs.Bytes()...), nil
+}
+
+// WriteTo writes the encoded header to w.
+func (h *Header) WriteTo(w io.Writer) (int64, error) {
+	b, err := h.Marshal()
+	if err != nil {
+		return 0, err
+	}
+	n, err := w.Write(b)
+	return int64(n), err
+}
+
+// Read parses a v2 header from r. Bytes following the header are left in r.
+func Read(r *bufio.Reader) (*Header, error) {
+	prefix, err := r.Peek(len(signature))
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(prefix, signature) {
+		return nil, ErrNoSignature
+	}
+
+	fixed := make([]byte, len(signature)+4)
+	if _, err := io.ReadFull(r, fixed); err != nil {
+		return nil, err
+	}
+	command, family := fixed[12], fixed[13]
+	body := make([]byte, binary.BigEndian.Uint16(fixed[14:]))
+	if _, err := io.ReadFull(r, body); err != nil {
+		return nil, err
+	}
+
+	h := new(Header)
+	switch command {
+	case versionCommandLocal:
+		// addresses of a LOCAL header carry no meaning and are skipped
+		body, err = skipAddrs(family, body)
+		if err != nil {
+			return nil, err
+		}
+	case versionCommandProxy:
+		switch family {
+		case familyTCPv4:
+			if len(body) < addrLenTCPv4 {
+				return nil, errTruncated
+			}
+			h.Source = &net.TCPAddr{IP: net.IP(body[0:4]), Port: int(binary.BigEndian.Uint16(body[8:]))}
+			h.Destination = &net.TCPAddr{IP: net.IP(body[4:8]), Port: int(binary.BigEndian.Uint16(body[10:]))}
+		case familyTCPv6:
+			if len(body) < addrLenTCPv6 {
+				return nil, errTruncated
+			}
+			h.Source = &net.TCPAddr{IP: net.IP(body[0:16]), Port: int(binary.BigEndian.Uint16(body[32:]))}
+			h.Destination = &net.TCPAddr{IP: net.IP(body[16:32]), Port: int(binary.BigEndian.Uint16(body[34:]))}
+		default:
+			return nil, fmt.Errorf("proxyproto: unsupported address family 0x%02x", family)
+		}
+		body, _ = skipAddrs(family, body)
+	default:
+		return nil, fmt.Errorf("proxyproto: unsupported version/command 0x%02x", command)
+	}
+
+	h.TLVs, err = parseTLVs(body)
+	if err != nil {
+		return nil, err
+	}
+	return h, nil
+}
+
+func skipAddrs(family byte, body []byte) ([]byte, error) {
+	n := 0
+	switch family {
+	case familyTCPv4:
+		n = addrLenTCPv4
+	case familyTCPv6:
+		n = addrLenTCPv6
+	}
+	if len(body) < n {
+		return nil, errTruncated
+	}
+	return body[n:], nil
+}
+
+func parseTLVs(b []byte) ([]TLV, error) {
+	var tlvs []TLV
+	for len(b) > 0 {
+		if len(b) < 3 {
+			return nil, errTruncated
+		}
+		n := int(binary.BigEndian.Uint16(b[1:3]))
+		if len(b) < 3+n {
+			return nil, errTruncated
+		}
+		tlvs = append(tlvs, TLV{Type: b[0], Value: b[3 : 3+n]})
+		b = b[3+n:]
+	}
+	return tlvs, nil
+}
+
+// UserIDTLV encodes a verified user id.
+func UserIDTLV(userID uint64) TLV {
+	return uint64TLV(TypeUserID, userID)
+}
+
+// DeviceIDTLV encodes a verified device id.
+func DeviceIDTLV(deviceID uint64) TLV {
+	return uint64TLV(TypeDeviceID, deviceID)
+}
+
+func uint64TLV(typ byte, n uint64) TLV {
+	v := make([]byte, 8)
+	binary.BigEndian.PutUint64(v, n)
+	return TLV{Type: typ, Value: v}
+}
+
+// SSLTLV describes the client side TLS session. verified reports whether a
+// client certificate was presented and verified.
+func SSLTLV(state tls.ConnectionState, verified bool) TLV {
+	var b bytes.Buffer
+
+	client := ClientSSL
+	if len(state.PeerCertificates) > 0 {
+		client |= ClientCertConn
+		if !state.DidResume {
+			client |= ClientCertSess
+		}
+	}
+	b.WriteByte(client)
+
+	// verify is zero when the client presented a certificate that was verified
+	var verify uint32 = 1
+	if verified {
+		verify = 0
+	}
+	binary.Write(&b, binary.BigEndian, verify)
+
+	sub := []TLV{
+		{Type: SubtypeSSLVersion, Value: []byte(tlsVersionName(state.Version))},
+		{Type: SubtypeSSLCipher, Value: []byte(tls.CipherSuiteName(state.CipherSuite))},
+	}
+	if len(state.PeerCertificates) > 0 {
+		sub = append(sub, TLV{Type: SubtypeSSLCN, Value: []byte(state.PeerCertificates[0].Subject.CommonName)})
+	}
+	for _, tlv := range sub {
+		b.WriteByte(tlv.Type)
+		binary.Write(&b, binary.BigEndian, uint16(len(tlv.Value)))
+		b.Write(tlv.Value)
+	}
+
+	return TLV{Type: TypeSSL, Value: b.Bytes()}
+}
+
+// SSL is the decoded content of a TypeSSL TLV.
+type SSL struct {
+	Client   byte
+	Verified bool
+	Version  string
+	Cipher   string
+	CN       string
+}
+
+// ParseSSL decodes the value of a TypeSSL TLV.
+func ParseSSL(v []byte) (*SSL, error) {
+	if len(v) < 5 {
+		return nil, errTruncated
+	}
+	s := &SSL{
+		Client:   v[0],
+		Verified: binary.BigEndian.Uint32(v[1:5]) == 0,
+	}
+	sub, err := parseTLVs(v[5:])
+	if err != nil {
+		return nil, err
+	}
+	for _, tlv := range sub {
+		switch tlv.Type {
+		case SubtypeSSLVersion:
+			s.Version = string(tlv.Value)
+		case SubtypeSSLCipher:
+			s.Cipher = string(tlv.Value)
+		case SubtypeSSLCN:
+			s.CN = string(tlv.Value)
+		}
+	}
+	return s, nil
+}
+
+func tlsVersionName(v uint16) string {
+	switch v {
+	case tls.VersionTLS10:
+		return "TLSv1.0"
+	case tls.VersionTLS11:
+		return "TLSv1.1"
+	case tls.VersionTLS12:
+		return "TLSv1.2"
+	case tls.VersionTLS13:
+		return "TLSv1.3"
+	}
+	return fmt.Sprintf("0x%04x", v)
+}
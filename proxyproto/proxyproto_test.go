@@ -0,0 +1,120 @@
+package proxyproto
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/tls"
+	"net"
+	"reflect"
+	"testing"
+)
+
+func TestRoundTrip(t *testing.T) {
+	tlvs := []TLV{
+		UserIDTLV(42),
+		DeviceIDTLV(7),
+		{Type: TypeCorrelationID, Value: []byte("0123456789abcdef")},
+		{Type: TypeAuthority, Value: []byte("chat.example.com")},
+	}
+	tests := []struct {
+		name string
+		h    *Header
+	}{
+		{"ipv4", &Header{
+			Source:      &net.TCPAddr{IP: net.IPv4(192, 0, 2, 1).To4(), Port: 51000},
+			Destination: &net.TCPAddr{IP: net.IPv4(198, 51, 100, 2).To4(), Port: 25500},
+			TLVs:        tlvs,
+		}},
+		{"ipv6", &Header{
+			Source:      &net.TCPAddr{IP: net.ParseIP("2001:db8::1"), Port: 443},
+			Destination: &net.TCPAddr{IP: net.ParseIP("2001:db8::2"), Port: 25500},
+		}},
+		{"local", &Header{TLVs: tlvs[:1]}},
+	}
+	for _, tt := range tests {
+		b, err := tt.h.Marshal()
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		// whatever follows the header stays in the reader
+		r := bufio.NewReader(bytes.NewReader(append(b, "rest"...)))
+		got, err := Read(r)
+		if err != nil {
+			t.Fatalf("%s: Read: %v", tt.name, err)
+		}
+		if !reflect.DeepEqual(got, tt.h) {
+			t.Errorf("%s: got %+v, want %+v", tt.name, got, tt.h)
+		}
+		if rest, _ := r.ReadString(0); rest != "rest" {
+			t.Errorf("%s: %q left after the header, want %q", tt.name, rest, "rest")
+		}
+	}
+
+	h := &Header{TLVs: tlvs}
+	if id, ok := h.UserID(); !ok || id != 42 {
+		t.Errorf("UserID() = %d, %v", id, ok)
+	}
+	if id, ok := h.DeviceID(); !ok || id != 7 {
+		t.Errorf("DeviceID() = %d, %v", id, ok)
+	}
+	if got := h.CorrelationID(); got != "0123456789abcdef" {
+		t.Errorf("CorrelationID() = %q", got)
+	}
+	if _, ok := (&Header{}).UserID(); ok {
+		t.Error("UserID() of a header without it")
+	}
+}
+
+func TestReadRejects(t *testing.T) {
+	valid, err := (&Header{
+		Source:      &net.TCPAddr{IP: net.IPv4(192, 0, 2, 1).To4(), Port: 1},
+		Destination: &net.TCPAddr{IP: net.IPv4(192, 0, 2, 2).To4(), Port: 2},
+		TLVs:        []TLV{UserIDTLV(1)},
+	}).Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	with := func(i int, v byte) []byte {
+		b := append([]byte(nil), valid...)
+		b[i] = v
+		return b
+	}
+
+	tests := []struct {
+		name string
+		in   []byte
+		want error
+	}{
+		{"no header", []byte("\x00\x00\x00\x10 a dispatch frame"), ErrNoSignature},
+		{"version 1", []byte("PROXY TCP4 192.0.2.1 192.0.2.2 1 2\r\n"), ErrNoSignature},
+		{"truncated addresses", with(15, 4), errTruncated},
+		{"truncated tlv", valid[:len(valid)-1], nil},
+		{"unknown command", with(12, 0x22), nil},
+		{"unknown family", with(13, 0x31), nil},
+	}
+	for _, tt := range tests {
+		_, err := Read(bufio.NewReader(bytes.NewReader(tt.in)))
+		if err == nil || tt.want != nil && err != tt.want {
+			t.Errorf("%s: got %v, want %v", tt.name, err, tt.want)
+		}
+	}
+}
+
+func TestSSL(t *testing.T) {
+	state := tls.ConnectionState{Version: tls.VersionTLS13, CipherSuite: tls.TLS_AES_128_GCM_SHA256}
+	tlv := SSLTLV(state, false)
+	if tlv.Type != TypeSSL {
+		t.Fatalf("type 0x%02x, want 0x%02x", tlv.Type, TypeSSL)
+	}
+	got, err := ParseSSL(tlv.Value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &SSL{Client: ClientSSL, Version: "TLSv1.3", Cipher: "TLS_AES_128_GCM_SHA256"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+	if _, err := ParseSSL(tlv.Value[:3]); err != errTruncated {
+		t.Errorf("truncated value: got %v, want %v", err, errTruncated)
+	}
+}
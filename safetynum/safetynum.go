@@ -0,0 +1,139 @@
+// Package safetynum derives safety numbers that let two users confirm that
+// they see each other's real identity keys, and remembers which contacts
+// were verified that way.
+//
+// Every user gets a fingerprint over the identity keys of all of their
+// active devices. The safety number of a conversation is both fingerprints
+// as 60 digits, ordered so that both sides display the same number. It can
+// be compared by reading it out or by scanning the QR code of the other
+// device.
+package safetynum
+
+import (
+	"bytes"
+	"crypto/sha512"
+	"crypto/subtle"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sort"
+)
+
+const (
+	// Version is the version of the fingerprint format, it is part of the
+	// hash input and of the QR payload.
+	Version = 1
+
+	// iterations slows down searching for keys with a colliding fingerprint.
+	iterations = 5200
+
+	// FingerprintSize is the number of hash bytes a fingerprint keeps.
+	FingerprintSize = 30
+
+	// QRSize is the length of the payload encoded in a QR code.
+	QRSize = 1 + 2*FingerprintSize
+)
+
+var (
+	// ErrQRVersion is returned for QR payloads of another format version.
+	ErrQRVersion = errors.New("safetynum: unsupported QR version")
+
+	// ErrQRSize is returned for QR payloads of the wrong length.
+	ErrQRSize = errors.New("safetynum: malformed QR payload")
+
+	// ErrStaleFingerprint is returned when verifying a fingerprint of keys
+	// that changed in the meantime.
+	ErrStaleFingerprint = errors.New("safetynum: keys changed since the fingerprint was shown")
+)
+
+// Fingerprint hashes the identity keys of one user's devices. The order of
+// keys does not matter.
+func Fingerprint(userID uint64, keys [][]byte) []byte {
+	sorted := make([][]byte, len(keys))
+	copy(sorted, keys)
+	sort.Slice(sorted, func(i, j int) bool { return bytes.Compare(sorted[i], sorted[j]) < 0 })
+
+	var key bytes.Buffer
+	for _, k := range sorted {
+		binary.Write(&key, binary.BigEndian, uint16(len(k)))
+		key.Write(k)
+	}
+
+	var id [8]byte
+	binary.BigEndian.PutUint64(id[:], userID)
+
+	h := sha512.New()
+	binary.Write(h, binary.BigEndian, uint16(Version))
+	h.Write(key.Bytes())
+	h.Write(id[:])
+	sum := h.Sum(nil)
+	for i := 0; i < iterations; i++ {
+		h.Reset()
+		h.Write(sum)
+		h.Write(key.Bytes())
+		sum = h.Sum(sum[:0])
+	}
+	return sum[:FingerprintSize]
+}
+
+// Digits renders a fingerprint as 30 digits, five for every five bytes.
+func Digits(fingerprint []byte) string {
+	var b bytes.Buffer
+	for i := 0; i+5 <= len(fingerprint); i += 5 {
+		var chunk [8]byte
+		copy(chunk[3:], fingerprint[i:i+5])
+		fmt.Fprintf(&b, "%05d", binary.BigEndian.Uint64(chunk[:])%100000)
+	}
+	return b.String()
+}
+
+// Number returns the safety number shown on both sides of a conversation.
+func Number(local, remote []byte) string {
+	a, b := Digits(local), Digits(remote)
+	if a > b {
+		a, b = b, a
+	}
+	return a + b
+}
+
+// Format splits a safety number into groups of five digits for display.
+func Format(number string) string {
+	var b bytes.Buffer
+	for i := 0; i < len(number); i += 5 {
+		if i > 0 {
+			b.WriteByte(' ')
+		}
+		end := i + 5
+		if end > len(number) {
+			end = len(number)
+		}
+		b.WriteString(number[i:end])
+	}
+	return b.String()
+}
+
+// QR returns the payload of the QR code a device shows: the format version
+// followed by its own and the remote fingerprint.
+func QR(local, remote []byte) []byte {
+	b := make([]byte, 0, QRSize)
+	b = append(b, Version)
+	b = append(b, local[:FingerprintSize]...)
+	return append(b, remote[:FingerprintSize]...)
+}
+
+// VerifyQR checks a QR payload scanned from the other device, on which the
+// roles of local and remote are swapped. It reports whether both sides see
+// the same keys.
+func VerifyQR(payload, local, remote []byte) (bool, error) {
+	if len(payload) != QRSize {
+		return false, ErrQRSize
+	}
+	if payload[0] != Version {
+		return false, ErrQRVersion
+	}
+	theirLocal := payload[1 : 1+FingerprintSize]
+	theirRemote := payload[1+FingerprintSize:]
+	ok := subtle.ConstantTimeCompare(theirLocal, remote[:FingerprintSize]) == 1 &&
+		subtle.ConstantTimeCompare(theirRemote, local[:FingerprintSize]) == 1
+	return ok, nil
+}
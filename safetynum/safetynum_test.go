@@ -0,0 +1,141 @@
+package safetynum
+
+import (
+	"bytes"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+var (
+	keyA = bytes.Repeat([]byte{0xa}, 32)
+	keyB = bytes.Repeat([]byte{0xb}, 32)
+	keyC = bytes.Repeat([]byte{0xc}, 32)
+)
+
+func TestFingerprint(t *testing.T) {
+	base := Fingerprint(1, [][]byte{keyA, keyB})
+	if len(base) != FingerprintSize {
+		t.Fatalf("fingerprint of %d bytes, want %d", len(base), FingerprintSize)
+	}
+	tests := []struct {
+		name   string
+		userID uint64
+		keys   [][]byte
+		same   bool
+	}{
+		{"keys in another order", 1, [][]byte{keyB, keyA}, true},
+		{"another user", 2, [][]byte{keyA, keyB}, false},
+		{"device added", 1, [][]byte{keyA, keyB, keyC}, false},
+		{"device removed", 1, [][]byte{keyA}, false},
+		{"key changed", 1, [][]byte{keyA, keyC}, false},
+		// the length prefix keeps key boundaries from shifting
+		{"keys split differently", 1, [][]byte{append(append([]byte(nil), keyA...), keyB[0]), keyB[1:]}, false},
+	}
+	for _, tt := range tests {
+		got := Fingerprint(tt.userID, tt.keys)
+		if bytes.Equal(got, base) != tt.same {
+			t.Errorf("%s: same fingerprint %v, want %v", tt.name, !tt.same, tt.same)
+		}
+	}
+}
+
+func TestNumber(t *testing.T) {
+	alice := Fingerprint(1, [][]byte{keyA})
+	bob := Fingerprint(2, [][]byte{keyB})
+
+	n := Number(alice, bob)
+	if n != Number(bob, alice) {
+		t.Fatal("both sides see different safety numbers")
+	}
+	if len(n) != 60 || strings.Trim(n, "0123456789") != "" {
+		t.Fatalf("safety number %q is not 60 digits", n)
+	}
+	f := Format(n)
+	if groups := strings.Split(f, " "); len(groups) != 12 || strings.Join(groups, "") != n {
+		t.Fatalf("Format(%s) = %q", n, f)
+	}
+	if got := Format("1234567"); got != "12345 67" {
+		t.Errorf("Format of a partial group = %q", got)
+	}
+}
+
+func TestVerifyQR(t *testing.T) {
+	alice := Fingerprint(1, [][]byte{keyA})
+	bob := Fingerprint(2, [][]byte{keyB})
+	mallory := Fingerprint(2, [][]byte{keyC})
+
+	shown := QR(bob, alice) // on bob's screen
+	tests := []struct {
+		name          string
+		payload       []byte
+		local, remote []byte
+		want          bool
+		wantErr       error
+	}{
+		{"same keys", shown, alice, bob, true, nil},
+		{"alice sees another key for bob", shown, alice, mallory, false, nil},
+		{"scanned own code", QR(alice, bob), alice, bob, false, nil},
+		{"other version", append([]byte{Version + 1}, shown[1:]...), alice, bob, false, ErrQRVersion},
+		{"truncated", shown[:QRSize-1], alice, bob, false, ErrQRSize},
+	}
+	for _, tt := range tests {
+		ok, err := VerifyQR(tt.payload, tt.local, tt.remote)
+		if ok != tt.want || err != tt.wantErr {
+			t.Errorf("%s: got %v, %v, want %v, %v", tt.name, ok, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestTrustStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "trust.json")
+	s, err := OpenTrustStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	first := []DeviceKey{{DeviceID: 2, IdentityKey: keyB}, {DeviceID: 1, IdentityKey: keyA}}
+	if c, err := s.Observe(7, first); c != nil || err != nil {
+		t.Fatalf("first keys: %+v, %v", c, err)
+	}
+	if c, err := s.Observe(7, first[1:]); c == nil || !reflect.DeepEqual(c.Removed, []uint64{2}) || c.WasVerified {
+		t.Fatalf("removed device: %+v, %v", c, err)
+	}
+	if c, _ := s.Observe(7, first); c == nil || !reflect.DeepEqual(c.Added, []uint64{2}) {
+		t.Fatalf("added device: %+v", c)
+	}
+
+	fp := s.Fingerprint(7)
+	if err := s.SetState(7, fp, Verified); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.SetState(8, fp, Verified); err == nil {
+		t.Fatal("verified a contact never seen")
+	}
+
+	// the store survives a restart
+	s, err = OpenTrustStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := s.State(7); got != Verified {
+		t.Fatalf("state after reopening: %v", got)
+	}
+
+	changed := []DeviceKey{{DeviceID: 1, IdentityKey: keyC}, {DeviceID: 2, IdentityKey: keyB}}
+	c, err := s.Observe(7, changed)
+	if err != nil || c == nil || !reflect.DeepEqual(c.Changed, []uint64{1}) || !c.WasVerified {
+		t.Fatalf("changed key of a verified contact: %+v, %v", c, err)
+	}
+	if got := s.State(7); got != Unverified {
+		t.Fatalf("state after a key change: %v", got)
+	}
+	// a number compared before the change does not verify the new keys
+	if err := s.SetState(7, fp, Verified); err != ErrStaleFingerprint {
+		t.Fatalf("stale fingerprint: got %v, want %v", err, ErrStaleFingerprint)
+	}
+	if c, err := s.Observe(7, changed); c != nil || err != nil {
+		t.Fatalf("same keys again: %+v, %v", c, err)
+	}
+}
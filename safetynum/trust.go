@@ -0,0 +1,218 @@
+package safetynum
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// State is how far the local user trusts the identity keys of a contact.
+type State int
+
+const (
+	// Unverified keys were seen but never compared.
+	Unverified State = iota
+
+	// Verified keys were confirmed by comparing safety numbers.
+	Verified
+)
+
+func (s State) String() string {
+	if s == Verified {
+		return "verified"
+	}
+	return "unverified"
+}
+
+// DeviceKey is the identity key of one device, as listed by srvhttps.
+type DeviceKey struct {
+	DeviceID    uint64 `json:"device_id"`
+	IdentityKey []byte `json:"identity_key"`
+}
+
+// IdentityChange is reported when the identity keys of a contact differ from
+// the ones seen before, which is expected when they add or revoke a device
+// but may also mean someone is intercepting the conversation.
+type IdentityChange struct {
+	UserID  uint64
+	Added   []uint64 // devices not seen before
+	Removed []uint64 // devices no longer listed
+	Changed []uint64 // devices whose key is different
+
+	// WasVerified is set when the previous keys had been verified. The
+	// contact is unverified again and the user should be warned prominently.
+	WasVerified bool
+}
+
+type contact struct {
+	State   State       `json:"state"`
+	Devices []DeviceKey `json:"devices"`
+	Updated time.Time   `json:"updated"`
+}
+
+// TrustStore remembers the identity keys seen for every contact and whether
+// they were verified. It is kept on the client in a JSON file.
+type TrustStore struct {
+	path string
+
+	mu       sync.Mutex
+	contacts map[uint64]*contact
+}
+
+// OpenTrustStore loads the store at path, which is created on the first
+// change if it does not exist.
+func OpenTrustStore(path string) (*TrustStore, error) {
+	s := &TrustStore{path: path, contacts: make(map[uint64]*contact)}
+	b, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(b, &s.contacts); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Observe records the device keys of userID as currently listed by the
+// directory. The first keys of a contact are accepted silently, later
+// differences are returned as an IdentityChange and reset the contact to
+// Unverified.
+func (s *TrustStore) Observe(userID uint64, keys []DeviceKey) (*IdentityChange, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	keys = sortedKeys(keys)
+	c, ok := s.contacts[userID]
+	if !ok {
+		s.contacts[userID] = &contact{Devices: keys, Updated: time.Now().UTC()}
+		return nil, s.save()
+	}
+
+	change := diff(userID, c.Devices, keys)
+	if change == nil {
+		return nil, nil
+	}
+	change.WasVerified = c.State == Verified
+	c.State = Unverified
+	c.Devices = keys
+	c.Updated = time.Now().UTC()
+	return change, s.save()
+}
+
+// Keys returns the device keys last seen for userID.
+func (s *TrustStore) Keys(userID uint64) []DeviceKey {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	c, ok := s.contacts[userID]
+	if !ok {
+		return nil
+	}
+	return append([]DeviceKey(nil), c.Devices...)
+}
+
+// Fingerprint returns the fingerprint of the keys last seen for userID.
+func (s *TrustStore) Fingerprint(userID uint64) []byte {
+	var keys [][]byte
+	for _, d := range s.Keys(userID) {
+		keys = append(keys, d.IdentityKey)
+	}
+	return Fingerprint(userID, keys)
+}
+
+// State returns the trust state of userID.
+func (s *TrustStore) State(userID uint64) State {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if c, ok := s.contacts[userID]; ok {
+		return c.State
+	}
+	return Unverified
+}
+
+// SetState records the outcome of comparing safety numbers. It only applies
+// to the keys last observed, so verifying cannot race with a key change.
+func (s *TrustStore) SetState(userID uint64, fingerprint []byte, state State) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	c, ok := s.contacts[userID]
+	if !ok {
+		return os.ErrNotExist
+	}
+	var keys [][]byte
+	for _, d := range c.Devices {
+		keys = append(keys, d.IdentityKey)
+	}
+	if !bytes.Equal(Fingerprint(userID, keys), fingerprint) {
+		return ErrStaleFingerprint
+	}
+	c.State = state
+	c.Updated = time.Now().UTC()
+	return s.save()
+}
+
+// save writes the store to a temporary file and renames it over the old one.
+// The caller holds s.mu.
+func (s *TrustStore) save() error {
+	b, err := json.MarshalIndent(s.contacts, "", "\t")
+	if err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(s.path), filepath.Base(s.path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(b); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), s.path)
+}
+
+func sortedKeys(keys []DeviceKey) []DeviceKey {
+	keys = append([]DeviceKey(nil), keys...)
+	sort.Slice(keys, func(i, j int) bool { return keys[i].DeviceID < keys[j].DeviceID })
+	return keys
+}
+
+// diff compares two sorted key lists, it returns nil if they are the same.
+func diff(userID uint64, old, cur []DeviceKey) *IdentityChange {
+	c := &IdentityChange{UserID: userID}
+	before := make(map[uint64][]byte, len(old))
+	for _, d := range old {
+		before[d.DeviceID] = d.IdentityKey
+	}
+	for _, d := range cur {
+		k, ok := before[d.DeviceID]
+		switch {
+		case !ok:
+			c.Added = append(c.Added, d.DeviceID)
+		case !bytes.Equal(k, d.IdentityKey):
+			c.Changed = append(c.Changed, d.DeviceID)
+		}
+		delete(before, d.DeviceID)
+	}
+	for _, d := range old {
+		if _, ok := before[d.DeviceID]; ok {
+			c.Removed = append(c.Removed, d.DeviceID)
+		}
+	}
+	if len(c.Added)+len(c.Removed)+len(c.Changed) == 0 {
+		return nil
+	}
+	return c
+}
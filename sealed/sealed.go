@@ -0,0 +1,191 @@
+// Package sealed implements sealed sender envelopes.
+//
+// A sealed envelope hides the sender from srvtls: the sender's certificate,
+// issued by srvhttps, travels encrypted next to the payload, so only the
+// recipient device learns who sent it. Each envelope is encrypted to the
+// recipient device's P-256 sealing key with a fresh ephemeral key, and the
+// sender signs the payload together with both keys so that a recipient
+// cannot replay the content to somebody else under the sender's name.
+//
+// Since srvtls cannot tell who is sending, abuse is bounded by access keys
+// instead: a recipient derives one from its profile key, shares it with its
+// contacts inside their encrypted messages, and srvtls only delivers sealed
+// actions carrying it.
+package sealed
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"errors"
+	"math/big"
+
+	"github.com/Apurer/e2eechat/dispatch"
+	"github.com/Apurer/e2eechat/padding"
+	"github.com/golang/protobuf/proto"
+)
+
+const (
+	// PublicKeySize is the size of an uncompressed P-256 sealing key.
+	PublicKeySize = 65
+
+	// AccessKeySize is the size of the keys returned by DeriveAccessKey.
+	AccessKeySize = 16
+
+	signatureContext = "e2eechat sealed sender v1"
+)
+
+var (
+	// ErrBadKey is returned for sealing keys that are not P-256 points.
+	ErrBadKey = errors.New("sealed: invalid sealing key")
+
+	// ErrDecrypt is returned when an envelope was not encrypted to the
+	// recipient's key or was modified.
+	ErrDecrypt = errors.New("sealed: cannot decrypt envelope")
+
+	// ErrBadSignature is returned when the content was not signed by the
+	// device named in the certificate.
+	ErrBadSignature = errors.New("sealed: bad content signature")
+)
+
+// GenerateKey returns a new sealing key pair. The public key is what a
+// device registers with srvhttps, the private key never leaves the device.
+func GenerateKey() (pub, priv []byte, err error) {
+	priv, x, y, err := elliptic.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+	return elliptic.Marshal(elliptic.P256(), x, y), priv, nil
+}
+
+// ValidKey reports whether pub is an uncompressed P-256 point.
+func ValidKey(pub []byte) bool {
+	x, _ := elliptic.Unmarshal(elliptic.P256(), pub)
+	return x != nil
+}
+
+// Seal pads payload with scheme, padding.Default if nil, and encrypts it to
+// the sealing key of one recipient device. cert is the sender's certificate
+// and identityKey the private key it certifies.
+func Seal(recipientKey []byte, cert *dispatch.SenderCertificate, identityKey ed25519.PrivateKey, payload []byte, scheme padding.Scheme) (*dispatch.SealedEnvelope, error) {
+	rx, ry := elliptic.Unmarshal(elliptic.P256(), recipientKey)
+	if rx == nil {
+		return nil, ErrBadKey
+	}
+	ephemeralPub, ephemeralPriv, err := GenerateKey()
+	if err != nil {
+		return nil, err
+	}
+	if scheme == nil {
+		scheme = padding.Default
+	}
+	payload = padding.Pad(scheme, payload)
+
+	content, err := proto.Marshal(&dispatch.SealedContent{
+		Certificate: cert,
+		Payload:     payload,
+		Signature:   ed25519.Sign(identityKey, signingBytes(ephemeralPub, recipientKey, payload)),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sx, _ := elliptic.P256().ScalarMult(rx, ry, ephemeralPriv)
+	aead, nonce, err := envelopeCipher(sx, ephemeralPub, recipientKey)
+	if err != nil {
+		return nil, err
+	}
+	return &dispatch.SealedEnvelope{
+		EphemeralKey: ephemeralPub,
+		Ciphertext:   aead.Seal(nil, nonce, content, nil),
+	}, nil
+}
+
+// Open decrypts an envelope with the recipient device's sealing key pair and
+// returns the sender's certificate, checked against the server's sender
+// certificate key, and the payload.
+func Open(recipientPub, recipientPriv []byte, serverKey ed25519.PublicKey, env *dispatch.SealedEnvelope) (*dispatch.SenderCertificate, []byte, error) {
+	ex, ey := elliptic.Unmarshal(elliptic.P256(), env.GetEphemeralKey())
+	if ex == nil {
+		return nil, nil, ErrBadKey
+	}
+	sx, _ := elliptic.P256().ScalarMult(ex, ey, recipientPriv)
+	aead, nonce, err := envelopeCipher(sx, env.GetEphemeralKey(), recipientPub)
+	if err != nil {
+		return nil, nil, err
+	}
+	b, err := aead.Open(nil, nonce, env.GetCiphertext(), nil)
+	if err != nil {
+		return nil, nil, ErrDecrypt
+	}
+
+	var content dispatch.SealedContent
+	if err := proto.Unmarshal(b, &content); err != nil {
+		return nil, nil, ErrDecrypt
+	}
+	cert := content.GetCertificate()
+	if cert == nil {
+		return nil, nil, dispatch.ErrBadCertificate
+	}
+	if err := cert.Verify(serverKey); err != nil {
+		return nil, nil, err
+	}
+	if len(cert.GetIdentityKey()) != ed25519.PublicKeySize {
+		return nil, nil, dispatch.ErrBadCertificate
+	}
+	sb := signingBytes(env.GetEphemeralKey(), recipientPub, content.GetPayload())
+	if !ed25519.Verify(cert.GetIdentityKey(), sb, content.GetSignature()) {
+		return nil, nil, ErrBadSignature
+	}
+	payload, err := padding.Unpad(content.GetPayload())
+	if err != nil {
+		return nil, nil, err
+	}
+	return cert, payload, nil
+}
+
+// DeriveAccessKey derives the access key a user hands to its contacts from
+// its profile key. srvtls only stores a hash of it.
+func DeriveAccessKey(profileKey []byte) []byte {
+	return hmacSHA256(profileKey, []byte("e2eechat access key"))[:AccessKeySize]
+}
+
+// envelopeCipher derives the AES-256-GCM key and nonce of an envelope from
+// the ECDH shared secret. The ephemeral key is never reused, so neither is
+// the key.
+func envelopeCipher(shared *big.Int, ephemeralKey, recipientKey []byte) (cipher.AEAD, []byte, error) {
+	secret := make([]byte, 32)
+	shared.FillBytes(secret)
+	prk := hmacSHA256(secret, append(append([]byte(nil), ephemeralKey...), recipientKey...))
+	key := hmacSHA256(prk, []byte("e2eechat sealed sender aes"))
+	nonce := hmacSHA256(prk, []byte("e2eechat sealed sender nonce"))
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, nil, err
+	}
+	return aead, nonce[:aead.NonceSize()], nil
+}
+
+func signingBytes(ephemeralKey, recipientKey, payload []byte) []byte {
+	var b bytes.Buffer
+	b.WriteString(signatureContext)
+	b.Write(ephemeralKey)
+	b.Write(recipientKey)
+	b.Write(payload)
+	return b.Bytes()
+}
+
+func hmacSHA256(key, data []byte) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write(data)
+	return h.Sum(nil)
+}
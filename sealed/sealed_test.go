@@ -0,0 +1,176 @@
+package sealed
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"testing"
+	"time"
+
+	"github.com/Apurer/e2eechat/dispatch"
+	"github.com/Apurer/e2eechat/padding"
+	"github.com/golang/protobuf/proto"
+)
+
+type sender struct {
+	cert        *dispatch.SenderCertificate
+	identityKey ed25519.PrivateKey
+}
+
+// newSender returns a device with a certificate signed by serverKey that
+// expires at expires.
+func newSender(t *testing.T, serverKey ed25519.PrivateKey, expires time.Time) *sender {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert := &dispatch.SenderCertificate{UserId: 1, DeviceId: 2, IdentityKey: pub, Expires: expires.Unix()}
+	cert.Sign(serverKey)
+	return &sender{cert: cert, identityKey: priv}
+}
+
+func TestSealOpen(t *testing.T) {
+	serverPub, serverKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := newSender(t, serverKey, time.Now().Add(time.Hour))
+	pub, priv, err := GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, scheme := range []padding.Scheme{nil, padding.Padme, padding.Buckets{64, 4096}} {
+		for _, payload := range [][]byte{{}, []byte("hello"), bytes.Repeat([]byte{0}, 3000)} {
+			env, err := Seal(pub, s.cert, s.identityKey, payload, scheme)
+			if err != nil {
+				t.Fatal(err)
+			}
+			cert, got, err := Open(pub, priv, serverPub, env)
+			if err != nil {
+				t.Fatalf("%T, %d bytes: Open: %v", scheme, len(payload), err)
+			}
+			if !bytes.Equal(got, payload) || cert.UserId != 1 || cert.DeviceId != 2 {
+				t.Fatalf("%T, %d bytes: got %d bytes from user %d", scheme, len(payload), len(got), cert.UserId)
+			}
+		}
+	}
+
+	// envelopes of payloads in the same bucket are the same size
+	a, _ := Seal(pub, s.cert, s.identityKey, []byte("a"), padding.Buckets{64, 4096})
+	b, _ := Seal(pub, s.cert, s.identityKey, bytes.Repeat([]byte("b"), 60), padding.Buckets{64, 4096})
+	if len(a.Ciphertext) != len(b.Ciphertext) {
+		t.Errorf("envelopes of %d and %d bytes in one bucket", len(a.Ciphertext), len(b.Ciphertext))
+	}
+
+	if _, err := Seal([]byte("not a point"), s.cert, s.identityKey, nil, nil); err != ErrBadKey {
+		t.Errorf("sealing to a bad key: got %v, want %v", err, ErrBadKey)
+	}
+}
+
+func TestOpenRejects(t *testing.T) {
+	serverPub, serverKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, otherServer, _ := ed25519.GenerateKey(rand.Reader)
+	pub, priv, err := GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherPub, otherPriv, err := GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := newSender(t, serverKey, time.Now().Add(time.Hour))
+	expired := newSender(t, serverKey, time.Now().Add(-time.Minute))
+	forged := newSender(t, otherServer, time.Now().Add(time.Hour))
+	// a certificate of another device, with a key the sender does not hold
+	stolen := &sender{cert: newSender(t, serverKey, time.Now().Add(time.Hour)).cert, identityKey: s.identityKey}
+
+	seal := func(s *sender, to []byte) *dispatch.SealedEnvelope {
+		env, err := Seal(to, s.cert, s.identityKey, []byte("hello"), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return env
+	}
+	// another recipient passes on what s sent them, signature and all
+	forwarded := reseal(t, pub, openContent(t, otherPub, otherPriv, seal(s, otherPub)))
+
+	tests := []struct {
+		name string
+		env  *dispatch.SealedEnvelope
+		want error
+	}{
+		{"to another recipient", seal(s, otherPub), ErrDecrypt},
+		{"changed ciphertext", func() *dispatch.SealedEnvelope {
+			env := seal(s, pub)
+			env.Ciphertext[0] ^= 1
+			return env
+		}(), ErrDecrypt},
+		{"bad ephemeral key", &dispatch.SealedEnvelope{EphemeralKey: []byte{4}, Ciphertext: seal(s, pub).Ciphertext}, ErrBadKey},
+		{"certificate of another server", seal(forged, pub), dispatch.ErrBadCertificate},
+		{"expired certificate", seal(expired, pub), dispatch.ErrExpiredCertificate},
+		{"certificate of another device", seal(stolen, pub), ErrBadSignature},
+		{"content forwarded under a new envelope", forwarded, ErrBadSignature},
+	}
+	for _, tt := range tests {
+		if _, _, err := Open(pub, priv, serverPub, tt.env); err != tt.want {
+			t.Errorf("%s: got %v, want %v", tt.name, err, tt.want)
+		}
+	}
+}
+
+// openContent decrypts an envelope without checking what is inside.
+func openContent(t *testing.T, pub, priv []byte, env *dispatch.SealedEnvelope) *dispatch.SealedContent {
+	ex, ey := elliptic.Unmarshal(elliptic.P256(), env.EphemeralKey)
+	sx, _ := elliptic.P256().ScalarMult(ex, ey, priv)
+	aead, nonce, err := envelopeCipher(sx, env.EphemeralKey, pub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := aead.Open(nil, nonce, env.Ciphertext, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	content := new(dispatch.SealedContent)
+	if err := proto.Unmarshal(b, content); err != nil {
+		t.Fatal(err)
+	}
+	return content
+}
+
+// reseal encrypts content to pub under a fresh ephemeral key.
+func reseal(t *testing.T, pub []byte, content *dispatch.SealedContent) *dispatch.SealedEnvelope {
+	b, err := proto.Marshal(content)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ephemeralPub, ephemeralPriv, err := GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	rx, ry := elliptic.Unmarshal(elliptic.P256(), pub)
+	sx, _ := elliptic.P256().ScalarMult(rx, ry, ephemeralPriv)
+	aead, nonce, err := envelopeCipher(sx, ephemeralPub, pub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &dispatch.SealedEnvelope{EphemeralKey: ephemeralPub, Ciphertext: aead.Seal(nil, nonce, b, nil)}
+}
+
+func TestDeriveAccessKey(t *testing.T) {
+	a := DeriveAccessKey([]byte("profile key a"))
+	if len(a) != AccessKeySize {
+		t.Fatalf("access key of %d bytes, want %d", len(a), AccessKeySize)
+	}
+	if !bytes.Equal(a, DeriveAccessKey([]byte("profile key a"))) {
+		t.Error("access key is not deterministic")
+	}
+	if bytes.Equal(a, DeriveAccessKey([]byte("profile key b"))) {
+		t.Error("different profile keys give the same access key")
+	}
+}
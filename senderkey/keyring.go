@@ -0,0 +1,157 @@
+package senderkey
+
+import (
+	"sync"
+
+	"github.com/Apurer/e2eechat/dispatch"
+	"github.com/Apurer/e2eechat/padding"
+)
+
+// Sender identifies the device that owns a sender key.
+type Sender struct {
+	UserID   uint64
+	DeviceID uint64
+}
+
+type receiverKey struct {
+	channelID uint64
+	sender    Sender
+}
+
+// Keyring holds the sender keys of the local device and the receivers for
+// the other members of its channels.
+type Keyring struct {
+	// Padding pads posts before they are encrypted. It is padding.Default
+	// unless changed before first use.
+	Padding padding.Scheme
+
+	mu        sync.Mutex
+	own       map[uint64]*SenderKey
+	receivers map[receiverKey]*Receiver
+}
+
+// NewKeyring returns an empty keyring.
+func NewKeyring() *Keyring {
+	return &Keyring{
+		Padding:   padding.Default,
+		own:       make(map[uint64]*SenderKey),
+		receivers: make(map[receiverKey]*Receiver),
+	}
+}
+
+// SenderKey returns the local sender key for a channel. A new key is created
+// on first use, in which case fresh is set and the caller must send its
+// distribution to every member before the first post.
+func (k *Keyring) SenderKey(channelID uint64) (key *SenderKey, fresh bool, err error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	if key, ok := k.own[channelID]; ok {
+		return key, false, nil
+	}
+	key, err = New(channelID)
+	if err != nil {
+		return nil, false, err
+	}
+	k.own[channelID] = key
+	return key, true, nil
+}
+
+// Encrypt pads a post to a channel and encrypts it with the local sender
+// key.
+func (k *Keyring) Encrypt(channelID uint64, plaintext []byte) (*dispatch.SenderKeyMessage, error) {
+	key, _, err := k.SenderKey(channelID)
+	if err != nil {
+		return nil, err
+	}
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	return key.Encrypt(padding.Pad(k.Padding, plaintext))
+}
+
+// Accept stores a distribution received from another device over a pairwise
+// session, replacing its previous key for the channel.
+func (k *Keyring) Accept(from Sender, d *dispatch.SenderKeyDistribution) error {
+	r, err := NewReceiver(d)
+	if err != nil {
+		return err
+	}
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.receivers[receiverKey{channelID: d.ChannelId, sender: from}] = r
+	return nil
+}
+
+// Decrypt decrypts a channel post sent by another device and strips its
+// padding.
+func (k *Keyring) Decrypt(channelID uint64, from Sender, m *dispatch.SenderKeyMessage) ([]byte, error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	r, ok := k.receivers[receiverKey{channelID: channelID, sender: from}]
+	if !ok {
+		return nil, ErrUnknownKey
+	}
+	b, err := r.Decrypt(m)
+	if err != nil {
+		return nil, err
+	}
+	return padding.Unpad(b)
+}
+
+// Rekey handles a ChannelEvent. When the membership changed it drops the
+// keys of members who left, rotates the local sender key and returns its
+// distribution, which the caller sends to every remaining member. It returns
+// nil when nothing needs to be sent.
+func (k *Keyring) Rekey(channelID uint64, ev *dispatch.ChannelEvent) (*dispatch.SenderKeyDistribution, error) {
+	if !NeedsRekey(ev) {
+		return nil, nil
+	}
+
+	members := make(map[uint64]bool, len(ev.Members))
+	for _, m := range ev.Members {
+		members[m.UserId] = true
+	}
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	for rk := range k.receivers {
+		if rk.channelID == channelID && !members[rk.sender.UserID] {
+			delete(k.receivers, rk)
+		}
+	}
+
+	key, err := New(channelID)
+	if err != nil {
+		return nil, err
+	}
+	k.own[channelID] = key
+	return key.Distribution(), nil
+}
+
+// Forget drops every key of a channel, for when the local user left it.
+func (k *Keyring) Forget(channelID uint64) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	delete(k.own, channelID)
+	for rk := range k.receivers {
+		if rk.channelID == channelID {
+			delete(k.receivers, rk)
+		}
+	}
+}
+
+// NeedsRekey reports whether a ChannelEvent changed the membership, after
+// which every member has to rotate its sender key.
+func NeedsRekey(ev *dispatch.ChannelEvent) bool {
+	if ev.Error != "" {
+		return false
+	}
+	switch ev.GetOp().GetOp() {
+	case dispatch.ChannelOp_INVITE, dispatch.ChannelOp_LEAVE, dispatch.ChannelOp_KICK:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,258 @@
+// Package senderkey implements Sender Keys group encryption for channels.
+//
+// Every device sending to a channel has its own sender key: a chain key that
+// is ratcheted forward with HMAC-SHA256 after each message, and an ed25519
+// key signing every message. The key is handed to the other members once,
+// over their pairwise sessions, as a dispatch.SenderKeyDistribution. After
+// that each post is encrypted a single time and srvtls fans the same
+// ciphertext out to every member.
+//
+// Removed members still hold the old keys, so every member rotates its
+// sender key and distributes the new one whenever the membership changes.
+package senderkey
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+
+	"github.com/Apurer/e2eechat/dispatch"
+)
+
+const (
+	chainKeySize = 32
+
+	// MaxSkip bounds how far ahead of the last received message a message
+	// may be, and how many skipped message keys are kept for late messages.
+	MaxSkip = 2000
+
+	signatureContext = "e2eechat sender key message v1"
+)
+
+var (
+	// ErrUnknownKey is returned for messages under a sender key that was
+	// never distributed or was already replaced.
+	ErrUnknownKey = errors.New("senderkey: unknown sender key")
+
+	// ErrBadSignature is returned when a message was not signed by the
+	// holder of the sender key.
+	ErrBadSignature = errors.New("senderkey: bad signature")
+
+	// ErrOldMessage is returned for a message whose key was already used or
+	// discarded.
+	ErrOldMessage = errors.New("senderkey: message key already used")
+
+	// ErrTooFarAhead is returned when more than MaxSkip messages would have
+	// to be skipped.
+	ErrTooFarAhead = errors.New("senderkey: message too far ahead")
+
+	// ErrInvalidDistribution is returned for malformed distribution messages.
+	ErrInvalidDistribution = errors.New("senderkey: invalid distribution")
+)
+
+var (
+	messageKeySeed = []byte{0x01}
+	chainKeySeed   = []byte{0x02}
+)
+
+// chain is the symmetric ratchet shared by the sender and the receivers.
+type chain struct {
+	key       []byte
+	iteration uint32
+}
+
+// next returns the message key for the current iteration and advances the
+// chain. The old chain key is overwritten so that compromising the current
+// state does not reveal earlier messages.
+func (c *chain) next() []byte {
+	mk := hmacSHA256(c.key, messageKeySeed)
+	next := hmacSHA256(c.key, chainKeySeed)
+	for i := range c.key {
+		c.key[i] = 0
+	}
+	c.key = next
+	c.iteration++
+	return mk
+}
+
+// SenderKey is the sender key of the local device for one channel.
+type SenderKey struct {
+	channelID  uint64
+	keyID      uint32
+	chain      chain
+	signingKey ed25519.PrivateKey
+}
+
+// New creates a fresh sender key for a channel.
+func New(channelID uint64) (*SenderKey, error) {
+	k := &SenderKey{channelID: channelID, chain: chain{key: make([]byte, chainKeySize)}}
+	if _, err := rand.Read(k.chain.key); err != nil {
+		return nil, err
+	}
+	var id [4]byte
+	if _, err := rand.Read(id[:]); err != nil {
+		return nil, err
+	}
+	k.keyID = binary.BigEndian.Uint32(id[:])
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	k.signingKey = priv
+	return k, nil
+}
+
+// KeyID identifies the key, it changes with every rotation.
+func (k *SenderKey) KeyID() uint32 {
+	return k.keyID
+}
+
+// Distribution returns the message that lets other members decrypt the
+// messages encrypted from now on.
+func (k *SenderKey) Distribution() *dispatch.SenderKeyDistribution {
+	return &dispatch.SenderKeyDistribution{
+		ChannelId:  k.channelID,
+		KeyId:      k.keyID,
+		Iteration:  k.chain.iteration,
+		ChainKey:   append([]byte(nil), k.chain.key...),
+		SigningKey: append([]byte(nil), k.signingKey.Public().(ed25519.PublicKey)...),
+	}
+}
+
+// Encrypt encrypts and signs a channel post.
+func (k *SenderKey) Encrypt(plaintext []byte) (*dispatch.SenderKeyMessage, error) {
+	iteration := k.chain.iteration
+	aead, nonce, err := messageCipher(k.chain.next())
+	if err != nil {
+		return nil, err
+	}
+
+	m := &dispatch.SenderKeyMessage{KeyId: k.keyID, Iteration: iteration}
+	m.Ciphertext = aead.Seal(nil, nonce, plaintext, additionalData(k.channelID, m))
+	m.Signature = ed25519.Sign(k.signingKey, signingBytes(k.channelID, m))
+	return m, nil
+}
+
+// Receiver decrypts the messages of one sender key of another device.
+type Receiver struct {
+	channelID  uint64
+	keyID      uint32
+	chain      chain
+	signingKey ed25519.PublicKey
+
+	// skipped holds the keys of messages that have not arrived yet
+	skipped map[uint32][]byte
+}
+
+// NewReceiver accepts a distribution message received over a pairwise
+// session.
+func NewReceiver(d *dispatch.SenderKeyDistribution) (*Receiver, error) {
+	if len(d.ChainKey) != chainKeySize || len(d.SigningKey) != ed25519.PublicKeySize {
+		return nil, ErrInvalidDistribution
+	}
+	return &Receiver{
+		channelID:  d.ChannelId,
+		keyID:      d.KeyId,
+		chain:      chain{key: append([]byte(nil), d.ChainKey...), iteration: d.Iteration},
+		signingKey: append(ed25519.PublicKey(nil), d.SigningKey...),
+		skipped:    make(map[uint32][]byte),
+	}, nil
+}
+
+// Decrypt verifies and decrypts a channel post. Messages may arrive out of
+// order, but every message can only be decrypted once.
+func (r *Receiver) Decrypt(m *dispatch.SenderKeyMessage) ([]byte, error) {
+	if m.KeyId != r.keyID {
+		return nil, ErrUnknownKey
+	}
+	// the signature is checked before touching the chain, so forged
+	// messages cannot make the receiver skip ahead
+	if !ed25519.Verify(r.signingKey, signingBytes(r.channelID, m), m.Signature) {
+		return nil, ErrBadSignature
+	}
+
+	mk, err := r.messageKey(m.Iteration)
+	if err != nil {
+		return nil, err
+	}
+	aead, nonce, err := messageCipher(mk)
+	if err != nil {
+		return nil, err
+	}
+	return aead.Open(nil, nonce, m.Ciphertext, additionalData(r.channelID, m))
+}
+
+// messageKey returns the key of a message, ratcheting the chain forward and
+// keeping the keys of skipped messages as needed.
+func (r *Receiver) messageKey(iteration uint32) ([]byte, error) {
+	if iteration < r.chain.iteration {
+		mk, ok := r.skipped[iteration]
+		if !ok {
+			return nil, ErrOldMessage
+		}
+		delete(r.skipped, iteration)
+		return mk, nil
+	}
+	if iteration-r.chain.iteration > MaxSkip {
+		return nil, ErrTooFarAhead
+	}
+	for r.chain.iteration < iteration {
+		it := r.chain.iteration
+		r.skipped[it] = r.chain.next()
+	}
+	// forget the oldest skipped keys, those messages are not coming anymore
+	for len(r.skipped) > MaxSkip {
+		oldest := r.chain.iteration
+		for it := range r.skipped {
+			if it < oldest {
+				oldest = it
+			}
+		}
+		delete(r.skipped, oldest)
+	}
+	return r.chain.next(), nil
+}
+
+// messageCipher derives the AES-256-GCM key and nonce of one message. Every
+// message key is used exactly once.
+func messageCipher(mk []byte) (cipher.AEAD, []byte, error) {
+	key := hmacSHA256(mk, []byte("e2eechat sender key aes"))
+	nonce := hmacSHA256(mk, []byte("e2eechat sender key nonce"))
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, nil, err
+	}
+	return aead, nonce[:aead.NonceSize()], nil
+}
+
+func additionalData(channelID uint64, m *dispatch.SenderKeyMessage) []byte {
+	var b [16]byte
+	binary.BigEndian.PutUint64(b[0:], channelID)
+	binary.BigEndian.PutUint32(b[8:], m.KeyId)
+	binary.BigEndian.PutUint32(b[12:], m.Iteration)
+	return b[:]
+}
+
+func signingBytes(channelID uint64, m *dispatch.SenderKeyMessage) []byte {
+	var b bytes.Buffer
+	b.WriteString(signatureContext)
+	b.Write(additionalData(channelID, m))
+	b.Write(m.Ciphertext)
+	return b.Bytes()
+}
+
+func hmacSHA256(key, data []byte) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write(data)
+	return h.Sum(nil)
+}
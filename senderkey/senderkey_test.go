@@ -0,0 +1,205 @@
+package senderkey
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"github.com/Apurer/e2eechat/dispatch"
+)
+
+const testChannel = 7
+
+func newPair(t *testing.T) (*SenderKey, *Receiver) {
+	k, err := New(testChannel)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := NewReceiver(k.Distribution())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return k, r
+}
+
+func encrypt(t *testing.T, k *SenderKey, n int) []*dispatch.SenderKeyMessage {
+	var msgs []*dispatch.SenderKeyMessage
+	for i := 0; i < n; i++ {
+		m, err := k.Encrypt([]byte(fmt.Sprintf("post %d", i)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		msgs = append(msgs, m)
+	}
+	return msgs
+}
+
+func TestDecryptOrder(t *testing.T) {
+	tests := []struct {
+		name  string
+		order []int
+		want  []error
+	}{
+		{"in order", []int{0, 1, 2}, []error{nil, nil, nil}},
+		{"late", []int{2, 0, 1}, []error{nil, nil, nil}},
+		{"replayed", []int{0, 1, 1}, []error{nil, nil, ErrOldMessage}},
+		{"replayed skipped", []int{2, 0, 0}, []error{nil, nil, ErrOldMessage}},
+	}
+	for _, tt := range tests {
+		k, r := newPair(t)
+		msgs := encrypt(t, k, 3)
+		for i, n := range tt.order {
+			got, err := r.Decrypt(msgs[n])
+			if err != tt.want[i] {
+				t.Fatalf("%s: message %d: got %v, want %v", tt.name, n, err, tt.want[i])
+			}
+			if err == nil && string(got) != fmt.Sprintf("post %d", n) {
+				t.Fatalf("%s: message %d decrypted to %q", tt.name, n, got)
+			}
+		}
+	}
+}
+
+func TestDistributionMidChain(t *testing.T) {
+	k, _ := newPair(t)
+	before := encrypt(t, k, 2)
+	r, err := NewReceiver(k.Distribution())
+	if err != nil {
+		t.Fatal(err)
+	}
+	// a member added later cannot read what was sent before
+	if _, err := r.Decrypt(before[1]); err != ErrOldMessage {
+		t.Fatalf("earlier message: got %v, want %v", err, ErrOldMessage)
+	}
+	after := encrypt(t, k, 1)
+	if _, err := r.Decrypt(after[0]); err != nil {
+		t.Fatalf("later message: %v", err)
+	}
+}
+
+func TestDecryptRejects(t *testing.T) {
+	k, _ := newPair(t)
+	other, _ := newPair(t)
+	forged := encrypt(t, other, 1)[0]
+	forged.KeyId = k.KeyID()
+
+	tests := []struct {
+		name    string
+		message func(m *dispatch.SenderKeyMessage) *dispatch.SenderKeyMessage
+		want    error
+	}{
+		{"other key id", func(m *dispatch.SenderKeyMessage) *dispatch.SenderKeyMessage { m.KeyId++; return m }, ErrUnknownKey},
+		{"changed ciphertext", func(m *dispatch.SenderKeyMessage) *dispatch.SenderKeyMessage { m.Ciphertext[0] ^= 1; return m }, ErrBadSignature},
+		{"changed iteration", func(m *dispatch.SenderKeyMessage) *dispatch.SenderKeyMessage { m.Iteration++; return m }, ErrBadSignature},
+		{"other signer", func(*dispatch.SenderKeyMessage) *dispatch.SenderKeyMessage { return forged }, ErrBadSignature},
+		{"too far ahead", func(*dispatch.SenderKeyMessage) *dispatch.SenderKeyMessage {
+			for i := 0; i <= MaxSkip; i++ {
+				k.chain.next()
+			}
+			return encrypt(t, k, 1)[0]
+		}, ErrTooFarAhead},
+	}
+	for _, tt := range tests {
+		r, err := NewReceiver(k.Distribution())
+		if err != nil {
+			t.Fatal(err)
+		}
+		m := tt.message(encrypt(t, k, 1)[0])
+		if _, err := r.Decrypt(m); err != tt.want {
+			t.Errorf("%s: got %v, want %v", tt.name, err, tt.want)
+		}
+	}
+
+	if _, err := NewReceiver(&dispatch.SenderKeyDistribution{ChainKey: make([]byte, 16)}); err != ErrInvalidDistribution {
+		t.Errorf("short chain key: got %v, want %v", err, ErrInvalidDistribution)
+	}
+}
+
+func TestKeyringRekey(t *testing.T) {
+	alice, bob, carol := Sender{1, 1}, Sender{2, 1}, Sender{3, 1}
+	ka, kb, kc := NewKeyring(), NewKeyring(), NewKeyring()
+
+	// everyone distributes a key to the others
+	rings := map[Sender]*Keyring{alice: ka, bob: kb, carol: kc}
+	for from, k := range rings {
+		key, fresh, err := k.SenderKey(testChannel)
+		if err != nil || !fresh {
+			t.Fatalf("SenderKey: %v, fresh %v", err, fresh)
+		}
+		for to, other := range rings {
+			if to != from {
+				if err := other.Accept(from, key.Distribution()); err != nil {
+					t.Fatal(err)
+				}
+			}
+		}
+	}
+
+	post := []byte("hello channel")
+	m, err := ka.Encrypt(testChannel, post)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(m.Ciphertext) <= len(post)+16 {
+		t.Errorf("post of %d bytes is not padded: %d bytes of ciphertext", len(post), len(m.Ciphertext))
+	}
+	for _, k := range []*Keyring{kb, kc} {
+		got, err := k.Decrypt(testChannel, alice, m)
+		if err != nil || !bytes.Equal(got, post) {
+			t.Fatalf("Decrypt: %q, %v", got, err)
+		}
+	}
+
+	// carol is kicked, bob drops her key and rotates his own
+	ev := &dispatch.ChannelEvent{
+		Op:      &dispatch.ChannelOp{Op: dispatch.ChannelOp_KICK, ChannelId: testChannel, UserId: carol.UserID},
+		Members: []*dispatch.ChannelMember{{UserId: alice.UserID}, {UserId: bob.UserID}},
+	}
+	old, _, _ := kb.SenderKey(testChannel)
+	d, err := kb.Rekey(testChannel, ev)
+	if err != nil || d == nil {
+		t.Fatalf("Rekey: %v, %v", d, err)
+	}
+	if d.KeyId == old.KeyID() {
+		t.Fatal("sender key was not rotated")
+	}
+	if _, err := kb.Decrypt(testChannel, carol, m); err != ErrUnknownKey {
+		t.Fatalf("post of a removed member: got %v, want %v", err, ErrUnknownKey)
+	}
+
+	// carol still has bob's old key, which his new posts are not under
+	m, err = kb.Encrypt(testChannel, post)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := kc.Decrypt(testChannel, bob, m); err != ErrUnknownKey {
+		t.Fatalf("removed member reading a new post: got %v, want %v", err, ErrUnknownKey)
+	}
+	if err := ka.Accept(bob, d); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := ka.Decrypt(testChannel, bob, m); err != nil || !bytes.Equal(got, post) {
+		t.Fatalf("post under the new key: %q, %v", got, err)
+	}
+
+	tests := []struct {
+		ev   *dispatch.ChannelEvent
+		want bool
+	}{
+		{&dispatch.ChannelEvent{Op: &dispatch.ChannelOp{Op: dispatch.ChannelOp_INVITE}}, true},
+		{&dispatch.ChannelEvent{Op: &dispatch.ChannelOp{Op: dispatch.ChannelOp_LEAVE}}, true},
+		{&dispatch.ChannelEvent{Op: &dispatch.ChannelOp{Op: dispatch.ChannelOp_KICK}, Error: "not allowed"}, false},
+		{&dispatch.ChannelEvent{Op: &dispatch.ChannelOp{Op: dispatch.ChannelOp_SET_ROLE}}, false},
+		{&dispatch.ChannelEvent{Op: &dispatch.ChannelOp{Op: dispatch.ChannelOp_LIST}}, false},
+	}
+	for _, tt := range tests {
+		if got := NeedsRekey(tt.ev); got != tt.want {
+			t.Errorf("NeedsRekey(%v) = %v, want %v", tt.ev.Op.Op, got, tt.want)
+		}
+	}
+
+	ka.Forget(testChannel)
+	if _, err := ka.Decrypt(testChannel, bob, m); err != ErrUnknownKey {
+		t.Fatalf("after Forget: got %v, want %v", err, ErrUnknownKey)
+	}
+}
@@ -0,0 +1,591 @@
+package main
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Apurer/e2eechat/logging"
+)
+
+// Attachments are uploaded in chunks so that an interrupted upload resumes
+// where it stopped: the client asks for the current offset with HEAD and
+// continues with PATCH from there, as in the tus protocol.
+const (
+	uploadOffsetHeader = "Upload-Offset"
+	uploadLengthHeader = "Upload-Length"
+	uploadTokenHeader  = "X-Upload-Token"
+
+	maxAttachmentSize = 100 << 20
+	maxChunkSize      = 1 << 20
+
+	// defaultAttachmentTTL is how long blobs are kept unless ATTACHMENT_TTL
+	// says otherwise. Recipients who were offline longer lose the file.
+	defaultAttachmentTTL = 30 * 24 * time.Hour
+
+	// maxUserAttachmentBytes bounds the attachments stored for one user,
+	// counting uploads in progress at their full size.
+	maxUserAttachmentBytes = 1 << 30
+
+	// maxPendingUploads bounds the uploads one user has in progress.
+	maxPendingUploads = 8
+
+	// abandonedUploadAge is how long an upload may go without a chunk
+	// before it is deleted.
+	abandonedUploadAge = 24 * time.Hour
+
+	blobSweepInterval = time.Hour
+)
+
+var (
+	errUploadBusy   = errors.New("another chunk of this upload is being written")
+	errBadOffset    = errors.New("offset does not match the uploaded size")
+	errBadToken     = errors.New("invalid upload token")
+	errDigest       = errors.New("uploaded data does not match the digest")
+	errBlobNotFound = errors.New("attachment not found")
+
+	errTooManyUploads = errors.New("too many uploads in progress")
+	errQuotaExceeded  = errors.New("attachment quota exceeded")
+)
+
+var blobs *blobStore
+
+type createUploadRequest struct {
+	UserID uint64 `json:"user_id"`
+	Size   int64  `json:"size"`   // size of the ciphertext
+	Digest []byte `json:"digest"` // base64 SHA-256 of the ciphertext
+}
+
+type uploadJSON struct {
+	ID          string    `json:"id"`
+	UploadToken string    `json:"upload_token"`
+	Expires     time.Time `json:"expires"`
+}
+
+// blobMeta is stored next to each blob as <id>.json.
+type blobMeta struct {
+	ID        string    `json:"id"`
+	UserID    uint64    `json:"user_id"`
+	Size      int64     `json:"size"`
+	Digest    []byte    `json:"digest"`
+	TokenHash []byte    `json:"token_hash"`
+	Created   time.Time `json:"created"`
+	Expires   time.Time `json:"expires"`
+}
+
+// blobStore keeps encrypted attachments in a directory. While an upload is
+// in progress the data is in <id>.part, which is renamed to <id> once all of
+// it arrived and matches the digest.
+type blobStore struct {
+	dir string
+	ttl time.Duration
+
+	quota      int64
+	maxPending int
+
+	mu      sync.Mutex
+	busy    map[string]bool
+	uploads map[string]bool // every stored blob, true while uploading
+	usage   map[uint64]*uploadUsage
+}
+
+// uploadUsage is what a user has stored.
+type uploadUsage struct {
+	bytes   int64
+	pending int
+}
+
+func openBlobStore(dir string, ttl time.Duration) (*blobStore, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	s := &blobStore{
+		dir:        dir,
+		ttl:        ttl,
+		quota:      maxUserAttachmentBytes,
+		maxPending: maxPendingUploads,
+		busy:       make(map[string]bool),
+		uploads:    make(map[string]bool),
+		usage:      make(map[uint64]*uploadUsage),
+	}
+
+	// count what is stored against the quotas, expired blobs included until
+	// the next sweep removes them
+	metas, err := s.list()
+	if err != nil {
+		return nil, err
+	}
+	for _, m := range metas {
+		_, err := os.Stat(s.path(m.ID, ".part"))
+		s.reserve(m, err == nil)
+	}
+	return s, nil
+}
+
+// attachmentTTLFromEnv reads ATTACHMENT_TTL, a duration such as "720h".
+func attachmentTTLFromEnv() (time.Duration, error) {
+	s := os.Getenv("ATTACHMENT_TTL")
+	if s == "" {
+		return defaultAttachmentTTL, nil
+	}
+	ttl, err := time.ParseDuration(s)
+	if err != nil || ttl <= 0 {
+		return 0, fmt.Errorf("invalid ATTACHMENT_TTL %q", s)
+	}
+	return ttl, nil
+}
+
+func (s *blobStore) path(id, ext string) string {
+	return filepath.Join(s.dir, id+ext)
+}
+
+// validBlobID keeps ids from naming files outside the store.
+func validBlobID(id string) bool {
+	if len(id) != 32 {
+		return false
+	}
+	_, err := hex.DecodeString(id)
+	return err == nil && strings.ToLower(id) == id
+}
+
+// list returns the metadata of every blob, skipping unreadable files.
+func (s *blobStore) list() ([]*blobMeta, error) {
+	names, err := filepath.Glob(filepath.Join(s.dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	var metas []*blobMeta
+	for _, name := range names {
+		b, err := ioutil.ReadFile(name)
+		if err != nil {
+			continue
+		}
+		m := new(blobMeta)
+		if err := json.Unmarshal(b, m); err != nil || !validBlobID(m.ID) {
+			logger.Warn("unreadable attachment metadata", "file", name, "error", err)
+			continue
+		}
+		metas = append(metas, m)
+	}
+	return metas, nil
+}
+
+// reserve counts a blob against the quotas of its user. The caller holds
+// s.mu or has the store to itself.
+func (s *blobStore) reserve(m *blobMeta, pending bool) {
+	u := s.usage[m.UserID]
+	if u == nil {
+		u = new(uploadUsage)
+		s.usage[m.UserID] = u
+	}
+	u.bytes += m.Size
+	if pending {
+		u.pending++
+	}
+	s.uploads[m.ID] = pending
+}
+
+// release undoes reserve for a blob that is gone. The caller holds s.mu.
+func (s *blobStore) release(m *blobMeta) {
+	pending, ok := s.uploads[m.ID]
+	if !ok {
+		return
+	}
+	delete(s.uploads, m.ID)
+	u := s.usage[m.UserID]
+	u.bytes -= m.Size
+	if pending {
+		u.pending--
+	}
+	if u.bytes == 0 && u.pending == 0 {
+		delete(s.usage, m.UserID)
+	}
+}
+
+// create starts an upload and returns its metadata and upload token. It
+// fails with errTooManyUploads or errQuotaExceeded when the user is over a
+// quota.
+func (s *blobStore) create(userID uint64, size int64, digest []byte) (*blobMeta, string, error) {
+	id := make([]byte, 16)
+	token := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return nil, "", err
+	}
+	if _, err := rand.Read(token); err != nil {
+		return nil, "", err
+	}
+	tokenHash := sha256.Sum256(token)
+	now := time.Now().UTC()
+	m := &blobMeta{
+		ID:        hex.EncodeToString(id),
+		UserID:    userID,
+		Size:      size,
+		Digest:    digest,
+		TokenHash: tokenHash[:],
+		Created:   now,
+		Expires:   now.Add(s.ttl),
+	}
+
+	var used uploadUsage
+	var err error
+	s.mu.Lock()
+	if u := s.usage[userID]; u != nil {
+		used = *u
+	}
+	switch {
+	case used.pending >= s.maxPending:
+		err = errTooManyUploads
+	case used.bytes+size > s.quota:
+		err = errQuotaExceeded
+	default:
+		s.reserve(m, true)
+	}
+	s.mu.Unlock()
+	if err != nil {
+		return nil, "", err
+	}
+
+	b, err := json.Marshal(m)
+	if err != nil {
+		s.remove(m)
+		return nil, "", err
+	}
+	if err := ioutil.WriteFile(s.path(m.ID, ".json"), b, 0600); err != nil {
+		s.remove(m)
+		return nil, "", err
+	}
+	if err := ioutil.WriteFile(s.path(m.ID, ".part"), nil, 0600); err != nil {
+		s.remove(m)
+		return nil, "", err
+	}
+	return m, base64.RawURLEncoding.EncodeToString(token), nil
+}
+
+func (s *blobStore) meta(id string) (*blobMeta, error) {
+	if !validBlobID(id) {
+		return nil, errBlobNotFound
+	}
+	b, err := ioutil.ReadFile(s.path(id, ".json"))
+	if os.IsNotExist(err) {
+		return nil, errBlobNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	var m blobMeta
+	if err := json.Unmarshal(b, &m); err != nil {
+		return nil, err
+	}
+	if time.Now().After(m.Expires) {
+		return nil, errBlobNotFound
+	}
+	return &m, nil
+}
+
+// offset returns how much of a blob was uploaded.
+func (s *blobStore) offset(m *blobMeta) (int64, error) {
+	if fi, err := os.Stat(s.path(m.ID, ".part")); err == nil {
+		return fi.Size(), nil
+	}
+	fi, err := os.Stat(s.path(m.ID, ""))
+	if err != nil {
+		return 0, err
+	}
+	return fi.Size(), nil
+}
+
+// appendChunk writes a chunk at offset and returns the new offset. When the
+// blob is complete its digest is checked, and a blob that does not match is
+// deleted.
+func (s *blobStore) appendChunk(m *blobMeta, offset int64, r io.Reader) (int64, error) {
+	s.mu.Lock()
+	if s.busy[m.ID] {
+		s.mu.Unlock()
+		return 0, errUploadBusy
+	}
+	s.busy[m.ID] = true
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		delete(s.busy, m.ID)
+		s.mu.Unlock()
+	}()
+
+	f, err := os.OpenFile(s.path(m.ID, ".part"), os.O_WRONLY|os.O_APPEND, 0600)
+	if os.IsNotExist(err) {
+		// already complete
+		return 0, errBadOffset
+	}
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	fi, err := f.Stat()
+	if err != nil {
+		return 0, err
+	}
+	if fi.Size() != offset {
+		return fi.Size(), errBadOffset
+	}
+	n, err := io.Copy(f, io.LimitReader(r, m.Size-offset))
+	if err == nil {
+		err = f.Sync()
+	}
+	if err != nil {
+		// keep what was written, the client resumes from the new offset
+		f.Truncate(offset + n)
+		return offset + n, err
+	}
+	offset += n
+	if offset < m.Size {
+		return offset, nil
+	}
+
+	if err := s.checkDigest(m); err != nil {
+		s.remove(m)
+		return 0, err
+	}
+	if err := os.Rename(s.path(m.ID, ".part"), s.path(m.ID, "")); err != nil {
+		return offset, err
+	}
+	s.mu.Lock()
+	if s.uploads[m.ID] {
+		s.uploads[m.ID] = false
+		s.usage[m.UserID].pending--
+	}
+	s.mu.Unlock()
+	return offset, nil
+}
+
+func (s *blobStore) checkDigest(m *blobMeta) error {
+	f, err := os.Open(s.path(m.ID, ".part"))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return err
+	}
+	if !bytes.Equal(h.Sum(nil), m.Digest) {
+		return errDigest
+	}
+	return nil
+}
+
+func (s *blobStore) remove(m *blobMeta) {
+	for _, ext := range []string{"", ".part", ".json"} {
+		os.Remove(s.path(m.ID, ext))
+	}
+	s.mu.Lock()
+	s.release(m)
+	s.mu.Unlock()
+}
+
+// sweep periodically deletes expired blobs and abandoned uploads.
+func (s *blobStore) sweep(interval time.Duration) {
+	for range time.Tick(interval) {
+		expired, abandoned, err := s.sweepOnce(time.Now())
+		if err != nil {
+			logger.Error("listing attachments", "error", err)
+			continue
+		}
+		if expired > 0 || abandoned > 0 {
+			logger.Info("attachments removed", "expired", expired, "abandoned", abandoned)
+		}
+	}
+}
+
+// sweepOnce deletes the blobs expired at now and the uploads that received
+// no chunk for abandonedUploadAge, which would otherwise hold on to the
+// quota of their user until they expire.
+func (s *blobStore) sweepOnce(now time.Time) (expired, abandoned int, err error) {
+	metas, err := s.list()
+	if err != nil {
+		return 0, 0, err
+	}
+	for _, m := range metas {
+		if now.After(m.Expires) {
+			s.remove(m)
+			expired++
+			continue
+		}
+		s.mu.Lock()
+		busy := s.busy[m.ID]
+		s.mu.Unlock()
+		if fi, err := os.Stat(s.path(m.ID, ".part")); err == nil && !busy && now.Sub(fi.ModTime()) > abandonedUploadAge {
+			s.remove(m)
+			abandoned++
+		}
+	}
+	return expired, abandoned, nil
+}
+
+// handleAttachments serves
+//
+//	POST  /attachments       start an upload, signed by a device
+//	HEAD  /attachments/{id}  Upload-Offset of an upload
+//	PATCH /attachments/{id}  append a chunk at Upload-Offset, with X-Upload-Token
+//	GET   /attachments/{id}  download a complete blob
+func handleAttachments(w http.ResponseWriter, r *http.Request) {
+	id := logging.NewCorrelationID()
+	w.Header().Set(correlationHeader, id)
+	l := logger.With(logging.CorrelationKey, id, "remote", r.RemoteAddr)
+
+	switch {
+	case r.URL.Path == "/attachments" && r.Method == http.MethodPost:
+		createUpload(w, r, l)
+	case strings.HasPrefix(r.URL.Path, "/attachments/"):
+		m, err := blobs.meta(strings.TrimPrefix(r.URL.Path, "/attachments/"))
+		if err == errBlobNotFound {
+			writeError(w, http.StatusNotFound, err)
+			return
+		}
+		if err != nil {
+			l.Error("reading attachment metadata", "error", err)
+			writeError(w, http.StatusInternalServerError, errors.New("internal error"))
+			return
+		}
+		switch r.Method {
+		case http.MethodHead:
+			uploadOffset(w, m)
+		case http.MethodPatch:
+			uploadChunk(w, r, m, l)
+		case http.MethodGet:
+			downloadBlob(w, r, m)
+		default:
+			writeError(w, http.StatusMethodNotAllowed, errors.New(http.StatusText(http.StatusMethodNotAllowed)))
+		}
+	default:
+		writeError(w, http.StatusMethodNotAllowed, errors.New(http.StatusText(http.StatusMethodNotAllowed)))
+	}
+}
+
+func createUpload(w http.ResponseWriter, r *http.Request, l *logging.Logger) {
+	body, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, maxDeviceBody))
+	if err != nil {
+		writeError(w, http.StatusRequestEntityTooLarge, err)
+		return
+	}
+	var req createUploadRequest
+	if err := json.Unmarshal(body, &req); err != nil || req.UserID == 0 || len(req.Digest) != sha256.Size {
+		writeError(w, http.StatusBadRequest, errors.New("invalid request"))
+		return
+	}
+	if req.Size <= 0 || req.Size > maxAttachmentSize {
+		writeError(w, http.StatusRequestEntityTooLarge, fmt.Errorf("attachments must be between 1 and %d bytes", maxAttachmentSize))
+		return
+	}
+	deviceID, err := verifyDeviceRequest(r, req.UserID, body)
+	if err != nil {
+		l.Info("attachment upload refused", "user_id", req.UserID, "error", err)
+		writeError(w, http.StatusUnauthorized, err)
+		return
+	}
+
+	m, token, err := blobs.create(req.UserID, req.Size, req.Digest)
+	switch err {
+	case nil:
+	case errTooManyUploads:
+		writeError(w, http.StatusTooManyRequests, err)
+		return
+	case errQuotaExceeded:
+		writeError(w, http.StatusInsufficientStorage, err)
+		return
+	default:
+		l.Error("creating attachment", "user_id", req.UserID, "error", err)
+		writeError(w, http.StatusInternalServerError, errors.New("internal error"))
+		return
+	}
+
+	l.Debug("attachment upload started", "user_id", req.UserID, "device_id", deviceID, "size", req.Size)
+	w.Header().Set("Location", "/attachments/"+m.ID)
+	writeJSON(w, http.StatusCreated, uploadJSON{ID: m.ID, UploadToken: token, Expires: m.Expires})
+}
+
+func uploadOffset(w http.ResponseWriter, m *blobMeta) {
+	offset, err := blobs.offset(m)
+	if err != nil {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	w.Header().Set(uploadOffsetHeader, strconv.FormatInt(offset, 10))
+	w.Header().Set(uploadLengthHeader, strconv.FormatInt(m.Size, 10))
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusOK)
+}
+
+func uploadChunk(w http.ResponseWriter, r *http.Request, m *blobMeta, l *logging.Logger) {
+	token, err := base64.RawURLEncoding.DecodeString(r.Header.Get(uploadTokenHeader))
+	tokenHash := sha256.Sum256(token)
+	if err != nil || subtle.ConstantTimeCompare(tokenHash[:], m.TokenHash) != 1 {
+		writeError(w, http.StatusUnauthorized, errBadToken)
+		return
+	}
+	offset, err := strconv.ParseInt(r.Header.Get(uploadOffsetHeader), 10, 64)
+	if err != nil || offset < 0 {
+		writeError(w, http.StatusBadRequest, errBadOffset)
+		return
+	}
+
+	offset, err = blobs.appendChunk(m, offset, http.MaxBytesReader(w, r.Body, maxChunkSize))
+	switch err {
+	case nil:
+	case errBadOffset:
+		w.Header().Set(uploadOffsetHeader, strconv.FormatInt(offset, 10))
+		writeError(w, http.StatusConflict, err)
+		return
+	case errUploadBusy:
+		writeError(w, http.StatusLocked, err)
+		return
+	case errDigest:
+		l.Info("attachment discarded", "user_id", m.UserID, "error", err)
+		writeError(w, http.StatusUnprocessableEntity, err)
+		return
+	default:
+		// a chunk over maxChunkSize or a broken connection, the part that
+		// arrived is kept
+		l.Debug("attachment chunk interrupted", "user_id", m.UserID, "error", err)
+		w.Header().Set(uploadOffsetHeader, strconv.FormatInt(offset, 10))
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if offset == m.Size {
+		l.Info("attachment uploaded", "user_id", m.UserID, "size", m.Size)
+	}
+	w.Header().Set(uploadOffsetHeader, strconv.FormatInt(offset, 10))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// downloadBlob serves a complete blob. Anyone who knows the id may fetch it,
+// only the recipients of the message have the key. Range requests let
+// interrupted downloads resume.
+func downloadBlob(w http.ResponseWriter, r *http.Request, m *blobMeta) {
+	f, err := os.Open(blobs.path(m.ID, ""))
+	if err != nil {
+		writeError(w, http.StatusNotFound, errBlobNotFound)
+		return
+	}
+	defer f.Close()
+
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Expires", m.Expires.Format(http.TimeFormat))
+	http.ServeContent(w, r, "", m.Created, f)
+}
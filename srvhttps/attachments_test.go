@@ -0,0 +1,169 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+)
+
+func newTestBlobStore(t *testing.T) *blobStore {
+	s, err := openBlobStore(t.TempDir(), time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestUploadResume(t *testing.T) {
+	s := newTestBlobStore(t)
+	data := bytes.Repeat([]byte("attachment "), 1000)
+	digest := sha256.Sum256(data)
+
+	m, _, err := s.create(1, int64(len(data)), digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name    string
+		offset  int64
+		chunk   []byte
+		want    int64
+		wantErr error
+	}{
+		{"first chunk", 0, data[:4000], 4000, nil},
+		{"wrong offset", 3000, data[3000:5000], 4000, errBadOffset},
+		{"resumed", 4000, data[4000:9000], 9000, nil},
+		{"more than declared", 9000, append(data[9000:], "extra"...), int64(len(data)), nil},
+		{"after completion", int64(len(data)), []byte("x"), 0, errBadOffset},
+	}
+	for _, tt := range tests {
+		got, err := s.appendChunk(m, tt.offset, bytes.NewReader(tt.chunk))
+		if got != tt.want || err != tt.wantErr {
+			t.Fatalf("%s: got %d, %v, want %d, %v", tt.name, got, err, tt.want, tt.wantErr)
+		}
+	}
+	b, err := ioutil.ReadFile(s.path(m.ID, ""))
+	if err != nil || !bytes.Equal(b, data) {
+		t.Fatalf("stored blob differs: %d bytes, %v", len(b), err)
+	}
+	if got := s.usage[1]; got.pending != 0 || got.bytes != int64(len(data)) {
+		t.Fatalf("usage after completion: %+v", *got)
+	}
+}
+
+func TestUploadDigestMismatch(t *testing.T) {
+	s := newTestBlobStore(t)
+	digest := sha256.Sum256([]byte("something else"))
+	m, _, err := s.create(1, 4, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.appendChunk(m, 0, bytes.NewReader([]byte("data"))); err != errDigest {
+		t.Fatalf("got %v, want %v", err, errDigest)
+	}
+	if _, err := s.meta(m.ID); err != errBlobNotFound {
+		t.Fatalf("mismatching blob kept: %v", err)
+	}
+	if _, ok := s.usage[1]; ok {
+		t.Fatal("mismatching blob still counts against the quota")
+	}
+}
+
+func TestUploadQuota(t *testing.T) {
+	s := newTestBlobStore(t)
+	s.quota = 1000
+	s.maxPending = 2
+	digest := make([]byte, sha256.Size)
+
+	tests := []struct {
+		name   string
+		userID uint64
+		size   int64
+		want   error
+	}{
+		{"first upload", 1, 400, nil},
+		{"second upload", 1, 400, nil},
+		{"third concurrent upload", 1, 100, errTooManyUploads},
+		{"other user", 2, 1000, nil},
+		{"over the quota", 2, 1, errQuotaExceeded},
+	}
+	var first *blobMeta
+	for _, tt := range tests {
+		m, _, err := s.create(tt.userID, tt.size, digest)
+		if err != tt.want {
+			t.Fatalf("%s: got %v, want %v", tt.name, err, tt.want)
+		}
+		if first == nil {
+			first = m
+		}
+	}
+
+	// finishing an upload frees a slot but not its bytes, removing it
+	// frees both
+	data := make([]byte, 400)
+	sum := sha256.Sum256(data)
+	first.Digest = sum[:]
+	if _, err := s.appendChunk(first, 0, bytes.NewReader(data)); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := s.create(1, 300, digest); err != errQuotaExceeded {
+		t.Fatalf("after completing one: got %v, want %v", err, errQuotaExceeded)
+	}
+	if _, _, err := s.create(1, 200, digest); err != nil {
+		t.Fatalf("after completing one: %v", err)
+	}
+	s.remove(first)
+	s.remove(first) // removing twice must not free its bytes twice
+	if got := s.usage[1]; got.bytes != 600 || got.pending != 2 {
+		t.Fatalf("usage after removal: %+v", *got)
+	}
+}
+
+func TestSweep(t *testing.T) {
+	s := newTestBlobStore(t)
+	digest := make([]byte, sha256.Size)
+
+	active, _, err := s.create(1, 10, digest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	abandoned, _, err := s.create(1, 10, digest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	old := time.Now().Add(-abandonedUploadAge - time.Minute)
+	if err := os.Chtimes(s.path(abandoned.ID, ".part"), old, old); err != nil {
+		t.Fatal(err)
+	}
+
+	expired, gone, err := s.sweepOnce(time.Now())
+	if err != nil || expired != 0 || gone != 1 {
+		t.Fatalf("sweep: %d expired, %d abandoned, %v", expired, gone, err)
+	}
+	if _, err := s.meta(abandoned.ID); err != errBlobNotFound {
+		t.Fatalf("abandoned upload kept: %v", err)
+	}
+	if _, err := s.meta(active.ID); err != nil {
+		t.Fatalf("active upload removed: %v", err)
+	}
+
+	// the usage is rebuilt when the store is opened again
+	reopened, err := openBlobStore(s.dir, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := reopened.usage[1]; got == nil || got.bytes != 10 || got.pending != 1 {
+		t.Fatalf("usage after reopening: %+v", got)
+	}
+
+	expired, _, err = reopened.sweepOnce(time.Now().Add(2 * time.Hour))
+	if err != nil || expired != 1 {
+		t.Fatalf("sweep after the TTL: %d expired, %v", expired, err)
+	}
+	if _, ok := reopened.usage[1]; ok {
+		t.Fatal("expired upload still counts against the quota")
+	}
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"github.com/Apurer/e2eechat/devices"
+	"github.com/Apurer/e2eechat/dispatch"
+	"github.com/Apurer/e2eechat/logging"
+	"github.com/golang/protobuf/proto"
+)
+
+// certificateLifetime is how long a sender certificate is valid. Clients
+// fetch a new one before it expires.
+const certificateLifetime = 24 * time.Hour
+
+// certificateKey signs the sender certificates that recipients of sealed
+// sender messages check.
+var certificateKey ed25519.PrivateKey
+
+type accessKeyRequest struct {
+	UserID       uint64 `json:"user_id"`
+	AccessKey    []byte `json:"access_key"` // base64, nil turns sealed delivery off
+	Unrestricted bool   `json:"unrestricted"`
+}
+
+type certificateRequest struct {
+	UserID uint64 `json:"user_id"`
+}
+
+type certificateJSON struct {
+	Certificate []byte    `json:"certificate"` // dispatch.SenderCertificate
+	Expires     time.Time `json:"expires"`
+}
+
+// handleAccessKey serves
+//
+//	PUT /access-key  set the key senders of sealed messages must present
+func handleAccessKey(w http.ResponseWriter, r *http.Request) {
+	id := logging.NewCorrelationID()
+	w.Header().Set(correlationHeader, id)
+	l := logger.With(logging.CorrelationKey, id, "remote", r.RemoteAddr)
+
+	if r.Method != http.MethodPut {
+		writeError(w, http.StatusMethodNotAllowed, errors.New(http.StatusText(http.StatusMethodNotAllowed)))
+		return
+	}
+	body, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, maxDeviceBody))
+	if err != nil {
+		writeError(w, http.StatusRequestEntityTooLarge, err)
+		return
+	}
+	var req accessKeyRequest
+	if err := json.Unmarshal(body, &req); err != nil || req.UserID == 0 {
+		writeError(w, http.StatusBadRequest, errors.New("invalid request"))
+		return
+	}
+
+	signer, err := verifyDeviceRequest(r, req.UserID, body)
+	if err != nil {
+		l.Info("access key change refused", "user_id", req.UserID, "error", err)
+		writeError(w, http.StatusUnauthorized, err)
+		return
+	}
+
+	switch err := deviceStore.SetAccess(req.UserID, req.AccessKey, req.Unrestricted); err {
+	case nil:
+	case devices.ErrBadAccessKey:
+		writeError(w, http.StatusBadRequest, err)
+		return
+	default:
+		l.Error("setting access key", "user_id", req.UserID, "error", err)
+		writeError(w, http.StatusInternalServerError, errors.New("internal error"))
+		return
+	}
+
+	l.Info("access key changed", "user_id", req.UserID, "by_device_id", signer, "unrestricted", req.Unrestricted)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleCertificate serves
+//
+//	GET  /certificate  public key that signs sender certificates
+//	POST /certificate  issue a sender certificate to the signing device
+func handleCertificate(w http.ResponseWriter, r *http.Request) {
+	id := logging.NewCorrelationID()
+	w.Header().Set(correlationHeader, id)
+	l := logger.With(logging.CorrelationKey, id, "remote", r.RemoteAddr)
+
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, map[string][]byte{"public_key": certificateKey.Public().(ed25519.PublicKey)})
+		return
+	case http.MethodPost:
+	default:
+		writeError(w, http.StatusMethodNotAllowed, errors.New(http.StatusText(http.StatusMethodNotAllowed)))
+		return
+	}
+
+	body, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, maxDeviceBody))
+	if err != nil {
+		writeError(w, http.StatusRequestEntityTooLarge, err)
+		return
+	}
+	var req certificateRequest
+	if err := json.Unmarshal(body, &req); err != nil || req.UserID == 0 {
+		writeError(w, http.StatusBadRequest, errors.New("invalid request"))
+		return
+	}
+
+	deviceID, err := verifyDeviceRequest(r, req.UserID, body)
+	if err != nil {
+		l.Info("sender certificate refused", "user_id", req.UserID, "error", err)
+		writeError(w, http.StatusUnauthorized, err)
+		return
+	}
+	d, err := deviceStore.Get(req.UserID, deviceID)
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, errNotSigned)
+		return
+	}
+
+	expires := time.Now().Add(certificateLifetime).Truncate(time.Second).UTC()
+	cert := &dispatch.SenderCertificate{
+		UserId:      d.UserID,
+		DeviceId:    d.ID,
+		IdentityKey: d.IdentityKey,
+		Expires:     expires.Unix(),
+	}
+	cert.Sign(certificateKey)
+	b, err := proto.Marshal(cert)
+	if err != nil {
+		l.Error("encoding sender certificate", "error", err)
+		writeError(w, http.StatusInternalServerError, errors.New("internal error"))
+		return
+	}
+
+	l.Debug("sender certificate issued", "user_id", d.UserID, "device_id", d.ID)
+	writeJSON(w, http.StatusOK, certificateJSON{Certificate: b, Expires: expires})
+}
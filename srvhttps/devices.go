@@ -0,0 +1,366 @@
+package main
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Apurer/e2eechat/devices"
+	"github.com/Apurer/e2eechat/dispatch"
+	"github.com/Apurer/e2eechat/logging"
+	"github.com/golang/protobuf/proto"
+)
+
+// Device management requests of a user who already has a device must be
+// signed by one of its active devices, with its identity key, over
+// deviceRequestBytes. Each signed request carries a random request id that
+// is accepted only once. The first device of a user, or the first one after
+// all were revoked, is registered with a login token instead, which shows
+// that the user logged in to the account.
+const (
+	deviceIDHeader        = "X-Device-Id"
+	deviceTimestampHeader = "X-Timestamp"
+	deviceSignatureHeader = "X-Signature"
+	requestIDHeader       = "X-Request-Id"
+	loginTokenHeader      = "X-Login-Token"
+
+	deviceRequestContext = "e2eechat device request v2"
+
+	// maxRequestSkew bounds how old a signed request may be.
+	maxRequestSkew = 5 * time.Minute
+
+	minRequestID = 16
+	maxRequestID = 64
+
+	maxDeviceBody = 4096
+)
+
+var (
+	errNotSigned     = errors.New("request must be signed by an active device")
+	errBadSignature  = errors.New("invalid request signature")
+	errStale         = errors.New("request timestamp too far from server time")
+	errBadRequestID  = errors.New("request id missing or invalid")
+	errReplayed      = errors.New("request was already made")
+	errNoLoginToken  = errors.New("the first device of a user needs a login token")
+	errBadLoginToken = errors.New("invalid login token")
+)
+
+// seenRequests remembers the signed requests accepted within maxRequestSkew,
+// older ones are refused as stale anyway.
+var seenRequests = newReplayCache(2 * maxRequestSkew)
+
+// deviceStore is shared with srvtls through the file it is stored in.
+var deviceStore *devices.Store
+
+// loginKey checks the login tokens of users registering their first device.
+var loginKey ed25519.PublicKey
+
+type deviceJSON struct {
+	ID          uint64     `json:"id"`
+	Name        string     `json:"name"`
+	IdentityKey []byte     `json:"identity_key"`
+	SealingKey  []byte     `json:"sealing_key,omitempty"`
+	Created     time.Time  `json:"created"`
+	Revoked     *time.Time `json:"revoked,omitempty"`
+}
+
+type registerRequest struct {
+	UserID      uint64 `json:"user_id"`
+	Name        string `json:"name"`
+	IdentityKey []byte `json:"identity_key"` // base64 ed25519 public key
+	SealingKey  []byte `json:"sealing_key"`  // base64 uncompressed P-256 point, optional
+}
+
+// handleDevices serves
+//
+//	GET    /devices?user_id=N       devices of a user with their identity keys
+//	POST   /devices                 register a device
+//	DELETE /devices/{id}?user_id=N  revoke a device
+func handleDevices(w http.ResponseWriter, r *http.Request) {
+	id := logging.NewCorrelationID()
+	w.Header().Set(correlationHeader, id)
+	l := logger.With(logging.CorrelationKey, id, "remote", r.RemoteAddr)
+
+	body, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, maxDeviceBody))
+	if err != nil {
+		writeError(w, http.StatusRequestEntityTooLarge, err)
+		return
+	}
+
+	switch {
+	case r.URL.Path == "/devices" && r.Method == http.MethodGet:
+		listDevices(w, r)
+	case r.URL.Path == "/devices" && r.Method == http.MethodPost:
+		registerDevice(w, r, body, l)
+	case strings.HasPrefix(r.URL.Path, "/devices/") && r.Method == http.MethodDelete:
+		revokeDevice(w, r, body, l)
+	default:
+		writeError(w, http.StatusMethodNotAllowed, errors.New(http.StatusText(http.StatusMethodNotAllowed)))
+	}
+}
+
+func listDevices(w http.ResponseWriter, r *http.Request) {
+	userID, err := strconv.ParseUint(r.URL.Query().Get("user_id"), 10, 64)
+	if err != nil || userID == 0 {
+		writeError(w, http.StatusBadRequest, errors.New("invalid user_id"))
+		return
+	}
+
+	// identity keys are public, senders need them to encrypt for every device
+	list := []deviceJSON{}
+	for _, d := range deviceStore.List(userID) {
+		list = append(list, deviceJSON{
+			ID:          d.ID,
+			Name:        d.Name,
+			IdentityKey: d.IdentityKey,
+			SealingKey:  d.SealingKey,
+			Created:     d.Created,
+			Revoked:     d.Revoked,
+		})
+	}
+	writeJSON(w, http.StatusOK, list)
+}
+
+func registerDevice(w http.ResponseWriter, r *http.Request, body []byte, l *logging.Logger) {
+	var req registerRequest
+	if err := json.Unmarshal(body, &req); err != nil || req.UserID == 0 {
+		writeError(w, http.StatusBadRequest, errors.New("invalid request"))
+		return
+	}
+
+	if len(deviceStore.Active(req.UserID)) > 0 {
+		if _, err := verifyDeviceRequest(r, req.UserID, body); err != nil {
+			l.Info("device registration refused", "user_id", req.UserID, "error", err)
+			writeError(w, http.StatusUnauthorized, err)
+			return
+		}
+	} else if err := verifyLoginToken(r, req.UserID); err != nil {
+		l.Info("device registration refused", "user_id", req.UserID, "error", err)
+		writeError(w, http.StatusUnauthorized, err)
+		return
+	}
+
+	d, err := deviceStore.Register(req.UserID, req.Name, req.IdentityKey, req.SealingKey)
+	switch err {
+	case nil:
+	case devices.ErrBadKey, devices.ErrTooMany:
+		writeError(w, http.StatusBadRequest, err)
+		return
+	default:
+		l.Error("registering device", "user_id", req.UserID, "error", err)
+		writeError(w, http.StatusInternalServerError, errors.New("internal error"))
+		return
+	}
+
+	if err := logDevice(d); err != nil {
+		l.Error("logging device key", "user_id", d.UserID, "device_id", d.ID, "error", err)
+		writeError(w, http.StatusInternalServerError, errors.New("internal error"))
+		return
+	}
+
+	l.Info("device registered", "user_id", d.UserID, "device_id", d.ID)
+	writeJSON(w, http.StatusCreated, deviceJSON{
+		ID:          d.ID,
+		Name:        d.Name,
+		IdentityKey: d.IdentityKey,
+		SealingKey:  d.SealingKey,
+		Created:     d.Created,
+	})
+}
+
+func revokeDevice(w http.ResponseWriter, r *http.Request, body []byte, l *logging.Logger) {
+	deviceID, err := strconv.ParseUint(strings.TrimPrefix(r.URL.Path, "/devices/"), 10, 64)
+	if err != nil {
+		writeError(w, http.StatusNotFound, devices.ErrNotFound)
+		return
+	}
+	userID, err := strconv.ParseUint(r.URL.Query().Get("user_id"), 10, 64)
+	if err != nil || userID == 0 {
+		writeError(w, http.StatusBadRequest, errors.New("invalid user_id"))
+		return
+	}
+
+	signer, err := verifyDeviceRequest(r, userID, body)
+	if err != nil {
+		l.Info("device revocation refused", "user_id", userID, "error", err)
+		writeError(w, http.StatusUnauthorized, err)
+		return
+	}
+
+	switch err := deviceStore.Revoke(userID, deviceID); err {
+	case nil:
+	case devices.ErrNotFound:
+		writeError(w, http.StatusNotFound, err)
+		return
+	case devices.ErrRevoked:
+		writeError(w, http.StatusConflict, err)
+		return
+	default:
+		l.Error("revoking device", "user_id", userID, "device_id", deviceID, "error", err)
+		writeError(w, http.StatusInternalServerError, errors.New("internal error"))
+		return
+	}
+
+	if d, err := deviceStore.Get(userID, deviceID); err == nil {
+		if err := logDevice(d); err != nil {
+			l.Error("logging device revocation", "user_id", userID, "device_id", deviceID, "error", err)
+			writeError(w, http.StatusInternalServerError, errors.New("internal error"))
+			return
+		}
+	}
+
+	l.Info("device revoked", "user_id", userID, "device_id", deviceID, "by_device_id", signer)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// verifyDeviceRequest checks that r was signed by an active device of
+// userID and was not made before, and returns that device's id.
+func verifyDeviceRequest(r *http.Request, userID uint64, body []byte) (uint64, error) {
+	deviceID, err := strconv.ParseUint(r.Header.Get(deviceIDHeader), 10, 64)
+	if err != nil {
+		return 0, errNotSigned
+	}
+	requestID := r.Header.Get(requestIDHeader)
+	if len(requestID) < minRequestID || len(requestID) > maxRequestID {
+		return 0, errBadRequestID
+	}
+	sig, err := base64.StdEncoding.DecodeString(r.Header.Get(deviceSignatureHeader))
+	if err != nil {
+		return 0, errBadSignature
+	}
+	ts, err := strconv.ParseInt(r.Header.Get(deviceTimestampHeader), 10, 64)
+	if err != nil {
+		return 0, errStale
+	}
+	if skew := time.Since(time.Unix(ts, 0)); skew > maxRequestSkew || skew < -maxRequestSkew {
+		return 0, errStale
+	}
+
+	d, err := deviceStore.Get(userID, deviceID)
+	if err != nil || !d.Active() {
+		return 0, errNotSigned
+	}
+	if !ed25519.Verify(d.IdentityKey, deviceRequestBytes(r, ts, requestID, body), sig) {
+		return 0, errBadSignature
+	}
+	// only signed requests are remembered, so others cannot use up ids
+	if !seenRequests.add(fmt.Sprintf("%d/%d/%s", userID, deviceID, requestID)) {
+		return 0, errReplayed
+	}
+	return deviceID, nil
+}
+
+// deviceRequestBytes is what a device signs to authorize a request.
+func deviceRequestBytes(r *http.Request, ts int64, requestID string, body []byte) []byte {
+	var b bytes.Buffer
+	b.WriteString(deviceRequestContext)
+	b.WriteByte('\n')
+	b.WriteString(r.Method)
+	b.WriteByte('\n')
+	b.WriteString(r.URL.RequestURI())
+	b.WriteByte('\n')
+	b.WriteString(strconv.FormatInt(ts, 10))
+	b.WriteByte('\n')
+	b.WriteString(requestID)
+	b.WriteByte('\n')
+	b.Write(body)
+	return b.Bytes()
+}
+
+// verifyLoginToken checks that r carries a login token issued to userID.
+func verifyLoginToken(r *http.Request, userID uint64) error {
+	h := r.Header.Get(loginTokenHeader)
+	if h == "" {
+		return errNoLoginToken
+	}
+	b, err := base64.StdEncoding.DecodeString(h)
+	if err != nil {
+		return errBadLoginToken
+	}
+	token := new(dispatch.LoginToken)
+	if err := proto.Unmarshal(b, token); err != nil {
+		return errBadLoginToken
+	}
+	if err := token.Verify(loginKey); err != nil || token.UserId != userID {
+		return errBadLoginToken
+	}
+	return nil
+}
+
+// loadLoginKey reads the PEM encoded ed25519 public key that login tokens
+// are signed with.
+func loadLoginKey(path string) (ed25519.PublicKey, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(b)
+	if block == nil {
+		return nil, fmt.Errorf("%s: no PEM data", path)
+	}
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	pub, ok := key.(ed25519.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("%s: not an ed25519 key", path)
+	}
+	return pub, nil
+}
+
+// replayCache remembers keys for a while.
+type replayCache struct {
+	ttl time.Duration
+
+	mu    sync.Mutex
+	seen  map[string]time.Time
+	order []string // keys in the order they were added
+}
+
+func newReplayCache(ttl time.Duration) *replayCache {
+	return &replayCache{ttl: ttl, seen: make(map[string]time.Time)}
+}
+
+// add records key and reports whether it was new.
+func (c *replayCache) add(key string) bool {
+	return c.addAt(key, time.Now())
+}
+
+func (c *replayCache) addAt(key string, now time.Time) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// keys are added in order, so the expired ones are at the front
+	for len(c.order) > 0 && now.Sub(c.seen[c.order[0]]) >= c.ttl {
+		delete(c.seen, c.order[0])
+		c.order = c.order[1:]
+	}
+	if _, ok := c.seen[key]; ok {
+		return false
+	}
+	c.seen[key] = now
+	c.order = append(c.order, key)
+	return true
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
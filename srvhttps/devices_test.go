@@ -0,0 +1,35 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestReplayCache(t *testing.T) {
+	c := newReplayCache(time.Minute)
+	start := time.Now()
+
+	tests := []struct {
+		name  string
+		key   string
+		after time.Duration
+		want  bool
+	}{
+		{"first request", "a", 0, true},
+		{"other request", "b", 10 * time.Second, true},
+		{"replayed", "a", 30 * time.Second, false},
+		{"replayed after the ttl", "a", 61 * time.Second, true},
+		{"still remembered", "b", 65 * time.Second, false},
+	}
+	for _, tt := range tests {
+		if got := c.addAt(tt.key, start.Add(tt.after)); got != tt.want {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+		}
+	}
+
+	// expired keys are dropped on the next insert, not only now and then
+	c.addAt("c", start.Add(3*time.Minute))
+	if len(c.seen) != 1 || len(c.order) != 1 {
+		t.Fatalf("%d keys remembered after the ttl, want 1", len(c.seen))
+	}
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Apurer/e2eechat/devices"
+	"github.com/Apurer/e2eechat/transparency"
+)
+
+// maxLogEntries bounds the entries returned by one request.
+const maxLogEntries = 1000
+
+// keyLog records every registration and revocation of an identity key, so
+// that clients can check that srvhttps hands out the same keys to everyone.
+var keyLog *transparency.Log
+
+// logDevice appends a device's current key to the transparency log.
+func logDevice(d *devices.Device) error {
+	_, err := keyLog.Append(transparency.Entry{
+		UserID:      d.UserID,
+		DeviceID:    d.ID,
+		IdentityKey: d.IdentityKey,
+		Revoked:     !d.Active(),
+		Timestamp:   time.Now(),
+	})
+	return err
+}
+
+// handleLog serves
+//
+//	GET /log/tree-head                             signed head of the current tree
+//	GET /log/entries?start=N&end=M                 entries in [start, end)
+//	GET /log/inclusion?index=N&tree_size=M         audit path of a leaf
+//	GET /log/consistency?first=N&second=M          proof that a tree extends another
+//	GET /log/key?user_id=N&device_id=M             latest entry of a device and its proof
+func handleLog(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, errors.New(http.StatusText(http.StatusMethodNotAllowed)))
+		return
+	}
+	q := r.URL.Query()
+
+	switch r.URL.Path {
+	case "/log/tree-head":
+		writeJSON(w, http.StatusOK, keyLog.TreeHead())
+
+	case "/log/entries":
+		start, err1 := strconv.ParseUint(q.Get("start"), 10, 64)
+		end, err2 := strconv.ParseUint(q.Get("end"), 10, 64)
+		if err1 != nil || err2 != nil || end < start || end-start > maxLogEntries {
+			writeError(w, http.StatusBadRequest, errors.New("invalid range"))
+			return
+		}
+		entries, err := keyLog.Entries(start, end)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, entries)
+
+	case "/log/inclusion":
+		index, err1 := strconv.ParseUint(q.Get("index"), 10, 64)
+		size, err2 := strconv.ParseUint(q.Get("tree_size"), 10, 64)
+		if err1 != nil || err2 != nil {
+			writeError(w, http.StatusBadRequest, errors.New("invalid index or tree_size"))
+			return
+		}
+		path, err := keyLog.Inclusion(index, size)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{"leaf_index": index, "audit_path": path})
+
+	case "/log/consistency":
+		first, err1 := strconv.ParseUint(q.Get("first"), 10, 64)
+		second, err2 := strconv.ParseUint(q.Get("second"), 10, 64)
+		if err1 != nil || err2 != nil {
+			writeError(w, http.StatusBadRequest, errors.New("invalid tree sizes"))
+			return
+		}
+		proof, err := keyLog.Consistency(first, second)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{"consistency": proof})
+
+	case "/log/key":
+		userID, err1 := strconv.ParseUint(q.Get("user_id"), 10, 64)
+		deviceID, err2 := strconv.ParseUint(q.Get("device_id"), 10, 64)
+		if err1 != nil || err2 != nil {
+			writeError(w, http.StatusBadRequest, errors.New("invalid user_id or device_id"))
+			return
+		}
+		proof, ok := keyLog.KeyProof(userID, deviceID)
+		if !ok {
+			writeError(w, http.StatusNotFound, devices.ErrNotFound)
+			return
+		}
+		writeJSON(w, http.StatusOK, proof)
+
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// loadSigningKey reads a PEM encoded ed25519 private key, such as the one
+// signing the tree heads.
+func loadSigningKey(path string) (ed25519.PrivateKey, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(b)
+	if block == nil {
+		return nil, fmt.Errorf("%s: no PEM data", path)
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	priv, ok := key.(ed25519.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("%s: not an ed25519 key", path)
+	}
+	return priv, nil
+}
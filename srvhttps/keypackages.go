@@ -0,0 +1,250 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/Apurer/e2eechat/dispatch"
+	"github.com/Apurer/e2eechat/logging"
+	"github.com/Apurer/e2eechat/mls"
+	"github.com/golang/protobuf/proto"
+)
+
+// Devices publish MLS key packages here so that members of a channel can add
+// them to its group while they are offline. Each key package is handed out
+// once, devices publish new ones when they run low.
+const (
+	// maxKeyPackages bounds the key packages stored for one device.
+	maxKeyPackages = 100
+
+	maxKeyPackageBody = 64 * 1024
+)
+
+var errTooManyKeyPackages = errors.New("too many key packages stored for the device")
+
+var keyPackages *keyPackageStore
+
+type publishKeyPackagesRequest struct {
+	UserID      uint64   `json:"user_id"`
+	KeyPackages [][]byte `json:"key_packages"` // base64 dispatch.MlsKeyPackage
+}
+
+type claimKeyPackagesRequest struct {
+	UserID      uint64 `json:"user_id"`       // the claiming user
+	ClaimUserID uint64 `json:"claim_user_id"` // the user to be added
+}
+
+type keyPackageJSON struct {
+	DeviceID   uint64 `json:"device_id"`
+	KeyPackage []byte `json:"key_package"` // dispatch.MlsKeyPackage
+}
+
+// keyPackageStore holds the published key packages of every device,
+// persisted as a JSON file.
+type keyPackageStore struct {
+	path string
+
+	mu       sync.Mutex
+	packages map[uint64]map[uint64][][]byte // user id, device id
+}
+
+func openKeyPackages(path string) (*keyPackageStore, error) {
+	s := &keyPackageStore{path: path, packages: make(map[uint64]map[uint64][][]byte)}
+	b, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(b, &s.packages); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// add stores key packages of a device and returns how many it has.
+func (s *keyPackageStore) add(userID, deviceID uint64, kps [][]byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.packages[userID] == nil {
+		s.packages[userID] = make(map[uint64][][]byte)
+	}
+	old := s.packages[userID][deviceID]
+	if len(old)+len(kps) > maxKeyPackages {
+		return len(old), errTooManyKeyPackages
+	}
+	s.packages[userID][deviceID] = append(old[:len(old):len(old)], kps...)
+	if err := s.save(); err != nil {
+		s.packages[userID][deviceID] = old
+		return len(old), err
+	}
+	return len(old) + len(kps), nil
+}
+
+// claim removes and returns one key package of each of the given devices of
+// userID that has one left.
+func (s *keyPackageStore) claim(userID uint64, deviceIDs []uint64) ([]keyPackageJSON, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	claimed := []keyPackageJSON{}
+	old := make(map[uint64][][]byte)
+	for _, id := range deviceIDs {
+		kps := s.packages[userID][id]
+		if len(kps) == 0 {
+			continue
+		}
+		old[id] = kps
+		claimed = append(claimed, keyPackageJSON{DeviceID: id, KeyPackage: kps[0]})
+		s.packages[userID][id] = kps[1:]
+	}
+	if len(claimed) == 0 {
+		return claimed, nil
+	}
+	if err := s.save(); err != nil {
+		for id, kps := range old {
+			s.packages[userID][id] = kps
+		}
+		return nil, err
+	}
+	return claimed, nil
+}
+
+// save writes the store to a temporary file and renames it over the old one.
+// The caller holds s.mu.
+func (s *keyPackageStore) save() error {
+	b, err := json.Marshal(s.packages)
+	if err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(s.path), filepath.Base(s.path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(b); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), s.path)
+}
+
+// handleKeyPackages serves
+//
+//	POST /key-packages        publish key packages of the signing device
+//	POST /key-packages/claim  take one key package of every active device of a user
+func handleKeyPackages(w http.ResponseWriter, r *http.Request) {
+	id := logging.NewCorrelationID()
+	w.Header().Set(correlationHeader, id)
+	l := logger.With(logging.CorrelationKey, id, "remote", r.RemoteAddr)
+
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, errors.New(http.StatusText(http.StatusMethodNotAllowed)))
+		return
+	}
+	body, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, maxKeyPackageBody))
+	if err != nil {
+		writeError(w, http.StatusRequestEntityTooLarge, err)
+		return
+	}
+
+	switch r.URL.Path {
+	case "/key-packages":
+		publishKeyPackages(w, r, body, l)
+	case "/key-packages/claim":
+		claimKeyPackages(w, r, body, l)
+	default:
+		writeError(w, http.StatusNotFound, errors.New(http.StatusText(http.StatusNotFound)))
+	}
+}
+
+func publishKeyPackages(w http.ResponseWriter, r *http.Request, body []byte, l *logging.Logger) {
+	var req publishKeyPackagesRequest
+	if err := json.Unmarshal(body, &req); err != nil || req.UserID == 0 || len(req.KeyPackages) == 0 {
+		writeError(w, http.StatusBadRequest, errors.New("invalid request"))
+		return
+	}
+	deviceID, err := verifyDeviceRequest(r, req.UserID, body)
+	if err != nil {
+		l.Info("key packages refused", "user_id", req.UserID, "error", err)
+		writeError(w, http.StatusUnauthorized, err)
+		return
+	}
+	d, err := deviceStore.Get(req.UserID, deviceID)
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, errNotSigned)
+		return
+	}
+
+	// members adding the device check its key package against the
+	// identity key it registered, so only those are accepted
+	for _, b := range req.KeyPackages {
+		kp := new(dispatch.MlsKeyPackage)
+		if err := proto.Unmarshal(b, kp); err != nil {
+			writeError(w, http.StatusBadRequest, mls.ErrBadKeyPackage)
+			return
+		}
+		if err := mls.VerifyKeyPackage(kp); err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		if kp.Leaf.UserId != d.UserID || kp.Leaf.DeviceId != d.ID || !bytes.Equal(kp.Leaf.SignatureKey, d.IdentityKey) {
+			writeError(w, http.StatusBadRequest, mls.ErrBadKeyPackage)
+			return
+		}
+	}
+
+	n, err := keyPackages.add(d.UserID, d.ID, req.KeyPackages)
+	switch err {
+	case nil:
+	case errTooManyKeyPackages:
+		writeError(w, http.StatusBadRequest, err)
+		return
+	default:
+		l.Error("storing key packages", "user_id", d.UserID, "device_id", d.ID, "error", err)
+		writeError(w, http.StatusInternalServerError, errors.New("internal error"))
+		return
+	}
+
+	l.Debug("key packages published", "user_id", d.UserID, "device_id", d.ID, "count", len(req.KeyPackages))
+	writeJSON(w, http.StatusOK, map[string]int{"stored": n})
+}
+
+func claimKeyPackages(w http.ResponseWriter, r *http.Request, body []byte, l *logging.Logger) {
+	var req claimKeyPackagesRequest
+	if err := json.Unmarshal(body, &req); err != nil || req.UserID == 0 || req.ClaimUserID == 0 {
+		writeError(w, http.StatusBadRequest, errors.New("invalid request"))
+		return
+	}
+	// claims use up key packages, so only devices of users may make them
+	if _, err := verifyDeviceRequest(r, req.UserID, body); err != nil {
+		l.Info("key package claim refused", "user_id", req.UserID, "error", err)
+		writeError(w, http.StatusUnauthorized, err)
+		return
+	}
+
+	var ids []uint64
+	for _, d := range deviceStore.Active(req.ClaimUserID) {
+		ids = append(ids, d.ID)
+	}
+	claimed, err := keyPackages.claim(req.ClaimUserID, ids)
+	if err != nil {
+		l.Error("claiming key packages", "claim_user_id", req.ClaimUserID, "error", err)
+		writeError(w, http.StatusInternalServerError, errors.New("internal error"))
+		return
+	}
+
+	l.Debug("key packages claimed", "user_id", req.UserID, "claim_user_id", req.ClaimUserID, "count", len(claimed))
+	writeJSON(w, http.StatusOK, claimed)
+}
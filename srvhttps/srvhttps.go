@@ -1,10 +1,13 @@
 package main
 
 import (
-	"os"
 	"fmt"
 	"net/http"
+	"os"
 
+	"github.com/Apurer/e2eechat/devices"
+	"github.com/Apurer/e2eechat/logging"
+	"github.com/Apurer/e2eechat/transparency"
 	"github.com/Apurer/eev"
 	"github.com/Apurer/eev/privatekey"
 )
@@ -16,52 +19,130 @@ type localAddr struct {
 
 var lclAddr localAddr
 
+var logger = logging.FromEnv("srvhttps")
+
+// correlationHeader carries the id that the client passes on in
+// dispatch.Authentication, so its chat session can be traced through
+// tls2tlsproxy and srvtls.
+const correlationHeader = "X-Correlation-Id"
+
 func (l *localAddr) redirect(w http.ResponseWriter, r *http.Request) {
 	http.Redirect(w, r, fmt.Sprintf("https://%s:%s%s", l.domain, l.port, r.RequestURI), http.StatusMovedPermanently)
 }
 
-func init() {
-
+// loadConfig reads the settings encrypted with the key at KEYPATH. The key
+// path and its passphrase are removed from the environment once read.
+func loadConfig() error {
 	keypath := os.Getenv("KEYPATH")
-	err := os.Unsetenv("KEYPATH")
-	if err != nil {
-		panic(err)
+	if err := os.Unsetenv("KEYPATH"); err != nil {
+		return err
 	}
 	passphrase := os.Getenv("PASSPHRASE")
-	err = os.Unsetenv("PASSPHRASE")
-	if err != nil {
-		panic(err)
+	if err := os.Unsetenv("PASSPHRASE"); err != nil {
+		return err
 	}
 
 	privkey, err := privatekey.Read(keypath, passphrase)
 	if err != nil {
-		panic(err)
+		return fmt.Errorf("%s: %v", keypath, err)
 	}
 
 	port, err := eev.Get("HTTPS_SERVER_PORT", privkey)
 	if err != nil {
-		panic(err)
+		return err
 	}
 
 	domain, err := eev.Get("HTTPS_SERVER_DOMAIN", privkey)
 	if err != nil {
-		panic(err)
+		return err
 	}
 
 	lclAddr.port = port
 	lclAddr.domain = domain
+	return nil
 }
 
 func main() {
+	if err := loadConfig(); err != nil {
+		logger.Error("reading configuration", "error", err)
+		return
+	}
+
+	var err error
+	loginKey, err = loadLoginKey("login.pub")
+	if err != nil {
+		logger.Error("loading login key", "error", err)
+		return
+	}
+
+	deviceStore, err = devices.Open("devices.json")
+	if err != nil {
+		logger.Error("opening device store", "error", err)
+		return
+	}
+
+	keyPackages, err = openKeyPackages("keypackages.json")
+	if err != nil {
+		logger.Error("opening key package store", "error", err)
+		return
+	}
+
+	logKey, err := loadSigningKey("log.key")
+	if err != nil {
+		logger.Error("loading log key", "error", err)
+		return
+	}
+	keyLog, err = transparency.OpenLog("keylog.jsonl", logKey)
+	if err != nil {
+		logger.Error("opening key transparency log", "error", err)
+		return
+	}
+	defer keyLog.Close()
+
+	certificateKey, err = loadSigningKey("sender.key")
+	if err != nil {
+		logger.Error("loading sender certificate key", "error", err)
+		return
+	}
+
+	ttl, err := attachmentTTLFromEnv()
+	if err != nil {
+		logger.Error("reading attachment settings", "error", err)
+		return
+	}
+	blobs, err = openBlobStore("attachments", ttl)
+	if err != nil {
+		logger.Error("opening attachment store", "error", err)
+		return
+	}
+	go blobs.sweep(blobSweepInterval)
 
 	http.HandleFunc("/", login)
-	go http.ListenAndServe(":80", http.HandlerFunc(lclAddr.redirect))
-	http.ListenAndServe(lclAddr.port, nil)
+	http.HandleFunc("/devices", handleDevices)
+	http.HandleFunc("/devices/", handleDevices)
+	http.HandleFunc("/log/", handleLog)
+	http.HandleFunc("/access-key", handleAccessKey)
+	http.HandleFunc("/certificate", handleCertificate)
+	http.HandleFunc("/attachments", handleAttachments)
+	http.HandleFunc("/attachments/", handleAttachments)
+	http.HandleFunc("/key-packages", handleKeyPackages)
+	http.HandleFunc("/key-packages/", handleKeyPackages)
+	go func() {
+		err := http.ListenAndServe(":80", http.HandlerFunc(lclAddr.redirect))
+		logger.Error("redirect listener", "error", err)
+	}()
+	logger.Info("listening", "port", lclAddr.port)
+	err = http.ListenAndServe(lclAddr.port, nil)
+	logger.Error("listener", "error", err)
 }
 
 func login(w http.ResponseWriter, r *http.Request) {
+	id := logging.NewCorrelationID()
+	w.Header().Set(correlationHeader, id)
+	l := logger.With(logging.CorrelationKey, id, "remote", r.RemoteAddr)
+
 	switch r.Method {
 	case "POST":
-
+		l.Info("login")
 	}
 }
@@ -0,0 +1,372 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"github.com/Apurer/e2eechat/dispatch"
+	"github.com/golang/protobuf/proto"
+)
+
+// maxMembers bounds the size of a channel, every post is fanned out to all
+// devices of all members.
+const maxMembers = 1000
+
+var (
+	errNoChannel     = errors.New("no such channel")
+	errNotMember     = errors.New("not a member of the channel")
+	errForbidden     = errors.New("not allowed for your role")
+	errAlreadyMember = errors.New("already a member of the channel")
+	errChannelFull   = errors.New("channel is full")
+	errBadChannelOp  = errors.New("invalid channel operation")
+)
+
+type role = dispatch.ChannelMember_Role
+
+// channel is a group of users. Posts to it are delivered to every member.
+type channel struct {
+	ID      uint64          `json:"id"`
+	Name    string          `json:"name"`
+	Members map[uint64]role `json:"members"`
+
+	// Epoch is the current MLS epoch of the channel, see mls.go.
+	Epoch uint64 `json:"mls_epoch,omitempty"`
+}
+
+func (ch *channel) userIDs() []uint64 {
+	ids := make([]uint64, 0, len(ch.Members))
+	for id := range ch.Members {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids
+}
+
+func (ch *channel) event(op *dispatch.ChannelOp, actor uint64) *dispatch.ChannelEvent {
+	ev := &dispatch.ChannelEvent{Op: op, ActorId: actor, Name: ch.Name}
+	for _, id := range ch.userIDs() {
+		ev.Members = append(ev.Members, &dispatch.ChannelMember{UserId: id, Role: ch.Members[id]})
+	}
+	return ev
+}
+
+// successor is the member who becomes owner when the owner leaves, the
+// admin with the lowest user id or else the member with the lowest user id.
+func (ch *channel) successor() (uint64, bool) {
+	var member uint64
+	for _, id := range ch.userIDs() {
+		if ch.Members[id] == dispatch.ChannelMember_ADMIN {
+			return id, true
+		}
+		if member == 0 {
+			member = id
+		}
+	}
+	return member, member != 0
+}
+
+type channelsFile struct {
+	NextID   uint64     `json:"next_id"`
+	Channels []*channel `json:"channels"`
+}
+
+// channelStore holds the membership of every channel, persisted as a JSON
+// file.
+type channelStore struct {
+	path string
+
+	mu       sync.Mutex
+	nextID   uint64
+	channels map[uint64]*channel
+}
+
+func openChannels(path string) (*channelStore, error) {
+	s := &channelStore{path: path, nextID: 1, channels: make(map[uint64]*channel)}
+
+	b, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var f channelsFile
+	if err := json.Unmarshal(b, &f); err != nil {
+		return nil, err
+	}
+	for _, ch := range f.Channels {
+		s.channels[ch.ID] = ch
+	}
+	if f.NextID > s.nextID {
+		s.nextID = f.NextID
+	}
+	return s, nil
+}
+
+// members returns the members of a channel if userID is one of them.
+func (s *channelStore) members(channelID, userID uint64) ([]uint64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ch := s.channels[channelID]
+	if ch == nil {
+		return nil, errNoChannel
+	}
+	if _, ok := ch.Members[userID]; !ok {
+		return nil, errNotMember
+	}
+	return ch.userIDs(), nil
+}
+
+// shareChannel reports whether users a and b are members of a common
+// channel.
+func (s *channelStore) shareChannel(a, b uint64) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, ch := range s.channels {
+		_, okA := ch.Members[a]
+		_, okB := ch.Members[b]
+		if okA && okB {
+			return true
+		}
+	}
+	return false
+}
+
+// apply performs op on behalf of actor. It returns the resulting event and
+// the users to notify, which is nobody but the actor for LIST.
+func (s *channelStore) apply(actor uint64, op *dispatch.ChannelOp) (*dispatch.ChannelEvent, []uint64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if op.Op == dispatch.ChannelOp_CREATE {
+		ch := &channel{
+			ID:      s.nextID,
+			Name:    op.Name,
+			Members: map[uint64]role{actor: dispatch.ChannelMember_OWNER},
+		}
+		s.channels[ch.ID] = ch
+		s.nextID++
+		if err := s.save(); err != nil {
+			delete(s.channels, ch.ID)
+			s.nextID--
+			return nil, nil, err
+		}
+		op.ChannelId = ch.ID
+		return ch.event(op, actor), ch.userIDs(), nil
+	}
+
+	ch := s.channels[op.ChannelId]
+	if ch == nil {
+		return nil, nil, errNoChannel
+	}
+	actorRole, ok := ch.Members[actor]
+	if !ok {
+		return nil, nil, errNotMember
+	}
+	if op.Op == dispatch.ChannelOp_LIST {
+		return ch.event(op, actor), []uint64{actor}, nil
+	}
+
+	// everyone in the channel before the change hears about it
+	notify := ch.userIDs()
+
+	// work on a copy so that a failed save leaves the channel untouched
+	members := make(map[uint64]role, len(ch.Members))
+	for id, r := range ch.Members {
+		members[id] = r
+	}
+
+	switch op.Op {
+	case dispatch.ChannelOp_INVITE:
+		if actorRole < dispatch.ChannelMember_ADMIN {
+			return nil, nil, errForbidden
+		}
+		if _, ok := members[op.UserId]; ok {
+			return nil, nil, errAlreadyMember
+		}
+		if len(members) >= maxMembers {
+			return nil, nil, errChannelFull
+		}
+		members[op.UserId] = dispatch.ChannelMember_MEMBER
+		notify = append(notify, op.UserId)
+
+	case dispatch.ChannelOp_LEAVE:
+		delete(members, actor)
+		if actorRole == dispatch.ChannelMember_OWNER {
+			next := &channel{Members: members}
+			if id, ok := next.successor(); ok {
+				members[id] = dispatch.ChannelMember_OWNER
+			}
+		}
+
+	case dispatch.ChannelOp_KICK:
+		target, ok := members[op.UserId]
+		if !ok {
+			return nil, nil, errNotMember
+		}
+		if op.UserId == actor || actorRole <= target || actorRole < dispatch.ChannelMember_ADMIN {
+			return nil, nil, errForbidden
+		}
+		delete(members, op.UserId)
+
+	case dispatch.ChannelOp_SET_ROLE:
+		if actorRole != dispatch.ChannelMember_OWNER {
+			return nil, nil, errForbidden
+		}
+		if _, ok := members[op.UserId]; !ok || op.UserId == actor {
+			return nil, nil, errBadChannelOp
+		}
+		if _, ok := dispatch.ChannelMember_Role_name[int32(op.Role)]; !ok {
+			return nil, nil, errBadChannelOp
+		}
+		members[op.UserId] = op.Role
+		if op.Role == dispatch.ChannelMember_OWNER {
+			members[actor] = dispatch.ChannelMember_ADMIN
+		}
+
+	default:
+		return nil, nil, errBadChannelOp
+	}
+
+	old := ch.Members
+	ch.Members = members
+	if len(members) == 0 {
+		delete(s.channels, ch.ID)
+	}
+	if err := s.save(); err != nil {
+		ch.Members = old
+		s.channels[ch.ID] = ch
+		return nil, nil, err
+	}
+	return ch.event(op, actor), notify, nil
+}
+
+// save writes the store to a temporary file and renames it over the old one.
+// The caller holds s.mu.
+func (s *channelStore) save() error {
+	f := channelsFile{NextID: s.nextID}
+	for _, ch := range s.channels {
+		f.Channels = append(f.Channels, ch)
+	}
+	sort.Slice(f.Channels, func(i, j int) bool { return f.Channels[i].ID < f.Channels[j].ID })
+	return writeJSON(s.path, &f)
+}
+
+// writeJSON writes v to a temporary file and renames it over path, so that
+// a crash never leaves a partly written file behind.
+func writeJSON(path string, v interface{}) error {
+	b, err := json.MarshalIndent(v, "", "\t")
+	if err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(b); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// handleChannelOp applies the ChannelOp carried by a and reports the outcome.
+func handleChannelOp(c *client, a *dispatch.Action) {
+	op := new(dispatch.ChannelOp)
+	if err := proto.Unmarshal(a.Payload, op); err != nil {
+		refuseChannelOp(c, op, errBadChannelOp)
+		return
+	}
+
+	if op.Op == dispatch.ChannelOp_INVITE && len(relay.store.Active(op.UserId)) == 0 {
+		refuseChannelOp(c, op, errUnknownRecipient)
+		return
+	}
+
+	ev, notify, err := channels.apply(c.userID, op)
+	if err != nil {
+		c.l.Info("channel op refused", "op", op.Op, "channel_id", op.ChannelId, "error", err)
+		refuseChannelOp(c, op, err)
+		return
+	}
+	if op.Op != dispatch.ChannelOp_LIST {
+		c.l.Info("channel changed", "op", op.Op, "channel_id", op.ChannelId, "target_user_id", op.UserId)
+	}
+
+	out, err := channelAction(op.ChannelId, ev)
+	if err != nil {
+		c.l.Error("encoding channel event", "error", err)
+		return
+	}
+	if op.Op == dispatch.ChannelOp_LIST {
+		out.RecipientId = c.userID
+		out.RecipientDeviceId = c.deviceID
+		if err := c.send(out); err != nil {
+			c.l.Debug("sending channel event", "error", err)
+		}
+		return
+	}
+	relay.notify(out, notify)
+}
+
+// refuseChannelOp tells the requesting device why op was refused.
+func refuseChannelOp(c *client, op *dispatch.ChannelOp, reason error) {
+	out, err := channelAction(op.ChannelId, &dispatch.ChannelEvent{
+		Op:      op,
+		ActorId: c.userID,
+		Error:   reason.Error(),
+	})
+	if err != nil {
+		c.l.Error("encoding channel event", "error", err)
+		return
+	}
+	out.RecipientId = c.userID
+	out.RecipientDeviceId = c.deviceID
+	if err := c.send(out); err != nil {
+		c.l.Debug("sending channel event", "error", err)
+	}
+}
+
+func channelAction(channelID uint64, ev *dispatch.ChannelEvent) (*dispatch.Action, error) {
+	b, err := proto.Marshal(ev)
+	if err != nil {
+		return nil, err
+	}
+	return &dispatch.Action{
+		Type:      dispatch.Action_CHANNEL,
+		ChannelId: channelID,
+		Payload:   b,
+	}, nil
+}
+
+// postToChannel delivers a to every member of its channel, provided the
+// sender is one of them.
+func postToChannel(c *client, a *dispatch.Action) {
+	members, err := channels.members(a.ChannelId, c.userID)
+	if err != nil {
+		c.l.Info("channel post refused", "channel_id", a.ChannelId, "error", err)
+		c.report(err, a.MessageId)
+		return
+	}
+	missing, err := relay.fanout(c.deviceKey, a, members)
+	if err != nil {
+		c.l.Info("channel post not delivered", "channel_id", a.ChannelId, "error", err)
+		c.report(err, a.MessageId)
+		return
+	}
+	if len(missing) > 0 {
+		c.l.Info("channel post lacks copies for some devices", "channel_id", a.ChannelId, "device_ids", missing)
+		c.reportMissing(missing, a.MessageId)
+	}
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/Apurer/e2eechat/dispatch"
+)
+
+func TestChannelOps(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "channels.json")
+	s, err := openChannels(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const owner, admin, member, outsider = 1, 2, 3, 4
+
+	tests := []struct {
+		name       string
+		actor      uint64
+		op         *dispatch.ChannelOp
+		wantErr    error
+		wantNotify []uint64
+	}{
+		{"create", owner, &dispatch.ChannelOp{Op: dispatch.ChannelOp_CREATE, Name: "team"}, nil, []uint64{owner}},
+		{"invite", owner, &dispatch.ChannelOp{Op: dispatch.ChannelOp_INVITE, ChannelId: 1, UserId: admin}, nil, []uint64{owner, admin}},
+		{"invite again", owner, &dispatch.ChannelOp{Op: dispatch.ChannelOp_INVITE, ChannelId: 1, UserId: admin}, errAlreadyMember, nil},
+		{"promote", owner, &dispatch.ChannelOp{Op: dispatch.ChannelOp_SET_ROLE, ChannelId: 1, UserId: admin, Role: dispatch.ChannelMember_ADMIN}, nil, []uint64{owner, admin}},
+		{"admin invites", admin, &dispatch.ChannelOp{Op: dispatch.ChannelOp_INVITE, ChannelId: 1, UserId: member}, nil, []uint64{owner, admin, member}},
+		{"member invites", member, &dispatch.ChannelOp{Op: dispatch.ChannelOp_INVITE, ChannelId: 1, UserId: outsider}, errForbidden, nil},
+		{"admin kicks the owner", admin, &dispatch.ChannelOp{Op: dispatch.ChannelOp_KICK, ChannelId: 1, UserId: owner}, errForbidden, nil},
+		{"admin sets a role", admin, &dispatch.ChannelOp{Op: dispatch.ChannelOp_SET_ROLE, ChannelId: 1, UserId: member, Role: dispatch.ChannelMember_ADMIN}, errForbidden, nil},
+		{"outsider lists", outsider, &dispatch.ChannelOp{Op: dispatch.ChannelOp_LIST, ChannelId: 1}, errNotMember, nil},
+		{"member lists", member, &dispatch.ChannelOp{Op: dispatch.ChannelOp_LIST, ChannelId: 1}, nil, []uint64{member}},
+		{"unknown channel", owner, &dispatch.ChannelOp{Op: dispatch.ChannelOp_LIST, ChannelId: 9}, errNoChannel, nil},
+		// the admin takes over when the owner leaves
+		{"owner leaves", owner, &dispatch.ChannelOp{Op: dispatch.ChannelOp_LEAVE, ChannelId: 1}, nil, []uint64{owner, admin, member}},
+		{"new owner kicks", admin, &dispatch.ChannelOp{Op: dispatch.ChannelOp_KICK, ChannelId: 1, UserId: member}, nil, []uint64{admin, member}},
+	}
+	for _, tt := range tests {
+		_, notify, err := s.apply(tt.actor, tt.op)
+		if err != tt.wantErr || !reflect.DeepEqual(notify, tt.wantNotify) {
+			t.Errorf("%s: got %v, %v, want %v, %v", tt.name, notify, err, tt.wantNotify, tt.wantErr)
+		}
+	}
+
+	// the store comes back as it was left
+	reopened, err := openChannels(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[uint64]role{admin: dispatch.ChannelMember_OWNER}
+	if ch := reopened.channels[1]; ch == nil || ch.Name != "team" || !reflect.DeepEqual(ch.Members, want) {
+		t.Fatalf("reopened channel: %+v", ch)
+	}
+	_, _, err = reopened.apply(owner, &dispatch.ChannelOp{Op: dispatch.ChannelOp_CREATE, Name: "second"})
+	if err != nil || reopened.channels[2] == nil {
+		t.Fatalf("ids after reopening: %v, %v", reopened.channels, err)
+	}
+
+	// a change that cannot be saved is not applied
+	reopened.path = filepath.Join(path, "not a directory", "channels.json")
+	if _, _, err := reopened.apply(admin, &dispatch.ChannelOp{Op: dispatch.ChannelOp_INVITE, ChannelId: 1, UserId: member}); err == nil {
+		t.Fatal("invite saved to an invalid path")
+	}
+	if !reflect.DeepEqual(reopened.channels[1].Members, want) {
+		t.Fatalf("members after a failed save: %v", reopened.channels[1].Members)
+	}
+
+	// the last member leaving removes the channel
+	reopened.path = path
+	if _, _, err := reopened.apply(admin, &dispatch.ChannelOp{Op: dispatch.ChannelOp_LEAVE, ChannelId: 1}); err != nil {
+		t.Fatal(err)
+	}
+	if again, err := openChannels(path); err != nil || again.channels[1] != nil {
+		t.Fatalf("empty channel kept: %v", err)
+	}
+}
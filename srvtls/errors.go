@@ -0,0 +1,78 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/Apurer/e2eechat/dispatch"
+)
+
+var (
+	errUnsupportedAction = errors.New("action type not accepted from clients")
+	errInvalidMessage    = errors.New("invalid message")
+)
+
+// errorCodes maps the errors actions are refused with to the codes reported
+// to clients. Anything else is reported as INTERNAL.
+var errorCodes = map[error]dispatch.Error_Code{
+	errInvalidMessage:       dispatch.Error_INVALID_MESSAGE,
+	errBadChannelOp:         dispatch.Error_INVALID_MESSAGE,
+	errBadPresence:          dispatch.Error_INVALID_MESSAGE,
+	errUnsupportedAction:    dispatch.Error_UNSUPPORTED,
+	errNotSealed:            dispatch.Error_UNSUPPORTED,
+	errUnknownRecipient:     dispatch.Error_UNKNOWN_RECIPIENT,
+	errMissingCopies:        dispatch.Error_MISSING_COPIES,
+	errAccessDenied:         dispatch.Error_FORBIDDEN,
+	errNotMember:            dispatch.Error_FORBIDDEN,
+	errForbidden:            dispatch.Error_FORBIDDEN,
+	errNoChannel:            dispatch.Error_NOT_FOUND,
+	errTooManySubscriptions: dispatch.Error_TOO_LARGE,
+}
+
+// report tells the device why the action with messageID was not handled.
+// Devices that did not negotiate ERROR_REPORTS are not told.
+func (c *client) report(err error, messageID uint64) {
+	code, ok := errorCodes[err]
+	if !ok {
+		code = dispatch.Error_INTERNAL
+	}
+	c.reportCode(code, err.Error(), messageID)
+}
+
+// reportMissing tells the sender for which devices its action carried no
+// copy, those devices did not get it.
+func (c *client) reportMissing(missing []uint64, messageID uint64) {
+	c.reportCode(dispatch.Error_MISSING_COPIES, fmt.Sprintf("no copy for devices %v", missing), messageID)
+}
+
+func (c *client) reportCode(code dispatch.Error_Code, detail string, messageID uint64) {
+	e := dispatch.NewError(code, detail, messageID)
+	e.CorrelationId = c.correlationID
+	a, err := e.Action()
+	if err != nil {
+		c.l.Error("encoding error", "error", err)
+		return
+	}
+	a.RecipientId = c.userID
+	a.RecipientDeviceId = c.deviceID
+	if err := c.send(a); err != nil {
+		c.l.Debug("sending error", "error", err)
+	}
+}
+
+// refuse reports why a connection is closed before it was registered.
+func refuse(conn net.Conn, peer *peerConn, code dispatch.Error_Code, detail string) {
+	if !dispatch.HasCapability(peer.Capabilities(), dispatch.Capability_ERROR_REPORTS) {
+		return
+	}
+	e := dispatch.NewError(code, detail, 0)
+	e.CorrelationId = peer.CorrelationID()
+	a, err := e.Action()
+	if err != nil {
+		return
+	}
+	conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+	dispatch.WriteMessage(conn, a)
+}
@@ -0,0 +1,429 @@
+package main
+
+import (
+	"errors"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/Apurer/e2eechat/devices"
+	"github.com/Apurer/e2eechat/dispatch"
+	"github.com/Apurer/e2eechat/logging"
+	"github.com/golang/protobuf/proto"
+)
+
+const (
+	// writeTimeout bounds how long a slow device can hold up a sender.
+	writeTimeout = 10 * time.Second
+
+	// maxQueued is the number of messages kept per offline device. The
+	// oldest ones are dropped first.
+	maxQueued = 1000
+
+	// deviceReload is how often the device registry is checked for
+	// registrations and revocations made through srvhttps.
+	deviceReload = 5 * time.Second
+)
+
+var errUnknownRecipient = errors.New("recipient has no active devices")
+
+// queued is an action on its way to a device. It is created once when the
+// action arrives and kept through every retry, so that its deadline does
+// not move.
+type queued struct {
+	*dispatch.Action
+
+	// expires is when a disappearing message is dropped undelivered, zero
+	// for other actions
+	expires time.Time
+}
+
+func newQueued(a *dispatch.Action) queued {
+	q := queued{Action: a}
+	if a.ExpireTimer > 0 {
+		q.expires = time.Now().Add(time.Duration(a.ExpireTimer) * time.Second)
+	}
+	return q
+}
+
+func (q queued) expired(now time.Time) bool {
+	return !q.expires.IsZero() && now.After(q.expires)
+}
+
+type deviceKey struct {
+	userID   uint64
+	deviceID uint64
+}
+
+// client is the connection of one device.
+type client struct {
+	deviceKey
+	conn net.Conn
+	l    *logging.Logger
+
+	// capabilities were negotiated at connect time, actions needing others
+	// are not written to this connection
+	capabilities  []dispatch.Capability
+	correlationID string
+
+	// mu serializes writes, and is held while queued messages are flushed so
+	// that newer ones cannot overtake them
+	mu sync.Mutex
+}
+
+func (c *client) send(a *dispatch.Action) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.write(a)
+}
+
+// write sends a with c.mu held. Actions the device cannot handle are
+// dropped, the sender has to fall back to what the device supports.
+func (c *client) write(a *dispatch.Action) error {
+	if !dispatch.Accepts(c.capabilities, a.Type) {
+		c.l.Debug("action not supported by the device, dropped", "type", a.Type)
+		return nil
+	}
+	c.conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+	return dispatch.WriteMessage(c.conn, a)
+}
+
+// hub routes actions to every active device of their recipient, queueing
+// them for devices which are not connected.
+type hub struct {
+	store *devices.Store
+
+	mu      sync.Mutex
+	clients map[deviceKey]*client
+	queues  map[deviceKey][]queued
+	online  map[uint64]int // connected devices per user
+}
+
+func newHub(store *devices.Store) *hub {
+	return &hub{
+		store:   store,
+		clients: make(map[deviceKey]*client),
+		queues:  make(map[deviceKey][]queued),
+		online:  make(map[uint64]int),
+	}
+}
+
+// register makes c the connection of its device, replacing and closing an
+// older one, and delivers what was queued while the device was offline. It
+// reports whether c is the only connected device of its user.
+func (h *hub) register(c *client) (first bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	h.mu.Lock()
+	old := h.clients[c.deviceKey]
+	h.clients[c.deviceKey] = c
+	if old == nil {
+		h.online[c.userID]++
+	}
+	first = h.online[c.userID] == 1
+	q := h.queues[c.deviceKey]
+	delete(h.queues, c.deviceKey)
+	h.mu.Unlock()
+
+	if old != nil {
+		old.l.Info("replaced by a newer connection of the same device")
+		old.conn.Close()
+	}
+
+	now := time.Now()
+	for i, a := range q {
+		if a.expired(now) {
+			continue
+		}
+		if err := c.write(a.Action); err != nil {
+			c.l.Debug("flushing queue", "error", err)
+			h.requeue(c.deviceKey, q[i:])
+			c.conn.Close()
+			return first
+		}
+	}
+	return first
+}
+
+// unregister removes c unless it was already replaced. It reports whether
+// that left its user without connected devices.
+func (h *hub) unregister(c *client) (last bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.clients[c.deviceKey] != c {
+		return false
+	}
+	delete(h.clients, c.deviceKey)
+	if !h.store.IsActive(c.userID, c.deviceID) {
+		// revoked, whatever failed to reach it stays undelivered
+		delete(h.queues, c.deviceKey)
+	}
+	h.online[c.userID]--
+	if h.online[c.userID] > 0 {
+		return false
+	}
+	delete(h.online, c.userID)
+	return true
+}
+
+// isOnline reports whether any device of the user is connected.
+func (h *hub) isOnline(userID uint64) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.online[userID] > 0
+}
+
+// deliver sends one copy of a to every active device of its recipient, each
+// carrying the payload encrypted for it. The sending device itself is
+// skipped when users message their own other devices. It returns the
+// devices for which a carries no copy, which means the sender works with an
+// outdated device list.
+func (h *hub) deliver(from deviceKey, a *dispatch.Action) (missing []uint64, err error) {
+	return h.fanout(from, a, []uint64{a.RecipientId})
+}
+
+// fanout delivers a like deliver, to the devices of every user in users.
+func (h *hub) fanout(from deviceKey, a *dispatch.Action, users []uint64) (missing []uint64, err error) {
+	var targets []*devices.Device
+	for _, u := range users {
+		targets = append(targets, h.store.Active(u)...)
+	}
+	if len(targets) == 0 {
+		return nil, errUnknownRecipient
+	}
+
+	// ids chosen by an anonymous sender could tie its sealed actions
+	// together, and nothing shows that what it deletes is its own
+	messageID, deleted := a.MessageId, a.DeletedMessageId
+	if from.userID == 0 {
+		messageID, deleted = 0, 0
+	}
+	if deleted != 0 {
+		if n := h.purge(from, deleted, targets); n > 0 {
+			logger.Debug("dropped queued copies of a deleted message", "sender_id", from.userID, "sender_device_id", from.deviceID, "message_id", deleted, "copies", n)
+		}
+	}
+
+	copies := make(map[uint64][]byte, len(a.Copies))
+	for _, c := range a.Copies {
+		copies[c.DeviceId] = c.Payload
+	}
+
+	for _, d := range targets {
+		if d.UserID == from.userID && d.ID == from.deviceID {
+			continue
+		}
+		payload := a.Payload
+		if len(a.Copies) > 0 {
+			p, ok := copies[d.ID]
+			if !ok {
+				missing = append(missing, d.ID)
+				continue
+			}
+			payload = p
+		}
+
+		out := &dispatch.Action{
+			Payload:           payload,
+			RecipientId:       d.UserID,
+			ChannelId:         a.ChannelId,
+			Publickey:         a.Publickey,
+			Hash:              a.Hash,
+			Type:              a.Type,
+			RecipientDeviceId: d.ID,
+			SenderId:          from.userID,
+			SenderDeviceId:    from.deviceID,
+			MessageId:         messageID,
+			DeletedMessageId:  deleted,
+			ExpireTimer:       a.ExpireTimer,
+		}
+		h.deliverTo(deviceKey{userID: d.UserID, deviceID: d.ID}, newQueued(out))
+	}
+	return missing, nil
+}
+
+// relayLive delivers an ephemeral action like fanout, but only to the
+// devices connected right now and with a's payload for all of them. Nothing
+// is queued, devices that are offline or fail to take it miss it.
+func (h *hub) relayLive(from deviceKey, a *dispatch.Action, users []uint64) error {
+	var targets []*client
+	active := false
+	h.mu.Lock()
+	for _, u := range users {
+		for _, d := range h.store.Active(u) {
+			active = true
+			k := deviceKey{userID: d.UserID, deviceID: d.ID}
+			if c := h.clients[k]; c != nil && k != from {
+				targets = append(targets, c)
+			}
+		}
+	}
+	h.mu.Unlock()
+	if !active {
+		return errUnknownRecipient
+	}
+
+	for _, c := range targets {
+		out := &dispatch.Action{
+			Payload:           a.Payload,
+			RecipientId:       c.userID,
+			ChannelId:         a.ChannelId,
+			Type:              a.Type,
+			RecipientDeviceId: c.deviceID,
+			SenderId:          from.userID,
+			SenderDeviceId:    from.deviceID,
+		}
+		if err := c.send(out); err != nil {
+			c.l.Debug("ephemeral delivery failed", "error", err)
+			c.conn.Close()
+		}
+	}
+	return nil
+}
+
+// purge drops the copies of the action with messageID from sender that are
+// still queued for the given devices, and returns how many it dropped.
+// Message ids are chosen by each device, so the sending device must match
+// as well as the user.
+func (h *hub) purge(sender deviceKey, messageID uint64, targets []*devices.Device) int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	n := 0
+	for _, d := range targets {
+		k := deviceKey{userID: d.UserID, deviceID: d.ID}
+		q := h.queues[k]
+		kept := q[:0]
+		for _, a := range q {
+			if a.SenderId == sender.userID && a.SenderDeviceId == sender.deviceID && a.MessageId == messageID {
+				n++
+				continue
+			}
+			kept = append(kept, a)
+		}
+		if len(kept) == 0 {
+			delete(h.queues, k)
+		} else {
+			h.queues[k] = kept
+		}
+	}
+	return n
+}
+
+// notify sends an action generated by srvtls itself to every active device
+// of users.
+func (h *hub) notify(a *dispatch.Action, users []uint64) {
+	for _, u := range users {
+		for _, d := range h.store.Active(u) {
+			out := proto.Clone(a).(*dispatch.Action)
+			out.RecipientId = d.UserID
+			out.RecipientDeviceId = d.ID
+			h.deliverTo(deviceKey{userID: d.UserID, deviceID: d.ID}, newQueued(out))
+		}
+	}
+}
+
+// deliverTo sends a to its device, or queues it when the device is offline
+// or the write fails. a keeps the deadline it got when it arrived either way.
+func (h *hub) deliverTo(k deviceKey, a queued) {
+	h.mu.Lock()
+	c := h.clients[k]
+	if c == nil {
+		h.enqueue(k, a)
+		h.mu.Unlock()
+		return
+	}
+	h.mu.Unlock()
+
+	if err := c.send(a.Action); err != nil {
+		c.l.Debug("delivery failed, queueing", "error", err)
+		h.requeue(k, []queued{a})
+		c.conn.Close()
+	}
+}
+
+// requeue puts actions that could not be written back in front of the queue.
+func (h *hub) requeue(k deviceKey, actions []queued) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	q := append(append([]queued(nil), actions...), h.queues[k]...)
+	h.queues[k] = nil
+	for _, a := range q {
+		h.enqueue(k, a)
+	}
+}
+
+// enqueue appends a to the queue of k. The caller holds h.mu.
+func (h *hub) enqueue(k deviceKey, a queued) {
+	q := append(h.queues[k], a)
+	if len(q) > maxQueued {
+		q = q[len(q)-maxQueued:]
+	}
+	h.queues[k] = q
+}
+
+// watch reloads the device registry periodically, disconnecting revoked
+// devices and dropping their queues. It also drops the disappearing messages
+// whose timer elapsed before they could be delivered.
+func (h *hub) watch(interval time.Duration) {
+	for range time.Tick(interval) {
+		if n := h.dropExpired(); n > 0 {
+			logger.Debug("dropped expired queued actions", "count", n)
+		}
+		changed, err := h.store.Reload()
+		if err != nil {
+			logger.Warn("reloading devices", "error", err)
+			continue
+		}
+		if changed {
+			h.dropRevoked()
+		}
+	}
+}
+
+func (h *hub) dropRevoked() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	// the connections are removed by unregister once they are closed
+	for k, c := range h.clients {
+		if !h.store.IsActive(k.userID, k.deviceID) {
+			c.l.Info("device revoked, closing connection")
+			c.conn.Close()
+		}
+	}
+	for k := range h.queues {
+		if !h.store.IsActive(k.userID, k.deviceID) {
+			delete(h.queues, k)
+		}
+	}
+}
+
+// dropExpired removes the expired actions from all queues and returns how
+// many it removed.
+func (h *hub) dropExpired() int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	now := time.Now()
+	n := 0
+	for k, q := range h.queues {
+		kept := q[:0]
+		for _, a := range q {
+			if a.expired(now) {
+				n++
+				continue
+			}
+			kept = append(kept, a)
+		}
+		if len(kept) == 0 {
+			delete(h.queues, k)
+		} else {
+			h.queues[k] = kept
+		}
+	}
+	return n
+}
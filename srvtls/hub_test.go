@@ -0,0 +1,282 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/Apurer/e2eechat/devices"
+	"github.com/Apurer/e2eechat/dispatch"
+)
+
+// queuedIDs returns the message ids queued for d.
+func queuedIDs(h *hub, d *devices.Device) []uint64 {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	var ids []uint64
+	for _, a := range h.queues[keyOf(d)] {
+		ids = append(ids, a.MessageId)
+	}
+	return ids
+}
+
+func TestFanout(t *testing.T) {
+	h, ds := newTestHub(t, 2, 2)
+	sender := keyOf(ds[0][0])
+	copy := func(d *devices.Device, payload string) *dispatch.DeviceCopy {
+		return &dispatch.DeviceCopy{DeviceId: d.ID, Payload: []byte(payload)}
+	}
+
+	tests := []struct {
+		name        string
+		from        deviceKey
+		a           *dispatch.Action
+		wantErr     error
+		wantMissing []uint64
+		// message id and payload queued per device of both users
+		want map[uint64]string
+	}{
+		{
+			name: "one payload for all",
+			from: sender,
+			a:    &dispatch.Action{RecipientId: 2, MessageId: 1, Payload: []byte("p")},
+			want: map[uint64]string{ds[1][0].ID: "p", ds[1][1].ID: "p"},
+		},
+		{
+			name: "copies per device, own other devices included",
+			from: sender,
+			a: &dispatch.Action{RecipientId: 2, MessageId: 1, Copies: []*dispatch.DeviceCopy{
+				copy(ds[1][0], "a"), copy(ds[1][1], "b"),
+			}},
+			want: map[uint64]string{ds[1][0].ID: "a", ds[1][1].ID: "b"},
+		},
+		{
+			name: "outdated device list",
+			from: sender,
+			a: &dispatch.Action{RecipientId: 2, MessageId: 1, Copies: []*dispatch.DeviceCopy{
+				copy(ds[1][0], "a"),
+			}},
+			wantMissing: []uint64{ds[1][1].ID},
+			want:        map[uint64]string{ds[1][0].ID: "a"},
+		},
+		{
+			name: "own devices skip the sender",
+			from: sender,
+			a:    &dispatch.Action{RecipientId: 1, MessageId: 1, Payload: []byte("sync")},
+			want: map[uint64]string{ds[0][1].ID: "sync"},
+		},
+		{
+			name:    "unknown recipient",
+			from:    sender,
+			a:       &dispatch.Action{RecipientId: 9, Payload: []byte("p")},
+			wantErr: errUnknownRecipient,
+			want:    map[uint64]string{},
+		},
+	}
+	for _, tt := range tests {
+		h.queues = make(map[deviceKey][]queued)
+		missing, err := h.deliver(tt.from, tt.a)
+		if err != tt.wantErr || !reflect.DeepEqual(missing, tt.wantMissing) {
+			t.Errorf("%s: got %v, %v, want %v, %v", tt.name, missing, err, tt.wantMissing, tt.wantErr)
+		}
+		got := make(map[uint64]string)
+		for k, q := range h.queues {
+			if len(q) != 1 || q[0].SenderId != tt.from.userID || q[0].SenderDeviceId != tt.from.deviceID {
+				t.Errorf("%s: queue of device %d: %v", tt.name, k.deviceID, q)
+				continue
+			}
+			got[k.deviceID] = string(q[0].Payload)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: queued %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestQueueFlush(t *testing.T) {
+	h, ds := newTestHub(t, 1, 1)
+	sender, recipient := keyOf(ds[0][0]), ds[1][0]
+
+	for id := uint64(1); id <= 3; id++ {
+		a := &dispatch.Action{RecipientId: 2, MessageId: id, Payload: []byte("p")}
+		if id == 2 {
+			a.ExpireTimer = 60
+		}
+		if _, err := h.deliver(sender, a); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// the timer of message 2 runs out while the device is offline
+	h.queues[keyOf(recipient)][1].expires = time.Now().Add(-time.Second)
+
+	_, remote := connect(t, h, recipient)
+	defer remote.Close()
+	for _, want := range []uint64{1, 3} {
+		if a := receive(t, remote); a.MessageId != want {
+			t.Fatalf("got message %d, want %d", a.MessageId, want)
+		}
+	}
+	if ids := queuedIDs(h, recipient); ids != nil {
+		t.Fatalf("queue after flushing: %v", ids)
+	}
+
+	// connected devices get what is sent right away
+	if _, err := h.deliver(sender, &dispatch.Action{RecipientId: 2, MessageId: 4}); err != nil {
+		t.Fatal(err)
+	}
+	if a := receive(t, remote); a.MessageId != 4 {
+		t.Fatalf("got message %d, want 4", a.MessageId)
+	}
+}
+
+func TestRequeue(t *testing.T) {
+	h, ds := newTestHub(t, 1, 1)
+	sender, recipient := keyOf(ds[0][0]), ds[1][0]
+
+	// a connection which fails every write
+	remote, conn := tcpPair(t)
+	remote.Close()
+	conn.Close()
+	h.register(&client{deviceKey: keyOf(recipient), conn: conn, l: logger})
+
+	a := &dispatch.Action{RecipientId: 2, MessageId: 1, ExpireTimer: 60}
+	if _, err := h.deliver(sender, a); err != nil {
+		t.Fatal(err)
+	}
+	h.mu.Lock()
+	q := h.queues[keyOf(recipient)]
+	h.mu.Unlock()
+	if len(q) != 1 || q[0].MessageId != 1 {
+		t.Fatalf("queue after a failed write: %v", q)
+	}
+	// the deadline is the one set when the action arrived
+	first := q[0].expires
+	h.requeue(keyOf(recipient), nil)
+	if got := h.queues[keyOf(recipient)][0].expires; !got.Equal(first) {
+		t.Fatalf("requeueing moved the deadline from %v to %v", first, got)
+	}
+}
+
+func TestEnqueueLimit(t *testing.T) {
+	h, ds := newTestHub(t, 1)
+	k := keyOf(ds[0][0])
+	for id := uint64(1); id <= maxQueued+5; id++ {
+		h.enqueue(k, newQueued(&dispatch.Action{MessageId: id}))
+	}
+	h.requeue(k, []queued{newQueued(&dispatch.Action{MessageId: 0})})
+
+	ids := queuedIDs(h, ds[0][0])
+	if len(ids) != maxQueued {
+		t.Fatalf("%d actions queued, want %d", len(ids), maxQueued)
+	}
+	// the oldest are dropped, including what was put back in front
+	if ids[0] != 6 || ids[len(ids)-1] != maxQueued+5 {
+		t.Fatalf("queue runs from %d to %d", ids[0], ids[len(ids)-1])
+	}
+}
+
+func TestDropExpired(t *testing.T) {
+	h, ds := newTestHub(t, 1, 1)
+	d := ds[1][0]
+	now := time.Now()
+
+	tests := []struct {
+		id      uint64
+		expires time.Time
+		kept    bool
+	}{
+		{1, time.Time{}, true},
+		{2, now.Add(-time.Second), false},
+		{3, now.Add(time.Hour), true},
+		{4, now.Add(-time.Hour), false},
+	}
+	for _, tt := range tests {
+		h.enqueue(keyOf(d), queued{Action: &dispatch.Action{MessageId: tt.id}, expires: tt.expires})
+	}
+	h.enqueue(keyOf(ds[0][0]), queued{Action: &dispatch.Action{MessageId: 5}, expires: now.Add(-time.Second)})
+
+	if n := h.dropExpired(); n != 3 {
+		t.Fatalf("dropped %d, want 3", n)
+	}
+	var want []uint64
+	for _, tt := range tests {
+		if tt.kept {
+			want = append(want, tt.id)
+		}
+	}
+	if got := queuedIDs(h, d); !reflect.DeepEqual(got, want) {
+		t.Fatalf("kept %v, want %v", got, want)
+	}
+	if _, ok := h.queues[keyOf(ds[0][0])]; ok {
+		t.Fatal("empty queue kept")
+	}
+
+	if q := newQueued(&dispatch.Action{ExpireTimer: 30}); q.expires.Before(now.Add(29*time.Second)) || q.expired(now) {
+		t.Fatalf("timer of 30s expires at %v", q.expires)
+	}
+	if q := newQueued(&dispatch.Action{}); q.expired(now.Add(24 * 365 * time.Hour)) {
+		t.Fatal("action without a timer expired")
+	}
+}
+
+func TestPurge(t *testing.T) {
+	h, ds := newTestHub(t, 2, 2, 1)
+	alice, alicePhone, carol := keyOf(ds[0][0]), keyOf(ds[0][1]), keyOf(ds[2][0])
+	bob := ds[1]
+
+	send := func(from deviceKey, a *dispatch.Action) {
+		if _, err := h.deliver(from, a); err != nil {
+			t.Fatal(err)
+		}
+	}
+	send(alice, &dispatch.Action{RecipientId: 2, MessageId: 5})
+	send(alice, &dispatch.Action{RecipientId: 2, MessageId: 6})
+	send(carol, &dispatch.Action{RecipientId: 2, MessageId: 5})
+	send(alicePhone, &dispatch.Action{RecipientId: 2, MessageId: 5})
+
+	tests := []struct {
+		name string
+		from deviceKey
+		a    *dispatch.Action
+		want []uint64 // queued for each device of bob
+	}{
+		// only the sending device's own message is dropped, carol's 5 and
+		// the 5 of alice's other device stay
+		{"delete for everyone", alice, &dispatch.Action{RecipientId: 2, MessageId: 7, DeletedMessageId: 5}, []uint64{6, 5, 5, 7}},
+		{"unknown message", alice, &dispatch.Action{RecipientId: 2, MessageId: 8, DeletedMessageId: 42}, []uint64{6, 5, 5, 7, 8}},
+		// anonymous senders cannot delete, and their ids are not passed on
+		{"anonymous", deviceKey{}, &dispatch.Action{RecipientId: 2, MessageId: 9, DeletedMessageId: 6}, []uint64{6, 5, 5, 7, 8, 0}},
+	}
+	for _, tt := range tests {
+		send(tt.from, tt.a)
+		for _, d := range bob {
+			if got := queuedIDs(h, d); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("%s: device %d has %v queued, want %v", tt.name, d.ID, got, tt.want)
+			}
+		}
+	}
+	if n := h.purge(carol, 5, bob); n != 2 {
+		t.Errorf("purge dropped %d copies, want 2", n)
+	}
+}
+
+func TestDropRevoked(t *testing.T) {
+	h, ds := newTestHub(t, 1, 2)
+	sender := keyOf(ds[0][0])
+	revoked, kept := ds[1][0], ds[1][1]
+
+	if _, err := h.deliver(sender, &dispatch.Action{RecipientId: 2, MessageId: 1}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := h.store.Revoke(revoked.UserID, revoked.ID); err != nil {
+		t.Fatal(err)
+	}
+	h.dropRevoked()
+	if ids := queuedIDs(h, revoked); ids != nil {
+		t.Fatalf("revoked device still has %v queued", ids)
+	}
+	if ids := queuedIDs(h, kept); !reflect.DeepEqual(ids, []uint64{1}) {
+		t.Fatalf("other device has %v queued", ids)
+	}
+}
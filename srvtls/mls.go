@@ -0,0 +1,108 @@
+package main
+
+import (
+	"errors"
+
+	"github.com/Apurer/e2eechat/dispatch"
+	"github.com/golang/protobuf/proto"
+)
+
+// srvtls is the MLS delivery service of a channel. The MLS state itself lives
+// on the clients, in package mls, and srvhttps hands out the key packages
+// they add each other with. The server only has to make sure that all
+// members apply the same commits in the same order. It keeps the current
+// epoch of every channel and accepts exactly one commit per epoch, the first
+// one to arrive, so concurrent commits cannot fork the group.
+
+var errWrongEpoch = errors.New("message for another epoch")
+
+// sequenceMLS checks an MLS message from userID against the epoch of its
+// channel, advancing the epoch for an accepted commit. It returns the epoch
+// after the message and the members to deliver it to.
+func (s *channelStore) sequenceMLS(channelID, userID uint64, m *dispatch.MlsMessage) (uint64, []uint64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ch := s.channels[channelID]
+	if ch == nil {
+		return 0, nil, errNoChannel
+	}
+	if _, ok := ch.Members[userID]; !ok {
+		return 0, nil, errNotMember
+	}
+
+	switch m.Kind {
+	case dispatch.MlsMessage_COMMIT:
+		if m.Epoch != ch.Epoch {
+			return ch.Epoch, nil, errWrongEpoch
+		}
+		ch.Epoch++
+		if err := s.save(); err != nil {
+			ch.Epoch--
+			return ch.Epoch, nil, err
+		}
+	case dispatch.MlsMessage_PROPOSAL:
+		// proposals are only valid until the next commit
+		if m.Epoch != ch.Epoch {
+			return ch.Epoch, nil, errWrongEpoch
+		}
+	case dispatch.MlsMessage_APPLICATION, dispatch.MlsMessage_WELCOME:
+		// members may still hold the secrets of earlier epochs
+		if m.Epoch > ch.Epoch {
+			return ch.Epoch, nil, errWrongEpoch
+		}
+	default:
+		return ch.Epoch, nil, errBadChannelOp
+	}
+	return ch.Epoch, ch.userIDs(), nil
+}
+
+// handleMLS sequences an MLS message and delivers it to the members of its
+// channel. The sender of a handshake message is told whether it was
+// accepted and what the current epoch is.
+func handleMLS(c *client, a *dispatch.Action) {
+	m := new(dispatch.MlsMessage)
+	if err := proto.Unmarshal(a.Payload, m); err != nil || a.ChannelId == 0 {
+		c.l.Info("invalid mls message", "channel_id", a.ChannelId, "error", err)
+		c.report(errInvalidMessage, a.MessageId)
+		return
+	}
+
+	epoch, members, err := channels.sequenceMLS(a.ChannelId, c.userID, m)
+	handshake := m.Kind == dispatch.MlsMessage_COMMIT || m.Kind == dispatch.MlsMessage_PROPOSAL
+	if err != nil {
+		c.l.Info("mls message refused", "channel_id", a.ChannelId, "kind", m.Kind, "epoch", m.Epoch, "current_epoch", epoch, "error", err)
+		answerMLS(c, a.ChannelId, m.Kind, dispatch.MlsMessage_REJECTED, epoch)
+		return
+	}
+
+	if _, err := relay.fanout(c.deviceKey, a, members); err != nil {
+		c.l.Info("mls message not delivered", "channel_id", a.ChannelId, "error", err)
+		c.report(err, a.MessageId)
+	}
+	if handshake {
+		answerMLS(c, a.ChannelId, m.Kind, dispatch.MlsMessage_ACCEPTED, epoch)
+	}
+}
+
+func answerMLS(c *client, channelID uint64, kind dispatch.MlsMessage_Kind, result dispatch.MlsMessage_Result, epoch uint64) {
+	b, err := proto.Marshal(&dispatch.MlsMessage{
+		Kind:         kind,
+		Result:       result,
+		CurrentEpoch: epoch,
+	})
+	if err != nil {
+		c.l.Error("encoding mls answer", "error", err)
+		return
+	}
+	err = c.send(&dispatch.Action{
+		Type:              dispatch.Action_MLS,
+		ChannelId:         channelID,
+		Payload:           b,
+		RecipientId:       c.userID,
+		RecipientDeviceId: c.deviceID,
+	})
+	if err != nil {
+		c.l.Debug("sending mls answer", "error", err)
+	}
+}
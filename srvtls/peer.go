@@ -0,0 +1,214 @@
+package main
+
+import (
+	"bufio"
+	"crypto/ed25519"
+	"errors"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Apurer/e2eechat/dispatch"
+	"github.com/Apurer/e2eechat/logging"
+	"github.com/Apurer/e2eechat/proxyproto"
+)
+
+const (
+	proxyHeaderTimeout = 5 * time.Second
+
+	// maxSessionSkew bounds how old a session header may be, which limits
+	// replaying a captured header.
+	maxSessionSkew = 30 * time.Second
+
+	// headerBufferSize fits the handshake messages sent by the proxy.
+	headerBufferSize = 4096
+)
+
+// errReplayedSession is returned for a session header whose nonce was seen
+// before.
+var errReplayedSession = errors.New("session header replayed")
+
+// seenNonces holds the nonces of the session headers accepted while they
+// could still pass Verify, which is up to maxSessionSkew after they were
+// issued, and those may be issued up to maxSessionSkew ahead.
+var seenNonces = newNonceCache(2 * maxSessionSkew)
+
+// peerConn is a client connection whose remote address may have been taken
+// from a PROXY protocol header sent by a trusted tls2tlsproxy. header is nil
+// when the proxy's route does not send one.
+type peerConn struct {
+	net.Conn
+	r       *bufio.Reader
+	remote  net.Addr
+	header  *proxyproto.Header
+	session *dispatch.SessionHeader
+
+	correlationID string
+}
+
+func (c *peerConn) Read(b []byte) (int, error) {
+	return c.r.Read(b)
+}
+
+// RemoteAddr returns the address of the real client rather than the proxy.
+func (c *peerConn) RemoteAddr() net.Addr {
+	return c.remote
+}
+
+// UserID returns the user id verified by the proxy, if any.
+func (c *peerConn) UserID() (uint64, bool) {
+	if c.session == nil {
+		return 0, false
+	}
+	return c.session.UserId, true
+}
+
+// DeviceID returns the device id verified by the proxy, if any.
+func (c *peerConn) DeviceID() (uint64, bool) {
+	if c.session == nil {
+		return 0, false
+	}
+	return c.session.DeviceId, true
+}
+
+// Version returns the protocol version negotiated by the proxy. Proxies
+// predating versions speak dispatch.Version1.
+func (c *peerConn) Version() uint32 {
+	if c.session == nil || c.session.Version == 0 {
+		return dispatch.Version1
+	}
+	return c.session.Version
+}
+
+// Capabilities returns the capabilities negotiated by the proxy.
+func (c *peerConn) Capabilities() []dispatch.Capability {
+	if c.session == nil {
+		return nil
+	}
+	return c.session.Capabilities
+}
+
+// CorrelationID returns the logging correlation id forwarded by the proxy,
+// or a fresh one for direct connections.
+func (c *peerConn) CorrelationID() string {
+	if c.session != nil && logging.ValidCorrelationID(c.session.CorrelationId) {
+		return c.session.CorrelationId
+	}
+	if c.correlationID == "" {
+		c.correlationID = logging.NewCorrelationID()
+	}
+	return c.correlationID
+}
+
+// newPeerConn wraps conn, consuming the optional PROXY header and the signed
+// session header if conn comes from one of the trusted proxies. Headers from
+// any other peer are never parsed, so a direct client cannot spoof its
+// address or identity.
+func newPeerConn(conn net.Conn, trusted []*net.IPNet, sessionKey ed25519.PublicKey) (*peerConn, error) {
+	c := &peerConn{
+		Conn:   conn,
+		r:      bufio.NewReader(conn),
+		remote: conn.RemoteAddr(),
+	}
+
+	addr, ok := conn.RemoteAddr().(*net.TCPAddr)
+	if !ok || !isTrusted(addr.IP, trusted) {
+		return c, nil
+	}
+
+	// the PROXY header is a setting of the proxy's route, the signed session
+	// header that follows it is not
+	conn.SetReadDeadline(time.Now().Add(proxyHeaderTimeout))
+	h, err := proxyproto.Read(c.r)
+	if err != nil && err != proxyproto.ErrNoSignature {
+		return nil, err
+	}
+
+	session := new(dispatch.SessionHeader)
+	if err := dispatch.ReadMessage(c.r, make([]byte, headerBufferSize), session); err != nil {
+		return nil, err
+	}
+	if err := session.Verify(sessionKey, maxSessionSkew); err != nil {
+		return nil, err
+	}
+	if !seenNonces.add(string(session.Nonce), time.Now()) {
+		return nil, errReplayedSession
+	}
+	conn.SetReadDeadline(time.Time{})
+
+	c.header = h
+	c.session = session
+	if h != nil && h.Source != nil {
+		c.remote = h.Source
+	}
+	return c, nil
+}
+
+func isTrusted(ip net.IP, trusted []*net.IPNet) bool {
+	for _, n := range trusted {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseTrusted parses a comma separated list of IP addresses and CIDR ranges.
+func parseTrusted(list string) ([]*net.IPNet, error) {
+	var nets []*net.IPNet
+	for _, s := range strings.Split(list, ",") {
+		s = strings.TrimSpace(s)
+		if s == "" {
+			continue
+		}
+		if !strings.Contains(s, "/") {
+			if ip := net.ParseIP(s); ip != nil && ip.To4() != nil {
+				s += "/32"
+			} else {
+				s += "/128"
+			}
+		}
+		_, n, err := net.ParseCIDR(s)
+		if err != nil {
+			return nil, err
+		}
+		nets = append(nets, n)
+	}
+	return nets, nil
+}
+
+// nonceCache remembers nonces for ttl after they were added.
+type nonceCache struct {
+	ttl time.Duration
+
+	mu    sync.Mutex
+	seen  map[string]time.Time
+	order []string // nonces in the order they were added
+}
+
+func newNonceCache(ttl time.Duration) *nonceCache {
+	return &nonceCache{ttl: ttl, seen: make(map[string]time.Time)}
+}
+
+// add records nonce and reports whether it was new. Empty nonces are never
+// new.
+func (c *nonceCache) add(nonce string, now time.Time) bool {
+	if nonce == "" {
+		return false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// nonces are added in order, so the expired ones are at the front
+	for len(c.order) > 0 && now.Sub(c.seen[c.order[0]]) >= c.ttl {
+		delete(c.seen, c.order[0])
+		c.order = c.order[1:]
+	}
+	if _, ok := c.seen[nonce]; ok {
+		return false
+	}
+	c.seen[nonce] = now
+	c.order = append(c.order, nonce)
+	return true
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"io/ioutil"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/Apurer/e2eechat/dispatch"
+	"github.com/Apurer/e2eechat/proxyproto"
+)
+
+// tcpPair returns both ends of a loopback TCP connection.
+func tcpPair(t *testing.T) (net.Conn, net.Conn) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	accepted := make(chan net.Conn, 1)
+	go func() {
+		c, _ := ln.Accept()
+		accepted <- c
+	}()
+	c, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := <-accepted
+	if s == nil {
+		t.Fatal("accept failed")
+	}
+	return c, s
+}
+
+func TestNewPeerConn(t *testing.T) {
+	pub, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, otherKey, _ := ed25519.GenerateKey(rand.Reader)
+	loopback, _ := parseTrusted("127.0.0.1")
+	client := &net.TCPAddr{IP: net.IPv4(192, 0, 2, 1).To4(), Port: 51000}
+
+	proxyHeader := func() []byte {
+		b, err := (&proxyproto.Header{
+			Source:      client,
+			Destination: &net.TCPAddr{IP: net.IPv4(192, 0, 2, 2).To4(), Port: 25500},
+		}).Marshal()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return b
+	}
+	session := func(key ed25519.PrivateKey) []byte {
+		h := &dispatch.SessionHeader{UserId: 1, DeviceId: 2, CorrelationId: "0123456789abcdef"}
+		if err := h.Sign(key); err != nil {
+			t.Fatal(err)
+		}
+		var b bytes.Buffer
+		if err := dispatch.WriteMessage(&b, h); err != nil {
+			t.Fatal(err)
+		}
+		return b.Bytes()
+	}
+	cat := func(parts ...[]byte) []byte { return bytes.Join(parts, nil) }
+	captured := session(key)
+
+	tests := []struct {
+		name       string
+		trusted    []*net.IPNet
+		sent       []byte
+		wantErr    error
+		wantRemote string // empty for the proxy's own address
+		wantUser   bool
+	}{
+		{"proxy header and session", loopback, cat(proxyHeader(), session(key)), nil, client.String(), true},
+		{"session only", loopback, session(key), nil, "", true},
+		{"session signed by another key", loopback, cat(proxyHeader(), session(otherKey)), dispatch.ErrBadSignature, "", false},
+		{"captured session", loopback, captured, nil, "", true},
+		{"replayed session", loopback, captured, errReplayedSession, "", false},
+		// headers from anyone else are client data
+		{"untrusted peer", nil, cat(proxyHeader(), session(key)), nil, "", false},
+	}
+	for _, tt := range tests {
+		c, s := tcpPair(t)
+		go func() {
+			c.Write(append(tt.sent, "data"...))
+			c.Close()
+		}()
+
+		p, err := newPeerConn(s, tt.trusted, pub)
+		if err != tt.wantErr {
+			t.Fatalf("%s: got %v, want %v", tt.name, err, tt.wantErr)
+		}
+		if err != nil {
+			s.Close()
+			continue
+		}
+
+		want := tt.wantRemote
+		if want == "" {
+			want = s.RemoteAddr().String()
+		}
+		if got := p.RemoteAddr().String(); got != want {
+			t.Errorf("%s: remote %s, want %s", tt.name, got, want)
+		}
+		if id, ok := p.UserID(); ok != tt.wantUser || ok && id != 1 {
+			t.Errorf("%s: user %d, %v", tt.name, id, ok)
+		}
+		rest, _ := ioutil.ReadAll(p)
+		if tt.wantUser && string(rest) != "data" {
+			t.Errorf("%s: %q left after the headers, want %q", tt.name, rest, "data")
+		}
+		if !tt.wantUser && !bytes.HasSuffix(rest, []byte("data")) {
+			t.Errorf("%s: client data lost", tt.name)
+		}
+		s.Close()
+	}
+}
+
+func TestNonceCache(t *testing.T) {
+	c := newNonceCache(time.Minute)
+	start := time.Now()
+
+	tests := []struct {
+		name  string
+		nonce string
+		after time.Duration
+		want  bool
+	}{
+		{"first", "a", 0, true},
+		{"other", "b", 10 * time.Second, true},
+		{"replayed", "a", 30 * time.Second, false},
+		{"empty", "", 30 * time.Second, false},
+		{"replayed after the ttl", "a", 61 * time.Second, true},
+		{"still remembered", "b", 65 * time.Second, false},
+	}
+	for _, tt := range tests {
+		if got := c.add(tt.nonce, start.Add(tt.after)); got != tt.want {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+		}
+	}
+	c.add("c", start.Add(3*time.Minute))
+	if len(c.seen) != 1 || len(c.order) != 1 {
+		t.Fatalf("%d nonces remembered after the ttl, want 1", len(c.seen))
+	}
+}
+
+func TestParseTrusted(t *testing.T) {
+	nets, err := parseTrusted(" 10.0.0.0/8, 192.0.2.7 ,2001:db8::1,")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		ip   string
+		want bool
+	}{
+		{"10.1.2.3", true},
+		{"192.0.2.7", true},
+		{"192.0.2.8", false},
+		{"2001:db8::1", true},
+		{"2001:db8::2", false},
+	}
+	for _, tt := range tests {
+		if got := isTrusted(net.ParseIP(tt.ip), nets); got != tt.want {
+			t.Errorf("isTrusted(%s) = %v, want %v", tt.ip, got, tt.want)
+		}
+	}
+	if _, err := parseTrusted("10.0.0.0/33"); err == nil {
+		t.Error("invalid range accepted")
+	}
+}
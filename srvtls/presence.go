@@ -0,0 +1,357 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/Apurer/e2eechat/dispatch"
+	"github.com/golang/protobuf/proto"
+)
+
+// Typing notifications and presence are ephemeral. srvtls passes them to the
+// devices connected at that moment and queues nothing. All it keeps is the
+// presence settings of each user, and in memory the time each user was last
+// connected, so users have no last-seen time after a restart until they
+// disconnect again.
+
+// maxSubscriptions bounds the users one connection can follow the presence
+// of.
+const maxSubscriptions = 1000
+
+var (
+	errBadPresence          = errors.New("invalid presence operation")
+	errTooManySubscriptions = errors.New("too many presence subscriptions")
+)
+
+type visibility = dispatch.PresenceSettings_Visibility
+
+type presenceSettings struct {
+	Presence visibility `json:"presence"`
+	LastSeen visibility `json:"last_seen"`
+	Allowed  []uint64   `json:"allowed,omitempty"`
+}
+
+func (p *presenceSettings) allowed(userID uint64) bool {
+	for _, id := range p.Allowed {
+		if id == userID {
+			return true
+		}
+	}
+	return false
+}
+
+type presenceFile struct {
+	Settings map[uint64]*presenceSettings `json:"settings"`
+}
+
+// presenceStore holds the presence settings of every user, persisted as a
+// JSON file, and the subscriptions of the connected devices.
+type presenceStore struct {
+	path string
+
+	mu       sync.Mutex
+	settings map[uint64]*presenceSettings
+	away     map[uint64]bool
+	lastSeen map[uint64]int64
+
+	// subscribers are the connections following a user, subscriptions the
+	// users a connection follows
+	subscribers   map[uint64]map[*client]bool
+	subscriptions map[*client]map[uint64]bool
+}
+
+func openPresence(path string) (*presenceStore, error) {
+	s := &presenceStore{
+		path:          path,
+		settings:      make(map[uint64]*presenceSettings),
+		away:          make(map[uint64]bool),
+		lastSeen:      make(map[uint64]int64),
+		subscribers:   make(map[uint64]map[*client]bool),
+		subscriptions: make(map[*client]map[uint64]bool),
+	}
+
+	b, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var f presenceFile
+	if err := json.Unmarshal(b, &f); err != nil {
+		return nil, err
+	}
+	for id, set := range f.Settings {
+		s.settings[id] = set
+	}
+	return s, nil
+}
+
+// visible reports whether viewer may see what v guards about userID. The
+// caller holds s.mu.
+func (s *presenceStore) visible(v visibility, set *presenceSettings, viewer, userID uint64) bool {
+	if viewer == userID {
+		return true
+	}
+	switch v {
+	case dispatch.PresenceSettings_EVERYONE:
+		return true
+	case dispatch.PresenceSettings_ALLOWED:
+		return set.allowed(viewer)
+	case dispatch.PresenceSettings_CHANNEL_MEMBERS:
+		return set.allowed(viewer) || channels.shareChannel(viewer, userID)
+	}
+	return false
+}
+
+// view is the presence of userID as viewer may see it. The caller holds
+// s.mu.
+func (s *presenceStore) view(viewer, userID uint64) *dispatch.Presence {
+	p := &dispatch.Presence{Op: dispatch.Presence_STATE, UserId: userID}
+	set := s.settings[userID]
+	if set == nil {
+		set = new(presenceSettings)
+	}
+	if !s.visible(set.Presence, set, viewer, userID) {
+		return p
+	}
+	switch {
+	case !relay.isOnline(userID):
+		if s.visible(set.LastSeen, set, viewer, userID) {
+			p.LastSeen = s.lastSeen[userID]
+		}
+	case s.away[userID]:
+		p.State = dispatch.Presence_AWAY
+	default:
+		p.State = dispatch.Presence_ONLINE
+	}
+	return p
+}
+
+// views returns what every subscriber of userID gets to see of it.
+func (s *presenceStore) views(userID uint64) map[*client]*dispatch.Presence {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	views := make(map[*client]*dispatch.Presence, len(s.subscribers[userID]))
+	for c := range s.subscribers[userID] {
+		views[c] = s.view(c.userID, userID)
+	}
+	return views
+}
+
+// broadcast tells the subscribers of userID about its presence.
+func (s *presenceStore) broadcast(userID uint64) {
+	for c, p := range s.views(userID) {
+		sendPresence(c, p)
+	}
+}
+
+// connected is called when the first device of a user connects.
+func (s *presenceStore) connected(userID uint64) {
+	s.broadcast(userID)
+}
+
+// disconnected is called when the last device of a user disconnects.
+func (s *presenceStore) disconnected(userID uint64) {
+	s.mu.Lock()
+	s.lastSeen[userID] = time.Now().Unix()
+	delete(s.away, userID)
+	s.mu.Unlock()
+
+	s.broadcast(userID)
+}
+
+// setAway marks the user as idle or back.
+func (s *presenceStore) setAway(userID uint64, away bool) {
+	s.mu.Lock()
+	changed := s.away[userID] != away
+	if away {
+		s.away[userID] = true
+	} else {
+		delete(s.away, userID)
+	}
+	s.mu.Unlock()
+
+	if changed {
+		s.broadcast(userID)
+	}
+}
+
+// setSettings replaces the settings of the user. Subscribers are told the
+// presence they may see from now on, OFFLINE for those who lost sight of it.
+func (s *presenceStore) setSettings(userID uint64, settings *dispatch.PresenceSettings) error {
+	if settings == nil {
+		return errBadPresence
+	}
+	for _, v := range []visibility{settings.Presence, settings.LastSeen} {
+		if _, ok := dispatch.PresenceSettings_Visibility_name[int32(v)]; !ok {
+			return errBadPresence
+		}
+	}
+	set := &presenceSettings{
+		Presence: settings.Presence,
+		LastSeen: settings.LastSeen,
+		Allowed:  settings.Allowed,
+	}
+
+	s.mu.Lock()
+	old := s.settings[userID]
+	s.settings[userID] = set
+	if err := s.save(); err != nil {
+		if old == nil {
+			delete(s.settings, userID)
+		} else {
+			s.settings[userID] = old
+		}
+		s.mu.Unlock()
+		return err
+	}
+	s.mu.Unlock()
+
+	s.broadcast(userID)
+	return nil
+}
+
+// subscribe makes c follow the presence of userID and sends it the current
+// one.
+func (s *presenceStore) subscribe(c *client, userID uint64) error {
+	if userID == 0 {
+		return errBadPresence
+	}
+	s.mu.Lock()
+	subs := s.subscriptions[c]
+	if subs == nil {
+		subs = make(map[uint64]bool)
+		s.subscriptions[c] = subs
+	}
+	if !subs[userID] && len(subs) >= maxSubscriptions {
+		s.mu.Unlock()
+		return errTooManySubscriptions
+	}
+	subs[userID] = true
+	if s.subscribers[userID] == nil {
+		s.subscribers[userID] = make(map[*client]bool)
+	}
+	s.subscribers[userID][c] = true
+	p := s.view(c.userID, userID)
+	s.mu.Unlock()
+
+	sendPresence(c, p)
+	return nil
+}
+
+// unsubscribe stops c from following the presence of userID.
+func (s *presenceStore) unsubscribe(c *client, userID uint64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.subscriptions[c], userID)
+	if len(s.subscriptions[c]) == 0 {
+		delete(s.subscriptions, c)
+	}
+	delete(s.subscribers[userID], c)
+	if len(s.subscribers[userID]) == 0 {
+		delete(s.subscribers, userID)
+	}
+}
+
+// drop removes every subscription of a closed connection.
+func (s *presenceStore) drop(c *client) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for userID := range s.subscriptions[c] {
+		delete(s.subscribers[userID], c)
+		if len(s.subscribers[userID]) == 0 {
+			delete(s.subscribers, userID)
+		}
+	}
+	delete(s.subscriptions, c)
+}
+
+// save writes the settings to the file of the store. The caller holds s.mu.
+func (s *presenceStore) save() error {
+	return writeJSON(s.path, &presenceFile{Settings: s.settings})
+}
+
+func sendPresence(c *client, p *dispatch.Presence) {
+	b, err := proto.Marshal(p)
+	if err != nil {
+		c.l.Error("encoding presence", "error", err)
+		return
+	}
+	a := &dispatch.Action{
+		Type:              dispatch.Action_PRESENCE,
+		Payload:           b,
+		RecipientId:       c.userID,
+		RecipientDeviceId: c.deviceID,
+	}
+	if err := c.send(a); err != nil {
+		c.l.Debug("sending presence", "error", err)
+	}
+}
+
+// handlePresence applies the Presence carried by a.
+func handlePresence(c *client, a *dispatch.Action) {
+	p := new(dispatch.Presence)
+	if err := proto.Unmarshal(a.Payload, p); err != nil {
+		c.l.Info("invalid presence", "error", err)
+		c.report(errInvalidMessage, a.MessageId)
+		return
+	}
+
+	var err error
+	switch p.Op {
+	case dispatch.Presence_STATE:
+		if p.State != dispatch.Presence_ONLINE && p.State != dispatch.Presence_AWAY {
+			err = errBadPresence
+			break
+		}
+		presence.setAway(c.userID, p.State == dispatch.Presence_AWAY)
+	case dispatch.Presence_SUBSCRIBE:
+		err = presence.subscribe(c, p.UserId)
+	case dispatch.Presence_UNSUBSCRIBE:
+		presence.unsubscribe(c, p.UserId)
+	case dispatch.Presence_SETTINGS:
+		err = presence.setSettings(c.userID, p.Settings)
+		if err == nil {
+			c.l.Info("presence settings changed", "presence", p.Settings.Presence, "last_seen", p.Settings.LastSeen)
+		}
+	default:
+		err = errBadPresence
+	}
+	if err != nil {
+		c.l.Info("presence refused", "op", p.Op, "error", err)
+		c.report(err, a.MessageId)
+	}
+}
+
+// handleTyping relays a typing notification to the connected devices of its
+// recipient, or of every member of its channel.
+func handleTyping(c *client, a *dispatch.Action) {
+	if err := proto.Unmarshal(a.Payload, new(dispatch.Typing)); err != nil {
+		c.l.Info("invalid typing notification", "error", err)
+		c.report(errInvalidMessage, a.MessageId)
+		return
+	}
+
+	users := []uint64{a.RecipientId}
+	if a.ChannelId != 0 {
+		members, err := channels.members(a.ChannelId, c.userID)
+		if err != nil {
+			c.l.Debug("typing notification refused", "channel_id", a.ChannelId, "error", err)
+			c.report(err, a.MessageId)
+			return
+		}
+		users = members
+	}
+	if err := relay.relayLive(c.deviceKey, a, users); err != nil {
+		c.l.Debug("typing notification not delivered", "recipient_id", a.RecipientId, "error", err)
+		c.report(err, a.MessageId)
+	}
+}
@@ -0,0 +1,287 @@
+package main
+
+import (
+	"bytes"
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Apurer/e2eechat/devices"
+	"github.com/Apurer/e2eechat/dispatch"
+	"github.com/golang/protobuf/proto"
+)
+
+// newTestHub returns a hub with the given number of devices registered for
+// users 1, 2 and so on.
+func newTestHub(t *testing.T, perUser ...int) (*hub, [][]*devices.Device) {
+	store, err := devices.Open(filepath.Join(t.TempDir(), "devices.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var all [][]*devices.Device
+	for i, n := range perUser {
+		var ds []*devices.Device
+		for j := 0; j < n; j++ {
+			d, err := store.Register(uint64(i+1), "test", bytes.Repeat([]byte{byte(j + 1)}, 32), nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			ds = append(ds, d)
+		}
+		all = append(all, ds)
+	}
+	return newHub(store), all
+}
+
+func keyOf(d *devices.Device) deviceKey {
+	return deviceKey{userID: d.UserID, deviceID: d.ID}
+}
+
+// connect registers a connection for d with the given capabilities and
+// returns it with the other end of it.
+func connect(t *testing.T, h *hub, d *devices.Device, caps ...dispatch.Capability) (*client, net.Conn) {
+	remote, conn := tcpPair(t)
+	c := &client{deviceKey: keyOf(d), conn: conn, l: logger, capabilities: caps}
+	h.register(c)
+	return c, remote
+}
+
+// receive reads the next action written to a device.
+func receive(t *testing.T, conn net.Conn) *dispatch.Action {
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	a, err := readAction(conn, make([]byte, 64*1024))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return a
+}
+
+// silent reports whether nothing arrives on conn for a short while.
+func silent(conn net.Conn) bool {
+	conn.SetReadDeadline(time.Now().Add(50 * time.Millisecond))
+	_, err := conn.Read(make([]byte, 1))
+	ne, ok := err.(net.Error)
+	return ok && ne.Timeout()
+}
+
+// usePresence points the relay, channel and presence globals at fresh
+// stores for the duration of a test.
+func usePresence(t *testing.T, perUser ...int) (*hub, [][]*devices.Device) {
+	h, ds := newTestHub(t, perUser...)
+	ch, err := openChannels(filepath.Join(t.TempDir(), "channels.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := openPresence(filepath.Join(t.TempDir(), "presence.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	oldRelay, oldChannels, oldPresence := relay, channels, presence
+	relay, channels, presence = h, ch, p
+	t.Cleanup(func() { relay, channels, presence = oldRelay, oldChannels, oldPresence })
+	return h, ds
+}
+
+func TestPresenceVisibility(t *testing.T) {
+	h, ds := usePresence(t, 1, 1, 1, 1)
+	const subject, allowed, member, stranger = 1, 2, 3, 4
+	if _, _, err := channels.apply(subject, &dispatch.ChannelOp{Op: dispatch.ChannelOp_CREATE}); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := channels.apply(subject, &dispatch.ChannelOp{Op: dispatch.ChannelOp_INVITE, ChannelId: 1, UserId: member}); err != nil {
+		t.Fatal(err)
+	}
+
+	c, remote := connect(t, h, ds[0][0])
+	defer remote.Close()
+
+	type view struct {
+		state    dispatch.Presence_State
+		lastSeen bool
+	}
+	tests := []struct {
+		name     string
+		presence dispatch.PresenceSettings_Visibility
+		lastSeen dispatch.PresenceSettings_Visibility
+		// what allowed, member, stranger and the subject itself see while
+		// the subject is away and after it disconnected
+		away    [4]view
+		offline [4]view
+	}{
+		{
+			name:     "defaults",
+			presence: dispatch.PresenceSettings_CHANNEL_MEMBERS, lastSeen: dispatch.PresenceSettings_CHANNEL_MEMBERS,
+			away:    [4]view{{dispatch.Presence_AWAY, false}, {dispatch.Presence_AWAY, false}, {}, {dispatch.Presence_AWAY, false}},
+			offline: [4]view{{0, true}, {0, true}, {}, {0, true}},
+		},
+		{
+			name:     "everyone, last seen hidden",
+			presence: dispatch.PresenceSettings_EVERYONE, lastSeen: dispatch.PresenceSettings_NOBODY,
+			away:    [4]view{{dispatch.Presence_AWAY, false}, {dispatch.Presence_AWAY, false}, {dispatch.Presence_AWAY, false}, {dispatch.Presence_AWAY, false}},
+			offline: [4]view{{}, {}, {}, {0, true}},
+		},
+		{
+			name:     "allowed only",
+			presence: dispatch.PresenceSettings_ALLOWED, lastSeen: dispatch.PresenceSettings_ALLOWED,
+			away:    [4]view{{dispatch.Presence_AWAY, false}, {}, {}, {dispatch.Presence_AWAY, false}},
+			offline: [4]view{{0, true}, {}, {}, {0, true}},
+		},
+		{
+			name:     "nobody",
+			presence: dispatch.PresenceSettings_NOBODY, lastSeen: dispatch.PresenceSettings_EVERYONE,
+			away:    [4]view{{}, {}, {}, {dispatch.Presence_AWAY, false}},
+			offline: [4]view{{}, {}, {}, {0, true}},
+		},
+	}
+	viewers := []uint64{allowed, member, stranger, subject}
+	check := func(name, when string, want [4]view) {
+		presence.mu.Lock()
+		defer presence.mu.Unlock()
+		for i, viewer := range viewers {
+			p := presence.view(viewer, subject)
+			if got := (view{p.State, p.LastSeen != 0}); got != want[i] {
+				t.Errorf("%s, %s: user %d sees %v, want %v", name, when, viewer, got, want[i])
+			}
+		}
+	}
+	for _, tt := range tests {
+		err := presence.setSettings(subject, &dispatch.PresenceSettings{
+			Presence: tt.presence, LastSeen: tt.lastSeen, Allowed: []uint64{allowed},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		h.register(c)
+		presence.setAway(subject, true)
+		check(tt.name, "away", tt.away)
+		h.unregister(c)
+		presence.disconnected(subject)
+		check(tt.name, "offline", tt.offline)
+	}
+
+	// settings survive a restart, unknown visibilities are refused
+	reopened, err := openPresence(presence.path)
+	if err != nil || reopened.settings[subject].Presence != dispatch.PresenceSettings_NOBODY {
+		t.Fatalf("settings after reopening: %+v, %v", reopened.settings[subject], err)
+	}
+	if err := presence.setSettings(subject, &dispatch.PresenceSettings{Presence: 9}); err != errBadPresence {
+		t.Fatalf("unknown visibility: got %v", err)
+	}
+}
+
+func TestPresenceSubscription(t *testing.T) {
+	h, ds := usePresence(t, 1, 1)
+	watcher, remote := connect(t, h, ds[1][0], dispatch.Capability_PRESENCE)
+	defer remote.Close()
+	if err := presence.setSettings(1, &dispatch.PresenceSettings{Presence: dispatch.PresenceSettings_EVERYONE}); err != nil {
+		t.Fatal(err)
+	}
+
+	receivePresence := func(when string) *dispatch.Presence {
+		a := receive(t, remote)
+		p := new(dispatch.Presence)
+		if a.Type != dispatch.Action_PRESENCE || proto.Unmarshal(a.Payload, p) != nil {
+			t.Fatalf("%s: got action %v", when, a.Type)
+		}
+		return p
+	}
+
+	if err := presence.subscribe(watcher, 1); err != nil {
+		t.Fatal(err)
+	}
+	if p := receivePresence("subscribed"); p.UserId != 1 || p.State != dispatch.Presence_OFFLINE {
+		t.Fatalf("on subscribing: %v", p)
+	}
+	_, other := connect(t, h, ds[0][0])
+	defer other.Close()
+	presence.connected(1)
+	if p := receivePresence("connected"); p.State != dispatch.Presence_ONLINE {
+		t.Fatalf("after connecting: %v", p)
+	}
+	presence.setAway(1, true)
+	if p := receivePresence("away"); p.State != dispatch.Presence_AWAY {
+		t.Fatalf("after going away: %v", p)
+	}
+	// nothing changed, nothing sent
+	presence.setAway(1, true)
+	presence.unsubscribe(watcher, 1)
+	presence.setAway(1, false)
+	if !silent(remote) {
+		t.Fatal("presence sent without a change or after unsubscribing")
+	}
+	if err := presence.subscribe(watcher, 0); err != errBadPresence {
+		t.Fatalf("subscribing to user 0: got %v", err)
+	}
+
+	presence.subscribe(watcher, 1)
+	receivePresence("subscribed again")
+	presence.drop(watcher)
+	if len(presence.subscribers) != 0 || len(presence.subscriptions) != 0 {
+		t.Fatalf("subscriptions left after drop: %v, %v", presence.subscribers, presence.subscriptions)
+	}
+}
+
+func TestTyping(t *testing.T) {
+	h, ds := usePresence(t, 1, 2, 1)
+	typing, err := proto.Marshal(&dispatch.Typing{State: dispatch.Typing_STARTED})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sender, senderRemote := connect(t, h, ds[0][0], dispatch.Capability_ERROR_REPORTS, dispatch.Capability_PRESENCE)
+	defer senderRemote.Close()
+	sender.correlationID = "0123456789abcdef"
+	_, online := connect(t, h, ds[1][0], dispatch.Capability_PRESENCE)
+	defer online.Close()
+	_, old := connect(t, h, ds[2][0])
+	defer old.Close()
+
+	handleTyping(sender, &dispatch.Action{Type: dispatch.Action_TYPING, RecipientId: 2, Payload: typing})
+	if a := receive(t, online); a.Type != dispatch.Action_TYPING || a.SenderId != 1 || !bytes.Equal(a.Payload, typing) {
+		t.Fatalf("connected device got %v from %d", a.Type, a.SenderId)
+	}
+	// typing is never queued for devices that are not connected
+	if q := h.queues[keyOf(ds[1][1])]; len(q) != 0 {
+		t.Fatalf("typing queued: %d actions", len(q))
+	}
+
+	// devices without PRESENCE are skipped, channels reach every member
+	if _, _, err := channels.apply(1, &dispatch.ChannelOp{Op: dispatch.ChannelOp_CREATE}); err != nil {
+		t.Fatal(err)
+	}
+	for _, u := range []uint64{2, 3} {
+		if _, _, err := channels.apply(1, &dispatch.ChannelOp{Op: dispatch.ChannelOp_INVITE, ChannelId: 1, UserId: u}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	handleTyping(sender, &dispatch.Action{Type: dispatch.Action_TYPING, ChannelId: 1, Payload: typing})
+	if a := receive(t, online); a.ChannelId != 1 {
+		t.Fatalf("channel typing arrived for channel %d", a.ChannelId)
+	}
+	if !silent(old) || !silent(senderRemote) {
+		t.Fatal("typing sent to a device without PRESENCE or back to the sender")
+	}
+	if _, _, err := channels.apply(3, &dispatch.ChannelOp{Op: dispatch.ChannelOp_CREATE}); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		a    *dispatch.Action
+		want dispatch.Error_Code
+	}{
+		{"not a member", &dispatch.Action{ChannelId: 2, Payload: typing, MessageId: 1}, dispatch.Error_FORBIDDEN},
+		{"unknown channel", &dispatch.Action{ChannelId: 9, Payload: typing, MessageId: 4}, dispatch.Error_NOT_FOUND},
+		{"unknown recipient", &dispatch.Action{RecipientId: 9, Payload: typing, MessageId: 2}, dispatch.Error_UNKNOWN_RECIPIENT},
+		{"invalid payload", &dispatch.Action{RecipientId: 2, Payload: []byte{0xff}, MessageId: 3}, dispatch.Error_INVALID_MESSAGE},
+	}
+	for _, tt := range tests {
+		tt.a.Type = dispatch.Action_TYPING
+		handleTyping(sender, tt.a)
+		a := receive(t, senderRemote)
+		e := new(dispatch.Error)
+		if a.Type != dispatch.Action_ERROR || proto.Unmarshal(a.Payload, e) != nil || e.Code != tt.want || e.MessageId != tt.a.MessageId {
+			t.Errorf("%s: got %v %v, want %v", tt.name, a.Type, e.Code, tt.want)
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"errors"
+	"io"
+
+	"github.com/Apurer/e2eechat/dispatch"
+)
+
+var (
+	errNotSealed     = errors.New("anonymous connections may only send sealed actions")
+	errAccessDenied  = errors.New("missing or wrong access key")
+	errMissingCopies = errors.New("sealed actions need one envelope per device")
+)
+
+// serveSealed reads the actions of an anonymous connection. Its sender is
+// unknown to srvtls, so it is not registered with the hub and receives
+// nothing but errors, and each action has to carry the access key of its
+// recipient. Any other action, or a wrong access key, ends the connection,
+// so that guessing keys costs a new session and its rate limits at the
+// proxy.
+func serveSealed(c *client, r io.Reader) {
+	l := c.l
+	l.Info("sealed sender connection opened")
+	buf := make([]byte, frameBufferSize)
+	for {
+		action, err := readAction(r, buf)
+		if err == errInvalidMessage {
+			l.Info("invalid action", "error", err)
+			c.report(err, 0)
+			continue
+		}
+		if err != nil {
+			if err != io.EOF {
+				l.Debug("connection ended with error", "error", err)
+			}
+			break
+		}
+		if action.Type != dispatch.Action_SEALED {
+			l.Info("connection closed", "error", errNotSealed)
+			c.report(errNotSealed, action.MessageId)
+			return
+		}
+		missing, err := sendSealed(action)
+		if err == errAccessDenied {
+			l.Info("connection closed", "recipient_id", action.RecipientId, "error", err)
+			c.report(err, action.MessageId)
+			return
+		}
+		if err != nil {
+			l.Info("sealed action not delivered", "recipient_id", action.RecipientId, "error", err)
+			c.report(err, action.MessageId)
+			continue
+		}
+		if len(missing) > 0 {
+			l.Info("sealed action lacks envelopes for some devices", "recipient_id", action.RecipientId, "device_ids", missing)
+			c.reportMissing(missing, action.MessageId)
+		}
+	}
+	l.Info("connection closed")
+}
+
+// sendSealed delivers the envelopes of a to the devices of its recipient
+// without any sender fields, and returns the devices it had none for.
+func sendSealed(a *dispatch.Action) ([]uint64, error) {
+	if a.ChannelId != 0 || len(a.Copies) == 0 {
+		return nil, errMissingCopies
+	}
+	if !relay.store.CheckAccess(a.RecipientId, a.AccessKey) {
+		return nil, errAccessDenied
+	}
+	// nothing outside the envelopes may point back at the sender
+	a.Publickey = nil
+	a.Hash = nil
+	a.AccessKey = nil
+
+	return relay.fanout(deviceKey{}, a, []uint64{a.RecipientId})
+}
@@ -1,5 +1,316 @@
 package main
 
+import (
+	"crypto/ed25519"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+
+	"github.com/Apurer/e2eechat/devices"
+	"github.com/Apurer/e2eechat/dispatch"
+	"github.com/Apurer/e2eechat/logging"
+	"github.com/Apurer/eev"
+	"github.com/Apurer/eev/privatekey"
+	"github.com/golang/protobuf/proto"
+)
+
+type localAddr struct {
+	port string
+}
+
+var lclAddr localAddr
+
+var logger = logging.FromEnv("srvtls")
+
+// trustedProxies lists the addresses allowed to send a PROXY protocol header.
+var trustedProxies []*net.IPNet
+
+// sessionKey verifies the session headers signed by tls2tlsproxy.
+var sessionKey ed25519.PublicKey
+
+// relay delivers actions between the connected devices.
+var relay *hub
+
+// channels holds the membership of the group channels.
+var channels *channelStore
+
+// presence holds the presence settings and subscriptions of the users.
+var presence *presenceStore
+
+// frameBufferSize fits the largest action a client may send, it matches the
+// relay buffers of tls2tlsproxy.
+const frameBufferSize = 32 * 1024
+
+// minVersion is the oldest protocol version srvtls still serves. The proxy
+// refuses older clients itself, this catches a misconfigured one.
+const minVersion = dispatch.Version1
+
+// loadConfig reads the settings encrypted with the key at KEY_PATH. The key
+// path and its passphrase are removed from the environment once read.
+func loadConfig() error {
+	keypath := os.Getenv("KEY_PATH")
+	if err := os.Unsetenv("KEY_PATH"); err != nil {
+		return err
+	}
+	passphrase := os.Getenv("PASSPHRASE")
+	if err := os.Unsetenv("PASSPHRASE"); err != nil {
+		return err
+	}
+
+	privkey, err := privatekey.Read(keypath, passphrase)
+	if err != nil {
+		return fmt.Errorf("%s: %v", keypath, err)
+	}
+
+	port, err := eev.Get("TLS_SERVER_PORT", privkey)
+	if err != nil {
+		return err
+	}
+
+	proxies, err := eev.Get("TRUSTED_PROXIES", privkey)
+	if err != nil {
+		return err
+	}
+
+	trustedProxies, err = parseTrusted(proxies)
+	if err != nil {
+		return fmt.Errorf("TRUSTED_PROXIES: %v", err)
+	}
+
+	lclAddr.port = port
+	return nil
+}
+
 func main() {
+	if err := loadConfig(); err != nil {
+		logger.Error("reading configuration", "error", err)
+		return
+	}
+
+	cer, err := tls.LoadX509KeyPair("srvtls.crt", "srvtls.key")
+	if err != nil {
+		logger.Error("loading certificate", "error", err)
+		return
+	}
+
+	sessionKey, err = loadSessionKey("session.pub")
+	if err != nil {
+		logger.Error("loading session key", "error", err)
+		return
+	}
+
+	store, err := devices.Open("devices.json")
+	if err != nil {
+		logger.Error("opening device store", "error", err)
+		return
+	}
+	relay = newHub(store)
+	go relay.watch(deviceReload)
+
+	channels, err = openChannels("channels.json")
+	if err != nil {
+		logger.Error("opening channel store", "error", err)
+		return
+	}
+
+	presence, err = openPresence("presence.json")
+	if err != nil {
+		logger.Error("opening presence store", "error", err)
+		return
+	}
+
+	config := &tls.Config{Certificates: []tls.Certificate{cer}}
+	ln, err := tls.Listen("tcp", ":"+lclAddr.port, config)
+	if err != nil {
+		logger.Error("listen", "error", err)
+		return
+	}
+	defer ln.Close()
+
+	logger.Info("listening", "addr", ln.Addr())
+
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			logger.Warn("accept", "error", err)
+			continue
+		}
+		go handleConn(conn)
+	}
+}
+
+func handleConn(conn net.Conn) {
+	defer conn.Close()
+
+	peer, err := newPeerConn(conn, trustedProxies, sessionKey)
+	if err != nil {
+		logger.Warn("reading proxy headers", "remote", conn.RemoteAddr(), "error", err)
+		return
+	}
+
+	l := logger.With(
+		logging.CorrelationKey, peer.CorrelationID(),
+		"remote", peer.RemoteAddr(),
+	)
+	// only clients relayed by the proxy carry a verified identity
+	userID, ok := peer.UserID()
+	if !ok {
+		l.Info("connection refused, not relayed by a trusted proxy")
+		return
+	}
+	if v := peer.Version(); v < minVersion || v > dispatch.CurrentVersion {
+		l.Info("connection refused, unsupported protocol version", "version", v)
+		refuse(conn, peer, dispatch.Error_UNSUPPORTED, fmt.Sprintf("protocol version %d not supported", v))
+		return
+	}
+	if userID == 0 {
+		// sealed sender session, the proxy vouches for no one
+		serveSealed(&client{
+			conn:          conn,
+			l:             l,
+			capabilities:  peer.Capabilities(),
+			correlationID: peer.CorrelationID(),
+		}, peer)
+		return
+	}
+	deviceID, _ := peer.DeviceID()
+	l = l.With("user_id", userID, "device_id", deviceID)
+
+	if !isActiveDevice(userID, deviceID) {
+		l.Info("connection refused, no active device")
+		refuse(conn, peer, dispatch.Error_UNAUTHENTICATED, "device is not registered or was revoked")
+		return
+	}
+
+	c := &client{
+		deviceKey:     deviceKey{userID: userID, deviceID: deviceID},
+		conn:          conn,
+		l:             l,
+		capabilities:  peer.Capabilities(),
+		correlationID: peer.CorrelationID(),
+	}
+	l.Info("connection opened")
+	if relay.register(c) {
+		presence.connected(userID)
+	}
+	defer func() {
+		presence.drop(c)
+		if relay.unregister(c) {
+			presence.disconnected(userID)
+		}
+	}()
+
+	buf := make([]byte, frameBufferSize)
+	for {
+		action, err := readAction(peer, buf)
+		if err == errInvalidMessage {
+			l.Info("invalid action", "error", err)
+			c.report(err, 0)
+			continue
+		}
+		if err != nil {
+			if err != io.EOF {
+				l.Debug("connection ended with error", "error", err)
+			}
+			break
+		}
+
+		switch {
+		case action.Type == dispatch.Action_ERROR, action.Type == dispatch.Action_GOAWAY, action.Type == dispatch.Action_THROTTLE:
+			// only ever sent to clients
+			c.report(errUnsupportedAction, action.MessageId)
+		case action.Type == dispatch.Action_CHANNEL:
+			handleChannelOp(c, action)
+		case action.Type == dispatch.Action_MLS:
+			handleMLS(c, action)
+		case action.Type == dispatch.Action_TYPING:
+			handleTyping(c, action)
+		case action.Type == dispatch.Action_PRESENCE:
+			handlePresence(c, action)
+		case action.Type == dispatch.Action_SEALED:
+			// accepted from identified devices too, but the sender
+			// fields stay empty all the same
+			if missing, err := sendSealed(action); err != nil {
+				l.Info("sealed action not delivered", "recipient_id", action.RecipientId, "error", err)
+				c.report(err, action.MessageId)
+			} else if len(missing) > 0 {
+				l.Info("sealed action lacks envelopes for some devices", "recipient_id", action.RecipientId, "device_ids", missing)
+				c.reportMissing(missing, action.MessageId)
+			}
+		case action.ChannelId != 0:
+			postToChannel(c, action)
+		default:
+			sendDirect(c, action)
+		}
+	}
+	l.Info("connection closed")
+}
+
+// readAction reads the next action. A frame that is not an action is
+// reported as errInvalidMessage, the stream itself is still intact then.
+func readAction(r io.Reader, buf []byte) (*dispatch.Action, error) {
+	b, err := dispatch.ReadFrame(r, buf)
+	if err != nil {
+		return nil, err
+	}
+	action := new(dispatch.Action)
+	if err := proto.Unmarshal(b, action); err != nil {
+		return nil, errInvalidMessage
+	}
+	return action, nil
+}
+
+// sendDirect delivers a to the devices of its recipient.
+func sendDirect(c *client, a *dispatch.Action) {
+	missing, err := relay.deliver(c.deviceKey, a)
+	if err != nil {
+		c.l.Info("action not delivered", "recipient_id", a.RecipientId, "error", err)
+		c.report(err, a.MessageId)
+		return
+	}
+	if len(missing) > 0 {
+		// the sender encrypted for an outdated device list
+		c.l.Info("action lacks copies for some devices", "recipient_id", a.RecipientId, "device_ids", missing)
+		c.reportMissing(missing, a.MessageId)
+	}
+}
+
+// isActiveDevice reports whether the device is registered and not revoked,
+// rereading the registry once in case it was registered a moment ago.
+func isActiveDevice(userID, deviceID uint64) bool {
+	if userID == 0 || deviceID == 0 {
+		return false
+	}
+	if relay.store.IsActive(userID, deviceID) {
+		return true
+	}
+	if changed, err := relay.store.Reload(); err != nil || !changed {
+		return false
+	}
+	return relay.store.IsActive(userID, deviceID)
+}
 
+func loadSessionKey(path string) (ed25519.PublicKey, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(b)
+	if block == nil {
+		return nil, fmt.Errorf("%s: no PEM data", path)
+	}
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	pub, ok := key.(ed25519.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("%s: not an ed25519 key", path)
+	}
+	return pub, nil
 }
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
+	"io"
+	"net"
+
+	"github.com/Apurer/e2eechat/devices"
+	"github.com/Apurer/e2eechat/dispatch"
+)
+
+// challengeSize is the size of the nonce a device signs to prove that it
+// holds its identity key.
+const challengeSize = 32
+
+var (
+	errNoDeviceID     = errors.New("authentication without device id")
+	errUnknownDevice  = errors.New("device is not registered or was revoked")
+	errNoDeviceProof  = errors.New("envelope without device proof")
+	errBadDeviceProof = errors.New("invalid device proof")
+)
+
+// deviceStore is the registry srvhttps writes, with the identity keys that
+// devices prove themselves with.
+var deviceStore *devices.Store
+
+// proveDevice sends the client a fresh nonce and checks that it comes back
+// signed by the identity key of the device in id, which has to be active. A
+// login token is issued to a user, this ties the session to one device.
+func proveDevice(conn net.Conn, buf []byte, id *identity) (dispatch.AuthResult_Status, error) {
+	d := activeDevice(id.userID, id.deviceID)
+	if d == nil {
+		authFailures.add("unknown_device", 1)
+		return dispatch.AuthResult_REJECTED, errUnknownDevice
+	}
+
+	nonce := make([]byte, challengeSize)
+	if _, err := rand.Read(nonce); err != nil {
+		return dispatch.AuthResult_UNAVAILABLE, err
+	}
+	challenge := &dispatch.AuthChallenge{Nonce: nonce}
+	var err error
+	if id.enveloped {
+		err = dispatch.WriteMessage(conn, &dispatch.Envelope{Version: id.version, Challenge: challenge})
+	} else {
+		err = dispatch.WriteMessage(conn, challenge)
+	}
+	if err != nil {
+		authFailures.add("read_error", 1)
+		return dispatch.AuthResult_INVALID, err
+	}
+
+	proof := new(dispatch.DeviceProof)
+	if id.enveloped {
+		var env dispatch.Envelope
+		if err := dispatch.ReadMessage(conn, buf, &env); err != nil {
+			return readFailure(err)
+		}
+		if env.DeviceProof == nil {
+			authFailures.add("invalid_message", 1)
+			return dispatch.AuthResult_INVALID, errNoDeviceProof
+		}
+		proof = env.DeviceProof
+	} else if err := dispatch.ReadMessage(conn, buf, proof); err != nil {
+		return readFailure(err)
+	}
+
+	if !ed25519.Verify(d.IdentityKey, dispatch.DeviceProofBytes(nonce, id.userID, id.deviceID), proof.Signature) {
+		authFailures.add("bad_device_proof", 1)
+		return dispatch.AuthResult_REJECTED, errBadDeviceProof
+	}
+	return dispatch.AuthResult_OK, nil
+}
+
+// activeDevice returns the device if it is registered and not revoked. The
+// registry is reread first if it changed, so that a revocation takes effect
+// with the next login.
+func activeDevice(userID, deviceID uint64) *devices.Device {
+	if _, err := deviceStore.Reload(); err != nil {
+		logger.Warn("reloading devices", "error", err)
+	}
+	d, err := deviceStore.Get(userID, deviceID)
+	if err != nil || !d.Active() {
+		return nil
+	}
+	return d
+}
+
+// readFailure maps an error reading an authentication message to the status
+// reported to the client.
+func readFailure(err error) (dispatch.AuthResult_Status, error) {
+	switch {
+	case isTimeout(err):
+		authFailures.add("timeout", 1)
+		return dispatch.AuthResult_TIMEOUT, err
+	case err == io.EOF || err == io.ErrUnexpectedEOF:
+		authFailures.add("read_error", 1)
+		return dispatch.AuthResult_INVALID, errAuthIncomplete
+	}
+	authFailures.add("invalid_message", 1)
+	return dispatch.AuthResult_INVALID, err
+}
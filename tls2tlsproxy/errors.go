@@ -0,0 +1,23 @@
+package main
+
+import (
+	"net"
+
+	"github.com/Apurer/e2eechat/dispatch"
+)
+
+// reportError tells an authenticated client why its session is about to
+// end, if it negotiated ERROR_REPORTS. Errors about single actions come from
+// srvtls, the proxy only reports on the session.
+func reportError(client net.Conn, id identity, code dispatch.Error_Code, detail string) error {
+	if !dispatch.HasCapability(id.capabilities, dispatch.Capability_ERROR_REPORTS) {
+		return nil
+	}
+	e := dispatch.NewError(code, detail, 0)
+	e.CorrelationId = id.correlationID
+	a, err := e.Action()
+	if err != nil {
+		return err
+	}
+	return dispatch.WriteMessage(client, a)
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"net"
+	"sync"
+)
+
+// connLimiter caps the number of concurrently proxied connections, both in
+// total and per remote IP, so a single client cannot exhaust goroutines and
+// buffers.
+type connLimiter struct {
+	mu       sync.Mutex
+	maxTotal int
+	maxPerIP int
+	total    int
+	perIP    map[string]int
+}
+
+func newConnLimiter(maxTotal, maxPerIP int) *connLimiter {
+	return &connLimiter{
+		maxTotal: maxTotal,
+		maxPerIP: maxPerIP,
+		perIP:    make(map[string]int),
+	}
+}
+
+// acquire reserves a slot for ip. It returns the rejection reason, or an
+// empty string when the connection may proceed. A zero limit means unlimited.
+func (l *connLimiter) acquire(ip string) string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.maxTotal > 0 && l.total >= l.maxTotal {
+		return "max_conns"
+	}
+	if l.maxPerIP > 0 && l.perIP[ip] >= l.maxPerIP {
+		return "max_conns_per_ip"
+	}
+	l.total++
+	l.perIP[ip]++
+	return ""
+}
+
+func (l *connLimiter) release(ip string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.total--
+	if l.perIP[ip] <= 1 {
+		delete(l.perIP, ip)
+		return
+	}
+	l.perIP[ip]--
+}
+
+func remoteIP(conn net.Conn) string {
+	host, _, err := net.SplitHostPort(conn.RemoteAddr().String())
+	if err != nil {
+		return conn.RemoteAddr().String()
+	}
+	return host
+}
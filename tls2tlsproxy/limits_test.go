@@ -0,0 +1,42 @@
+package main
+
+import "testing"
+
+func TestConnLimiter(t *testing.T) {
+	l := newConnLimiter(3, 2)
+	tests := []struct {
+		name    string
+		release string // released before acquiring
+		ip      string
+		want    string
+	}{
+		{"first", "", "192.0.2.1", ""},
+		{"second from the same ip", "", "192.0.2.1", ""},
+		{"third from the same ip", "", "192.0.2.1", "max_conns_per_ip"},
+		{"other ip", "", "192.0.2.2", ""},
+		{"total reached", "", "192.0.2.3", "max_conns"},
+		{"slot freed", "192.0.2.1", "192.0.2.3", ""},
+		{"per ip slot freed", "192.0.2.2", "192.0.2.1", ""},
+	}
+	for _, tt := range tests {
+		if tt.release != "" {
+			l.release(tt.release)
+		}
+		if got := l.acquire(tt.ip); got != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.name, got, tt.want)
+		}
+	}
+	for _, ip := range []string{"192.0.2.1", "192.0.2.1", "192.0.2.3"} {
+		l.release(ip)
+	}
+	if l.total != 0 || len(l.perIP) != 0 {
+		t.Fatalf("after releasing everything: %d total, %v", l.total, l.perIP)
+	}
+
+	unlimited := newConnLimiter(0, 0)
+	for i := 0; i < 100; i++ {
+		if got := unlimited.acquire("192.0.2.1"); got != "" {
+			t.Fatalf("zero limits rejected connection %d: %s", i, got)
+		}
+	}
+}
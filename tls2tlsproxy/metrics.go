@@ -0,0 +1,236 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// The proxy exposes its metrics in the Prometheus text format. The handful of
+// metric types needed here are implemented directly rather than pulling in a
+// client library.
+
+type metric interface {
+	write(w io.Writer)
+}
+
+var (
+	connsAccepted = &counterVec{
+		name: "proxy_connections_accepted_total",
+		help: "Connections accepted by the listener.",
+	}
+	connsRejected = &counterVec{
+		name:  "proxy_connections_rejected_total",
+		help:  "Connections dropped before relaying, by reason.",
+		label: "reason",
+	}
+	authFailures = &counterVec{
+		name:  "proxy_auth_failures_total",
+		help:  "Failed client authentications, by reason.",
+		label: "reason",
+	}
+	relayedBytes = &counterVec{
+		name:  "proxy_relayed_bytes_total",
+		help:  "Bytes relayed between clients and backends, by direction.",
+		label: "direction",
+	}
+	throttledFrames = &counterVec{
+		name:  "proxy_throttled_frames_total",
+		help:  "Client frames dropped for exceeding a quota, by scope.",
+		label: "scope",
+	}
+	activeSessions = &gauge{
+		name: "proxy_active_sessions",
+		help: "Sessions currently relaying to a backend.",
+	}
+	backendDial = &histogram{
+		name:    "proxy_backend_dial_seconds",
+		help:    "Time taken to connect to a backend.",
+		buckets: []float64{0.001, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5},
+	}
+	sessionDuration = &histogram{
+		name:    "proxy_session_duration_seconds",
+		help:    "Lifetime of relayed sessions.",
+		buckets: []float64{1, 10, 60, 300, 900, 3600, 4 * 3600, 12 * 3600, 24 * 3600},
+	}
+	poolGets = &counterVec{
+		name:  "proxy_pool_gets_total",
+		help:  "Objects taken from a pool.",
+		label: "pool",
+	}
+	poolPuts = &counterVec{
+		name:  "proxy_pool_puts_total",
+		help:  "Objects returned to a pool.",
+		label: "pool",
+	}
+	poolAllocs = &counterVec{
+		name:  "proxy_pool_allocs_total",
+		help:  "Objects allocated because a pool was empty.",
+		label: "pool",
+	}
+)
+
+var registry = []metric{
+	connsAccepted,
+	connsRejected,
+	authFailures,
+	relayedBytes,
+	throttledFrames,
+	activeSessions,
+	backendDial,
+	sessionDuration,
+	poolGets,
+	poolPuts,
+	poolAllocs,
+}
+
+func reject(reason string) {
+	connsRejected.add(reason, 1)
+}
+
+// serveMetrics exposes the registry on addr under /metrics.
+func serveMetrics(addr string) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/metrics", metricsHandler)
+	if err := http.ListenAndServe(addr, mux); err != nil {
+		logger.Error("metrics listener", "error", err)
+	}
+}
+
+func metricsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	for _, m := range registry {
+		m.write(w)
+	}
+}
+
+// counterVec is a counter, optionally partitioned by a single label.
+type counterVec struct {
+	name  string
+	help  string
+	label string
+
+	mu     sync.Mutex
+	values map[string]*uint64
+}
+
+func (c *counterVec) add(value string, n uint64) {
+	c.mu.Lock()
+	v, ok := c.values[value]
+	if !ok {
+		if c.values == nil {
+			c.values = make(map[string]*uint64)
+		}
+		v = new(uint64)
+		c.values[value] = v
+	}
+	c.mu.Unlock()
+	atomic.AddUint64(v, n)
+}
+
+func (c *counterVec) write(w io.Writer) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s counter\n", c.name, c.help, c.name)
+
+	c.mu.Lock()
+	keys := make([]string, 0, len(c.values))
+	for k := range c.values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	values := make([]uint64, len(keys))
+	for i, k := range keys {
+		values[i] = atomic.LoadUint64(c.values[k])
+	}
+	c.mu.Unlock()
+
+	if c.label == "" {
+		var total uint64
+		for _, v := range values {
+			total += v
+		}
+		fmt.Fprintf(w, "%s %d\n", c.name, total)
+		return
+	}
+	for i, k := range keys {
+		fmt.Fprintf(w, "%s{%s=\"%s\"} %d\n", c.name, c.label, escapeLabel(k), values[i])
+	}
+}
+
+type gauge struct {
+	name string
+	help string
+	v    int64
+}
+
+func (g *gauge) add(n int64) {
+	atomic.AddInt64(&g.v, n)
+}
+
+func (g *gauge) write(w io.Writer) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s gauge\n%s %d\n", g.name, g.help, g.name, g.name, atomic.LoadInt64(&g.v))
+}
+
+// histogram counts observations into cumulative buckets with fixed upper
+// bounds.
+type histogram struct {
+	name    string
+	help    string
+	buckets []float64
+
+	mu     sync.Mutex
+	counts []uint64
+	sum    float64
+	count  uint64
+}
+
+func (h *histogram) observe(v float64) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.counts == nil {
+		h.counts = make([]uint64, len(h.buckets))
+	}
+	for i, le := range h.buckets {
+		if v <= le {
+			h.counts[i]++
+		}
+	}
+	h.sum += v
+	h.count++
+}
+
+func (h *histogram) since(start time.Time) {
+	h.observe(time.Since(start).Seconds())
+}
+
+func (h *histogram) write(w io.Writer) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s histogram\n", h.name, h.help, h.name)
+	for i, le := range h.buckets {
+		var n uint64
+		if h.counts != nil {
+			n = h.counts[i]
+		}
+		fmt.Fprintf(w, "%s_bucket{le=\"%s\"} %d\n", h.name, formatFloat(le), n)
+	}
+	fmt.Fprintf(w, "%s_bucket{le=\"+Inf\"} %d\n", h.name, h.count)
+	fmt.Fprintf(w, "%s_sum %s\n%s_count %d\n", h.name, formatFloat(h.sum), h.name, h.count)
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapeLabel(s string) string {
+	return labelEscaper.Replace(s)
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestMetricsOutput(t *testing.T) {
+	c := &counterVec{name: "test_total", help: "Things.", label: "reason"}
+	c.add("b", 2)
+	c.add("a", 1)
+	c.add("b", 3)
+	c.add(`say "hi"`+"\n", 1)
+	plain := &counterVec{name: "test_plain_total", help: "All things."}
+	plain.add("", 4)
+	plain.add("x", 1)
+	g := &gauge{name: "test_active", help: "Now."}
+	g.add(3)
+	g.add(-1)
+	h := &histogram{name: "test_seconds", help: "Time.", buckets: []float64{0.5, 1, 2.5}}
+	for _, v := range []float64{0.25, 1, 3} {
+		h.observe(v)
+	}
+
+	tests := []struct {
+		m    metric
+		want string
+	}{
+		{c, `# HELP test_total Things.
+# TYPE test_total counter
+test_total{reason="a"} 1
+test_total{reason="b"} 5
+test_total{reason="say \"hi\"\n"} 1
+`},
+		{plain, `# HELP test_plain_total All things.
+# TYPE test_plain_total counter
+test_plain_total 5
+`},
+		{g, `# HELP test_active Now.
+# TYPE test_active gauge
+test_active 2
+`},
+		{h, `# HELP test_seconds Time.
+# TYPE test_seconds histogram
+test_seconds_bucket{le="0.5"} 1
+test_seconds_bucket{le="1"} 2
+test_seconds_bucket{le="2.5"} 2
+test_seconds_bucket{le="+Inf"} 3
+test_seconds_sum 4.25
+test_seconds_count 3
+`},
+	}
+	for _, tt := range tests {
+		var b bytes.Buffer
+		tt.m.write(&b)
+		if b.String() != tt.want {
+			t.Errorf("got\n%s\nwant\n%s", b.String(), tt.want)
+		}
+	}
+
+	// an empty histogram still lists its buckets
+	var b bytes.Buffer
+	(&histogram{name: "empty", buckets: []float64{1}}).write(&b)
+	if !strings.Contains(b.String(), "empty_bucket{le=\"1\"} 0\n") {
+		t.Errorf("empty histogram:\n%s", b.String())
+	}
+}
+
+func TestMetricsHandler(t *testing.T) {
+	reject("test_reason")
+	w := httptest.NewRecorder()
+	metricsHandler(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain") {
+		t.Errorf("content type %q", ct)
+	}
+	body := w.Body.String()
+	for _, m := range registry {
+		var b bytes.Buffer
+		m.write(&b)
+		name := strings.Fields(b.String())[2]
+		if !strings.Contains(body, "# TYPE "+name+" ") {
+			t.Errorf("%s missing", name)
+		}
+	}
+	if !strings.Contains(body, `proxy_connections_rejected_total{reason="test_reason"} 1`) {
+		t.Errorf("rejection not counted:\n%s", body)
+	}
+}
@@ -0,0 +1,281 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Apurer/e2eechat/dispatch"
+)
+
+// Devices may authenticate with a client certificate issued by our own CA
+// instead of sending a dispatch.Authentication. The user and device are taken
+// from a URI SAN of the form
+//
+//	e2eechat:user/<user_id>/device/<device_id>
+//
+// or, failing that, from a subject common name of "<user_id>:<device_id>".
+const certURIScheme = "e2eechat"
+
+const (
+	authModeDispatch = "dispatch"
+	authModeMTLS     = "mtls"
+)
+
+var errNoClientIdentity = errors.New("client certificate carries no user id")
+
+// crl is the revocation list of configureMTLS, nil without one.
+var crl *revocationList
+
+// configureMTLS makes every authenticating route require a client certificate
+// signed by the CA in caPath and not revoked by the CRL in crlPath.
+//
+// The certificate is checked again on every handshake, resumed sessions
+// included, and relayed sessions are closed when a reloaded CRL revokes
+// their certificate.
+func configureMTLS(t *routeTable, caPath, crlPath string, reload time.Duration) error {
+	b, err := ioutil.ReadFile(caPath)
+	if err != nil {
+		return err
+	}
+	pool := x509.NewCertPool()
+	var cas []*x509.Certificate
+	for {
+		var block *pem.Block
+		block, b = pem.Decode(b)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		ca, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return fmt.Errorf("%s: %v", caPath, err)
+		}
+		pool.AddCert(ca)
+		cas = append(cas, ca)
+	}
+	if len(cas) == 0 {
+		return fmt.Errorf("%s: no certificates found", caPath)
+	}
+
+	if crlPath != "" {
+		crl = &revocationList{path: crlPath, issuers: cas, sessions: make(map[*certSession]struct{})}
+		if err := crl.load(); err != nil {
+			return err
+		}
+		if reload > 0 {
+			go crl.watch(reload)
+		}
+	}
+
+	all := t.Routes
+	if t.Default != nil {
+		all = append(all[:len(all):len(all)], t.Default)
+	}
+	for _, r := range all {
+		if !r.Authenticate {
+			continue
+		}
+		r.config.ClientAuth = tls.RequireAndVerifyClientCert
+		r.config.ClientCAs = pool
+		// VerifyPeerCertificate is skipped when a session is resumed,
+		// VerifyConnection is not
+		r.config.VerifyConnection = verifyClientCert
+		// clients offering it open with an Envelope to negotiate
+		r.envelopeConfig = r.config.Clone()
+		r.envelopeConfig.NextProtos = append([]string{dispatch.EnvelopeALPN}, r.ALPN...)
+	}
+	return nil
+}
+
+// verifyClientCert checks the client certificate of a handshake. On a full
+// handshake it was verified against the CA already, on a resumed one it is
+// the certificate of the original session.
+func verifyClientCert(cs tls.ConnectionState) error {
+	if len(cs.PeerCertificates) == 0 {
+		return errors.New("no verified client certificate")
+	}
+	leaf := cs.PeerCertificates[0]
+	if time.Now().After(leaf.NotAfter) {
+		authFailures.add("certificate_expired", 1)
+		return fmt.Errorf("client certificate %s expired", leaf.SerialNumber)
+	}
+	if crl != nil && crl.isRevoked(leaf) {
+		authFailures.add("certificate_revoked", 1)
+		return fmt.Errorf("client certificate %s revoked", leaf.SerialNumber)
+	}
+	if _, _, err := identityFromCert(leaf); err != nil {
+		authFailures.add("certificate_identity", 1)
+		return err
+	}
+	return nil
+}
+
+// identityFromCert maps a verified client certificate to a user and device.
+func identityFromCert(cert *x509.Certificate) (userID, deviceID uint64, err error) {
+	for _, u := range cert.URIs {
+		if u.Scheme == certURIScheme {
+			return parseCertURI(u)
+		}
+	}
+
+	parts := strings.SplitN(cert.Subject.CommonName, ":", 2)
+	userID, err = strconv.ParseUint(parts[0], 10, 64)
+	if err != nil || userID == 0 {
+		return 0, 0, errNoClientIdentity
+	}
+	if len(parts) == 2 {
+		deviceID, err = strconv.ParseUint(parts[1], 10, 64)
+		if err != nil {
+			return 0, 0, errNoClientIdentity
+		}
+	}
+	return userID, deviceID, nil
+}
+
+func parseCertURI(u *url.URL) (userID, deviceID uint64, err error) {
+	parts := strings.Split(u.Opaque, "/")
+	if len(parts) != 4 || parts[0] != "user" || parts[2] != "device" {
+		return 0, 0, errNoClientIdentity
+	}
+	userID, err = strconv.ParseUint(parts[1], 10, 64)
+	if err != nil || userID == 0 {
+		return 0, 0, errNoClientIdentity
+	}
+	deviceID, err = strconv.ParseUint(parts[3], 10, 64)
+	if err != nil {
+		return 0, 0, errNoClientIdentity
+	}
+	return userID, deviceID, nil
+}
+
+// revocationList holds the serial numbers revoked by a CRL file and reloads
+// them when the file changes.
+type revocationList struct {
+	path    string
+	issuers []*x509.Certificate
+
+	mu       sync.RWMutex
+	revoked  map[string]struct{}
+	modTime  time.Time
+	sessions map[*certSession]struct{}
+}
+
+// certSession is a relayed session authenticated with a client certificate.
+type certSession struct {
+	serial string
+	close  func()
+}
+
+func (r *revocationList) load() error {
+	fi, err := os.Stat(r.path)
+	if err != nil {
+		return err
+	}
+	b, err := ioutil.ReadFile(r.path)
+	if err != nil {
+		return err
+	}
+	crl, err := x509.ParseCRL(b)
+	if err != nil {
+		return fmt.Errorf("%s: %v", r.path, err)
+	}
+	if err := r.checkSignature(crl); err != nil {
+		return fmt.Errorf("%s: %v", r.path, err)
+	}
+
+	revoked := make(map[string]struct{}, len(crl.TBSCertList.RevokedCertificates))
+	for _, rc := range crl.TBSCertList.RevokedCertificates {
+		revoked[rc.SerialNumber.String()] = struct{}{}
+	}
+
+	r.mu.Lock()
+	r.revoked = revoked
+	r.modTime = fi.ModTime()
+	var closing []*certSession
+	for s := range r.sessions {
+		if _, ok := revoked[s.serial]; ok {
+			closing = append(closing, s)
+			delete(r.sessions, s)
+		}
+	}
+	r.mu.Unlock()
+
+	for _, s := range closing {
+		logger.Info("closing session of revoked certificate", "serial", s.serial)
+		go s.close()
+	}
+
+	if crl.HasExpired(time.Now()) {
+		logger.Warn("CRL is past its next update time", "path", r.path)
+	}
+	return nil
+}
+
+func (r *revocationList) checkSignature(crl *pkix.CertificateList) error {
+	var err error
+	for _, ca := range r.issuers {
+		if err = ca.CheckCRLSignature(crl); err == nil {
+			return nil
+		}
+	}
+	return err
+}
+
+// watch reloads the CRL whenever its modification time changes. A CRL that
+// fails to load keeps the previous one in effect.
+func (r *revocationList) watch(interval time.Duration) {
+	for range time.Tick(interval) {
+		fi, err := os.Stat(r.path)
+		if err != nil {
+			logger.Error("checking CRL", "path", r.path, "error", err)
+			continue
+		}
+		r.mu.RLock()
+		changed := !fi.ModTime().Equal(r.modTime)
+		r.mu.RUnlock()
+		if !changed {
+			continue
+		}
+		if err := r.load(); err != nil {
+			logger.Error("reloading CRL", "path", r.path, "error", err)
+			continue
+		}
+		logger.Info("CRL reloaded", "path", r.path)
+	}
+}
+
+// track registers a session of cert, which is closed with close if a later
+// CRL revokes cert. The returned func unregisters it.
+func (r *revocationList) track(cert *x509.Certificate, close func()) func() {
+	s := &certSession{serial: cert.SerialNumber.String(), close: close}
+	r.mu.Lock()
+	r.sessions[s] = struct{}{}
+	r.mu.Unlock()
+
+	return func() {
+		r.mu.Lock()
+		delete(r.sessions, s)
+		r.mu.Unlock()
+	}
+}
+
+func (r *revocationList) isRevoked(cert *x509.Certificate) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	_, ok := r.revoked[cert.SerialNumber.String()]
+	return ok
+}
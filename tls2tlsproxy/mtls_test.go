@@ -0,0 +1,198 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/Apurer/e2eechat/dispatch"
+)
+
+// writeCRL replaces the CRL with one revoking certs.
+func (p *testPKI) writeCRL(certs ...*x509.Certificate) string {
+	var revoked []pkix.RevokedCertificate
+	for _, c := range certs {
+		revoked = append(revoked, pkix.RevokedCertificate{SerialNumber: c.SerialNumber, RevocationTime: time.Now()})
+	}
+	der, err := p.cert.CreateCRL(rand.Reader, p.key, revoked, time.Now(), time.Now().Add(time.Hour))
+	if err != nil {
+		p.t.Fatal(err)
+	}
+	return p.write("ca.crl", "X509 CRL", der)
+}
+
+// mtlsRoutes loads a route table with one authenticating route for
+// chat.example and configures mtls for it.
+func mtlsRoutes(t *testing.T, p *testPKI, crlPath string) *routeTable {
+	key, cert := p.issue(&x509.Certificate{DNSNames: []string{"chat.example"}})
+	der, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := json.Marshal(routeTable{Default: &route{
+		CertFile:     p.write("proxy.crt", "CERTIFICATE", cert.Raw),
+		KeyFile:      p.write("proxy.key", "EC PRIVATE KEY", der),
+		Backend:      "127.0.0.1:1",
+		Authenticate: true,
+	}})
+	path := filepath.Join(p.dir, "routes.json")
+	if err := ioutil.WriteFile(path, b, 0600); err != nil {
+		t.Fatal(err)
+	}
+	routes, err := loadRoutes(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := configureMTLS(routes, filepath.Join(p.dir, "ca.crt"), crlPath, 0); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { crl = nil })
+	return routes
+}
+
+func clientConfig(p *testPKI, cert tls.Certificate, protos ...string) *tls.Config {
+	return &tls.Config{
+		ServerName:   "chat.example",
+		RootCAs:      p.pool,
+		Certificates: []tls.Certificate{cert},
+		NextProtos:   protos,
+	}
+}
+
+func TestMTLSVersionNegotiation(t *testing.T) {
+	p := newTestPKI(t)
+	routes := mtlsRoutes(t, p, "")
+	proxy := &tls.Config{GetConfigForClient: routes.getConfigForClient}
+	cert := p.keyPair(&x509.Certificate{Subject: pkix.Name{CommonName: "7:3"}})
+	defer func(v uint) { *minVersion = v }(*minVersion)
+
+	caps := []dispatch.Capability{dispatch.Capability_FLOW_CONTROL, dispatch.Capability_ERROR_REPORTS}
+	tests := []struct {
+		name        string
+		protos      []string
+		minVersion  uint32
+		wantStatus  dispatch.AuthResult_Status
+		wantVersion uint32
+		wantCaps    []dispatch.Capability
+	}{
+		{"envelope", []string{dispatch.EnvelopeALPN}, dispatch.Version1, dispatch.AuthResult_OK, dispatch.Version2, caps},
+		{"envelope among others", []string{"h2", dispatch.EnvelopeALPN}, dispatch.Version2, dispatch.AuthResult_OK, dispatch.Version2, caps},
+		{"version 1", nil, dispatch.Version1, dispatch.AuthResult_OK, dispatch.Version1, nil},
+		{"other protocol", []string{"h2"}, dispatch.Version1, dispatch.AuthResult_OK, dispatch.Version1, nil},
+		{"version 1 refused", nil, dispatch.Version2, dispatch.AuthResult_UNSUPPORTED_VERSION, 0, nil},
+	}
+	for _, tt := range tests {
+		*minVersion = uint(tt.minVersion)
+		client, server, err := handshake(t, proxy, clientConfig(p, cert, tt.protos...))
+		if err != nil {
+			t.Fatalf("%s: handshake: %v", tt.name, err)
+		}
+		if got := client.ConnectionState().NegotiatedProtocol; (got == dispatch.EnvelopeALPN) != (tt.wantVersion == dispatch.Version2) {
+			t.Errorf("%s: negotiated %q", tt.name, got)
+		}
+		if client.ConnectionState().NegotiatedProtocol == dispatch.EnvelopeALPN {
+			go dispatch.WriteMessage(client, &dispatch.Envelope{Version: dispatch.CurrentVersion, Capabilities: caps})
+		}
+
+		id := identity{}
+		status, _ := authenticateCert(server, server, &id)
+		if status != tt.wantStatus || id.version != tt.wantVersion || !reflect.DeepEqual(id.capabilities, tt.wantCaps) {
+			t.Errorf("%s: got %v, version %d, %v, want %v, version %d, %v", tt.name, status, id.version, id.capabilities, tt.wantStatus, tt.wantVersion, tt.wantCaps)
+		}
+		if status == dispatch.AuthResult_OK && (id.userID != 7 || id.deviceID != 3) {
+			t.Errorf("%s: authenticated as user %d device %d", tt.name, id.userID, id.deviceID)
+		}
+		client.Close()
+		server.Close()
+	}
+}
+
+func TestCRLReload(t *testing.T) {
+	p := newTestPKI(t)
+	crlPath := p.writeCRL()
+	mtlsRoutes(t, p, crlPath)
+	kept := p.keyPair(&x509.Certificate{Subject: pkix.Name{CommonName: "7:1"}})
+	revoked := p.keyPair(&x509.Certificate{Subject: pkix.Name{CommonName: "7:2"}})
+
+	closed := make(chan string, 2)
+	defer crl.track(kept.Leaf, func() { closed <- "kept" })()
+	defer crl.track(revoked.Leaf, func() { closed <- "revoked" })()
+
+	p.writeCRL(revoked.Leaf)
+	if err := crl.load(); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case got := <-closed:
+		if got != "revoked" {
+			t.Fatalf("closed the session of the %s certificate", got)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("session of the revoked certificate not closed")
+	}
+
+	tests := []struct {
+		cert    tls.Certificate
+		wantErr bool
+	}{
+		{kept, false},
+		{revoked, true},
+	}
+	for _, tt := range tests {
+		err := verifyClientCert(tls.ConnectionState{PeerCertificates: []*x509.Certificate{tt.cert.Leaf}})
+		if (err != nil) != tt.wantErr {
+			t.Errorf("certificate %s: got %v", tt.cert.Leaf.Subject.CommonName, err)
+		}
+	}
+
+	// a CRL not signed by the CA keeps the previous one in effect
+	other := newTestPKI(t)
+	other.dir = p.dir
+	other.writeCRL()
+	if err := crl.load(); err == nil {
+		t.Fatal("CRL of another CA loaded")
+	}
+	if !crl.isRevoked(revoked.Leaf) {
+		t.Fatal("revocation lost after a bad CRL")
+	}
+}
+
+func TestResumeRevoked(t *testing.T) {
+	p := newTestPKI(t)
+	crlPath := p.writeCRL()
+	routes := mtlsRoutes(t, p, crlPath)
+	// the session tickets are encrypted with the keys of this config
+	proxy := &tls.Config{GetConfigForClient: routes.getConfigForClient}
+	cert := p.keyPair(&x509.Certificate{Subject: pkix.Name{CommonName: "7:1"}})
+
+	config := clientConfig(p, cert)
+	// TLS 1.2 hands out the session ticket during the handshake
+	config.MaxVersion = tls.VersionTLS12
+	config.ClientSessionCache = tls.NewLRUClientSessionCache(1)
+
+	for _, want := range []bool{false, true} {
+		client, server, err := handshake(t, proxy, config)
+		if err != nil || server.ConnectionState().DidResume != want {
+			t.Fatalf("handshake: resumed %v, %v, want resumed %v", server.ConnectionState().DidResume, err, want)
+		}
+		client.Close()
+		server.Close()
+	}
+
+	p.writeCRL(cert.Leaf)
+	if err := crl.load(); err != nil {
+		t.Fatal(err)
+	}
+	client, _, err := handshake(t, proxy, config)
+	client.Close()
+	if err == nil {
+		t.Fatal("session of a revoked certificate resumed")
+	}
+}
@@ -0,0 +1,267 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/Apurer/e2eechat/dispatch"
+)
+
+// errThrottled ends the session of a client over its quota that cannot be
+// sent a THROTTLE.
+var errThrottled = errors.New("over quota")
+
+// tier is a named set of limits. Zero rates are unlimited.
+type tier struct {
+	BytesPerSec  float64 `json:"bytes_per_sec"`
+	BurstBytes   float64 `json:"burst_bytes"`
+	FramesPerSec float64 `json:"frames_per_sec"`
+	BurstFrames  float64 `json:"burst_frames"`
+}
+
+// quotaConfig is the JSON document passed with -quotas. Users get UserTier
+// unless listed in Users, every client IP gets IPTier.
+type quotaConfig struct {
+	Tiers    map[string]tier   `json:"tiers"`
+	UserTier string            `json:"user_tier"`
+	IPTier   string            `json:"ip_tier"`
+	Users    map[string]string `json:"users"`
+}
+
+func loadQuotas(path string) (*quotas, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var cfg quotaConfig
+	if err := json.Unmarshal(b, &cfg); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+
+	check := func(name string) error {
+		if _, ok := cfg.Tiers[name]; name != "" && !ok {
+			return fmt.Errorf("%s: unknown tier %q", path, name)
+		}
+		return nil
+	}
+	if err := check(cfg.UserTier); err != nil {
+		return nil, err
+	}
+	if err := check(cfg.IPTier); err != nil {
+		return nil, err
+	}
+	users := make(map[uint64]string, len(cfg.Users))
+	for id, name := range cfg.Users {
+		if err := check(name); err != nil {
+			return nil, err
+		}
+		userID, err := strconv.ParseUint(id, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%s: invalid user id %q", path, id)
+		}
+		users[userID] = name
+	}
+
+	q := &quotas{
+		cfg:     cfg,
+		users:   users,
+		buckets: make(map[string]*bucketPair),
+	}
+	go q.sweep(time.Minute)
+	return q, nil
+}
+
+// quotas holds the token buckets shared by all sessions of a user or IP.
+type quotas struct {
+	cfg   quotaConfig
+	users map[uint64]string
+
+	mu      sync.Mutex
+	buckets map[string]*bucketPair
+}
+
+// bucketPair limits both the byte and the frame rate of one user or IP.
+type bucketPair struct {
+	mu     sync.Mutex
+	bytes  tokenBucket
+	frames tokenBucket
+	refs   int
+}
+
+// acquire returns the buckets for key, creating them from t if needed.
+func (q *quotas) acquire(key string, t tier) *bucketPair {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	p, ok := q.buckets[key]
+	if !ok {
+		now := time.Now()
+		p = &bucketPair{
+			bytes:  newTokenBucket(t.BytesPerSec, t.BurstBytes, now),
+			frames: newTokenBucket(t.FramesPerSec, t.BurstFrames, now),
+		}
+		q.buckets[key] = p
+	}
+	p.refs++
+	return p
+}
+
+func (q *quotas) release(p *bucketPair) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	p.refs--
+}
+
+// sweep drops the buckets of users and IPs without sessions. They start over
+// with a full burst the next time, which an idle client has earned anyway.
+func (q *quotas) sweep(interval time.Duration) {
+	for range time.Tick(interval) {
+		q.mu.Lock()
+		for key, p := range q.buckets {
+			if p.refs == 0 {
+				delete(q.buckets, key)
+			}
+		}
+		q.mu.Unlock()
+	}
+}
+
+// session returns the quota of one client session, or nil when neither the
+// user nor the IP is limited. Sealed sender sessions have no user id and are
+// only limited by IP.
+func (q *quotas) session(userID uint64, ip string) *sessionQuota {
+	s := &sessionQuota{q: q}
+	if userID != 0 {
+		name, ok := q.users[userID]
+		if !ok {
+			name = q.cfg.UserTier
+		}
+		if t, ok := q.cfg.Tiers[name]; ok {
+			s.user = q.acquire("user:"+strconv.FormatUint(userID, 10), t)
+		}
+	}
+	if t, ok := q.cfg.Tiers[q.cfg.IPTier]; ok {
+		s.ip = q.acquire("ip:"+ip, t)
+	}
+	if s.user == nil && s.ip == nil {
+		return nil
+	}
+	return s
+}
+
+// sessionQuota charges the frames of one session against the buckets of its
+// user and IP.
+type sessionQuota struct {
+	q    *quotas
+	user *bucketPair
+	ip   *bucketPair
+}
+
+// allow charges a frame of n bytes. If either the user or the IP is over its
+// quota nothing is charged, and the scope and time until the frame would
+// fit are returned.
+func (s *sessionQuota) allow(n int) (dispatch.Throttle_Scope, time.Duration, bool) {
+	now := time.Now()
+
+	// always lock user before IP
+	for _, p := range []*bucketPair{s.user, s.ip} {
+		if p != nil {
+			p.mu.Lock()
+			defer p.mu.Unlock()
+		}
+	}
+
+	if wait := s.user.wait(n, now); wait > 0 {
+		return dispatch.Throttle_USER, wait, false
+	}
+	if wait := s.ip.wait(n, now); wait > 0 {
+		return dispatch.Throttle_IP, wait, false
+	}
+	s.user.take(n)
+	s.ip.take(n)
+	return 0, 0, true
+}
+
+func (s *sessionQuota) release() {
+	for _, p := range []*bucketPair{s.user, s.ip} {
+		if p != nil {
+			s.q.release(p)
+		}
+	}
+}
+
+// wait refills both buckets and returns how long until a frame of n bytes
+// fits. The caller holds p.mu.
+func (p *bucketPair) wait(n int, now time.Time) time.Duration {
+	if p == nil {
+		return 0
+	}
+	p.bytes.refill(now)
+	p.frames.refill(now)
+	w := p.bytes.wait(float64(n))
+	if fw := p.frames.wait(1); fw > w {
+		w = fw
+	}
+	return w
+}
+
+// take charges a frame of n bytes. The caller holds p.mu.
+func (p *bucketPair) take(n int) {
+	if p == nil {
+		return
+	}
+	p.bytes.take(float64(n))
+	p.frames.take(1)
+}
+
+// tokenBucket allows rate tokens per second with bursts of up to burst
+// tokens. A zero rate means unlimited.
+type tokenBucket struct {
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func newTokenBucket(rate, burst float64, now time.Time) tokenBucket {
+	if burst < rate {
+		burst = rate
+	}
+	return tokenBucket{rate: rate, burst: burst, tokens: burst, last: now}
+}
+
+func (b *tokenBucket) refill(now time.Time) {
+	if b.rate == 0 {
+		return
+	}
+	b.tokens = math.Min(b.burst, b.tokens+now.Sub(b.last).Seconds()*b.rate)
+	b.last = now
+}
+
+// wait returns how long until n tokens are available. Requests larger than
+// the burst are allowed once the bucket is full, so that one large frame
+// cannot be blocked forever.
+func (b *tokenBucket) wait(n float64) time.Duration {
+	if b.rate == 0 {
+		return 0
+	}
+	need := math.Min(n, b.burst)
+	if b.tokens >= need {
+		return 0
+	}
+	return time.Duration((need - b.tokens) / b.rate * float64(time.Second))
+}
+
+func (b *tokenBucket) take(n float64) {
+	if b.rate == 0 {
+		return
+	}
+	b.tokens -= n
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"github.com/Apurer/e2eechat/dispatch"
+)
+
+func TestTokenBucket(t *testing.T) {
+	start := time.Now()
+	b := newTokenBucket(10, 20, start)
+
+	tests := []struct {
+		name  string
+		after time.Duration
+		take  float64
+		want  time.Duration
+	}{
+		{"burst", 0, 20, 0},
+		{"empty", 0, 5, 500 * time.Millisecond},
+		{"refilled in part", 200 * time.Millisecond, 2, 0},
+		{"taken again", 200 * time.Millisecond, 5, 500 * time.Millisecond},
+		{"refill stops at the burst", time.Hour, 20, 0},
+		// a frame over the burst passes once the bucket is full
+		{"over the burst", 2 * time.Hour, 100, 0},
+		{"in debt after it", 2 * time.Hour, 1, 8100 * time.Millisecond},
+	}
+	for _, tt := range tests {
+		b.refill(start.Add(tt.after))
+		got := b.wait(tt.take)
+		if got != tt.want {
+			t.Errorf("%s: wait %v, want %v", tt.name, got, tt.want)
+		}
+		if got == 0 {
+			b.take(tt.take)
+		}
+	}
+
+	unlimited := newTokenBucket(0, 0, start)
+	unlimited.take(1e9)
+	if w := unlimited.wait(1e9); w != 0 {
+		t.Fatalf("zero rate waits %v", w)
+	}
+}
+
+func TestSessionQuota(t *testing.T) {
+	q := &quotas{
+		cfg: quotaConfig{
+			Tiers: map[string]tier{
+				"small": {FramesPerSec: 1, BurstFrames: 2},
+				"large": {FramesPerSec: 1, BurstFrames: 4},
+			},
+			UserTier: "small",
+			IPTier:   "large",
+		},
+		users:   map[uint64]string{7: "large"},
+		buckets: make(map[string]*bucketPair),
+	}
+
+	// two sessions of user 1 share its buckets
+	a, b := q.session(1, "192.0.2.1"), q.session(1, "192.0.2.2")
+	tests := []struct {
+		name      string
+		s         *sessionQuota
+		wantScope dispatch.Throttle_Scope
+		wantOK    bool
+	}{
+		{"first session", a, 0, true},
+		{"second session", b, 0, true},
+		{"user over its quota", a, dispatch.Throttle_USER, false},
+		// a user listed with its own tier is limited by the IP of both
+		{"listed user", q.session(7, "192.0.2.1"), 0, true},
+		{"listed user", q.session(7, "192.0.2.1"), 0, true},
+		{"listed user", q.session(7, "192.0.2.1"), 0, true},
+		{"ip over its quota", q.session(7, "192.0.2.1"), dispatch.Throttle_IP, false},
+		// sealed sender sessions only have an IP
+		{"sealed sender", q.session(0, "192.0.2.3"), 0, true},
+	}
+	for _, tt := range tests {
+		scope, wait, ok := tt.s.allow(10)
+		if ok != tt.wantOK || scope != tt.wantScope || ok != (wait == 0) {
+			t.Errorf("%s: got %v, %v, %v, want %v, %v", tt.name, scope, wait, ok, tt.wantScope, tt.wantOK)
+		}
+	}
+
+	a.release()
+	b.release()
+	if p := q.buckets["user:1"]; p.refs != 0 {
+		t.Fatalf("%d references left after releasing both sessions", p.refs)
+	}
+	if s := (&quotas{cfg: quotaConfig{}}).session(1, "192.0.2.1"); s != nil {
+		t.Fatal("session quota without any tier")
+	}
+}
+
+func TestThrottle(t *testing.T) {
+	q := &quotas{
+		cfg:     quotaConfig{Tiers: map[string]tier{"one": {FramesPerSec: 1, BurstFrames: 1}}, UserTier: "one"},
+		buckets: make(map[string]*bucketPair),
+	}
+	tests := []struct {
+		name    string
+		caps    []dispatch.Capability
+		wantErr error
+	}{
+		{"flow control", []dispatch.Capability{dispatch.Capability_FLOW_CONTROL}, nil},
+		// clients that cannot be told lose the session
+		{"version 1", nil, errThrottled},
+	}
+	for i, tt := range tests {
+		remote, client := tcpPair(t)
+		filter := throttle(client, identity{capabilities: tt.caps}, q.session(uint64(i+1), "192.0.2.1"))
+		if ok, err := filter([]byte("first")); !ok || err != nil {
+			t.Fatalf("%s: first frame: %v, %v", tt.name, ok, err)
+		}
+		ok, err := filter([]byte("second"))
+		if ok || err != tt.wantErr {
+			t.Errorf("%s: got %v, %v, want %v", tt.name, ok, err, tt.wantErr)
+		}
+		client.Close()
+		sent, _ := ioutil.ReadAll(remote)
+		remote.Close()
+		if tt.wantErr != nil {
+			if len(sent) != 0 {
+				t.Errorf("%s: %d bytes sent to the client", tt.name, len(sent))
+			}
+			continue
+		}
+		var a dispatch.Action
+		if err := dispatch.ReadMessage(bytes.NewReader(sent), make([]byte, 1024), &a); err != nil || a.Type != dispatch.Action_THROTTLE {
+			t.Errorf("%s: sent %v, %v, want a THROTTLE", tt.name, a.Type, err)
+		}
+	}
+}
@@ -0,0 +1,238 @@
+package main
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Apurer/e2eechat/dispatch"
+)
+
+// activity records the last time either side of a relayed session moved data,
+// so that a one-directional stream is not considered idle.
+type activity struct {
+	last int64
+}
+
+func (a *activity) touch() {
+	atomic.StoreInt64(&a.last, time.Now().UnixNano())
+}
+
+func (a *activity) idleFor() time.Duration {
+	return time.Since(time.Unix(0, atomic.LoadInt64(&a.last)))
+}
+
+// idleReader reads from a connection with a read deadline derived from
+// idleTimeout, which is re-armed for as long as either direction of the
+// session is active.
+type idleReader struct {
+	conn net.Conn
+	act  *activity
+}
+
+func (r *idleReader) Read(b []byte) (int, error) {
+	for {
+		if *idleTimeout > 0 {
+			r.conn.SetReadDeadline(time.Now().Add(*idleTimeout))
+		}
+		n, err := r.conn.Read(b)
+		if n > 0 {
+			r.act.touch()
+		}
+		if err != nil && n == 0 && isTimeout(err) && r.act.idleFor() < *idleTimeout {
+			continue
+		}
+		return n, err
+	}
+}
+
+// bufferedConn is a connection whose first bytes were already pulled into a
+// bufio.Reader while reading the handshake.
+type bufferedConn struct {
+	net.Conn
+	r *bufio.Reader
+}
+
+func (c *bufferedConn) Read(b []byte) (int, error) {
+	return c.r.Read(b)
+}
+
+func (c *bufferedConn) CloseWrite() error {
+	if cw, ok := c.Conn.(closeWriter); ok {
+		return cw.CloseWrite()
+	}
+	return nil
+}
+
+// relayStats holds the number of bytes relayed in each direction of a session.
+type relayStats struct {
+	Upstream   int64 // client -> backend
+	Downstream int64 // backend -> client
+}
+
+type closeWriter interface {
+	CloseWrite() error
+}
+
+// relay copies data between client and backend in both directions until both
+// directions are finished. When one side stops sending, the write half of the
+// other side is closed so that in-flight data in the opposite direction can
+// still drain. The first error other than a clean EOF is returned.
+//
+// When framed is set both directions carry dispatch frames, which are
+// forwarded whole so that the proxy can insert its own messages between
+// them, and every frame from the client is passed to filter first if set.
+// A client frame over the buffer size, or a backend frame over
+// maxBackendFrame, ends the relay, tooLarge is called first if set so that
+// the client can be told.
+func relay(client, backend net.Conn, framed bool, filter frameFilter, tooLarge func(upstream bool)) (relayStats, error) {
+	var (
+		stats relayStats
+		wg    sync.WaitGroup
+		once  sync.Once
+		first error
+	)
+
+	act := new(activity)
+	act.touch()
+
+	fail := func(err error) {
+		once.Do(func() {
+			first = err
+			// unblock the other direction
+			client.Close()
+			backend.Close()
+		})
+	}
+
+	half := func(dst, src net.Conn, n *int64, filter frameFilter, upstream bool) {
+		defer wg.Done()
+		r := &idleReader{conn: src, act: act}
+		var err error
+		switch {
+		case !framed:
+			err = copyStream(dst, r, n)
+		case upstream:
+			err = copyFrames(dst, r, n, filter, bufferSize)
+		default:
+			err = copyFrames(dst, r, n, filter, maxBackendFrame)
+		}
+		if err == dispatch.ErrFrameTooLarge && tooLarge != nil {
+			tooLarge(upstream)
+		}
+		if err != nil {
+			fail(err)
+			return
+		}
+		if cw, ok := dst.(closeWriter); ok {
+			if err := cw.CloseWrite(); err != nil {
+				fail(err)
+			}
+			return
+		}
+		fail(nil)
+	}
+
+	wg.Add(2)
+	go half(backend, client, &stats.Upstream, filter, true)
+	go half(client, backend, &stats.Downstream, nil, false)
+	wg.Wait()
+
+	return stats, first
+}
+
+// copyStream copies src to dst using a pooled buffer until src reports EOF,
+// keeping a running total in n.
+func copyStream(dst io.Writer, src io.Reader, n *int64) error {
+	buf := getBuffer()
+	defer releaseBuffer(buf)
+
+	for {
+		nr, rerr := src.Read(buf)
+		if nr > 0 {
+			if err := write(dst, buf[:nr], n); err != nil {
+				return err
+			}
+		}
+		if rerr == io.EOF {
+			return nil
+		}
+		if rerr != nil {
+			return rerr
+		}
+	}
+}
+
+// frameFilter decides whether a frame is forwarded. It may answer the sender
+// itself, and returns an error to end the session.
+type frameFilter func(frame []byte) (bool, error)
+
+// copyFrames forwards complete dispatch frames of up to max bytes from src
+// to dst, each with a single write, until src reports EOF between two
+// frames. Frames that do not fit the pooled buffer are read into one of
+// their own.
+func copyFrames(dst io.Writer, src io.Reader, n *int64, filter frameFilter, max int) error {
+	buf := getBuffer()
+	defer releaseBuffer(buf)
+
+	for {
+		frame, err := readFrame(src, buf, max)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if filter != nil {
+			ok, err := filter(frame)
+			if err != nil {
+				return err
+			}
+			if !ok {
+				continue
+			}
+		}
+		if err := write(dst, frame, n); err != nil {
+			return err
+		}
+	}
+}
+
+// readFrame reads one frame of up to max bytes, header included, into buf or
+// into a new slice if it does not fit.
+func readFrame(r io.Reader, buf []byte, max int) ([]byte, error) {
+	if _, err := io.ReadFull(r, buf[:dispatch.FrameHeaderSize]); err != nil {
+		return nil, err
+	}
+	size := dispatch.FrameHeaderSize + int(binary.BigEndian.Uint32(buf))
+	if size > max {
+		return nil, dispatch.ErrFrameTooLarge
+	}
+	if size > len(buf) {
+		buf = append(make([]byte, 0, size), buf[:dispatch.FrameHeaderSize]...)
+	}
+	frame := buf[:size]
+	if _, err := io.ReadFull(r, frame[dispatch.FrameHeaderSize:]); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	return frame, nil
+}
+
+func write(dst io.Writer, b []byte, n *int64) error {
+	nw, err := dst.Write(b)
+	atomic.AddInt64(n, int64(nw))
+	if err != nil {
+		return err
+	}
+	if nw != len(b) {
+		return io.ErrShortWrite
+	}
+	return nil
+}
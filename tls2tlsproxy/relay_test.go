@@ -0,0 +1,254 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/Apurer/e2eechat/dispatch"
+)
+
+// tcpPair returns both ends of a loopback TCP connection.
+func tcpPair(tb testing.TB) (net.Conn, net.Conn) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		tb.Fatal(err)
+	}
+	defer ln.Close()
+
+	accepted := make(chan net.Conn, 1)
+	go func() {
+		c, err := ln.Accept()
+		if err != nil {
+			tb.Error(err)
+		}
+		accepted <- c
+	}()
+	c, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		tb.Fatal(err)
+	}
+	return c, <-accepted
+}
+
+func TestRelay(t *testing.T) {
+	for _, framed := range []bool{false, true} {
+		client, clientSide := tcpPair(t)
+		backendSide, backend := tcpPair(t)
+
+		type result struct {
+			stats relayStats
+			err   error
+		}
+		done := make(chan result, 1)
+		go func() {
+			stats, err := relay(clientSide, backendSide, framed, nil, nil)
+			done <- result{stats, err}
+		}()
+
+		up := dispatch.AppendFrame(nil, bytes.Repeat([]byte("u"), 5000))
+		down := dispatch.AppendFrame(nil, bytes.Repeat([]byte("d"), 70))
+		go func() {
+			client.Write(up)
+			client.(*net.TCPConn).CloseWrite()
+		}()
+		go func() {
+			backend.Write(down)
+			backend.(*net.TCPConn).CloseWrite()
+		}()
+
+		// each side sees all the other sent, then EOF from the half close
+		gotUp, err := ioutil.ReadAll(backend)
+		if err != nil || !bytes.Equal(gotUp, up) {
+			t.Fatalf("framed=%v: backend got %d bytes, %v", framed, len(gotUp), err)
+		}
+		gotDown, err := ioutil.ReadAll(client)
+		if err != nil || !bytes.Equal(gotDown, down) {
+			t.Fatalf("framed=%v: client got %d bytes, %v", framed, len(gotDown), err)
+		}
+
+		select {
+		case r := <-done:
+			if r.err != nil {
+				t.Fatalf("framed=%v: relay: %v", framed, r.err)
+			}
+			if r.stats.Upstream != int64(len(up)) || r.stats.Downstream != int64(len(down)) {
+				t.Fatalf("framed=%v: stats %+v, want %d up and %d down", framed, r.stats, len(up), len(down))
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("framed=%v: relay did not end", framed)
+		}
+		client.Close()
+		backend.Close()
+	}
+}
+
+func TestRelayLargeFrames(t *testing.T) {
+	tests := []struct {
+		name     string
+		upstream bool
+		size     int
+		want     error
+	}{
+		{"backend frame over the buffer", false, 2 * bufferSize, nil},
+		{"backend frame over the limit", false, maxBackendFrame, dispatch.ErrFrameTooLarge},
+		{"client frame over the buffer", true, bufferSize, dispatch.ErrFrameTooLarge},
+	}
+	for _, tt := range tests {
+		client, clientSide := tcpPair(t)
+		backendSide, backend := tcpPair(t)
+
+		var told []bool
+		done := make(chan error, 1)
+		go func() {
+			_, err := relay(clientSide, backendSide, true, nil, func(upstream bool) { told = append(told, upstream) })
+			done <- err
+		}()
+
+		from, to := backend, client
+		if tt.upstream {
+			from, to = client, backend
+		}
+		frame := dispatch.AppendFrame(nil, bytes.Repeat([]byte("x"), tt.size))
+		go func() {
+			from.Write(frame)
+			from.(*net.TCPConn).CloseWrite()
+		}()
+		got, _ := ioutil.ReadAll(to)
+		if tt.want == nil && !bytes.Equal(got, frame) {
+			t.Errorf("%s: got %d bytes, want %d", tt.name, len(got), len(frame))
+		}
+		client.Close()
+		backend.Close()
+
+		select {
+		case err := <-done:
+			if err != tt.want {
+				t.Errorf("%s: got %v, want %v", tt.name, err, tt.want)
+			}
+			if tt.want != nil && (len(told) != 1 || told[0] != tt.upstream) {
+				t.Errorf("%s: client told %v", tt.name, told)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("%s: relay did not end", tt.name)
+		}
+	}
+}
+
+// The relay replaced a loop that read both connections in goroutines,
+// copied every read into a new slice and passed it over a channel to a
+// single goroutine writing both directions. legacyPipe is that loop, kept
+// here to compare against.
+
+func legacyChanFromConn(conn net.Conn) chan []byte {
+	c := make(chan []byte)
+	go func() {
+		buf := getBuffer()
+		defer releaseBuffer(buf)
+
+		for {
+			n, err := conn.Read(buf)
+			if err != nil {
+				c <- nil
+				return
+			}
+			if n > 0 {
+				res := make([]byte, n)
+				copy(res, buf[:n])
+				c <- res
+			}
+		}
+	}()
+	return c
+}
+
+func legacyPipe(conn1, conn2 net.Conn) {
+	chan1 := legacyChanFromConn(conn1)
+	chan2 := legacyChanFromConn(conn2)
+	for {
+		select {
+		case b1 := <-chan1:
+			if b1 == nil {
+				return
+			}
+			conn2.Write(b1)
+		case b2 := <-chan2:
+			if b2 == nil {
+				return
+			}
+			conn1.Write(b2)
+		}
+	}
+}
+
+// benchmarkRelay sends b.N chunks from a client through run to a backend
+// and measures the throughput.
+func benchmarkRelay(b *testing.B, chunk []byte, run func(client, backend net.Conn)) {
+	client, clientSide := tcpPair(b)
+	backendSide, backend := tcpPair(b)
+	done := make(chan struct{})
+	go func() {
+		run(clientSide, backendSide)
+		clientSide.Close()
+		backendSide.Close()
+		close(done)
+	}()
+
+	b.SetBytes(int64(len(chunk)))
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	go func() {
+		for i := 0; i < b.N; i++ {
+			if _, err := client.Write(chunk); err != nil {
+				b.Error(err)
+				return
+			}
+		}
+	}()
+	if _, err := io.CopyN(ioutil.Discard, backend, int64(b.N)*int64(len(chunk))); err != nil {
+		b.Fatal(err)
+	}
+
+	b.StopTimer()
+	client.Close()
+	backend.Close()
+	<-done
+}
+
+func frames(size, n int) []byte {
+	var b []byte
+	for i := 0; i < n; i++ {
+		b = dispatch.AppendFrame(b, make([]byte, size))
+	}
+	return b
+}
+
+func BenchmarkLegacyPipe(b *testing.B) {
+	benchmarkRelay(b, make([]byte, 16*1024), legacyPipe)
+}
+
+func BenchmarkRelayStream(b *testing.B) {
+	benchmarkRelay(b, make([]byte, 16*1024), func(client, backend net.Conn) {
+		relay(client, backend, false, nil, nil)
+	})
+}
+
+func BenchmarkLegacyPipeSmallFrames(b *testing.B) {
+	benchmarkRelay(b, frames(200, 64), legacyPipe)
+}
+
+func BenchmarkRelayFramedSmallFrames(b *testing.B) {
+	benchmarkRelay(b, frames(200, 64), func(client, backend net.Conn) {
+		relay(client, backend, true, nil, nil)
+	})
+}
+
+func BenchmarkRelayStreamSmallFrames(b *testing.B) {
+	benchmarkRelay(b, frames(200, 64), func(client, backend net.Conn) {
+		relay(client, backend, false, nil, nil)
+	})
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"github.com/Apurer/e2eechat/dispatch"
+)
+
+// route decides which certificate is presented and which backend receives
+// the traffic for a given server name and ALPN protocol.
+type route struct {
+	// ServerName is matched against SNI, either exactly or as a "*.domain"
+	// wildcard covering one label.
+	ServerName string `json:"server_name"`
+	// ALPN lists the protocols offered for this route. A route with no
+	// protocols matches regardless of what the client offers.
+	ALPN     []string `json:"alpn"`
+	CertFile string   `json:"cert_file"`
+	KeyFile  string   `json:"key_file"`
+	Backend  string   `json:"backend"`
+	// Authenticate requires a dispatch.Authentication as the first message,
+	// which only makes sense for the chat relay.
+	Authenticate bool `json:"authenticate"`
+	// ProxyProtocol sends a PROXY protocol v2 header with the client address
+	// before anything else. srvtls reads it from trusted proxies when it is
+	// there, other backends must be configured to expect it.
+	ProxyProtocol bool `json:"proxy_protocol"`
+
+	config *tls.Config
+	// envelopeConfig adds dispatch.EnvelopeALPN to config for clients
+	// offering it, on authenticating routes when -auth-mode is mtls.
+	envelopeConfig *tls.Config
+}
+
+// accepts reports whether the route serves a client offering protos.
+func (r *route) accepts(protos []string) bool {
+	return matchALPN(r.ALPN, protos) || r.envelopeConfig != nil && matchALPN([]string{dispatch.EnvelopeALPN}, protos)
+}
+
+// routeTable is the JSON document passed with -routes.
+type routeTable struct {
+	Routes        []*route `json:"routes"`
+	Default       *route   `json:"default"`
+	RejectUnknown bool     `json:"reject_unknown"`
+}
+
+var errUnknownServerName = errors.New("unknown server name")
+
+// defaultRoutes reproduces the single hard-coded chat relay used when no
+// route table is configured.
+func defaultRoutes() *routeTable {
+	return &routeTable{
+		Default: &route{
+			CertFile:      "proxy.crt",
+			KeyFile:       "proxy.key",
+			Backend:       remoteAddr,
+			Authenticate:  true,
+			ProxyProtocol: true,
+		},
+	}
+}
+
+func loadRoutes(path string) (*routeTable, error) {
+	t := defaultRoutes()
+	if path != "" {
+		b, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		t = new(routeTable)
+		if err := json.Unmarshal(b, t); err != nil {
+			return nil, fmt.Errorf("%s: %v", path, err)
+		}
+	}
+
+	all := t.Routes
+	if t.Default != nil {
+		all = append(all[:len(all):len(all)], t.Default)
+	}
+	if len(all) == 0 {
+		return nil, errors.New("no routes configured")
+	}
+	for _, r := range all {
+		if r.Backend == "" {
+			return nil, fmt.Errorf("route %q: no backend", r.ServerName)
+		}
+		cer, err := tls.LoadX509KeyPair(r.CertFile, r.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("route %q: %v", r.ServerName, err)
+		}
+		r.config = &tls.Config{
+			Certificates: []tls.Certificate{cer},
+			NextProtos:   r.ALPN,
+		}
+	}
+	return t, nil
+}
+
+// match returns the route for serverName and the offered protocols. Routes
+// are tried in order, the default route is used when nothing matches unless
+// unknown names are rejected.
+func (t *routeTable) match(serverName string, protos []string) (*route, error) {
+	name := strings.ToLower(strings.TrimSuffix(serverName, "."))
+	for _, r := range t.Routes {
+		if matchName(r.ServerName, name) && r.accepts(protos) {
+			return r, nil
+		}
+	}
+	if t.RejectUnknown || t.Default == nil {
+		return nil, errUnknownServerName
+	}
+	return t.Default, nil
+}
+
+// getConfigForClient picks the certificate and ALPN protocols during the
+// handshake. dispatch.EnvelopeALPN is only advertised to clients offering
+// it, so that the route keeps its own protocols for the others.
+func (t *routeTable) getConfigForClient(hello *tls.ClientHelloInfo) (*tls.Config, error) {
+	r, err := t.match(hello.ServerName, hello.SupportedProtos)
+	if err != nil {
+		reject("unknown_server_name")
+		return nil, err
+	}
+	if r.envelopeConfig != nil && matchALPN([]string{dispatch.EnvelopeALPN}, hello.SupportedProtos) {
+		return r.envelopeConfig, nil
+	}
+	return r.config, nil
+}
+
+// lookup returns the route chosen during a completed handshake.
+func (t *routeTable) lookup(state tls.ConnectionState) (*route, error) {
+	var protos []string
+	if state.NegotiatedProtocol != "" {
+		protos = []string{state.NegotiatedProtocol}
+	}
+	return t.match(state.ServerName, protos)
+}
+
+func matchName(pattern, name string) bool {
+	pattern = strings.ToLower(pattern)
+	if strings.HasPrefix(pattern, "*.") {
+		i := strings.IndexByte(name, '.')
+		return i > 0 && name[i+1:] == pattern[2:]
+	}
+	return pattern == name
+}
+
+func matchALPN(route, offered []string) bool {
+	if len(route) == 0 {
+		return true
+	}
+	for _, p := range route {
+		for _, o := range offered {
+			if p == o {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// testPKI is a CA issuing the certificates of the proxy and its clients.
+type testPKI struct {
+	t    *testing.T
+	dir  string
+	key  *ecdsa.PrivateKey
+	cert *x509.Certificate
+	pool *x509.CertPool
+}
+
+func newTestPKI(t *testing.T) *testPKI {
+	p := &testPKI{t: t, dir: t.TempDir()}
+	p.key, p.cert = p.issue(&x509.Certificate{
+		Subject:               pkix.Name{CommonName: "test ca"},
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+	})
+	p.pool = x509.NewCertPool()
+	p.pool.AddCert(p.cert)
+	p.write("ca.crt", "CERTIFICATE", p.cert.Raw)
+	return p
+}
+
+var testSerial int64
+
+// issue signs tmpl with the CA, or by itself while there is no CA yet.
+func (p *testPKI) issue(tmpl *x509.Certificate) (*ecdsa.PrivateKey, *x509.Certificate) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		p.t.Fatal(err)
+	}
+	testSerial++
+	tmpl.SerialNumber = big.NewInt(testSerial)
+	tmpl.NotBefore = time.Now().Add(-time.Hour)
+	tmpl.NotAfter = time.Now().Add(time.Hour)
+	parent, signer := tmpl, key
+	if p.cert != nil {
+		parent, signer = p.cert, p.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, parent, &key.PublicKey, signer)
+	if err != nil {
+		p.t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		p.t.Fatal(err)
+	}
+	return key, cert
+}
+
+func (p *testPKI) write(name, typ string, der []byte) string {
+	path := filepath.Join(p.dir, name)
+	if err := ioutil.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: typ, Bytes: der}), 0600); err != nil {
+		p.t.Fatal(err)
+	}
+	return path
+}
+
+// keyPair issues a certificate for the given template as a tls.Certificate.
+func (p *testPKI) keyPair(tmpl *x509.Certificate) tls.Certificate {
+	key, cert := p.issue(tmpl)
+	return tls.Certificate{Certificate: [][]byte{cert.Raw}, PrivateKey: key, Leaf: cert}
+}
+
+// handshake connects a client with config to the proxy listening with
+// proxy and returns both ends once both finished the handshake, with the
+// error of the server side.
+func handshake(t *testing.T, proxy, config *tls.Config) (client, server *tls.Conn, err error) {
+	c, s := tcpPair(t)
+	client = tls.Client(c, config)
+	server = tls.Server(s, proxy)
+	done := make(chan struct{})
+	go func() {
+		c.SetDeadline(time.Now().Add(5 * time.Second))
+		client.Handshake()
+		c.SetDeadline(time.Time{})
+		close(done)
+	}()
+	s.SetDeadline(time.Now().Add(5 * time.Second))
+	err = server.Handshake()
+	s.SetDeadline(time.Time{})
+	if err != nil {
+		// the client waits for a server that gave up
+		s.Close()
+	}
+	<-done
+	return client, server, err
+}
+
+func TestRouteMatch(t *testing.T) {
+	chat := &route{ServerName: "chat.example", Backend: "chat"}
+	h2 := &route{ServerName: "*.example", ALPN: []string{"h2"}, Backend: "web"}
+	any := &route{ServerName: "*.example", Backend: "any"}
+	def := &route{Backend: "default"}
+	table := &routeTable{Routes: []*route{chat, h2, any}, Default: def}
+
+	tests := []struct {
+		name       string
+		serverName string
+		protos     []string
+		want       *route
+		wantErr    error
+	}{
+		{"exact name", "chat.example", nil, chat, nil},
+		{"case and trailing dot", "CHAT.Example.", []string{"h2"}, chat, nil},
+		{"wildcard with alpn", "www.example", []string{"http/1.1", "h2"}, h2, nil},
+		{"wildcard without the alpn", "www.example", []string{"http/1.1"}, any, nil},
+		{"wildcard covers one label", "a.b.example", nil, def, nil},
+		{"wildcard needs a label", "example", nil, def, nil},
+		{"no sni", "", nil, def, nil},
+	}
+	for _, tt := range tests {
+		got, err := table.match(tt.serverName, tt.protos)
+		if got != tt.want || err != tt.wantErr {
+			t.Errorf("%s: got %v, %v, want %v, %v", tt.name, got, err, tt.want, tt.wantErr)
+		}
+	}
+
+	table.RejectUnknown = true
+	if _, err := table.match("other.test", nil); err != errUnknownServerName {
+		t.Fatalf("unknown name with reject_unknown: got %v", err)
+	}
+}
+
+func TestRouteHandshake(t *testing.T) {
+	p := newTestPKI(t)
+	key, cert := p.issue(&x509.Certificate{DNSNames: []string{"chat.example", "www.example"}})
+	der, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certFile := p.write("proxy.crt", "CERTIFICATE", cert.Raw)
+	keyFile := p.write("proxy.key", "EC PRIVATE KEY", der)
+	b, _ := json.Marshal(routeTable{
+		Routes: []*route{
+			{ServerName: "www.example", ALPN: []string{"h2", "http/1.1"}, CertFile: certFile, KeyFile: keyFile, Backend: "web"},
+			{ServerName: "chat.example", CertFile: certFile, KeyFile: keyFile, Backend: "chat", Authenticate: true},
+		},
+		RejectUnknown: true,
+	})
+	path := filepath.Join(p.dir, "routes.json")
+	if err := ioutil.WriteFile(path, b, 0600); err != nil {
+		t.Fatal(err)
+	}
+	routes, err := loadRoutes(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	proxy := &tls.Config{GetConfigForClient: routes.getConfigForClient}
+
+	tests := []struct {
+		name        string
+		serverName  string
+		protos      []string
+		wantBackend string // empty when the handshake fails
+		wantProto   string
+	}{
+		{"web", "www.example", []string{"h2"}, "web", "h2"},
+		{"web prefers the route's order", "www.example", []string{"http/1.1", "h2"}, "web", "h2"},
+		{"web over http/1.1", "www.example", []string{"http/1.1"}, "web", "http/1.1"},
+		{"chat without alpn", "chat.example", nil, "chat", ""},
+		{"unknown name", "mail.example", nil, "", ""},
+	}
+	for _, tt := range tests {
+		config := &tls.Config{ServerName: tt.serverName, RootCAs: p.pool, NextProtos: tt.protos}
+		client, server, err := handshake(t, proxy, config)
+		if (err != nil) != (tt.wantBackend == "") {
+			t.Errorf("%s: handshake: %v", tt.name, err)
+		}
+		if err == nil {
+			state := server.ConnectionState()
+			r, err := routes.lookup(state)
+			if err != nil || r.Backend != tt.wantBackend || state.NegotiatedProtocol != tt.wantProto {
+				t.Errorf("%s: routed to %v with %q, %v, want %s with %q", tt.name, r, state.NegotiatedProtocol, err, tt.wantBackend, tt.wantProto)
+			}
+		}
+		client.Close()
+		server.Close()
+	}
+}
@@ -0,0 +1,241 @@
+package main
+
+import (
+	"net"
+	"os"
+	"os/exec"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/Apurer/e2eechat/dispatch"
+)
+
+// syncConn serializes writes so that control messages generated by the proxy
+// are never interleaved with relayed data.
+type syncConn struct {
+	net.Conn
+	mu sync.Mutex
+}
+
+func (c *syncConn) Write(b []byte) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.Conn.Write(b)
+}
+
+func (c *syncConn) CloseWrite() error {
+	if cw, ok := c.Conn.(closeWriter); ok {
+		return cw.CloseWrite()
+	}
+	return nil
+}
+
+// sessionTracker keeps track of every connection handled by the proxy so
+// that they can be notified and drained on shutdown.
+type sessionTracker struct {
+	wg       sync.WaitGroup
+	mu       sync.Mutex
+	closing  bool
+	conns    map[net.Conn]struct{}
+	relaying map[*syncConn]shutdownNotice
+}
+
+// shutdownNotice is how a relaying client learns about a shutdown.
+type shutdownNotice int
+
+const (
+	// noticeNone lets the session drain, the backend does not speak
+	// dispatch.
+	noticeNone shutdownNotice = iota
+	// noticeGoAway sends a GOAWAY action.
+	noticeGoAway
+	// noticeClose closes the connection of a client that did not negotiate
+	// FLOW_CONTROL and would not understand a GOAWAY.
+	noticeClose
+)
+
+func newSessionTracker() *sessionTracker {
+	return &sessionTracker{
+		conns:    make(map[net.Conn]struct{}),
+		relaying: make(map[*syncConn]shutdownNotice),
+	}
+}
+
+// add registers a freshly accepted connection. It reports false once
+// shutdown has started.
+func (t *sessionTracker) add(conn net.Conn) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.closing {
+		return false
+	}
+	t.conns[conn] = struct{}{}
+	t.wg.Add(1)
+	return true
+}
+
+func (t *sessionTracker) done(conn net.Conn) {
+	t.mu.Lock()
+	delete(t.conns, conn)
+	t.mu.Unlock()
+	t.wg.Done()
+}
+
+// startRelay marks the client as relaying, notice is how it is told about a
+// shutdown. It reports false once shutdown has started.
+func (t *sessionTracker) startRelay(client *syncConn, notice shutdownNotice) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.closing {
+		return false
+	}
+	t.relaying[client] = notice
+	return true
+}
+
+func (t *sessionTracker) stopRelay(client *syncConn) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	delete(t.relaying, client)
+}
+
+func (t *sessionTracker) isClosing() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.closing
+}
+
+// shutdown stops accepting on lns, tells every relaying client that the
+// server is going away and waits up to timeout for sessions to finish before
+// closing the remaining connections.
+func (t *sessionTracker) shutdown(lns []net.Listener, reason string, timeout time.Duration) {
+	t.mu.Lock()
+	t.closing = true
+	t.mu.Unlock()
+
+	for _, ln := range lns {
+		ln.Close()
+	}
+
+	goAway, err := (&dispatch.GoAway{
+		Reason:   reason,
+		Deadline: time.Now().Add(timeout).Unix(),
+	}).Action()
+	if err != nil {
+		logger.Error("encoding goaway", "error", err)
+	}
+
+	t.mu.Lock()
+	for client, notice := range t.relaying {
+		if notice == noticeClose {
+			client.Close()
+			continue
+		}
+		if notice != noticeGoAway || goAway == nil {
+			continue
+		}
+		// a client that stopped reading must not hold up the others
+		go func(client *syncConn) {
+			if err := dispatch.WriteMessage(client, goAway); err != nil {
+				logger.Debug("sending goaway", "remote", client.RemoteAddr(), "error", err)
+			}
+		}(client)
+	}
+	t.mu.Unlock()
+
+	drained := make(chan struct{})
+	go func() {
+		t.wg.Wait()
+		close(drained)
+	}()
+
+	select {
+	case <-drained:
+		return
+	case <-time.After(timeout):
+	}
+
+	t.mu.Lock()
+	logger.Warn("drain deadline passed, closing connections", "remaining", len(t.conns))
+	for conn := range t.conns {
+		conn.Close()
+	}
+	t.mu.Unlock()
+
+	<-drained
+}
+
+// listen returns the listeners inherited from a parent process (or systemd
+// socket activation) when LISTEN_FDS matches the number of addresses, in the
+// same order, and fresh ones otherwise.
+func listen(addrs ...string) ([]net.Listener, error) {
+	inherit := os.Getenv("LISTEN_FDS") == strconv.Itoa(len(addrs))
+	if pid := os.Getenv("LISTEN_PID"); pid != "" && pid != strconv.Itoa(os.Getpid()) {
+		inherit = false
+	}
+	os.Unsetenv("LISTEN_FDS")
+	os.Unsetenv("LISTEN_PID")
+
+	lns := make([]net.Listener, 0, len(addrs))
+	for i, addr := range addrs {
+		var (
+			ln  net.Listener
+			err error
+		)
+		if inherit {
+			// inherited descriptors start right after stdin, stdout and stderr
+			f := os.NewFile(uintptr(3+i), "listener")
+			ln, err = net.FileListener(f)
+			f.Close()
+		} else {
+			ln, err = net.Listen("tcp", addr)
+		}
+		if err != nil {
+			for _, ln := range lns {
+				ln.Close()
+			}
+			return nil, err
+		}
+		lns = append(lns, ln)
+	}
+	return lns, nil
+}
+
+// handoff starts a new copy of the proxy which inherits the listening sockets,
+// so the caller can drain its own sessions without refusing new clients.
+func handoff(lns []net.Listener) error {
+	files := make([]*os.File, 0, len(lns))
+	defer func() {
+		for _, f := range files {
+			f.Close()
+		}
+	}()
+	for _, ln := range lns {
+		tcpLn, ok := ln.(*net.TCPListener)
+		if !ok {
+			return &net.OpError{Op: "handoff", Net: "tcp", Addr: ln.Addr(), Err: os.ErrInvalid}
+		}
+		f, err := tcpLn.File()
+		if err != nil {
+			return err
+		}
+		files = append(files, f)
+	}
+
+	path, err := os.Executable()
+	if err != nil {
+		return err
+	}
+
+	cmd := exec.Command(path, os.Args[1:]...)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	cmd.ExtraFiles = files
+	cmd.Env = append(os.Environ(), "LISTEN_FDS="+strconv.Itoa(len(files)))
+	return cmd.Start()
+}
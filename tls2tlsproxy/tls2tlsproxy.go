@@ -1,34 +1,56 @@
 package main
 
 import (
+	"bufio"
+	"crypto/ed25519"
 	"crypto/tls"
-	"log"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
 	"net"
+	"os"
+	"os/signal"
+	"strings"
 	"sync"
+	"syscall"
+	"time"
 
+	"github.com/Apurer/e2eechat/devices"
 	"github.com/Apurer/e2eechat/dispatch"
-	"github.com/golang/protobuf/proto"
+	"github.com/Apurer/e2eechat/logging"
+	"github.com/Apurer/e2eechat/proxyproto"
 )
 
 const (
 	bufferSize = 32 * 1024
+
+	// maxBackendFrame bounds the frames forwarded from the relay. They can
+	// be larger than the buffer since srvtls adds fields to what clients
+	// send and answers with lists of devices or channel members.
+	maxBackendFrame = 1 << 20
 )
 
 var (
 	bufferPool = sync.Pool{
 		New: func() interface{} {
+			poolAllocs.add("buffer", 1)
 			return make([]byte, bufferSize)
 		},
 	}
 
 	authPool = sync.Pool{
 		New: func() interface{} {
+			poolAllocs.add("auth", 1)
 			return new(dispatch.Authentication)
 		},
 	}
 )
 
 func getBuffer() []byte {
+	poolGets.add("buffer", 1)
 	return bufferPool.Get().([]byte)
 }
 
@@ -36,128 +58,607 @@ func releaseBuffer(b []byte) {
 	if len(b) != bufferSize {
 		panic("attempted to release buffer with invalid length")
 	}
+	poolPuts.add("buffer", 1)
 	bufferPool.Put(b)
 }
 
+func getAuth() *dispatch.Authentication {
+	poolGets.add("auth", 1)
+	return authPool.Get().(*dispatch.Authentication)
+}
+
+func releaseAuth(auth *dispatch.Authentication) {
+	poolPuts.add("auth", 1)
+	authPool.Put(auth)
+}
+
 const localAddr string = ":25500"
 const remoteAddr string = "127.0.0.1:25501"
 
+var (
+	handshakeTimeout = flag.Duration("handshake-timeout", 10*time.Second, "deadline for completing the TLS handshake")
+	authTimeout      = flag.Duration("auth-timeout", 10*time.Second, "deadline for receiving the Authentication message")
+	idleTimeout      = flag.Duration("idle-timeout", 5*time.Minute, "close sessions without traffic in either direction for this long (0 disables)")
+	sessionTimeout   = flag.Duration("session-timeout", 24*time.Hour, "absolute lifetime of a session (0 disables)")
+	maxConns         = flag.Int("max-conns", 10000, "maximum concurrent connections (0 means unlimited)")
+	maxConnsPerIP    = flag.Int("max-conns-per-ip", 16, "maximum concurrent connections per client IP (0 means unlimited)")
+	drainTimeout     = flag.Duration("drain-timeout", 30*time.Second, "how long to wait for sessions to finish on shutdown")
+	metricsAddr      = flag.String("metrics-addr", "127.0.0.1:25590", "address serving Prometheus metrics on /metrics (empty disables)")
+	routesPath       = flag.String("routes", "", "JSON file with SNI/ALPN routes (default: relay everything to "+remoteAddr+")")
+	authMode         = flag.String("auth-mode", authModeDispatch, "how clients of authenticating routes prove who they are: dispatch or mtls")
+	clientCA         = flag.String("client-ca", "client-ca.crt", "PEM file with the CA certificates accepted in mtls mode")
+	crlPath          = flag.String("crl", "", "CRL file checked in mtls mode (empty disables revocation checks)")
+	crlReload        = flag.Duration("crl-reload", 30*time.Second, "how often to check the CRL file for changes (0 disables)")
+	quotasPath       = flag.String("quotas", "", "JSON file with per-user and per-IP rate limit tiers (empty disables rate limiting)")
+	sessionKeyPath   = flag.String("session-key", "session.key", "PEM encoded ed25519 key signing the session headers sent to srvtls")
+	loginKeyPath     = flag.String("login-key", "login.pub", "PEM encoded ed25519 public key checking the login tokens of clients")
+	devicesPath      = flag.String("devices", "devices.json", "device registry of srvhttps, with the identity keys devices prove themselves with")
+	wsAddr           = flag.String("ws-addr", "", "address accepting browser clients over WebSocket on "+wsPath+" (empty disables)")
+	wsOrigins        = flag.String("ws-origins", "", "comma separated Origin values allowed to open WebSockets (empty allows any)")
+	minVersion       = flag.Uint("min-version", uint(dispatch.Version1), "lowest dispatch protocol version accepted from clients")
+)
+
+var (
+	logger     = logging.FromEnv("tls2tlsproxy")
+	limiter    *connLimiter
+	routes     *routeTable
+	quota      *quotas
+	sessionKey ed25519.PrivateKey
+	loginKey   ed25519.PublicKey
+	sessions   = newSessionTracker()
+)
+
 func main() {
-	log.SetFlags(log.Lshortfile)
+	flag.Parse()
+
+	limiter = newConnLimiter(*maxConns, *maxConnsPerIP)
+
+	var err error
+	sessionKey, err = loadSessionKey(*sessionKeyPath)
+	if err != nil {
+		logger.Error("loading session key", "error", err)
+		return
+	}
+
+	loginKey, err = loadLoginKey(*loginKeyPath)
+	if err != nil {
+		logger.Error("loading login key", "error", err)
+		return
+	}
+
+	if *quotasPath != "" {
+		quota, err = loadQuotas(*quotasPath)
+		if err != nil {
+			logger.Error("loading quotas", "error", err)
+			return
+		}
+	}
 
-	cer, err := tls.LoadX509KeyPair("proxy.crt", "proxy.key")
+	routes, err = loadRoutes(*routesPath)
 	if err != nil {
-		log.Println(err)
+		logger.Error("loading routes", "error", err)
+		return
+	}
+
+	switch *authMode {
+	case authModeDispatch:
+		deviceStore, err = devices.Open(*devicesPath)
+		if err != nil {
+			logger.Error("opening device registry", "error", err)
+			return
+		}
+	case authModeMTLS:
+		if err := configureMTLS(routes, *clientCA, *crlPath, *crlReload); err != nil {
+			logger.Error("configuring mtls", "error", err)
+			return
+		}
+	default:
+		logger.Error("invalid -auth-mode", "auth_mode", *authMode)
 		return
 	}
 
-	config := &tls.Config{Certificates: []tls.Certificate{cer}}
-	ln, err := tls.Listen("tcp", localAddr, config)
+	config := &tls.Config{GetConfigForClient: routes.getConfigForClient}
+	addrs := []string{localAddr}
+	if *wsAddr != "" {
+		addrs = append(addrs, *wsAddr)
+	}
+	lns, err := listen(addrs...)
 	if err != nil {
-		log.Println(err)
+		logger.Error("listen", "error", err)
 		return
 	}
-	defer ln.Close()
+	ln := lns[0]
+
+	logger.Info("listening", "addr", ln.Addr())
 
-	log.Printf("Listening: %v -> %v\n\n", localAddr, remoteAddr)
+	if *metricsAddr != "" {
+		go serveMetrics(*metricsAddr)
+	}
+
+	if *wsAddr != "" {
+		var origins []string
+		if *wsOrigins != "" {
+			origins = strings.Split(*wsOrigins, ",")
+		}
+		logger.Info("listening for websockets", "addr", lns[1].Addr())
+		go func() {
+			err := serveWebSocket(&admitListener{lns[1]}, config, origins)
+			if !sessions.isClosing() {
+				logger.Error("serving websockets", "error", err)
+			}
+		}()
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		handleSignals(lns)
+	}()
 
 	for {
 		conn, err := ln.Accept()
 		if err != nil {
-			log.Println(err)
+			if sessions.isClosing() {
+				break
+			}
+			logger.Warn("accept", "error", err)
+			continue
+		}
+
+		release, ok := admit(conn)
+		if !ok {
 			continue
 		}
-		go proxyConn(conn)
+		go func() {
+			defer release()
+			proxyConn(tls.Server(conn, config))
+		}()
+	}
+
+	<-done
+}
+
+// admit applies the connection limits to a freshly accepted connection and
+// registers it for draining. It closes rejected connections, the returned
+// function must be called once an admitted one is finished.
+func admit(conn net.Conn) (func(), bool) {
+	connsAccepted.add("", 1)
+
+	// limits are enforced before the handshake so rejected clients cost no crypto work
+	ip := remoteIP(conn)
+	if reason := limiter.acquire(ip); reason != "" {
+		reject(reason)
+		conn.Close()
+		return nil, false
+	}
+	if !sessions.add(conn) {
+		limiter.release(ip)
+		conn.Close()
+		return nil, false
+	}
+	return func() {
+		sessions.done(conn)
+		limiter.release(ip)
+	}, true
+}
+
+// handleSignals blocks until the process is asked to stop. SIGTERM and SIGINT
+// drain the sessions of this process, SIGUSR2 first hands the listening
+// sockets over to a freshly started process for a zero-downtime restart.
+func handleSignals(lns []net.Listener) {
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, syscall.SIGTERM, syscall.SIGINT, syscall.SIGUSR2)
+
+	for s := range sig {
+		reason := "shutdown"
+		if s == syscall.SIGUSR2 {
+			if err := handoff(lns); err != nil {
+				logger.Error("handoff", "error", err)
+				continue
+			}
+			reason = "restart"
+		}
+		signal.Stop(sig)
+
+		logger.Info("draining sessions", "signal", s, "timeout", *drainTimeout)
+		sessions.shutdown(lns, reason, *drainTimeout)
+		return
 	}
 }
 
-func proxyConn(conn net.Conn) {
+// identity is what the proxy learned about the client while authenticating.
+type identity struct {
+	userID        uint64
+	deviceID      uint64
+	correlationID string
+
+	// version and capabilities were negotiated with the client, enveloped
+	// is set when it opened with an Envelope and expects one back
+	version      uint32
+	capabilities []dispatch.Capability
+	enveloped    bool
+}
+
+func proxyConn(conn *tls.Conn) {
 	defer conn.Close()
 
-	buf := getBuffer()
-	defer releaseBuffer(buf)
-	n, err := conn.Read(buf)
+	l := logger.With("remote", conn.RemoteAddr())
+
+	conn.SetDeadline(time.Now().Add(*handshakeTimeout))
+	if err := conn.Handshake(); err != nil {
+		if isTimeout(err) {
+			reject("handshake_timeout")
+		}
+		l.Debug("handshake failed", "error", err)
+		return
+	}
+
+	rt, err := routes.lookup(conn.ConnectionState())
 	if err != nil {
-		log.Printf("handleConnection end: %s\n", conn.RemoteAddr())
+		l.Info("no route", "server_name", conn.ConnectionState().ServerName, "error", err)
 		return
 	}
-	if n > 0 {
-		auth := authPool.Get().(*dispatch.Authentication)
-		err = proto.Unmarshal(buf[:n], auth) // first check authorization - based on that it will decide whetever it should pass it forward or drop the connection
-		authPool.Put(auth)
-		// not sure if it should validate within proxy or from main server - maybe here it should only validate object and thats about it on proxy side
-		// need to limit database connections
-		// if user fails to authenticate just remove iptable rule allowing for connection to proxy
-		// if connection drops remove iptable rule allowing for connection to proxy
+	l = l.With("backend", rt.Backend)
+
+	// anything the client sent after its Authentication stays buffered here
+	// and is relayed once the backend is ready
+	serveClient(conn, &bufferedConn{Conn: conn, r: bufio.NewReader(conn)}, rt, l)
+}
+
+// serveClient authenticates the client if the route requires it and relays
+// it to the route's backend. client carries the traffic, conn is the TLS
+// connection underneath it.
+func serveClient(conn *tls.Conn, client net.Conn, rt *route, l *logging.Logger) {
+	id := identity{correlationID: logging.NewCorrelationID(), version: dispatch.Version1}
+
+	var err error
+	if rt.Authenticate {
+		var status dispatch.AuthResult_Status
+		if *authMode == authModeMTLS {
+			status, err = authenticateCert(conn, client, &id)
+		} else {
+			status, err = authenticate(client, &id)
+		}
 		if err != nil {
-			log.Printf("handleConnection end: %s\n", conn.RemoteAddr())
+			l.Info("authentication failed", logging.CorrelationKey, id.correlationID, "error", err)
+			sendAuthResult(client, status, err.Error(), id)
 			return
 		}
+		l = l.With("user_id", id.userID, "device_id", id.deviceID, "version", id.version)
 	}
+	l = l.With(logging.CorrelationKey, id.correlationID)
+	client.SetDeadline(time.Time{})
 
-	rAddr, err := net.ResolveTCPAddr("tcp", remoteAddr)
+	dialStart := time.Now()
+	rConn, err := tls.Dial("tcp", rt.Backend, &tls.Config{
+		InsecureSkipVerify: true,
+	})
 	if err != nil {
-		log.Print(err)
+		reject("backend_unavailable")
+		l.Error("backend dial", "error", err)
+		if rt.Authenticate {
+			sendAuthResult(client, dispatch.AuthResult_UNAVAILABLE, "relay unavailable", id)
+		}
+		return
 	}
+	backendDial.since(dialStart)
 
-	conf := &tls.Config{
-		InsecureSkipVerify: true,
+	defer rConn.Close()
+
+	if rt.ProxyProtocol {
+		if err := writeProxyHeader(rConn, conn, id); err != nil {
+			l.Error("writing proxy header", "error", err)
+			if rt.Authenticate {
+				sendAuthResult(client, dispatch.AuthResult_UNAVAILABLE, "relay unavailable", id)
+			}
+			return
+		}
 	}
 
-	rConn, err := tls.Dial("tcp", rAddr.String(), conf)
-	if err != nil {
-		log.Print(err)
+	if rt.Authenticate {
+		if err := writeSessionHeader(rConn, id); err != nil {
+			l.Error("writing session header", "error", err)
+			sendAuthResult(client, dispatch.AuthResult_UNAVAILABLE, "relay unavailable", id)
+			return
+		}
+		if err := sendAuthResult(client, dispatch.AuthResult_OK, "", id); err != nil {
+			l.Debug("sending auth result", "error", err)
+			return
+		}
+	}
+
+	sc := &syncConn{Conn: client}
+	// only the chat relay speaks dispatch, other backends must not see a GoAway
+	notice := noticeNone
+	if rt.Authenticate {
+		notice = noticeClose
+		if dispatch.HasCapability(id.capabilities, dispatch.Capability_FLOW_CONTROL) {
+			notice = noticeGoAway
+		}
+	}
+	if !sessions.startRelay(sc, notice) {
+		if rt.Authenticate {
+			reportError(sc, id, dispatch.Error_UNAVAILABLE, "proxy is shutting down")
+		}
 		return
 	}
+	defer sessions.stopRelay(sc)
 
-	defer rConn.Close()
+	if crl != nil && rt.Authenticate && *authMode == authModeMTLS {
+		defer crl.track(conn.ConnectionState().PeerCertificates[0], func() {
+			sc.SetWriteDeadline(time.Now().Add(time.Second))
+			reportError(sc, id, dispatch.Error_UNAUTHENTICATED, "client certificate revoked")
+			client.Close()
+			rConn.Close()
+		})()
+	}
+
+	if *sessionTimeout > 0 {
+		t := time.AfterFunc(*sessionTimeout, func() {
+			client.Close()
+			rConn.Close()
+		})
+		defer t.Stop()
+	}
 
-	pipe(conn, rConn)
+	var filter frameFilter
+	if quota != nil && rt.Authenticate {
+		if q := quota.session(id.userID, remoteIP(conn)); q != nil {
+			defer q.release()
+			filter = throttle(sc, id, q)
+		}
+	}
 
-	log.Printf("handleConnection end: %s\n", conn.RemoteAddr())
+	l.Info("session started")
+	activeSessions.add(1)
+	start := time.Now()
+	var tooLarge func(upstream bool)
+	if rt.Authenticate {
+		tooLarge = func(upstream bool) {
+			if upstream {
+				reportError(sc, id, dispatch.Error_TOO_LARGE, fmt.Sprintf("messages are limited to %d bytes", bufferSize-dispatch.FrameHeaderSize))
+				return
+			}
+			l.Warn("relay sent a frame too large to forward")
+			reportError(sc, id, dispatch.Error_INTERNAL, "relay sent a message too large to forward")
+		}
+	}
+	stats, err := relay(sc, rConn, rt.Authenticate, filter, tooLarge)
+	activeSessions.add(-1)
+	sessionDuration.since(start)
+	relayedBytes.add("upstream", uint64(stats.Upstream))
+	relayedBytes.add("downstream", uint64(stats.Downstream))
+	if err != nil {
+		l.Debug("relay ended with error", "error", err)
+	}
+
+	l.Info("session ended",
+		"duration", time.Since(start),
+		"bytes_up", stats.Upstream,
+		"bytes_down", stats.Downstream,
+	)
 }
 
-func chanFromConn(conn net.Conn) chan []byte {
-	c := make(chan []byte)
+var (
+	errNoUserID       = errors.New("authentication without user id")
+	errAuthIncomplete = errors.New("authentication incomplete")
+	errTokenUser      = errors.New("login token was issued to another user")
+)
+
+// authenticate reads the framed dispatch.Authentication the client has to
+// send first, checks its login token, has the device prove that it holds its
+// identity key and records who the client is in id. On failure the returned
+// status is the one to report back to the client.
+func authenticate(conn net.Conn, id *identity) (dispatch.AuthResult_Status, error) {
+	buf := getBuffer()
+	defer releaseBuffer(buf)
+	conn.SetDeadline(time.Now().Add(*authTimeout))
 
-	go func() {
-		buf := getBuffer()
-		defer releaseBuffer(buf)
+	auth := getAuth()
+	defer releaseAuth(auth)
 
-		for {
-			n, err := conn.Read(buf)
-			if err != nil {
-				c <- nil
-				break
-			}
-			if n > 0 {
-				res := make([]byte, n)
-				// Copy the buffer so it doesn't get changed while read by the recipient.
-				copy(res, buf[:n])
-				c <- res
-			}
+	// first check authorization - based on that it will decide whetever it should pass it forward or drop the connection
+	msg, err := dispatch.ReadFrame(conn, buf)
+	if err != nil {
+		return readFailure(err)
+	}
+	if status, err := openEnvelope(msg, auth, id); err != nil {
+		return status, err
+	}
+	if auth.SealedSender {
+		// anonymous session for sealed sender messages: srvtls checks the
+		// recipient's access key on each action instead of a user id
+		if logging.ValidCorrelationID(auth.CorrelationId) {
+			id.correlationID = auth.CorrelationId
 		}
-	}()
+		return dispatch.AuthResult_OK, nil
+	}
+	if auth.UserId == 0 {
+		authFailures.add("invalid_message", 1)
+		return dispatch.AuthResult_INVALID, errNoUserID
+	}
+	// srvtls trusts whatever user the proxy signs a header for, so the
+	// client has to show that it logged in as that user
+	if err := auth.LoginToken.Verify(loginKey); err != nil {
+		authFailures.add("bad_login_token", 1)
+		return dispatch.AuthResult_REJECTED, err
+	}
+	if auth.LoginToken.UserId != auth.UserId {
+		authFailures.add("bad_login_token", 1)
+		return dispatch.AuthResult_REJECTED, errTokenUser
+	}
+	if auth.DeviceId == 0 {
+		authFailures.add("invalid_message", 1)
+		return dispatch.AuthResult_INVALID, errNoDeviceID
+	}
 
-	return c
+	id.userID = auth.UserId
+	id.deviceID = auth.DeviceId
+	// adopt the id issued at login so the session can be followed across services
+	if logging.ValidCorrelationID(auth.CorrelationId) {
+		id.correlationID = auth.CorrelationId
+	}
+	return proveDevice(conn, buf, id)
 }
 
-func pipe(conn1 net.Conn, conn2 net.Conn) {
-	chan1 := chanFromConn(conn1)
-	chan2 := chanFromConn(conn2)
+// sendAuthResult answers the client's Authentication, inside an Envelope if
+// the client opened with one.
+func sendAuthResult(conn net.Conn, status dispatch.AuthResult_Status, detail string, id identity) error {
+	conn.SetWriteDeadline(time.Now().Add(*authTimeout))
+	defer conn.SetWriteDeadline(time.Time{})
 
-	for {
-		select {
-		case b1 := <-chan1:
-			if b1 == nil {
-				return
-			}
-			conn2.Write(b1)
-		case b2 := <-chan2:
-			if b2 == nil {
-				return
-			}
-			conn1.Write(b2)
+	result := &dispatch.AuthResult{
+		Status:        status,
+		Detail:        detail,
+		CorrelationId: id.correlationID,
+	}
+	if !id.enveloped {
+		return dispatch.WriteMessage(conn, result)
+	}
+	return dispatch.WriteMessage(conn, &dispatch.Envelope{
+		Version:      id.version,
+		Capabilities: id.capabilities,
+		AuthResult:   result,
+	})
+}
+
+// writeSessionHeader hands the authenticated identity to srvtls, signed so
+// that srvtls does not have to trust the transport alone.
+func writeSessionHeader(backend net.Conn, id identity) error {
+	h := &dispatch.SessionHeader{
+		UserId:        id.userID,
+		DeviceId:      id.deviceID,
+		CorrelationId: id.correlationID,
+		Version:       id.version,
+		Capabilities:  id.capabilities,
+	}
+	if err := h.Sign(sessionKey); err != nil {
+		return err
+	}
+	return dispatch.WriteMessage(backend, h)
+}
+
+// throttle returns a frame filter enforcing q. Frames over the quota are not
+// forwarded, instead the client is told when to retry. Clients that did not
+// negotiate FLOW_CONTROL cannot be told, their session ends.
+func throttle(client net.Conn, id identity, q *sessionQuota) frameFilter {
+	return func(frame []byte) (bool, error) {
+		scope, wait, ok := q.allow(len(frame))
+		if ok {
+			return true, nil
+		}
+		throttledFrames.add(strings.ToLower(scope.String()), 1)
+		if !dispatch.HasCapability(id.capabilities, dispatch.Capability_FLOW_CONTROL) {
+			return false, errThrottled
+		}
+		a, err := (&dispatch.Throttle{
+			Scope:        scope,
+			RetryAfterMs: int64(wait/time.Millisecond) + 1,
+		}).Action()
+		if err != nil {
+			return false, err
+		}
+		return false, dispatch.WriteMessage(client, a)
+	}
+}
+
+// authenticateCert takes the identity from the client certificate that was
+// verified during the handshake. Clients that offered dispatch.EnvelopeALPN
+// send an Envelope without Authentication first, read from client.
+func authenticateCert(conn *tls.Conn, client net.Conn, id *identity) (dispatch.AuthResult_Status, error) {
+	if conn.ConnectionState().NegotiatedProtocol == dispatch.EnvelopeALPN {
+		if status, err := readHello(client, id); err != nil {
+			return status, err
 		}
+	} else if uint32(*minVersion) > dispatch.Version1 {
+		authFailures.add("unsupported_version", 1)
+		return dispatch.AuthResult_UNSUPPORTED_VERSION, dispatch.ErrUnsupportedVersion
+	} else {
+		id.version = dispatch.Version1
+	}
+	// a resumed session has no VerifiedChains, verifyClientCert checked
+	// the certificate it was resumed with
+	certs := conn.ConnectionState().PeerCertificates
+	if len(certs) == 0 {
+		authFailures.add("no_certificate", 1)
+		return dispatch.AuthResult_REJECTED, errNoClientIdentity
+	}
+	userID, deviceID, err := identityFromCert(certs[0])
+	if err != nil {
+		return dispatch.AuthResult_REJECTED, err
+	}
+	id.userID = userID
+	id.deviceID = deviceID
+	return dispatch.AuthResult_OK, nil
+}
+
+// writeProxyHeader tells the backend the real client address, the verified
+// user and device if any, the correlation id and the parameters of the client TLS session.
+func writeProxyHeader(backend net.Conn, client *tls.Conn, id identity) error {
+	src, _ := client.RemoteAddr().(*net.TCPAddr)
+	dst, _ := client.LocalAddr().(*net.TCPAddr)
+
+	state := client.ConnectionState()
+	h := &proxyproto.Header{
+		Source:      src,
+		Destination: dst,
+		TLVs: []proxyproto.TLV{
+			proxyproto.SSLTLV(state, len(state.VerifiedChains) > 0),
+			{Type: proxyproto.TypeCorrelationID, Value: []byte(id.correlationID)},
+		},
+	}
+	// only authenticating routes know who the client is
+	if id.userID != 0 {
+		h.TLVs = append(h.TLVs, proxyproto.UserIDTLV(id.userID), proxyproto.DeviceIDTLV(id.deviceID))
+	}
+	if state.ServerName != "" {
+		h.TLVs = append(h.TLVs, proxyproto.TLV{Type: proxyproto.TypeAuthority, Value: []byte(state.ServerName)})
+	}
+	_, err := h.WriteTo(backend)
+	return err
+}
+
+func loadSessionKey(path string) (ed25519.PrivateKey, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(b)
+	if block == nil {
+		return nil, fmt.Errorf("%s: no PEM data", path)
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
 	}
+	priv, ok := key.(ed25519.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("%s: not an ed25519 key", path)
+	}
+	return priv, nil
+}
+
+func loadLoginKey(path string) (ed25519.PublicKey, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(b)
+	if block == nil {
+		return nil, fmt.Errorf("%s: no PEM data", path)
+	}
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	pub, ok := key.(ed25519.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("%s: not an ed25519 key", path)
+	}
+	return pub, nil
+}
+
+func isTimeout(err error) bool {
+	nerr, ok := err.(net.Error)
+	return ok && nerr.Timeout()
 }
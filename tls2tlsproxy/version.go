@@ -0,0 +1,74 @@
+package main
+
+import (
+	"errors"
+	"net"
+	"time"
+
+	"github.com/Apurer/e2eechat/dispatch"
+	"github.com/golang/protobuf/proto"
+)
+
+var errNoAuthentication = errors.New("envelope without authentication")
+
+// openEnvelope decodes the first message of a client into auth. Version 1
+// clients send a bare Authentication, later ones an Envelope around it, and
+// the negotiated version and capabilities are recorded in id.
+func openEnvelope(msg []byte, auth *dispatch.Authentication, id *identity) (dispatch.AuthResult_Status, error) {
+	var env dispatch.Envelope
+	if err := proto.Unmarshal(msg, &env); err != nil {
+		authFailures.add("invalid_message", 1)
+		return dispatch.AuthResult_INVALID, err
+	}
+	if env.Version == 0 {
+		auth.Reset()
+		if err := proto.Unmarshal(msg, auth); err != nil {
+			authFailures.add("invalid_message", 1)
+			return dispatch.AuthResult_INVALID, err
+		}
+		return negotiate(id, &env)
+	}
+
+	id.enveloped = true
+	if status, err := negotiate(id, &env); err != nil {
+		return status, err
+	}
+	if env.Authentication == nil {
+		authFailures.add("invalid_message", 1)
+		return dispatch.AuthResult_INVALID, errNoAuthentication
+	}
+	auth.Reset()
+	proto.Merge(auth, env.Authentication)
+	return dispatch.AuthResult_OK, nil
+}
+
+// readHello reads the Envelope a client authenticated by certificate opens
+// with. Its Authentication is ignored.
+func readHello(client net.Conn, id *identity) (dispatch.AuthResult_Status, error) {
+	buf := getBuffer()
+	defer releaseBuffer(buf)
+	client.SetReadDeadline(time.Now().Add(*authTimeout))
+
+	var env dispatch.Envelope
+	if err := dispatch.ReadMessage(client, buf, &env); err != nil {
+		return readFailure(err)
+	}
+	id.enveloped = true
+	return negotiate(id, &env)
+}
+
+// negotiate settles the version and capabilities of a session. Newer clients
+// are downgraded to dispatch.CurrentVersion, older ones than -min-version
+// are refused.
+func negotiate(id *identity, env *dispatch.Envelope) (dispatch.AuthResult_Status, error) {
+	version, err := dispatch.NegotiateVersion(env.Version, uint32(*minVersion), dispatch.CurrentVersion)
+	if err != nil {
+		// answer in the newest version, the client has to upgrade anyway
+		id.version = dispatch.CurrentVersion
+		authFailures.add("unsupported_version", 1)
+		return dispatch.AuthResult_UNSUPPORTED_VERSION, err
+	}
+	id.version = version
+	id.capabilities = dispatch.NegotiateCapabilities(version, env.Capabilities, dispatch.SupportedCapabilities)
+	return dispatch.AuthResult_OK, nil
+}
@@ -0,0 +1,440 @@
+package main
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Apurer/e2eechat/dispatch"
+)
+
+// Browsers cannot open raw TLS sockets, so the proxy can also accept chat
+// clients over WebSocket (RFC 6455). Every binary message carries exactly one
+// dispatch message; wsConn translates between that and the length-prefixed
+// frames used everywhere else, so the rest of the proxy cannot tell the two
+// kinds of client apart.
+
+const (
+	wsPath        = "/ws"
+	wsSubprotocol = "e2eechat.dispatch"
+	wsGUID        = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+	wsOpContinuation = 0x0
+	wsOpText         = 0x1
+	wsOpBinary       = 0x2
+	wsOpClose        = 0x8
+	wsOpPing         = 0x9
+	wsOpPong         = 0xA
+
+	wsCloseNormal      = 1000
+	wsCloseUnsupported = 1003
+	wsCloseProtocol    = 1002
+	wsCloseTooBig      = 1009
+
+	maxControlPayload = 125
+)
+
+var (
+	errWSProtocol   = errors.New("websocket: protocol error")
+	errWSText       = errors.New("websocket: text messages are not supported")
+	errWSTimeout    = errors.New("websocket: read timeout inside a message")
+	errWSNotFramed  = errors.New("websocket: write is not a sequence of dispatch frames")
+	errWSBadRequest = errors.New("websocket: bad handshake request")
+	errWSClosed     = errors.New("websocket: close frame already sent")
+)
+
+// serveWebSocket accepts WebSocket clients on ln until it is closed.
+func serveWebSocket(ln net.Listener, config *tls.Config, origins []string) error {
+	srv := &http.Server{
+		Handler:           &wsHandler{origins: origins},
+		TLSConfig:         config,
+		ReadHeaderTimeout: *handshakeTimeout,
+		ErrorLog:          log.New(httpErrorLog{}, "", 0),
+	}
+	return srv.Serve(tls.NewListener(ln, config))
+}
+
+// httpErrorLog sends the complaints of the http package, mostly failed
+// handshakes, to the debug log instead of plain text on stderr.
+type httpErrorLog struct{}
+
+func (httpErrorLog) Write(b []byte) (int, error) {
+	logger.Debug("http server", "error", strings.TrimSpace(string(b)))
+	return len(b), nil
+}
+
+// admitListener applies the same limits to WebSocket clients as to
+// connections on the main listener.
+type admitListener struct {
+	net.Listener
+}
+
+func (l *admitListener) Accept() (net.Conn, error) {
+	for {
+		conn, err := l.Listener.Accept()
+		if err != nil {
+			return nil, err
+		}
+		if release, ok := admit(conn); ok {
+			return &admittedConn{Conn: conn, release: release}, nil
+		}
+	}
+}
+
+// admittedConn releases its admission when closed.
+type admittedConn struct {
+	net.Conn
+	release func()
+	once    sync.Once
+}
+
+func (c *admittedConn) Close() error {
+	err := c.Conn.Close()
+	c.once.Do(c.release)
+	return err
+}
+
+type wsHandler struct {
+	origins []string
+}
+
+func (h *wsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	l := logger.With("remote", r.RemoteAddr, "transport", "websocket")
+
+	if r.URL.Path != wsPath {
+		http.NotFound(w, r)
+		return
+	}
+	if !h.allowOrigin(r.Header.Get("Origin")) {
+		reject("websocket_origin")
+		http.Error(w, "origin not allowed", http.StatusForbidden)
+		return
+	}
+	key, err := wsCheckRequest(r)
+	if err != nil {
+		reject("websocket_handshake")
+		w.Header().Set("Sec-WebSocket-Version", "13")
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	hj, ok := w.(http.Hijacker)
+	if !ok {
+		http.Error(w, "websocket not supported", http.StatusInternalServerError)
+		return
+	}
+	netConn, brw, err := hj.Hijack()
+	if err != nil {
+		l.Error("hijack", "error", err)
+		return
+	}
+	defer netConn.Close()
+
+	conn, ok := netConn.(*tls.Conn)
+	if !ok {
+		return
+	}
+	rt, err := routes.lookup(conn.ConnectionState())
+	if err != nil || !rt.Authenticate {
+		// only the chat relay speaks dispatch
+		reject("unknown_server_name")
+		return
+	}
+	l = l.With("backend", rt.Backend)
+
+	resp := "HTTP/1.1 101 Switching Protocols\r\n" +
+		"Upgrade: websocket\r\n" +
+		"Connection: Upgrade\r\n" +
+		"Sec-WebSocket-Accept: " + wsAcceptKey(key) + "\r\n"
+	if wsOffers(r, wsSubprotocol) {
+		resp += "Sec-WebSocket-Protocol: " + wsSubprotocol + "\r\n"
+	}
+	resp += "\r\n"
+	conn.SetWriteDeadline(time.Now().Add(*handshakeTimeout))
+	if _, err := io.WriteString(conn, resp); err != nil {
+		l.Debug("writing handshake response", "error", err)
+		return
+	}
+	conn.SetWriteDeadline(time.Time{})
+
+	// the auth deadline starts now, like right after a TLS handshake
+	conn.SetDeadline(time.Now().Add(*authTimeout))
+	serveClient(conn, newWSConn(conn, brw.Reader), rt, l)
+}
+
+func (h *wsHandler) allowOrigin(origin string) bool {
+	if len(h.origins) == 0 {
+		return true
+	}
+	for _, o := range h.origins {
+		if strings.EqualFold(o, origin) {
+			return true
+		}
+	}
+	return false
+}
+
+// wsCheckRequest validates an upgrade request and returns its key.
+func wsCheckRequest(r *http.Request) (string, error) {
+	if r.Method != http.MethodGet ||
+		!wsHeaderContains(r.Header, "Connection", "upgrade") ||
+		!wsHeaderContains(r.Header, "Upgrade", "websocket") ||
+		r.Header.Get("Sec-WebSocket-Version") != "13" {
+		return "", errWSBadRequest
+	}
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if b, err := base64.StdEncoding.DecodeString(key); err != nil || len(b) != 16 {
+		return "", errWSBadRequest
+	}
+	return key, nil
+}
+
+func wsHeaderContains(h http.Header, name, token string) bool {
+	for _, v := range h[http.CanonicalHeaderKey(name)] {
+		for _, t := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(t), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func wsOffers(r *http.Request, protocol string) bool {
+	return wsHeaderContains(r.Header, "Sec-WebSocket-Protocol", protocol)
+}
+
+func wsAcceptKey(key string) string {
+	h := sha1.Sum([]byte(key + wsGUID))
+	return base64.StdEncoding.EncodeToString(h[:])
+}
+
+// wsConn adapts a WebSocket connection to the dispatch framing. Read returns
+// each incoming binary message prefixed with its length, Write expects whole
+// dispatch frames and sends each one as a binary message.
+type wsConn struct {
+	net.Conn
+	r *bufio.Reader
+
+	// pending holds the rest of the last message not yet returned by Read
+	pending []byte
+	msg     []byte
+
+	wmu    sync.Mutex
+	closed bool
+}
+
+func newWSConn(conn net.Conn, r *bufio.Reader) *wsConn {
+	return &wsConn{Conn: conn, r: r}
+}
+
+func (c *wsConn) Read(b []byte) (int, error) {
+	if len(c.pending) == 0 {
+		if err := c.readMessage(); err != nil {
+			return 0, err
+		}
+	}
+	n := copy(b, c.pending)
+	c.pending = c.pending[n:]
+	return n, nil
+}
+
+// readMessage reads the next complete binary message into pending, answering
+// control frames on the way.
+func (c *wsConn) readMessage() error {
+	msg := append(c.msg[:0], 0, 0, 0, 0)
+	started := false
+	for {
+		// a timeout before the first byte of a message leaves the stream
+		// intact, so it is passed on for the idle timeout logic to decide
+		if !started {
+			if _, err := c.r.Peek(1); err != nil {
+				return err
+			}
+		}
+		fin, op, payload, err := c.readFrame()
+		if err != nil {
+			if isTimeout(err) {
+				return errWSTimeout
+			}
+			return err
+		}
+
+		switch op {
+		case wsOpPing:
+			if err := c.writeFrame(wsOpPong, payload); err != nil {
+				return err
+			}
+			continue
+		case wsOpPong:
+			continue
+		case wsOpClose:
+			c.writeClose(wsCloseNormal)
+			return io.EOF
+		case wsOpText:
+			c.writeClose(wsCloseUnsupported)
+			return errWSText
+		case wsOpBinary:
+			if started {
+				c.writeClose(wsCloseProtocol)
+				return errWSProtocol
+			}
+			started = true
+		case wsOpContinuation:
+			if !started {
+				c.writeClose(wsCloseProtocol)
+				return errWSProtocol
+			}
+		default:
+			c.writeClose(wsCloseProtocol)
+			return errWSProtocol
+		}
+
+		if len(msg)+len(payload) > bufferSize {
+			c.writeClose(wsCloseTooBig)
+			return dispatch.ErrFrameTooLarge
+		}
+		msg = append(msg, payload...)
+		if fin {
+			break
+		}
+	}
+
+	binary.BigEndian.PutUint32(msg, uint32(len(msg)-dispatch.FrameHeaderSize))
+	c.msg = msg
+	c.pending = msg
+	return nil
+}
+
+// readFrame reads one frame and unmasks its payload.
+func (c *wsConn) readFrame() (fin bool, op byte, payload []byte, err error) {
+	var hdr [2]byte
+	if _, err = io.ReadFull(c.r, hdr[:]); err != nil {
+		return
+	}
+	fin = hdr[0]&0x80 != 0
+	op = hdr[0] & 0x0F
+	if hdr[0]&0x70 != 0 || hdr[1]&0x80 == 0 {
+		// no extensions were negotiated and clients must mask
+		return false, 0, nil, errWSProtocol
+	}
+
+	n := uint64(hdr[1] & 0x7F)
+	switch n {
+	case 126:
+		var ext [2]byte
+		if _, err = io.ReadFull(c.r, ext[:]); err != nil {
+			return
+		}
+		n = uint64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err = io.ReadFull(c.r, ext[:]); err != nil {
+			return
+		}
+		n = binary.BigEndian.Uint64(ext[:])
+	}
+	if op >= wsOpClose && (n > maxControlPayload || !fin) {
+		return false, 0, nil, errWSProtocol
+	}
+	if n > bufferSize {
+		return false, 0, nil, dispatch.ErrFrameTooLarge
+	}
+
+	var mask [4]byte
+	if _, err = io.ReadFull(c.r, mask[:]); err != nil {
+		return
+	}
+	payload = make([]byte, n)
+	if _, err = io.ReadFull(c.r, payload); err != nil {
+		return
+	}
+	for i := range payload {
+		payload[i] ^= mask[i%4]
+	}
+	return fin, op, payload, nil
+}
+
+// Write sends every dispatch frame in b as one binary message.
+func (c *wsConn) Write(b []byte) (int, error) {
+	written := 0
+	for len(b) > 0 {
+		if len(b) < dispatch.FrameHeaderSize {
+			return written, errWSNotFramed
+		}
+		n := int(binary.BigEndian.Uint32(b)) + dispatch.FrameHeaderSize
+		if n > len(b) {
+			return written, errWSNotFramed
+		}
+		if err := c.writeFrame(wsOpBinary, b[dispatch.FrameHeaderSize:n]); err != nil {
+			return written, err
+		}
+		written += n
+		b = b[n:]
+	}
+	return written, nil
+}
+
+func (c *wsConn) writeFrame(op byte, payload []byte) error {
+	hdr := make([]byte, 2, 10+len(payload))
+	hdr[0] = 0x80 | op
+	switch n := len(payload); {
+	case n < 126:
+		hdr[1] = byte(n)
+	case n <= 0xFFFF:
+		hdr[1] = 126
+		hdr = append(hdr, byte(n>>8), byte(n))
+	default:
+		hdr[1] = 127
+		var ext [8]byte
+		binary.BigEndian.PutUint64(ext[:], uint64(n))
+		hdr = append(hdr, ext[:]...)
+	}
+
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	if c.closed {
+		return errWSClosed
+	}
+	_, err := c.Conn.Write(append(hdr, payload...))
+	return err
+}
+
+// writeClose sends a close frame. Nothing may be written after it.
+func (c *wsConn) writeClose(code uint16) {
+	var payload [2]byte
+	binary.BigEndian.PutUint16(payload[:], code)
+	c.Conn.SetWriteDeadline(time.Now().Add(time.Second))
+	c.writeFrame(wsOpClose, payload[:])
+
+	c.wmu.Lock()
+	c.closed = true
+	c.wmu.Unlock()
+}
+
+// CloseWrite tells the client that no more messages follow, the WebSocket
+// equivalent of a TCP half-close.
+func (c *wsConn) CloseWrite() error {
+	c.writeClose(wsCloseNormal)
+	return nil
+}
+
+func (c *wsConn) Close() error {
+	c.wmu.Lock()
+	closed := c.closed
+	c.wmu.Unlock()
+	if !closed {
+		c.writeClose(wsCloseNormal)
+	}
+	return c.Conn.Close()
+}
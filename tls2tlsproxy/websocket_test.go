@@ -0,0 +1,337 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/Apurer/e2eechat/dispatch"
+)
+
+// clientFrame builds a frame as a browser sends it, masked unless unmasked
+// is set.
+func clientFrame(fin bool, op byte, payload []byte, unmasked bool) []byte {
+	b := []byte{op, 0}
+	if fin {
+		b[0] |= 0x80
+	}
+	switch n := len(payload); {
+	case n < 126:
+		b[1] = byte(n)
+	case n <= 0xFFFF:
+		b[1] = 126
+		b = append(b, byte(n>>8), byte(n))
+	default:
+		b[1] = 127
+		var ext [8]byte
+		binary.BigEndian.PutUint64(ext[:], uint64(n))
+		b = append(b, ext[:]...)
+	}
+	if unmasked {
+		return append(b, payload...)
+	}
+	b[1] |= 0x80
+	mask := [4]byte{0x37, 0xfa, 0x21, 0x3d}
+	b = append(b, mask[:]...)
+	for i, c := range payload {
+		b = append(b, c^mask[i%4])
+	}
+	return b
+}
+
+// readServerFrame reads one unmasked frame sent by the proxy.
+func readServerFrame(r io.Reader) (op byte, payload []byte, err error) {
+	var hdr [2]byte
+	if _, err = io.ReadFull(r, hdr[:]); err != nil {
+		return
+	}
+	if hdr[0]&0x80 == 0 || hdr[1]&0x80 != 0 {
+		return 0, nil, errWSProtocol
+	}
+	n := uint64(hdr[1])
+	switch n {
+	case 126:
+		var ext [2]byte
+		if _, err = io.ReadFull(r, ext[:]); err != nil {
+			return
+		}
+		n = uint64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err = io.ReadFull(r, ext[:]); err != nil {
+			return
+		}
+		n = binary.BigEndian.Uint64(ext[:])
+	}
+	payload = make([]byte, n)
+	_, err = io.ReadFull(r, payload)
+	return hdr[0] & 0x0F, payload, err
+}
+
+func closeFrame(code uint16) []byte {
+	var b [2]byte
+	binary.BigEndian.PutUint16(b[:], code)
+	return b[:]
+}
+
+func TestWSConnRead(t *testing.T) {
+	medium := bytes.Repeat([]byte("m"), 300)
+	large := bytes.Repeat([]byte("l"), 20000)
+	cat := func(parts ...[]byte) []byte { return bytes.Join(parts, nil) }
+
+	type reply struct {
+		op      byte
+		payload []byte
+	}
+	tests := []struct {
+		name    string
+		sent    []byte
+		want    []byte // the dispatch frame Read returns
+		wantErr error
+		replies []reply // frames the proxy answers with
+	}{
+		{
+			name: "binary message",
+			sent: clientFrame(true, wsOpBinary, []byte("hello"), false),
+			want: dispatch.AppendFrame(nil, []byte("hello")),
+		},
+		{
+			name: "16 bit length",
+			sent: clientFrame(true, wsOpBinary, medium, false),
+			want: dispatch.AppendFrame(nil, medium),
+		},
+		{
+			name: "fragments around a ping",
+			sent: cat(
+				clientFrame(false, wsOpBinary, large[:10000], false),
+				clientFrame(true, wsOpPing, []byte("are you there"), false),
+				clientFrame(false, wsOpContinuation, large[10000:15000], false),
+				clientFrame(true, wsOpContinuation, large[15000:], false),
+			),
+			want:    dispatch.AppendFrame(nil, large),
+			replies: []reply{{wsOpPong, []byte("are you there")}},
+		},
+		{
+			name: "pong is ignored",
+			sent: cat(
+				clientFrame(true, wsOpPong, nil, false),
+				clientFrame(true, wsOpBinary, []byte("x"), false),
+			),
+			want: dispatch.AppendFrame(nil, []byte("x")),
+		},
+		{
+			name:    "close",
+			sent:    clientFrame(true, wsOpClose, closeFrame(wsCloseNormal), false),
+			wantErr: io.EOF,
+			replies: []reply{{wsOpClose, closeFrame(wsCloseNormal)}},
+		},
+		{
+			name:    "text message",
+			sent:    clientFrame(true, wsOpText, []byte("hello"), false),
+			wantErr: errWSText,
+			replies: []reply{{wsOpClose, closeFrame(wsCloseUnsupported)}},
+		},
+		{
+			name:    "continuation without a start",
+			sent:    clientFrame(true, wsOpContinuation, []byte("x"), false),
+			wantErr: errWSProtocol,
+			replies: []reply{{wsOpClose, closeFrame(wsCloseProtocol)}},
+		},
+		{
+			name: "new message inside a fragmented one",
+			sent: cat(
+				clientFrame(false, wsOpBinary, []byte("a"), false),
+				clientFrame(true, wsOpBinary, []byte("b"), false),
+			),
+			wantErr: errWSProtocol,
+			replies: []reply{{wsOpClose, closeFrame(wsCloseProtocol)}},
+		},
+		{
+			name:    "unknown opcode",
+			sent:    clientFrame(true, 0x3, nil, false),
+			wantErr: errWSProtocol,
+			replies: []reply{{wsOpClose, closeFrame(wsCloseProtocol)}},
+		},
+		{
+			name:    "unmasked",
+			sent:    clientFrame(true, wsOpBinary, []byte("x"), true),
+			wantErr: errWSProtocol,
+		},
+		{
+			name:    "fragmented ping",
+			sent:    clientFrame(false, wsOpPing, nil, false),
+			wantErr: errWSProtocol,
+		},
+		{
+			name:    "frame too large",
+			sent:    clientFrame(true, wsOpBinary, make([]byte, bufferSize+1), false),
+			wantErr: dispatch.ErrFrameTooLarge,
+		},
+		{
+			name: "message too large",
+			sent: cat(
+				clientFrame(false, wsOpBinary, make([]byte, bufferSize/2), false),
+				clientFrame(true, wsOpContinuation, make([]byte, bufferSize/2), false),
+			),
+			wantErr: dispatch.ErrFrameTooLarge,
+			replies: []reply{{wsOpClose, closeFrame(wsCloseTooBig)}},
+		},
+	}
+	for _, tt := range tests {
+		client, s := tcpPair(t)
+		go client.Write(tt.sent)
+
+		c := newWSConn(s, bufio.NewReader(s))
+		// small reads must see the message across calls
+		var got []byte
+		var err error
+		buf := make([]byte, 4096)
+		for {
+			var n int
+			n, err = c.Read(buf)
+			got = append(got, buf[:n]...)
+			if err != nil || len(got) >= len(tt.want) {
+				break
+			}
+		}
+		if err != tt.wantErr {
+			t.Errorf("%s: got %v, want %v", tt.name, err, tt.wantErr)
+		}
+		if tt.wantErr == nil && !bytes.Equal(got, tt.want) {
+			t.Errorf("%s: read %d bytes, want %d", tt.name, len(got), len(tt.want))
+		}
+
+		client.SetReadDeadline(time.Now().Add(time.Second))
+		for _, want := range tt.replies {
+			op, payload, err := readServerFrame(client)
+			if err != nil || op != want.op || !bytes.Equal(payload, want.payload) {
+				t.Errorf("%s: proxy sent op %x %q, %v, want op %x %q", tt.name, op, payload, err, want.op, want.payload)
+			}
+		}
+		client.Close()
+		s.Close()
+	}
+}
+
+func TestWSConnWrite(t *testing.T) {
+	client, s := tcpPair(t)
+	defer client.Close()
+	c := newWSConn(s, bufio.NewReader(s))
+
+	small := []byte("hi")
+	medium := bytes.Repeat([]byte("m"), 300)
+	large := bytes.Repeat([]byte("l"), 70000)
+	frames := dispatch.AppendFrame(dispatch.AppendFrame(nil, small), medium)
+
+	done := make(chan error, 1)
+	go func() {
+		if _, err := c.Write(frames); err != nil {
+			done <- err
+			return
+		}
+		_, err := c.Write(dispatch.AppendFrame(nil, large))
+		done <- err
+	}()
+	for _, want := range [][]byte{small, medium, large} {
+		op, payload, err := readServerFrame(client)
+		if err != nil || op != wsOpBinary || !bytes.Equal(payload, want) {
+			t.Fatalf("got op %x with %d bytes, %v, want a binary message of %d bytes", op, len(payload), err, len(want))
+		}
+	}
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		b    []byte
+		want int
+	}{
+		{"short header", []byte{0, 0}, 0},
+		{"truncated frame", frames[:len(frames)-1], len(dispatch.AppendFrame(nil, small))},
+	}
+	for _, tt := range tests {
+		n, err := c.Write(tt.b)
+		if n != tt.want || err != errWSNotFramed {
+			t.Errorf("%s: got %d, %v, want %d, %v", tt.name, n, err, tt.want, errWSNotFramed)
+		}
+	}
+	// the complete frame before the truncated one was still sent
+	if _, payload, err := readServerFrame(client); err != nil || !bytes.Equal(payload, small) {
+		t.Fatalf("frame before the truncated one: %q, %v", payload, err)
+	}
+
+	if err := c.CloseWrite(); err != nil {
+		t.Fatal(err)
+	}
+	if op, payload, err := readServerFrame(client); err != nil || op != wsOpClose || !bytes.Equal(payload, closeFrame(wsCloseNormal)) {
+		t.Fatalf("CloseWrite sent op %x %v, %v", op, payload, err)
+	}
+	if _, err := c.Write(frames); err != errWSClosed {
+		t.Fatalf("write after close: got %v, want %v", err, errWSClosed)
+	}
+	c.Close()
+	// Close does not send a second close frame
+	if _, _, err := readServerFrame(client); err != io.EOF {
+		t.Fatalf("after Close: got %v, want %v", err, io.EOF)
+	}
+}
+
+func TestWSCheckRequest(t *testing.T) {
+	// the sample handshake of RFC 6455 section 1.3
+	const key = "dGhlIHNhbXBsZSBub25jZQ=="
+	if got := wsAcceptKey(key); got != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+		t.Fatalf("wsAcceptKey = %s", got)
+	}
+
+	valid := func() *http.Request {
+		r, _ := http.NewRequest(http.MethodGet, "https://chat.example/ws", nil)
+		r.Header.Set("Connection", "keep-alive, Upgrade")
+		r.Header.Set("Upgrade", "websocket")
+		r.Header.Set("Sec-WebSocket-Version", "13")
+		r.Header.Set("Sec-WebSocket-Key", key)
+		return r
+	}
+	tests := []struct {
+		name   string
+		change func(r *http.Request)
+		want   error
+	}{
+		{"valid", func(*http.Request) {}, nil},
+		{"post", func(r *http.Request) { r.Method = http.MethodPost }, errWSBadRequest},
+		{"no upgrade", func(r *http.Request) { r.Header.Del("Upgrade") }, errWSBadRequest},
+		{"connection close", func(r *http.Request) { r.Header.Set("Connection", "close") }, errWSBadRequest},
+		{"old version", func(r *http.Request) { r.Header.Set("Sec-WebSocket-Version", "8") }, errWSBadRequest},
+		{"short key", func(r *http.Request) { r.Header.Set("Sec-WebSocket-Key", "c2hvcnQ=") }, errWSBadRequest},
+		{"key not base64", func(r *http.Request) { r.Header.Set("Sec-WebSocket-Key", "not a key") }, errWSBadRequest},
+	}
+	for _, tt := range tests {
+		r := valid()
+		tt.change(r)
+		got, err := wsCheckRequest(r)
+		if err != tt.want || err == nil && got != key {
+			t.Errorf("%s: got %q, %v, want %v", tt.name, got, err, tt.want)
+		}
+	}
+
+	r := valid()
+	r.Header.Add("Sec-WebSocket-Protocol", "chat, "+wsSubprotocol)
+	if !wsOffers(r, wsSubprotocol) {
+		t.Error("offered subprotocol not found")
+	}
+	if wsOffers(valid(), wsSubprotocol) {
+		t.Error("subprotocol found without being offered")
+	}
+
+	h := &wsHandler{origins: []string{"https://chat.example"}}
+	if !h.allowOrigin("https://CHAT.example") || h.allowOrigin("https://evil.example") {
+		t.Error("origin check")
+	}
+	if !(&wsHandler{}).allowOrigin("https://any.example") {
+		t.Error("no origins configured should allow any")
+	}
+}
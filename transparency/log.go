@@ -0,0 +1,250 @@
+package transparency
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/ed25519"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"os"
+	"sync"
+	"time"
+)
+
+const treeHeadContext = "e2eechat key transparency tree head v1"
+
+var (
+	// ErrBadTreeHead is returned when a tree head was not signed by the log.
+	ErrBadTreeHead = errors.New("transparency: bad tree head signature")
+
+	// ErrKeyMismatch is returned when the logged entry does not match the
+	// key a client was given.
+	ErrKeyMismatch = errors.New("transparency: key does not match the log")
+)
+
+// Entry is one publication of an identity key. Revocations are logged too,
+// so that the log shows the full history of a device.
+type Entry struct {
+	UserID      uint64    `json:"user_id"`
+	DeviceID    uint64    `json:"device_id"`
+	IdentityKey []byte    `json:"identity_key"`
+	Revoked     bool      `json:"revoked,omitempty"`
+	Timestamp   time.Time `json:"timestamp"`
+}
+
+// Leaf returns the canonical encoding of the entry that is hashed into the
+// tree.
+func (e *Entry) Leaf() []byte {
+	var b bytes.Buffer
+	binary.Write(&b, binary.BigEndian, e.UserID)
+	binary.Write(&b, binary.BigEndian, e.DeviceID)
+	binary.Write(&b, binary.BigEndian, uint16(len(e.IdentityKey)))
+	b.Write(e.IdentityKey)
+	if e.Revoked {
+		b.WriteByte(1)
+	} else {
+		b.WriteByte(0)
+	}
+	binary.Write(&b, binary.BigEndian, e.Timestamp.UnixNano())
+	return b.Bytes()
+}
+
+// TreeHead is a signed statement of the log's size and root.
+type TreeHead struct {
+	Size      uint64    `json:"tree_size"`
+	Root      []byte    `json:"root_hash"`
+	Timestamp time.Time `json:"timestamp"`
+	Signature []byte    `json:"signature"`
+}
+
+func (h *TreeHead) signingBytes() []byte {
+	var b bytes.Buffer
+	b.WriteString(treeHeadContext)
+	binary.Write(&b, binary.BigEndian, h.Size)
+	b.Write(h.Root)
+	binary.Write(&b, binary.BigEndian, h.Timestamp.UnixNano())
+	return b.Bytes()
+}
+
+// Verify checks the signature of the tree head.
+func (h *TreeHead) Verify(key ed25519.PublicKey) error {
+	if !ed25519.Verify(key, h.signingBytes(), h.Signature) {
+		return ErrBadTreeHead
+	}
+	return nil
+}
+
+// KeyProof proves that an entry is in the log at the given tree head.
+type KeyProof struct {
+	Entry    Entry    `json:"entry"`
+	Index    uint64   `json:"leaf_index"`
+	Path     [][]byte `json:"audit_path"`
+	TreeHead TreeHead `json:"tree_head"`
+}
+
+// Audit checks that the identity key a client received for a device is in
+// the log at a tree head the log signed. The proof alone cannot show that
+// no later entry of the device exists, the log serves the latest one but
+// only the device's owner, monitoring the entries of the log, can tell when
+// a key was published or revoked behind their back. Clients should also
+// check that the tree head is consistent with the last one they saw.
+func (p *KeyProof) Audit(logKey ed25519.PublicKey, userID, deviceID uint64, identityKey []byte) error {
+	if err := p.TreeHead.Verify(logKey); err != nil {
+		return err
+	}
+	e := p.Entry
+	if e.UserID != userID || e.DeviceID != deviceID || e.Revoked || !bytes.Equal(e.IdentityKey, identityKey) {
+		return ErrKeyMismatch
+	}
+	return VerifyInclusion(LeafHash(e.Leaf()), p.Index, p.TreeHead.Size, p.Path, p.TreeHead.Root)
+}
+
+type deviceRef struct {
+	userID   uint64
+	deviceID uint64
+}
+
+// Log is the append-only log, stored as one JSON entry per line.
+type Log struct {
+	key ed25519.PrivateKey
+
+	mu      sync.RWMutex
+	f       *os.File
+	entries []Entry
+	leaves  tree
+	latest  map[deviceRef]uint64
+}
+
+// OpenLog loads the log at path, creating it if needed. Tree heads are
+// signed with key.
+func OpenLog(path string, key ed25519.PrivateKey) (*Log, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, err
+	}
+	l := &Log{key: key, f: f, latest: make(map[deviceRef]uint64)}
+
+	s := bufio.NewScanner(f)
+	s.Buffer(make([]byte, 64*1024), 1024*1024)
+	for s.Scan() {
+		var e Entry
+		if err := json.Unmarshal(s.Bytes(), &e); err != nil {
+			f.Close()
+			return nil, err
+		}
+		l.add(e)
+	}
+	if err := s.Err(); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return l, nil
+}
+
+func (l *Log) add(e Entry) {
+	l.latest[deviceRef{e.UserID, e.DeviceID}] = uint64(len(l.entries))
+	l.entries = append(l.entries, e)
+	l.leaves.append(LeafHash(e.Leaf()))
+}
+
+// Append adds an entry and writes it to disk before returning.
+func (l *Log) Append(e Entry) (uint64, error) {
+	e.Timestamp = e.Timestamp.UTC()
+	b, err := json.Marshal(&e)
+	if err != nil {
+		return 0, err
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if _, err := l.f.Write(append(b, '\n')); err != nil {
+		return 0, err
+	}
+	if err := l.f.Sync(); err != nil {
+		return 0, err
+	}
+	l.add(e)
+	return uint64(len(l.entries) - 1), nil
+}
+
+// TreeHead returns a freshly signed head of the current tree.
+func (l *Log) TreeHead() *TreeHead {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.treeHead(l.leaves.size())
+}
+
+// treeHead signs the head of the first size leaves. The caller holds l.mu.
+func (l *Log) treeHead(size int) *TreeHead {
+	h := &TreeHead{
+		Size:      uint64(size),
+		Root:      l.leaves.root(size),
+		Timestamp: time.Now().UTC(),
+	}
+	h.Signature = ed25519.Sign(l.key, h.signingBytes())
+	return h
+}
+
+// Entries returns the entries in [start, end).
+func (l *Log) Entries(start, end uint64) ([]Entry, error) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	if start > end || end > uint64(len(l.entries)) {
+		return nil, ErrOutOfRange
+	}
+	return append([]Entry(nil), l.entries[start:end]...), nil
+}
+
+// Inclusion returns the audit path of the leaf at index in the tree of size
+// leaves.
+func (l *Log) Inclusion(index, size uint64) ([][]byte, error) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	if index >= size || size > uint64(l.leaves.size()) {
+		return nil, ErrOutOfRange
+	}
+	return l.leaves.inclusion(int(index), int(size)), nil
+}
+
+// Consistency returns the proof that the tree of size1 leaves is a prefix of
+// the tree of size2 leaves.
+func (l *Log) Consistency(size1, size2 uint64) ([][]byte, error) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	if size1 > size2 || size2 > uint64(l.leaves.size()) {
+		return nil, ErrOutOfRange
+	}
+	if size1 == 0 {
+		return nil, nil
+	}
+	return l.leaves.consistency(int(size1), int(size2)), nil
+}
+
+// KeyProof returns the latest entry of a device with its proof against the
+// current tree head.
+func (l *Log) KeyProof(userID, deviceID uint64) (*KeyProof, bool) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	i, ok := l.latest[deviceRef{userID, deviceID}]
+	if !ok {
+		return nil, false
+	}
+	size := l.leaves.size()
+	return &KeyProof{
+		Entry:    l.entries[i],
+		Index:    i,
+		Path:     l.leaves.inclusion(int(i), size),
+		TreeHead: *l.treeHead(size),
+	}, true
+}
+
+// Close closes the log file.
+func (l *Log) Close() error {
+	return l.f.Close()
+}
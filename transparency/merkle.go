@@ -0,0 +1,231 @@
+// Package transparency implements an append-only log of identity key
+// publications, hashed into a Merkle tree as in RFC 6962. srvhttps appends
+// to the log and signs its tree heads, clients use the proofs it serves to
+// check that the keys they were given are in the log and that the log never
+// rewrites its history.
+package transparency
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"math/bits"
+)
+
+// HashSize is the size of every hash in the tree.
+const HashSize = sha256.Size
+
+var (
+	// ErrInvalidProof is returned when a proof does not lead to the expected
+	// root.
+	ErrInvalidProof = errors.New("transparency: invalid proof")
+
+	// ErrOutOfRange is returned for indices and tree sizes the log cannot
+	// prove.
+	ErrOutOfRange = errors.New("transparency: index out of range")
+)
+
+// LeafHash returns the hash of a leaf with the given data.
+func LeafHash(data []byte) []byte {
+	h := sha256.New()
+	h.Write([]byte{0x00})
+	h.Write(data)
+	return h.Sum(nil)
+}
+
+func nodeHash(left, right []byte) []byte {
+	h := sha256.New()
+	h.Write([]byte{0x01})
+	h.Write(left)
+	h.Write(right)
+	return h.Sum(nil)
+}
+
+// emptyRoot is the root of a tree without leaves.
+func emptyRoot() []byte {
+	sum := sha256.Sum256(nil)
+	return sum[:]
+}
+
+// split returns the largest power of two smaller than n.
+func split(n int) int {
+	k := 1
+	for k<<1 < n {
+		k <<= 1
+	}
+	return k
+}
+
+// tree holds the leaf hashes of the log together with the hash of every
+// complete subtree, so that a root or a proof for any size the log has had
+// takes O(log n) hashes instead of rehashing the whole tree.
+type tree struct {
+	// nodes[h][i] is the hash of the 2^h leaves starting at leaf i<<h,
+	// nodes[0] are the leaf hashes.
+	nodes [][][]byte
+}
+
+func (t *tree) size() int {
+	if len(t.nodes) == 0 {
+		return 0
+	}
+	return len(t.nodes[0])
+}
+
+// append adds a leaf hash and the hashes of the subtrees it completes.
+func (t *tree) append(leafHash []byte) {
+	h := leafHash
+	for level := 0; ; level++ {
+		if level == len(t.nodes) {
+			t.nodes = append(t.nodes, nil)
+		}
+		t.nodes[level] = append(t.nodes[level], h)
+		i := len(t.nodes[level]) - 1
+		if i&1 == 0 {
+			return
+		}
+		h = nodeHash(t.nodes[level][i-1], h)
+	}
+}
+
+// hash returns the root of the n > 0 leaves starting at start. The RFC 6962
+// recursion only ever asks for complete subtrees aligned to their size, and
+// those are all stored.
+func (t *tree) hash(start, n int) []byte {
+	if n&(n-1) == 0 {
+		level := bits.TrailingZeros(uint(n))
+		return t.nodes[level][start>>level]
+	}
+	k := split(n)
+	return nodeHash(t.hash(start, k), t.hash(start+k, n-k))
+}
+
+// root returns the root of the tree of the first size leaves.
+func (t *tree) root(size int) []byte {
+	if size == 0 {
+		return emptyRoot()
+	}
+	return t.hash(0, size)
+}
+
+// inclusion returns the audit path of leaf m in the tree of the first size
+// leaves.
+func (t *tree) inclusion(m, size int) [][]byte {
+	return t.path(m, 0, size)
+}
+
+func (t *tree) path(m, start, n int) [][]byte {
+	if n <= 1 {
+		return nil
+	}
+	k := split(n)
+	if m < k {
+		return append(t.path(m, start, k), t.hash(start+k, n-k))
+	}
+	return append(t.path(m-k, start+k, n-k), t.hash(start, k))
+}
+
+// consistency returns the proof that the first m leaves are a prefix of the
+// first size leaves.
+func (t *tree) consistency(m, size int) [][]byte {
+	return t.subproof(m, 0, size, true)
+}
+
+func (t *tree) subproof(m, start, n int, complete bool) [][]byte {
+	if m == n {
+		if complete {
+			return nil
+		}
+		return [][]byte{t.hash(start, n)}
+	}
+	k := split(n)
+	if m <= k {
+		return append(t.subproof(m, start, k, complete), t.hash(start+k, n-k))
+	}
+	return append(t.subproof(m-k, start+k, n-k, false), t.hash(start, k))
+}
+
+// VerifyInclusion checks that leafHash is the leaf at index in the tree of
+// size leaves with the given root.
+func VerifyInclusion(leafHash []byte, index, size uint64, proof [][]byte, root []byte) error {
+	if index >= size {
+		return ErrOutOfRange
+	}
+	fn, sn := index, size-1
+	r := leafHash
+	for _, p := range proof {
+		if sn == 0 {
+			return ErrInvalidProof
+		}
+		if fn&1 == 1 || fn == sn {
+			r = nodeHash(p, r)
+			for fn&1 == 0 && fn != 0 {
+				fn >>= 1
+				sn >>= 1
+			}
+		} else {
+			r = nodeHash(r, p)
+		}
+		fn >>= 1
+		sn >>= 1
+	}
+	if sn != 0 || !bytes.Equal(r, root) {
+		return ErrInvalidProof
+	}
+	return nil
+}
+
+// VerifyConsistency checks that the tree of size1 leaves with root1 is a
+// prefix of the tree of size2 leaves with root2.
+func VerifyConsistency(size1, size2 uint64, root1, root2 []byte, proof [][]byte) error {
+	switch {
+	case size1 > size2:
+		return ErrOutOfRange
+	case size1 == size2:
+		if len(proof) != 0 || !bytes.Equal(root1, root2) {
+			return ErrInvalidProof
+		}
+		return nil
+	case size1 == 0:
+		// the empty tree is a prefix of every tree
+		if len(proof) != 0 {
+			return ErrInvalidProof
+		}
+		return nil
+	}
+
+	if size1&(size1-1) == 0 {
+		proof = append([][]byte{root1}, proof...)
+	}
+	if len(proof) == 0 {
+		return ErrInvalidProof
+	}
+
+	fn, sn := size1-1, size2-1
+	for fn&1 == 1 {
+		fn >>= 1
+		sn >>= 1
+	}
+	fr, sr := proof[0], proof[0]
+	for _, c := range proof[1:] {
+		if sn == 0 {
+			return ErrInvalidProof
+		}
+		if fn&1 == 1 || fn == sn {
+			fr = nodeHash(c, fr)
+			sr = nodeHash(c, sr)
+			for fn&1 == 0 && fn != 0 {
+				fn >>= 1
+				sn >>= 1
+			}
+		} else {
+			sr = nodeHash(sr, c)
+		}
+		fn >>= 1
+		sn >>= 1
+	}
+	if sn != 0 || !bytes.Equal(fr, root1) || !bytes.Equal(sr, root2) {
+		return ErrInvalidProof
+	}
+	return nil
+}
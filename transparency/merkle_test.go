@@ -0,0 +1,187 @@
+package transparency
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/binary"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// refRoot is the RFC 6962 definition of the root, recomputed from the
+// leaves.
+func refRoot(leaves [][]byte) []byte {
+	switch len(leaves) {
+	case 0:
+		return emptyRoot()
+	case 1:
+		return leaves[0]
+	}
+	k := split(len(leaves))
+	return nodeHash(refRoot(leaves[:k]), refRoot(leaves[k:]))
+}
+
+func leafHashes(n int) [][]byte {
+	var leaves [][]byte
+	for i := 0; i < n; i++ {
+		var b [8]byte
+		binary.BigEndian.PutUint64(b[:], uint64(i))
+		leaves = append(leaves, LeafHash(b[:]))
+	}
+	return leaves
+}
+
+func TestTree(t *testing.T) {
+	const n = 70
+	leaves := leafHashes(n)
+	var tr tree
+	for _, l := range leaves {
+		tr.append(l)
+	}
+
+	for size := 0; size <= n; size++ {
+		root := tr.root(size)
+		if !bytes.Equal(root, refRoot(leaves[:size])) {
+			t.Fatalf("root of %d leaves differs from RFC 6962", size)
+		}
+		for m := 0; m < size; m++ {
+			if err := VerifyInclusion(leaves[m], uint64(m), uint64(size), tr.inclusion(m, size), root); err != nil {
+				t.Fatalf("inclusion of leaf %d in %d leaves: %v", m, size, err)
+			}
+		}
+		for m := 1; m <= size; m++ {
+			proof := tr.consistency(m, size)
+			if err := VerifyConsistency(uint64(m), uint64(size), tr.root(m), root, proof); err != nil {
+				t.Fatalf("consistency of %d with %d leaves: %v", m, size, err)
+			}
+		}
+	}
+}
+
+func TestVerifyRejects(t *testing.T) {
+	const size = 11
+	leaves := leafHashes(size)
+	var tr tree
+	for _, l := range leaves {
+		tr.append(l)
+	}
+	root := tr.root(size)
+	path := tr.inclusion(5, size)
+	cons := tr.consistency(6, size)
+
+	other := append([][]byte(nil), path...)
+	other[1] = leaves[0]
+	tests := []struct {
+		name string
+		err  error
+		want error
+	}{
+		{"other leaf", VerifyInclusion(leaves[4], 5, size, path, root), ErrInvalidProof},
+		{"other index", VerifyInclusion(leaves[5], 6, size, path, root), ErrInvalidProof},
+		{"index out of range", VerifyInclusion(leaves[5], size, size, path, root), ErrOutOfRange},
+		{"changed path", VerifyInclusion(leaves[5], 5, size, other, root), ErrInvalidProof},
+		{"short path", VerifyInclusion(leaves[5], 5, size, path[1:], root), ErrInvalidProof},
+		{"other root", VerifyInclusion(leaves[5], 5, size, path, tr.root(size-1)), ErrInvalidProof},
+		{"rewritten history", VerifyConsistency(6, size, tr.root(5), root, cons), ErrInvalidProof},
+		{"other size", VerifyConsistency(7, size, tr.root(6), root, cons), ErrInvalidProof},
+		{"shrinking tree", VerifyConsistency(size, 6, root, tr.root(6), cons), ErrOutOfRange},
+		{"same size", VerifyConsistency(6, 6, tr.root(6), root, nil), ErrInvalidProof},
+	}
+	for _, tt := range tests {
+		if tt.err != tt.want {
+			t.Errorf("%s: got %v, want %v", tt.name, tt.err, tt.want)
+		}
+	}
+}
+
+func TestLog(t *testing.T) {
+	pub, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "log.jsonl")
+	l, err := OpenLog(path, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	old, cur := []byte("old identity key"), []byte("new identity key")
+	for _, e := range []Entry{
+		{UserID: 1, DeviceID: 1, IdentityKey: old},
+		{UserID: 2, DeviceID: 1, IdentityKey: []byte("bob")},
+		{UserID: 1, DeviceID: 1, IdentityKey: old, Revoked: true},
+		{UserID: 1, DeviceID: 1, IdentityKey: cur},
+	} {
+		e.Timestamp = time.Now()
+		if _, err := l.Append(e); err != nil {
+			t.Fatal(err)
+		}
+	}
+	first := l.TreeHead()
+
+	p, ok := l.KeyProof(1, 1)
+	if !ok {
+		t.Fatal("no key proof")
+	}
+	if p.Index != 3 {
+		t.Fatalf("proof of entry %d, want the latest, 3", p.Index)
+	}
+	if err := p.Audit(pub, 1, 1, cur); err != nil {
+		t.Fatalf("Audit: %v", err)
+	}
+	if err := p.Audit(pub, 1, 1, old); err != ErrKeyMismatch {
+		t.Fatalf("Audit of the old key: got %v, want %v", err, ErrKeyMismatch)
+	}
+	otherKey, _, _ := ed25519.GenerateKey(rand.Reader)
+	if err := p.Audit(otherKey, 1, 1, cur); err != ErrBadTreeHead {
+		t.Fatalf("Audit with another log key: got %v, want %v", err, ErrBadTreeHead)
+	}
+	if _, ok := l.KeyProof(3, 1); ok {
+		t.Fatal("key proof for a device never logged")
+	}
+	if err := l.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// the reopened log has the same tree and keeps growing consistently
+	l, err = OpenLog(path, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	if h := l.TreeHead(); h.Size != first.Size || !bytes.Equal(h.Root, first.Root) {
+		t.Fatalf("reopened log has size %d, want %d with the same root", h.Size, first.Size)
+	}
+	if _, err := l.Append(Entry{UserID: 3, DeviceID: 1, IdentityKey: []byte("carol")}); err != nil {
+		t.Fatal(err)
+	}
+	h := l.TreeHead()
+	proof, err := l.Consistency(first.Size, h.Size)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := VerifyConsistency(first.Size, h.Size, first.Root, h.Root, proof); err != nil {
+		t.Fatalf("consistency with the earlier head: %v", err)
+	}
+	if _, err := l.Inclusion(0, h.Size+1); err != ErrOutOfRange {
+		t.Fatalf("inclusion beyond the log: got %v, want %v", err, ErrOutOfRange)
+	}
+}
+
+func BenchmarkKeyProof(b *testing.B) {
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		b.Fatal(err)
+	}
+	l := &Log{key: key, latest: make(map[deviceRef]uint64)}
+	for i := 0; i < 1<<16; i++ {
+		l.add(Entry{UserID: uint64(i), DeviceID: 1, IdentityKey: make([]byte, ed25519.PublicKeySize)})
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		l.KeyProof(uint64(i%(1<<16)), 1)
+	}
+}